@@ -0,0 +1,334 @@
+package integratetest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseCaptureAnalysisJob struct {
+	ID            string  `json:"id"`
+	Status        string  `json:"status"`
+	PromptVersion int     `json:"prompt_version"`
+	Attempts      int     `json:"attempts"`
+	MaxAttempts   int     `json:"max_attempts"`
+	NextAttemptAt string  `json:"next_attempt_at"`
+	ErrorMessage  *string `json:"error_message"`
+	StartedAt     *string `json:"started_at"`
+	FinishedAt    *string `json:"finished_at"`
+	CreatedAt     string  `json:"created_at"`
+}
+
+type responseCaptureAnalysis struct {
+	CaptureID            string                      `json:"capture_id"`
+	CurrentPromptVersion int                         `json:"current_prompt_version"`
+	Job                  *responseCaptureAnalysisJob `json:"job"`
+	Analysis             *struct {
+		JobID         string   `json:"job_id"`
+		PromptVersion int      `json:"prompt_version"`
+		Category      string   `json:"category"`
+		Summary       string   `json:"summary"`
+		Suggestions   []string `json:"suggestions"`
+		AnalyzedAt    *string  `json:"analyzed_at"`
+	} `json:"analysis"`
+}
+
+// GET /captures/:id/analysis を、最新のジョブが終了するまで繰り返す
+func waitCaptureAnalysis(t *testing.T, mux *http.ServeMux, captureID string) responseCaptureAnalysis {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		req := httptest.NewRequest(http.MethodGet, "/captures/"+captureID+"/analysis", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if !assert.Equal(t, http.StatusOK, rec.Code) {
+			t.Fatalf("unexpected response: %s", rec.Body.String())
+		}
+		typedResponse := responseCaptureAnalysis{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if typedResponse.Job != nil && (typedResponse.Job.Status == "succeeded" || typedResponse.Job.Status == "failed") {
+			return typedResponse
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("analysis did not finish: %s", rec.Body.String())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestCaptureAnalysisIntegrate(t *testing.T) {
+	t.Run("POST /capture/screenshot は解析ジョブを作成し、GET /captures/:id/analysis で結果を取得できる", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		req, err := NewMultipartRequest("/capture/screenshot", map[string]string{"captured_at": "2025-11-18T09:00:00+09:00"}, pngImage)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		rec := httptest.NewRecorder()
+
+		// Act
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		uploaded := struct {
+			Capture     responseCaptureUnit         `json:"capture"`
+			AnalysisJob *responseCaptureAnalysisJob `json:"analysis_job"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &uploaded); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if !assert.NotNil(t, uploaded.AnalysisJob) {
+			return
+		}
+		assert.Equal(t, "queued", uploaded.AnalysisJob.Status)
+		assert.Equal(t, 0, uploaded.AnalysisJob.Attempts)
+		assert.Equal(t, 3, uploaded.AnalysisJob.MaxAttempts)
+
+		analysis := waitCaptureAnalysis(t, mux, uploaded.Capture.ID)
+		assert.Equal(t, uploaded.Capture.ID, analysis.CaptureID)
+		assert.Equal(t, uploaded.AnalysisJob.ID, analysis.Job.ID)
+		assert.Equal(t, "succeeded", analysis.Job.Status)
+		assert.Equal(t, 1, analysis.Job.Attempts)
+		assert.NotNil(t, analysis.Job.StartedAt)
+		assert.NotNil(t, analysis.Job.FinishedAt)
+		if assert.NotNil(t, analysis.Analysis) {
+			assert.Equal(t, uploaded.AnalysisJob.ID, analysis.Analysis.JobID)
+			assert.Equal(t, analysis.CurrentPromptVersion, analysis.Analysis.PromptVersion)
+			assert.Equal(t, "other", analysis.Analysis.Category)
+			assert.True(t, strings.HasPrefix(analysis.Analysis.Summary, "2025-11-18 09:00"), analysis.Analysis.Summary)
+			assert.NotNil(t, analysis.Analysis.Suggestions)
+		}
+	})
+
+	t.Run("LLMにキャプチャの画像を送り、応答のカテゴリ・要約・提案を解析結果として記録する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		requests := make(chan ollamaChatRequest, 1)
+		stub := newOllamaStub(t, []string{`{"category": "learning", "summary": "Goのドキュメントを読んでいる", `, `"suggestions": ["メモを取る"]}`}, requests)
+		cfg := GetTestConfig(t)
+		cfg.LLMProvider = llm.ProviderOllama
+		cfg.LLMEndpoint = stub.URL
+		cfg.LLMModel = "llava"
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		req, err := NewMultipartRequest("/capture/screenshot", map[string]string{"captured_at": "2025-11-18T09:00:00+09:00"}, pngImage)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		rec := httptest.NewRecorder()
+
+		// Act
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		uploaded := struct {
+			Capture responseCaptureUnit `json:"capture"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &uploaded); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		analysis := waitCaptureAnalysis(t, mux, uploaded.Capture.ID)
+		assert.Equal(t, "succeeded", analysis.Job.Status)
+		if assert.NotNil(t, analysis.Analysis) {
+			assert.Equal(t, 1, analysis.Analysis.PromptVersion)
+			assert.Equal(t, "learning", analysis.Analysis.Category)
+			assert.Equal(t, "Goのドキュメントを読んでいる", analysis.Analysis.Summary)
+			assert.Equal(t, []string{"メモを取る"}, analysis.Analysis.Suggestions)
+		}
+		request := <-requests
+		assert.Equal(t, "llava", request.Model)
+		if assert.Len(t, request.Messages, 2) {
+			assert.Equal(t, "system", request.Messages[0].Role)
+			assert.Contains(t, request.Messages[0].Content, "撮影日時: 2025-11-18 09:00（64x48）")
+			assert.Equal(t, "user", request.Messages[1].Role)
+			if assert.Len(t, request.Messages[1].Images, 1) {
+				data, err := base64.StdEncoding.DecodeString(request.Messages[1].Images[0])
+				assert.NoError(t, err)
+				config, format, err := image.DecodeConfig(bytes.NewReader(data))
+				assert.NoError(t, err)
+				assert.Equal(t, "png", format)
+				assert.Equal(t, []int{64, 48}, []int{config.Width, config.Height})
+			}
+		}
+	})
+
+	t.Run("POST /captures/:id/analysis は再解析のジョブを作成し、前回の結果は新しい結果に置き換わる", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		req, err := NewMultipartRequest("/capture/screenshot", nil, pngImage)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		uploaded := responseCapture{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &uploaded); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		first := waitCaptureAnalysis(t, mux, uploaded.Capture.ID)
+
+		// Act
+		req = httptest.NewRequest(http.MethodPost, "/captures/"+uploaded.Capture.ID+"/analysis", nil)
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		enqueued := struct {
+			Job responseCaptureAnalysisJob `json:"job"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &enqueued); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.NotEqual(t, first.Job.ID, enqueued.Job.ID)
+		assert.Equal(t, "queued", enqueued.Job.Status)
+		second := waitCaptureAnalysis(t, mux, uploaded.Capture.ID)
+		assert.Equal(t, enqueued.Job.ID, second.Job.ID)
+		if assert.NotNil(t, second.Analysis) {
+			assert.Equal(t, enqueued.Job.ID, second.Analysis.JobID)
+		}
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM capture_analysis_jobs WHERE capture_id = ?", uploaded.Capture.ID).Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("POST /captures/:id/analysis は実行待ちのジョブがある場合 409 Conflict を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		req, err := NewMultipartRequest("/capture/screenshot", nil, pngImage)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		uploaded := responseCapture{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &uploaded); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		waitCaptureAnalysis(t, mux, uploaded.Capture.ID)
+		// 再試行待ちのジョブ
+		future := time.Now().Add(time.Hour).UTC()
+		if _, err := db.Exec(
+			"INSERT INTO capture_analysis_jobs (id, capture_id, status, prompt_version, attempts, max_attempts, next_attempt_at, error_message, created_at, updated_at) VALUES ('job-retry', ?, 'queued', 1, 1, 3, ?, 'timeout', ?, ?)",
+			uploaded.Capture.ID, future, future, future,
+		); err != nil {
+			t.Fatalf("failed to insert analysis job: %v", err)
+		}
+
+		// Act
+		req = httptest.NewRequest(http.MethodPost, "/captures/"+uploaded.Capture.ID+"/analysis", nil)
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		typedResponse := responseCodedError{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, "INVALID_STATE", typedResponse.Code)
+		// 実行待ちのジョブの状態と、前回成功した結果を返す
+		req = httptest.NewRequest(http.MethodGet, "/captures/"+uploaded.Capture.ID+"/analysis", nil)
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		analysis := responseCaptureAnalysis{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &analysis); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if assert.NotNil(t, analysis.Job) {
+			assert.Equal(t, "job-retry", analysis.Job.ID)
+			assert.Equal(t, "queued", analysis.Job.Status)
+			if assert.NotNil(t, analysis.Job.ErrorMessage) {
+				assert.Equal(t, "timeout", *analysis.Job.ErrorMessage)
+			}
+		}
+		assert.NotNil(t, analysis.Analysis)
+	})
+
+	t.Run("GET/POST /captures/:id/analysis はキャプチャが存在しない場合 404 Not Found を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			req := httptest.NewRequest(method, "/captures/unknown/analysis", nil)
+			rec := httptest.NewRecorder()
+
+			// Act
+			mux.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, http.StatusNotFound, rec.Code, method)
+			typedResponse := responseCodedError{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			assert.Equal(t, "NOT_FOUND", typedResponse.Code, method)
+		}
+	})
+}
@@ -0,0 +1,239 @@
+package integratetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/stretchr/testify/assert"
+)
+
+// mode=scheduledでスクリーンショットをアップロードし、レスポンスを返す
+func postScheduledScreenshot(t *testing.T, mux *http.ServeMux, capturedAt string, image []byte) responseCapture {
+	t.Helper()
+	req, err := NewMultipartRequest("/capture/screenshot", map[string]string{"mode": "scheduled", "captured_at": capturedAt}, image)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if !assert.Equal(t, http.StatusOK, rec.Code) {
+		t.Fatalf("unexpected response: %s", rec.Body.String())
+	}
+	typedResponse := responseCapture{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return typedResponse
+}
+
+func TestCaptureDedupIntegrate(t *testing.T) {
+	t.Run("POST /capture/screenshot は dedup_mode=mark の場合、直前と重複する定期キャプチャを保存し duplicate_of を記録する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		jpegImage, err := NewJPEG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create jpeg: %v", err)
+		}
+
+		// Act
+		first := postScheduledScreenshot(t, mux, "2025-11-17T09:00:00+09:00", pngImage)
+		second := postScheduledScreenshot(t, mux, "2025-11-17T09:05:00+09:00", jpegImage)
+
+		// Assert
+		assert.Nil(t, first.Capture.DuplicateOf)
+		assert.False(t, second.Dropped)
+		assert.NotEqual(t, first.Capture.ID, second.Capture.ID)
+		if assert.NotNil(t, second.Capture.DuplicateOf) {
+			assert.Equal(t, first.Capture.ID, *second.Capture.DuplicateOf)
+		}
+		// 重複は解析しない
+		assert.NotNil(t, first.AnalysisJob)
+		assert.Nil(t, second.AnalysisJob)
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM captures").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("POST /capture/screenshot は dedup_mode=drop の場合、重複する定期キャプチャを保存せず重複元の duplicate_count を増やす", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		if _, err := db.Exec("UPDATE settings SET dedup_mode = 'drop' WHERE id = 1"); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+
+		// Act
+		first := postScheduledScreenshot(t, mux, "2025-11-17T09:00:00+09:00", pngImage)
+		second := postScheduledScreenshot(t, mux, "2025-11-17T09:05:00+09:00", pngImage)
+		third := postScheduledScreenshot(t, mux, "2025-11-17T09:10:00+09:00", pngImage)
+
+		// Assert
+		assert.False(t, first.Dropped)
+		assert.True(t, second.Dropped)
+		assert.True(t, third.Dropped)
+		assert.Nil(t, third.AnalysisJob)
+		assert.Equal(t, first.Capture.ID, third.Capture.ID)
+		assert.Equal(t, 2, third.Capture.DuplicateCount)
+		if assert.NotNil(t, third.Capture.LastDuplicateAt) {
+			assert.Equal(t, "2025-11-17T09:10:00+09:00", *third.Capture.LastDuplicateAt)
+		}
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM captures").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		// 捨てた重複も要求の完了として記録される
+		err = db.QueryRow("SELECT COUNT(*) FROM capture_events WHERE state = 'completed' AND capture_id = ?", first.Capture.ID).Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+	})
+
+	t.Run("POST /capture/screenshot は手動キャプチャの重複を判定しない", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		if _, err := db.Exec("UPDATE settings SET dedup_mode = 'drop' WHERE id = 1"); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		postScheduledScreenshot(t, mux, "2025-11-17T09:00:00+09:00", pngImage)
+		req, err := NewMultipartRequest("/capture/screenshot", map[string]string{"mode": "manual", "captured_at": "2025-11-17T09:05:00+09:00"}, pngImage)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		rec := httptest.NewRecorder()
+
+		// Act
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		typedResponse := responseCapture{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.False(t, typedResponse.Dropped)
+		assert.Nil(t, typedResponse.Capture.DuplicateOf)
+	})
+
+	t.Run("GET /captures/idle-periods は重複が続いた期間を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		first := postScheduledScreenshot(t, mux, "2025-11-17T09:00:00+09:00", pngImage)
+		postScheduledScreenshot(t, mux, "2025-11-17T09:10:00+09:00", pngImage)
+		postScheduledScreenshot(t, mux, "2025-11-17T09:20:00+09:00", pngImage)
+		req := httptest.NewRequest(http.MethodGet, "/captures/idle-periods?from=2025-11-17T00:00:00%2B09:00&to=2025-11-18T00:00:00%2B09:00&min_duration_min=15", nil)
+		rec := httptest.NewRecorder()
+
+		// Act
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		expected, _ := json.Marshal(map[string]interface{}{
+			"idle_periods": []map[string]interface{}{
+				{
+					"start":         "2025-11-17T09:00:00+09:00",
+					"end":           "2025-11-17T09:20:00+09:00",
+					"duration_sec":  1200,
+					"capture_id":    first.Capture.ID,
+					"capture_count": 3,
+				},
+			},
+		})
+		response, err := GetResponseBodyJson(rec)
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(expected), response)
+
+		// min_duration_min より短い期間は返さない
+		req = httptest.NewRequest(http.MethodGet, "/captures/idle-periods?from=2025-11-17T00:00:00%2B09:00&to=2025-11-18T00:00:00%2B09:00&min_duration_min=30", nil)
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		response, err = GetResponseBodyJson(rec)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"idle_periods": []}`, response)
+	})
+
+	t.Run("GET /captures/idle-periods はクエリが不正な場合 400 Bad Request を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		queries := []string{
+			"min_duration_min=0",
+			"min_duration_min=1441",
+			"min_duration_min=abc",
+			"from=2025-11-17",
+			"to=yesterday",
+			"from=2025-11-18T00:00:00Z&to=2025-11-17T00:00:00Z",
+		}
+
+		for _, query := range queries {
+			req := httptest.NewRequest(http.MethodGet, "/captures/idle-periods?"+query, nil)
+			rec := httptest.NewRecorder()
+
+			// Act
+			mux.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+			typedResponse := responseCodedError{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			assert.Equal(t, "INVALID_REQUEST", typedResponse.Code, query)
+		}
+	})
+}
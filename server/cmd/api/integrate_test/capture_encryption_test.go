@@ -0,0 +1,62 @@
+package integratetest
+
+import (
+	"bytes"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureEncryptionIntegrate(t *testing.T) {
+	t.Run("CAPTURE_ENCRYPTION_KEY_PATH を設定すると画像とサムネイルを暗号化して保存し、配信・解析時は復号する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		cfg.CaptureEncryptionKeyPath = filepath.Join(t.TempDir(), "capture.key")
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		pngImage, err := NewPNG(640, 360)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+
+		// Act
+		captured := postScreenshot(t, mux, map[string]string{}, pngImage)
+
+		// Assert
+		// 鍵ファイルは所有者のみ読み書きできる
+		keyInfo, err := os.Stat(cfg.CaptureEncryptionKeyPath)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), keyInfo.Mode().Perm())
+		// 保存されたファイルは画像として読めない
+		for _, relPath := range []string{captured.Path, *captured.ThumbPath} {
+			raw, err := os.ReadFile(filepath.Join(cfg.CaptureStoragePath, relPath))
+			assert.NoError(t, err)
+			assert.True(t, capture.IsEncrypted(raw), relPath)
+			_, _, err = image.DecodeConfig(bytes.NewReader(raw))
+			assert.Error(t, err, relPath)
+		}
+		// サムネイルは復号して配信する
+		req := httptest.NewRequest(http.MethodGet, "/captures/"+captured.ID+"/thumbnail", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		config, format, err := image.DecodeConfig(bytes.NewReader(rec.Body.Bytes()))
+		assert.NoError(t, err)
+		assert.Equal(t, "jpeg", format)
+		assert.Equal(t, 320, config.Width)
+		// 解析は復号した画像で行う
+		analysis := waitCaptureAnalysis(t, mux, captured.ID)
+		assert.Equal(t, "succeeded", analysis.Job.Status)
+	})
+}
@@ -0,0 +1,319 @@
+package integratetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseCaptureEventUnit struct {
+	ID           string  `json:"id"`
+	RequestID    string  `json:"request_id"`
+	State        string  `json:"state"`
+	Mode         string  `json:"mode"`
+	ScheduleID   *string `json:"schedule_id"`
+	CaptureID    *string `json:"capture_id"`
+	ErrorCode    *string `json:"error_code"`
+	ErrorMessage *string `json:"error_message"`
+	OccurredAt   string  `json:"occurred_at"`
+}
+
+type responseCaptureEvents struct {
+	Events []responseCaptureEventUnit `json:"events"`
+	Limit  int                        `json:"limit"`
+	Offset int                        `json:"offset"`
+}
+
+// targetをGETし、ステータスコードとキャプチャイベント一覧を返す
+func getCaptureEvents(t *testing.T, mux http.Handler, target string) (int, responseCaptureEvents) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	typedResponse := responseCaptureEvents{}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+	}
+	return rec.Code, typedResponse
+}
+
+// eventsの状態を古い順に並べて返す
+func captureEventStates(events []responseCaptureEventUnit) []string {
+	states := []string{}
+	for i := len(events) - 1; i >= 0; i-- {
+		states = append(states, events[i].State)
+	}
+	return states
+}
+
+func TestCaptureEventsIntegrate(t *testing.T) {
+	t.Run("POST /capture/events は状態機械に従って遷移を記録し、不正な遷移は 409 Conflict を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		status, body := postJSON(t, mux, "/capture/events", `{"state": "requested"}`)
+		if status != http.StatusOK {
+			t.Fatalf("failed to post capture event: %d %s", status, body)
+		}
+		requested := struct {
+			Event responseCaptureEventUnit `json:"event"`
+		}{}
+		if err := json.Unmarshal(body, &requested); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		requestID := requested.Event.RequestID
+		assert.NotEmpty(t, requestID)
+		assert.Equal(t, "manual", requested.Event.Mode)
+
+		type testCase struct {
+			body           string
+			expectedStatus int
+			expectedCode   string
+		}
+		testCases := []testCase{
+			// completed は POST /capture/screenshot でのみ記録する
+			{body: `{"request_id": "` + requestID + `", "state": "completed"}`, expectedStatus: http.StatusBadRequest, expectedCode: "INVALID_REQUEST"},
+			{body: `{"request_id": "` + requestID + `", "state": "failed", "error_code": "CAPTURE_ERROR"}`, expectedStatus: http.StatusConflict, expectedCode: "INVALID_STATE"},
+			{body: `{"request_id": "` + requestID + `", "state": "requested"}`, expectedStatus: http.StatusConflict, expectedCode: "INVALID_STATE"},
+			{body: `{"request_id": "unknown", "state": "granted"}`, expectedStatus: http.StatusNotFound, expectedCode: "NOT_FOUND"},
+			{body: `{"request_id": "` + requestID + `", "state": "granted"}`, expectedStatus: http.StatusOK},
+			// denied・failed は error_code が必須
+			{body: `{"request_id": "` + requestID + `", "state": "failed"}`, expectedStatus: http.StatusBadRequest, expectedCode: "INVALID_REQUEST"},
+			{body: `{"request_id": "` + requestID + `", "state": "failed", "error_code": "CAPTURE_ERROR", "error_message": "display disconnected"}`, expectedStatus: http.StatusOK},
+			{body: `{"request_id": "` + requestID + `", "state": "granted"}`, expectedStatus: http.StatusConflict, expectedCode: "INVALID_STATE"},
+		}
+
+		for i, testCase := range testCases {
+			t.Logf("request %d: %s", i, testCase.body)
+			// Act
+			status, body := postJSON(t, mux, "/capture/events", testCase.body)
+
+			// Assert
+			assert.Equal(t, testCase.expectedStatus, status)
+			if testCase.expectedStatus != http.StatusOK {
+				typedResponse := responseCodedError{}
+				if err := json.Unmarshal(body, &typedResponse); err != nil {
+					t.Fatalf("failed to unmarshal response: %v", err)
+				}
+				assert.Equal(t, testCase.expectedCode, typedResponse.Code)
+			}
+		}
+		status, events := getCaptureEvents(t, mux, "/capture/events")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, []string{"requested", "granted", "failed"}, captureEventStates(events.Events))
+		failed := events.Events[0]
+		if assert.NotNil(t, failed.ErrorCode) && assert.NotNil(t, failed.ErrorMessage) {
+			assert.Equal(t, "CAPTURE_ERROR", *failed.ErrorCode)
+			assert.Equal(t, "display disconnected", *failed.ErrorMessage)
+		}
+	})
+
+	t.Run("POST /capture/events はリクエストが不正な場合 400 Bad Request を返す", func(t *testing.T) {
+		// Arrange
+		// リクエストパターン:
+		// - JSON でない
+		// - state が欠如
+		// - state が不正
+		// - mode が不正
+		// - request_id が欠如（requested 以外）
+		// - requested に error_code を指定
+		requests := []string{
+			`state`,
+			`{}`,
+			`{"state": "unknown"}`,
+			`{"state": "requested", "mode": "auto"}`,
+			`{"state": "granted"}`,
+			`{"state": "requested", "error_code": "CAPTURE_ERROR"}`,
+		}
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+
+		for i, request := range requests {
+			t.Logf("request %d: %s", i, request)
+			// Act
+			status, body := postJSON(t, mux, "/capture/events", request)
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, status)
+			typedResponse := responseCodedError{}
+			if err := json.Unmarshal(body, &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			assert.Equal(t, "INVALID_REQUEST", typedResponse.Code)
+		}
+	})
+
+	t.Run("POST /capture/screenshot はキャプチャ要求の実行成功を記録する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(8, 8)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		scheduleID := "schedule-0"
+		if err := InsertCaptureEvents(db, []datamodel.CaptureEvent{
+			{ID: "event-0", RequestID: "request-0", State: "requested", Mode: "scheduled", ScheduleID: &scheduleID, OccurredAt: time.Now().Add(-time.Minute)},
+		}); err != nil {
+			t.Fatalf("failed to insert capture events: %v", err)
+		}
+
+		// Act
+		// 権限確認の報告を省略しても granted を補う
+		scheduled := postScreenshot(t, mux, map[string]string{"mode": "scheduled", "request_id": "request-0"}, pngImage)
+		// 要求なしのキャプチャは要求の受付から記録する
+		manual := postScreenshot(t, mux, map[string]string{"mode": "manual"}, pngImage)
+		// 完了済みの要求には記録できず、キャプチャも保存しない
+		req, err := NewMultipartRequest("/capture/screenshot", map[string]string{"mode": "scheduled", "request_id": "request-0"}, pngImage)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var captureCount int
+		if err := db.QueryRow("SELECT COUNT(*) FROM captures").Scan(&captureCount); err != nil {
+			t.Fatalf("failed to count captures: %v", err)
+		}
+		assert.Equal(t, 2, captureCount)
+
+		status, events := getCaptureEvents(t, mux, "/capture/events?outcome=completed")
+		assert.Equal(t, http.StatusOK, status)
+		if assert.Len(t, events.Events, 2) {
+			assert.Equal(t, "manual", events.Events[0].Mode)
+			assert.Nil(t, events.Events[0].ScheduleID)
+			if assert.NotNil(t, events.Events[0].CaptureID) {
+				assert.Equal(t, manual.ID, *events.Events[0].CaptureID)
+			}
+			assert.Equal(t, "request-0", events.Events[1].RequestID)
+			assert.Equal(t, "scheduled", events.Events[1].Mode)
+			if assert.NotNil(t, events.Events[1].ScheduleID) {
+				assert.Equal(t, scheduleID, *events.Events[1].ScheduleID)
+			}
+			if assert.NotNil(t, events.Events[1].CaptureID) {
+				assert.Equal(t, scheduled.ID, *events.Events[1].CaptureID)
+			}
+		}
+		_, events = getCaptureEvents(t, mux, "/capture/events")
+		assert.Equal(t, []string{"requested", "granted", "completed", "requested", "granted", "completed"}, captureEventStates(events.Events))
+	})
+
+	t.Run("GET /capture/events は期間・結果で絞り込み、新しい順にページングする", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		timezone := GetJSTTimezone()
+		base := time.Date(2025, 11, 6, 10, 0, 0, 0, timezone)
+		errorCode := "PERMISSION_DENIED"
+		events := []datamodel.CaptureEvent{}
+		for i := 0; i < 4; i++ {
+			requestID := fmt.Sprintf("request-%d", i)
+			occurredAt := base.Add(time.Duration(i) * time.Hour)
+			events = append(events,
+				datamodel.CaptureEvent{ID: requestID + "-requested", RequestID: requestID, State: "requested", Mode: "manual", OccurredAt: occurredAt},
+			)
+			if i%2 == 0 {
+				events = append(events, datamodel.CaptureEvent{ID: requestID + "-denied", RequestID: requestID, State: "denied", Mode: "manual", ErrorCode: &errorCode, OccurredAt: occurredAt.Add(time.Second)})
+			} else {
+				events = append(events, datamodel.CaptureEvent{ID: requestID + "-granted", RequestID: requestID, State: "granted", Mode: "manual", OccurredAt: occurredAt.Add(time.Second)})
+			}
+		}
+		if err := InsertCaptureEvents(db, events); err != nil {
+			t.Fatalf("failed to insert capture events: %v", err)
+		}
+
+		type testCase struct {
+			target      string
+			expectedIDs []string
+		}
+		testCases := []testCase{
+			{
+				target:      "/capture/events?outcome=denied",
+				expectedIDs: []string{"request-2-denied", "request-0-denied"},
+			},
+			{
+				target:      "/capture/events?outcome=denied,granted&limit=2&offset=1",
+				expectedIDs: []string{"request-2-denied", "request-1-granted"},
+			},
+			{
+				target:      "/capture/events?from=2025-11-06T11:00:00%2B09:00&to=2025-11-06T12:00:00%2B09:00",
+				expectedIDs: []string{"request-1-granted", "request-1-requested"},
+			},
+			{
+				target:      "/capture/events?from=2025-11-06T03:00:00Z&outcome=requested",
+				expectedIDs: []string{"request-3-requested", "request-2-requested"},
+			},
+		}
+
+		for _, testCase := range testCases {
+			t.Logf("target: %s", testCase.target)
+			// Act
+			status, response := getCaptureEvents(t, mux, testCase.target)
+
+			// Assert
+			assert.Equal(t, http.StatusOK, status)
+			ids := []string{}
+			for _, event := range response.Events {
+				ids = append(ids, event.ID)
+			}
+			assert.Equal(t, testCase.expectedIDs, ids)
+		}
+		_, response := getCaptureEvents(t, mux, "/capture/events?outcome=denied")
+		if assert.NotEmpty(t, response.Events) {
+			assert.Equal(t, "2025-11-06T12:00:01+09:00", response.Events[0].OccurredAt)
+		}
+	})
+
+	t.Run("GET /capture/events はクエリが不正な場合 400 Bad Request を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		targets := []string{
+			"/capture/events?outcome=unknown",
+			"/capture/events?outcome=denied,",
+			"/capture/events?from=2025-11-06",
+			"/capture/events?to=yesterday",
+			"/capture/events?limit=0",
+			"/capture/events?offset=-1",
+		}
+
+		for _, target := range targets {
+			// Act
+			status, _ := getCaptureEvents(t, mux, target)
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, status, target)
+		}
+	})
+}
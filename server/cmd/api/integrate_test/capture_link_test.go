@@ -0,0 +1,273 @@
+package integratetest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseCaptureList struct {
+	Captures []responseCaptureUnit `json:"captures"`
+	Limit    int                   `json:"limit"`
+	Offset   int                   `json:"offset"`
+}
+
+// 目標goal-1と、その目標のタスクtask-1を作成する。
+// task-1は2025-11-17 09:00から09:30（日本時間）までdoingだったものとする。
+func insertLinkTargets(t *testing.T, db *sql.DB) {
+	t.Helper()
+	startDate := time.Date(2025, 11, 1, 0, 0, 0, 0, GetJSTTimezone())
+	err := InsertGoals(db, []datamodel.Goal{
+		{ID: "goal-1", Title: "Goal 1", Description: "", StartDate: startDate, EndDate: startDate.AddDate(0, 1, 0), Status: "active", CreatedAt: startDate, UpdatedAt: startDate},
+		{ID: "goal-2", Title: "Goal 2", Description: "", StartDate: startDate, EndDate: startDate.AddDate(0, 1, 0), Status: "active", CreatedAt: startDate, UpdatedAt: startDate},
+	})
+	if err != nil {
+		t.Fatalf("failed to insert goals: %v", err)
+	}
+	for _, query := range []string{
+		"INSERT INTO tasks (id, goal_id, title, status, tags) VALUES ('task-1', 'goal-1', 'Task 1', 'doing', '[\"重要\"]')",
+		"INSERT INTO tasks (id, title) VALUES ('task-2', 'Task 2')",
+		"UPDATE tasks SET status = 'done' WHERE id = 'task-1'",
+		"UPDATE task_sessions SET started_at = '2025-11-17 00:00:00+00:00', ended_at = '2025-11-17 00:30:00+00:00' WHERE task_id = 'task-1'",
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+	}
+}
+
+func patchCapture(t *testing.T, mux http.Handler, id string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPatch, "/captures/"+id, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCaptureLinkIntegrate(t *testing.T) {
+	t.Run("POST /capture/screenshot は撮影時に doing だったタスクとその目標に紐付ける", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		insertLinkTargets(t, db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+
+		// Act
+		during := postScreenshot(t, mux, map[string]string{"mode": "manual", "captured_at": "2025-11-17T09:10:00+09:00"}, pngImage)
+		after := postScreenshot(t, mux, map[string]string{"mode": "manual", "captured_at": "2025-11-17T09:30:00+09:00"}, pngImage)
+
+		// Assert
+		if assert.NotNil(t, during.LinkedTaskID) && assert.NotNil(t, during.LinkedGoalID) {
+			assert.Equal(t, "task-1", *during.LinkedTaskID)
+			assert.Equal(t, "goal-1", *during.LinkedGoalID)
+		}
+		assert.Nil(t, after.LinkedTaskID)
+		assert.Nil(t, after.LinkedGoalID)
+
+		// タスクと目標の詳細に紐付いたキャプチャが含まれる
+		for _, target := range []string{"/tasks/task-1", "/goal/goal-1"} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code, target)
+			typedResponse := responseCaptureList{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if assert.Len(t, typedResponse.Captures, 1, target) {
+				assert.Equal(t, during.ID, typedResponse.Captures[0].ID, target)
+			}
+		}
+	})
+
+	t.Run("GET /tasks/:id はタスクの詳細と、紐付いたキャプチャを撮影日時の新しい順に返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		insertLinkTargets(t, db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		first := postScreenshot(t, mux, map[string]string{"mode": "manual", "captured_at": "2025-11-17T09:00:00+09:00"}, pngImage)
+		second := postScreenshot(t, mux, map[string]string{"mode": "manual", "captured_at": "2025-11-17T09:05:00+09:00"}, pngImage)
+		req := httptest.NewRequest(http.MethodGet, "/tasks/task-1?limit=1&offset=1", nil)
+		rec := httptest.NewRecorder()
+
+		// Act
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var typedResponse struct {
+			Task map[string]interface{} `json:"task"`
+			responseCaptureList
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, "task-1", typedResponse.Task["id"])
+		assert.Equal(t, "goal-1", typedResponse.Task["goal_id"])
+		assert.Equal(t, "Task 1", typedResponse.Task["title"])
+		assert.Equal(t, "done", typedResponse.Task["status"])
+		assert.Nil(t, typedResponse.Task["due"])
+		assert.Equal(t, []interface{}{"重要"}, typedResponse.Task["tags"])
+		assert.Equal(t, []interface{}{}, typedResponse.Task["attachments"])
+		assert.Equal(t, 1, typedResponse.Limit)
+		assert.Equal(t, 1, typedResponse.Offset)
+		if assert.Len(t, typedResponse.Captures, 1) {
+			assert.Equal(t, first.ID, typedResponse.Captures[0].ID)
+		}
+		assert.NotEqual(t, first.ID, second.ID)
+	})
+
+	t.Run("GET /tasks/:id と GET /goal/:id は存在しない場合 404 Not Found を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+
+		for _, target := range []string{"/tasks/not-found", "/goal/not-found"} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			rec := httptest.NewRecorder()
+
+			// Act
+			mux.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, http.StatusNotFound, rec.Code, target)
+			typedResponse := responseCodedError{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			assert.Equal(t, "NOT_FOUND", typedResponse.Code, target)
+		}
+	})
+
+	t.Run("PATCH /captures/:id は紐付けるタスクと目標を変更する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		insertLinkTargets(t, db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		c := postScreenshot(t, mux, map[string]string{"mode": "manual", "captured_at": "2025-11-17T10:00:00+09:00"}, pngImage)
+		ptr := func(s string) *string { return &s }
+		cases := []struct {
+			body         string
+			expectedTask *string
+			expectedGoal *string
+		}{
+			// タスクだけを指定した場合、目標はそのタスクの目標になる
+			{`{"linked_task_id": "task-1"}`, ptr("task-1"), ptr("goal-1")},
+			{`{"linked_goal_id": "goal-2"}`, ptr("task-1"), ptr("goal-2")},
+			{`{"linked_task_id": "task-2"}`, ptr("task-2"), nil},
+			{`{"linked_task_id": "task-1", "linked_goal_id": null}`, ptr("task-1"), nil},
+			{`{}`, ptr("task-1"), nil},
+			{`{"linked_task_id": null}`, nil, nil},
+		}
+
+		for _, tc := range cases {
+			// Act
+			rec := patchCapture(t, mux, c.ID, tc.body)
+
+			// Assert
+			assert.Equal(t, http.StatusOK, rec.Code, tc.body)
+			typedResponse := responseCapture{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			assert.Equal(t, c.ID, typedResponse.Capture.ID, tc.body)
+			assert.Equal(t, tc.expectedTask, typedResponse.Capture.LinkedTaskID, tc.body)
+			assert.Equal(t, tc.expectedGoal, typedResponse.Capture.LinkedGoalID, tc.body)
+		}
+	})
+
+	t.Run("PATCH /captures/:id はリクエストが不正な場合 400 Bad Request、キャプチャが存在しない場合 404 Not Found を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		insertLinkTargets(t, db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		c := postScreenshot(t, mux, map[string]string{"mode": "manual", "captured_at": "2025-11-17T10:00:00+09:00"}, pngImage)
+		cases := []struct {
+			id     string
+			body   string
+			status int
+			code   string
+		}{
+			{c.ID, `invalid`, http.StatusBadRequest, "INVALID_REQUEST"},
+			{c.ID, `{"linked_task_id": 1}`, http.StatusBadRequest, "INVALID_REQUEST"},
+			{c.ID, `{"linked_goal_id": ""}`, http.StatusBadRequest, "INVALID_REQUEST"},
+			{c.ID, `{"linked_task_id": "not-found"}`, http.StatusBadRequest, "INVALID_REQUEST"},
+			{c.ID, `{"linked_goal_id": "not-found"}`, http.StatusBadRequest, "INVALID_REQUEST"},
+			{"not-found", `{"linked_task_id": "task-1"}`, http.StatusNotFound, "NOT_FOUND"},
+		}
+
+		for _, tc := range cases {
+			// Act
+			rec := patchCapture(t, mux, tc.id, tc.body)
+
+			// Assert
+			assert.Equal(t, tc.status, rec.Code, tc.body)
+			typedResponse := responseCodedError{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			assert.Equal(t, tc.code, typedResponse.Code, tc.body)
+		}
+		// 紐付けは変更されていない
+		var linkedTaskID sql.NullString
+		err = db.QueryRow("SELECT linked_task_id FROM captures WHERE id = ?", c.ID).Scan(&linkedTaskID)
+		assert.NoError(t, err)
+		assert.False(t, linkedTaskID.Valid)
+	})
+}
@@ -0,0 +1,118 @@
+package integratetest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureMaskIntegrate(t *testing.T) {
+	t.Run("POST /capture/screenshot は撮影したディスプレイのマスクルールを適用してから保存する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		cfg := GetTestConfig(t)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		// 赤と青の境界をまたぐ矩形をモザイクにする
+		req := httptest.NewRequest(http.MethodPatch, "/settings", bytes.NewBufferString(`{"mask_rules": [{"x": 24, "y": 0, "width": 16, "height": 16, "mode": "pixelate"}]}`))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if !assert.Equal(t, http.StatusOK, rec.Code) {
+			t.Fatalf("unexpected response: %s", rec.Body.String())
+		}
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+
+		// Act
+		captured := postScreenshot(t, mux, map[string]string{}, pngImage)
+
+		// Assert
+		data, err := os.ReadFile(filepath.Join(cfg.CaptureStoragePath, captured.Path))
+		if err != nil {
+			t.Fatalf("failed to read capture file: %v", err)
+		}
+		// レスポンスのハッシュとサイズはマスク後のファイルのもの
+		sum := sha256.Sum256(data)
+		assert.Equal(t, hex.EncodeToString(sum[:]), captured.SHA256)
+		assert.Equal(t, int64(len(data)), captured.SizeBytes)
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("failed to decode capture file: %v", err)
+		}
+		at := func(x int, y int) color.RGBA {
+			return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+		}
+		// 矩形内は赤8px、青8pxの平均色になる
+		mixed := color.RGBA{R: 128, B: 128, A: 255}
+		assert.Equal(t, mixed, at(24, 0))
+		assert.Equal(t, mixed, at(39, 15))
+		// 矩形外は変わらない
+		assert.Equal(t, color.RGBA{R: 255, A: 255}, at(23, 0))
+		assert.Equal(t, color.RGBA{R: 255, A: 255}, at(24, 16))
+		assert.Equal(t, color.RGBA{B: 255, A: 255}, at(40, 15))
+	})
+
+	t.Run("POST /capture/screenshot はディスプレイを指定したルールを他のディスプレイに適用しない", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		cfg := GetTestConfig(t)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		req := httptest.NewRequest(http.MethodPatch, "/settings", bytes.NewBufferString(`{"mask_rules": [
+			{"display_id": "display-2", "x": 24, "y": 0, "width": 16, "height": 16, "mode": "blur"}
+		]}`))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if !assert.Equal(t, http.StatusOK, rec.Code) {
+			t.Fatalf("unexpected response: %s", rec.Body.String())
+		}
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+
+		// Act
+		unmasked := postScreenshot(t, mux, map[string]string{"display_id": "display-1"}, pngImage)
+		masked := postScreenshot(t, mux, map[string]string{"display_id": "display-2"}, pngImage)
+
+		// Assert
+		unmaskedData, err := os.ReadFile(filepath.Join(cfg.CaptureStoragePath, unmasked.Path))
+		assert.NoError(t, err)
+		assert.Equal(t, pngImage, unmaskedData)
+		maskedData, err := os.ReadFile(filepath.Join(cfg.CaptureStoragePath, masked.Path))
+		assert.NoError(t, err)
+		assert.NotEqual(t, pngImage, maskedData)
+		img, _, err := image.Decode(bytes.NewReader(maskedData))
+		if err != nil {
+			t.Fatalf("failed to decode capture file: %v", err)
+		}
+		// 境界付近は赤と青が混ざる
+		r, _, b, _ := img.At(31, 8).RGBA()
+		assert.Greater(t, b>>8, uint32(0))
+		assert.Less(t, r>>8, uint32(255))
+	})
+}
@@ -0,0 +1,167 @@
+package integratetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseRetentionPolicy struct {
+	MaxItems *int   `json:"max_items"`
+	MaxDays  *int   `json:"max_days"`
+	MaxBytes *int64 `json:"max_bytes"`
+}
+
+type responseRetentionDryRun struct {
+	Policy     responseRetentionPolicy `json:"policy"`
+	Captures   []responseCaptureUnit   `json:"captures"`
+	TotalCount int                     `json:"total_count"`
+	TotalBytes int64                   `json:"total_bytes"`
+}
+
+func TestCaptureRetentionIntegrate(t *testing.T) {
+	t.Run("GET /capture/retention/dry-run は削除対象のキャプチャを返し、実際には削除しない", func(t *testing.T) {
+		// Arrange
+		// バックグラウンドの削除を止めて、dry-run の結果だけを確認する
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		cfg := GetTestConfig(t)
+		mux, _ := setuphandlers.SetupHandlers(ctx, db, cfg)
+		pngImage, err := NewPNG(8, 8)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		now := time.Now()
+		// デフォルトの保持期間(30日)を過ぎたもの、期間内のもの
+		expiredCapture := postScreenshot(t, mux, map[string]string{"captured_at": now.AddDate(0, 0, -31).Format(time.RFC3339)}, pngImage)
+		postScreenshot(t, mux, map[string]string{"captured_at": now.AddDate(0, 0, -1).Format(time.RFC3339)}, pngImage)
+
+		// Act
+		req := httptest.NewRequest(http.MethodGet, "/capture/retention/dry-run", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		typedResponse := responseRetentionDryRun{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if assert.NotNil(t, typedResponse.Policy.MaxItems) && assert.NotNil(t, typedResponse.Policy.MaxDays) {
+			assert.Equal(t, 100, *typedResponse.Policy.MaxItems)
+			assert.Equal(t, 30, *typedResponse.Policy.MaxDays)
+		}
+		assert.Nil(t, typedResponse.Policy.MaxBytes)
+		if assert.Len(t, typedResponse.Captures, 1) {
+			assert.Equal(t, expiredCapture.ID, typedResponse.Captures[0].ID)
+		}
+		assert.Equal(t, 1, typedResponse.TotalCount)
+		assert.Equal(t, expiredCapture.SizeBytes, typedResponse.TotalBytes)
+
+		// 削除されていない
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM captures").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+		_, err = os.Stat(filepath.Join(cfg.CaptureStoragePath, expiredCapture.Path))
+		assert.NoError(t, err)
+	})
+
+	t.Run("保持ポリシーを超えたキャプチャはバックグラウンドでファイルとレコードごと削除される", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		pngImage, err := NewPNG(8, 8)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPatch, "/settings", bytes.NewBuffer([]byte(`{"retention_max_items": 2}`)))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("failed to patch settings: %d %s", rec.Code, rec.Body.String())
+		}
+		now := time.Now()
+
+		// Act
+		oldest := postScreenshot(t, mux, map[string]string{"captured_at": now.Add(-3 * time.Minute).Format(time.RFC3339)}, pngImage)
+		postScreenshot(t, mux, map[string]string{"captured_at": now.Add(-2 * time.Minute).Format(time.RFC3339)}, pngImage)
+		postScreenshot(t, mux, map[string]string{"captured_at": now.Add(-1 * time.Minute).Format(time.RFC3339)}, pngImage)
+
+		// Assert
+		assert.Eventually(t, func() bool {
+			var count int
+			if err := db.QueryRow("SELECT COUNT(*) FROM captures WHERE id = ?", oldest.ID).Scan(&count); err != nil {
+				return false
+			}
+			return count == 0
+		}, 5*time.Second, 50*time.Millisecond)
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM captures").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+		_, err = os.Stat(filepath.Join(cfg.CaptureStoragePath, oldest.Path))
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(cfg.CaptureStoragePath, *oldest.ThumbPath))
+		assert.True(t, os.IsNotExist(err))
+	})
+	t.Run("保持ポリシーで重複元のキャプチャが削除されると、重複したキャプチャの duplicate_of を外す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(8, 8)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPatch, "/settings", bytes.NewBuffer([]byte(`{"retention_max_items": 1}`)))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("failed to patch settings: %d %s", rec.Code, rec.Body.String())
+		}
+		now := time.Now()
+
+		// Act
+		original := postScheduledScreenshot(t, mux, now.Add(-2*time.Minute).Format(time.RFC3339), pngImage)
+		duplicate := postScheduledScreenshot(t, mux, now.Add(-1*time.Minute).Format(time.RFC3339), pngImage)
+
+		// Assert
+		if assert.NotNil(t, duplicate.Capture.DuplicateOf) {
+			assert.Equal(t, original.Capture.ID, *duplicate.Capture.DuplicateOf)
+		}
+		assert.Eventually(t, func() bool {
+			var count int
+			if err := db.QueryRow("SELECT COUNT(*) FROM captures WHERE id = ?", original.Capture.ID).Scan(&count); err != nil {
+				return false
+			}
+			return count == 0
+		}, 5*time.Second, 50*time.Millisecond)
+		var duplicateOf *string
+		err = db.QueryRow("SELECT duplicate_of FROM captures WHERE id = ?", duplicate.Capture.ID).Scan(&duplicateOf)
+		assert.NoError(t, err)
+		assert.Nil(t, duplicateOf)
+	})
+}
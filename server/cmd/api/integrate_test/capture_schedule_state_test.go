@@ -0,0 +1,276 @@
+package integratetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseCaptureScheduleUnit struct {
+	ID                  string  `json:"id"`
+	Active              bool    `json:"active"`
+	State               string  `json:"state"`
+	IntervalMin         int     `json:"interval_min"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	LastFailureAt       *string `json:"last_failure_at"`
+	LastFailureReason   *string `json:"last_failure_reason"`
+	TimeWindows         []struct {
+		Weekdays []int  `json:"weekdays"`
+		Start    string `json:"start"`
+		End      string `json:"end"`
+	} `json:"time_windows"`
+	ExcludedDates  []string               `json:"excluded_dates"`
+	JitterPct      int                    `json:"jitter_pct"`
+	CaptureMode    string                 `json:"capture_mode"`
+	Region         *responseCaptureRegion `json:"region"`
+	ExcludeWindows []string               `json:"exclude_windows"`
+}
+
+type responseCaptureRegion struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type responseCaptureScheduleTransition struct {
+	Message  string                      `json:"message"`
+	Schedule responseCaptureScheduleUnit `json:"schedule"`
+}
+
+// targetにbodyをPOSTし、ステータスコードとレスポンスボディを返す
+func postJSON(t *testing.T, mux http.Handler, target string, body string) (int, []byte) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewBuffer([]byte(body)))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.Bytes()
+}
+
+func TestCaptureScheduleStateIntegrate(t *testing.T) {
+	t.Run("POST /capture/schedule/start|stop|resume は状態遷移を行い、不正な遷移は 409 Conflict を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		now := time.Now()
+		schedules := []datamodel.CaptureSchedule{
+			{
+				ID:          "schedule-0",
+				State:       datamodel.CaptureScheduleStateInactive,
+				IntervalMin: 5,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			},
+		}
+		if err := InsertCaptureSchedules(db, schedules); err != nil {
+			t.Fatalf("failed to insert capture schedules: %v", err)
+		}
+		type testCase struct {
+			target         string
+			expectedStatus int
+			expectedState  string
+		}
+		testCases := []testCase{
+			{target: "/capture/schedule/stop", expectedStatus: http.StatusConflict},
+			{target: "/capture/schedule/resume", expectedStatus: http.StatusConflict},
+			{target: "/capture/schedule/start", expectedStatus: http.StatusOK, expectedState: "active"},
+			{target: "/capture/schedule/start", expectedStatus: http.StatusConflict},
+			{target: "/capture/schedule/resume", expectedStatus: http.StatusConflict},
+			{target: "/capture/schedule/stop", expectedStatus: http.StatusOK, expectedState: "inactive"},
+		}
+
+		for i, testCase := range testCases {
+			t.Logf("request %d: %s", i, testCase.target)
+			// Act
+			status, body := postJSON(t, mux, testCase.target, "")
+
+			// Assert
+			assert.Equal(t, testCase.expectedStatus, status)
+			if testCase.expectedStatus != http.StatusOK {
+				typedResponse := responseCodedError{}
+				if err := json.Unmarshal(body, &typedResponse); err != nil {
+					t.Fatalf("failed to unmarshal response: %v", err)
+				}
+				assert.Equal(t, "INVALID_STATE", typedResponse.Code)
+				continue
+			}
+			typedResponse := responseCaptureScheduleTransition{}
+			if err := json.Unmarshal(body, &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			assert.Equal(t, "schedule-0", typedResponse.Schedule.ID)
+			assert.Equal(t, testCase.expectedState, typedResponse.Schedule.State)
+			var state string
+			err := db.QueryRow("SELECT state FROM capture_schedules WHERE id = ?", "schedule-0").Scan(&state)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedState, state)
+		}
+	})
+
+	t.Run("POST /capture/schedule/start はスケジュールがない場合 404 Not Found を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+
+		// Act
+		status, body := postJSON(t, mux, "/capture/schedule/start", "")
+
+		// Assert
+		assert.Equal(t, http.StatusNotFound, status)
+		typedResponse := responseCodedError{}
+		if err := json.Unmarshal(body, &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, "NOT_FOUND", typedResponse.Code)
+	})
+
+	t.Run("POST /capture/schedule/failures はリクエストが不正な場合 400 Bad Request を返す", func(t *testing.T) {
+		// Arrange
+		// リクエストパターン:
+		// - JSON でない
+		// - reason が欠如
+		// - reason が空白のみ
+		// - reason が非文字列
+		requests := []string{
+			`reason`,
+			`{}`,
+			`{"reason": "  "}`,
+			`{"reason": 1}`,
+		}
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+
+		for i, request := range requests {
+			t.Logf("request %d: %s", i, request)
+			// Act
+			status, body := postJSON(t, mux, "/capture/schedule/failures", request)
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, status)
+			typedResponse := responseCodedError{}
+			if err := json.Unmarshal(body, &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			assert.Equal(t, "INVALID_REQUEST", typedResponse.Code)
+		}
+	})
+
+	t.Run("POST /capture/schedule/failures は連続失敗が5回に達すると PAUSED にし、スケジュール実行の成功で回数を戻す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		now := time.Now()
+		schedules := []datamodel.CaptureSchedule{
+			{
+				ID:          "schedule-0",
+				State:       datamodel.CaptureScheduleStateActive,
+				IntervalMin: 5,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			},
+		}
+		if err := InsertCaptureSchedules(db, schedules); err != nil {
+			t.Fatalf("failed to insert capture schedules: %v", err)
+		}
+		pngImage, err := NewPNG(8, 8)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		postFailure := func() responseCaptureScheduleUnit {
+			t.Helper()
+			status, body := postJSON(t, mux, "/capture/schedule/failures", `{"reason": "permission denied"}`)
+			if status != http.StatusOK {
+				t.Fatalf("failed to post failure: %d %s", status, body)
+			}
+			typedResponse := responseCaptureScheduleTransition{}
+			if err := json.Unmarshal(body, &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			return typedResponse.Schedule
+		}
+		getConsecutiveFailures := func() int {
+			t.Helper()
+			var consecutiveFailures int
+			if err := db.QueryRow("SELECT consecutive_failures FROM capture_schedules WHERE id = ?", "schedule-0").Scan(&consecutiveFailures); err != nil {
+				t.Fatalf("failed to get consecutive failures: %v", err)
+			}
+			return consecutiveFailures
+		}
+
+		// Act & Assert
+		for i := 1; i <= 4; i++ {
+			schedule := postFailure()
+			assert.Equal(t, i, schedule.ConsecutiveFailures)
+			assert.Equal(t, "active", schedule.State)
+			if assert.NotNil(t, schedule.LastFailureReason) && assert.NotNil(t, schedule.LastFailureAt) {
+				assert.Equal(t, "permission denied", *schedule.LastFailureReason)
+			}
+		}
+
+		// 手動キャプチャでは戻らない
+		postScreenshot(t, mux, map[string]string{"mode": "manual"}, pngImage)
+		assert.Equal(t, 4, getConsecutiveFailures())
+		// スケジュール実行の成功で戻る
+		postScreenshot(t, mux, map[string]string{"mode": "scheduled"}, pngImage)
+		assert.Equal(t, 0, getConsecutiveFailures())
+
+		for i := 1; i <= 4; i++ {
+			postFailure()
+		}
+		schedule := postFailure()
+		assert.Equal(t, 5, schedule.ConsecutiveFailures)
+		assert.Equal(t, "paused", schedule.State)
+		assert.False(t, schedule.Active)
+
+		// PAUSED 中は失敗を受け付けない
+		status, _ := postJSON(t, mux, "/capture/schedule/failures", `{"reason": "permission denied"}`)
+		assert.Equal(t, http.StatusConflict, status)
+
+		// GET /capture/schedule は PAUSED のスケジュールも返す
+		req := httptest.NewRequest(http.MethodGet, "/capture/schedule", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		getResponse := struct {
+			Schedule responseCaptureScheduleUnit `json:"schedule"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &getResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, "paused", getResponse.Schedule.State)
+
+		// resume で ACTIVE に戻り、回数もリセットされる
+		status, body := postJSON(t, mux, "/capture/schedule/resume", "")
+		assert.Equal(t, http.StatusOK, status)
+		resumed := responseCaptureScheduleTransition{}
+		if err := json.Unmarshal(body, &resumed); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, "resumed", resumed.Message)
+		assert.Equal(t, "active", resumed.Schedule.State)
+		assert.Equal(t, 0, resumed.Schedule.ConsecutiveFailures)
+		assert.Equal(t, 0, getConsecutiveFailures())
+	})
+}
@@ -1,15 +1,27 @@
 package integratetest
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
 	"io"
+	"mime/multipart"
+	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"testing"
 	"time"
 
+	"github.com/ano333333/llm-time-manager/server/internal/config"
 	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
 	"github.com/ano333333/llm-time-manager/server/internal/database"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
 )
 
 const DBPathKey = "DB_PATH"
@@ -37,10 +49,41 @@ func BeforeEach() (*sql.DB, error) {
 
 func AfterEach(db *sql.DB) {
 	db.Close()
-	os.Remove(dbPath)
+	// 終了前のバックグラウンドワーカーが古い接続で共有メモリを参照していることがあるため、
+	// 次のテストで同じファイルを再利用しないようWAL・共有メモリのファイルも削除する
+	for _, path := range []string{dbPath, dbPath + "-wal", dbPath + "-shm"} {
+		os.Remove(path)
+	}
 	os.Setenv(DBPathKey, originalDBPath)
 }
 
+// テスト用の設定を返す。キャプチャの保存先はテストごとの一時ディレクトリになる。
+//
+// LLMはネットワークを使わないfakeプロバイダ（最後のユーザーのメッセージを返す）になる。
+func GetTestConfig(t *testing.T) config.Config {
+	return config.Config{
+		DBPath:                dbPath,
+		CaptureStoragePath:    t.TempDir(),
+		CaptureMaxUploadBytes: 1024 * 1024,
+		AnalysisWorkers:       1,
+		LLMProvider:           llm.ProviderFake,
+	}
+}
+
+// fakeプロバイダの応答スクリプトを一時ディレクトリに書き込み、そのパスを返す
+func WriteFakeLLMScript(t *testing.T, responses []llm.FakeResponse) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{"responses": responses})
+	if err != nil {
+		t.Fatalf("failed to marshal fake llm script: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "llm-script.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write fake llm script: %v", err)
+	}
+	return path
+}
+
 func GetResponseBodyJson(rec *httptest.ResponseRecorder) (string, error) {
 	body, err := io.ReadAll(rec.Result().Body)
 	if err != nil {
@@ -49,6 +92,14 @@ func GetResponseBodyJson(rec *httptest.ResponseRecorder) (string, error) {
 	return string(body), nil
 }
 
+// キャプチャの保持ポリシーを無制限にする。固定の過去日時でキャプチャを作成するテストで、バックグラウンドの削除と競合しないようにする。
+func DisableCaptureRetention(db *sql.DB) error {
+	if _, err := db.Exec("UPDATE settings SET retention_max_items = NULL, retention_max_days = NULL, retention_max_bytes = NULL WHERE id = 1"); err != nil {
+		return fmt.Errorf("failed to disable capture retention: %w", err)
+	}
+	return nil
+}
+
 func GetJSTTimezone() *time.Location {
 	return time.FixedZone("JST", 9*60*60)
 }
@@ -60,7 +111,7 @@ func InsertCaptureSchedules(db *sql.DB, schedules []datamodel.CaptureSchedule) e
 	}
 	defer tx.Rollback()
 	for _, schedule := range schedules {
-		_, err := tx.Exec("INSERT INTO capture_schedules (id, active, interval_min, created_at, updated_at) VALUES (?, ?, ?, ?, ?)", schedule.ID, schedule.Active, schedule.IntervalMin, schedule.CreatedAt, schedule.UpdatedAt)
+		_, err := tx.Exec("INSERT INTO capture_schedules (id, state, interval_min, consecutive_failures, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)", schedule.ID, schedule.State, schedule.IntervalMin, schedule.ConsecutiveFailures, schedule.CreatedAt, schedule.UpdatedAt)
 		if err != nil {
 			return fmt.Errorf("failed to insert capture schedule: %w", err)
 		}
@@ -71,6 +122,27 @@ func InsertCaptureSchedules(db *sql.DB, schedules []datamodel.CaptureSchedule) e
 	return nil
 }
 
+func InsertCaptureEvents(db *sql.DB, events []datamodel.CaptureEvent) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, event := range events {
+		_, err := tx.Exec(
+			"INSERT INTO capture_events (id, request_id, state, mode, schedule_id, capture_id, error_code, error_message, occurred_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			event.ID, event.RequestID, event.State, event.Mode, event.ScheduleID, event.CaptureID, event.ErrorCode, event.ErrorMessage, event.OccurredAt.UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert capture event: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 func InsertGoals(db *sql.DB, goals []datamodel.Goal) error {
 	tx, err := db.Begin()
 	if err != nil {
@@ -98,3 +170,61 @@ func InsertGoals(db *sql.DB, goals []datamodel.Goal) error {
 	}
 	return nil
 }
+
+// width x height のPNG画像を返す。左半分が赤、右半分が青になる。
+func NewPNG(width int, height int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, newTestImage(width, height)); err != nil {
+		return nil, fmt.Errorf("failed to encode png: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// width x height のJPEG画像を返す。左半分が赤、右半分が青になる。
+func NewJPEG(width int, height int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, newTestImage(width, height), nil); err != nil {
+		return nil, fmt.Errorf("failed to encode jpeg: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func newTestImage(width int, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	return img
+}
+
+// multipart/form-dataのPOSTリクエストを作成する。imageがnilの場合はimageフィールドを含めない。
+func NewMultipartRequest(target string, fields map[string]string, image []byte) (*http.Request, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, value := range fields {
+		if err := writer.WriteField(key, value); err != nil {
+			return nil, fmt.Errorf("failed to write field: %w", err)
+		}
+	}
+	if image != nil {
+		part, err := writer.CreateFormFile("image", "screenshot")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create form file: %w", err)
+		}
+		if _, err := part.Write(image); err != nil {
+			return nil, fmt.Errorf("failed to write form file: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req, nil
+}
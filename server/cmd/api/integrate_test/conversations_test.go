@@ -0,0 +1,368 @@
+package integratetest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/ano333333/llm-time-manager/server/internal/config"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseConversationUnit struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type responseConversations struct {
+	Conversations []responseConversationUnit `json:"conversations"`
+	Limit         int                        `json:"limit"`
+	Offset        int                        `json:"offset"`
+}
+
+type responseChatMessageUnit struct {
+	ID             string         `json:"id"`
+	ConversationID string         `json:"conversation_id"`
+	Role           string         `json:"role"`
+	Content        string         `json:"content"`
+	FinishReason   *string        `json:"finish_reason"`
+	PromptVersions map[string]int `json:"prompt_versions"`
+	CreatedAt      string         `json:"created_at"`
+}
+
+type responseConversationMessages struct {
+	Conversation responseConversationUnit  `json:"conversation"`
+	Messages     []responseChatMessageUnit `json:"messages"`
+	Limit        int                       `json:"limit"`
+	Offset       int                       `json:"offset"`
+}
+
+func TestConversationsIntegrate(t *testing.T) {
+	setup := func(t *testing.T, cfgModifier func(cfg *config.Config)) http.Handler {
+		t.Helper()
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		t.Cleanup(func() { AfterEach(db) })
+		cfg := GetTestConfig(t)
+		if cfgModifier != nil {
+			cfgModifier(&cfg)
+		}
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		return mux
+	}
+	// POST /llm/chat を送り、会話IDと残りのdata行を返す
+	chat := func(t *testing.T, mux http.Handler, body string) (string, []string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/llm/chat", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("failed to chat: %d %s", rec.Code, rec.Body.String())
+		}
+		return splitConversationEvent(t, readSSEData(t, rec.Body))
+	}
+	request := func(t *testing.T, mux http.Handler, method string, target string, response any) int {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if err := json.Unmarshal(rec.Body.Bytes(), response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return rec.Code
+	}
+	finishReasons := func(messages []responseChatMessageUnit) []*string {
+		results := make([]*string, 0, len(messages))
+		for _, m := range messages {
+			results = append(results, m.FinishReason)
+		}
+		return results
+	}
+	stringPtr := func(s string) *string { return &s }
+
+	t.Run("POST /llm/chat は会話を作り、リクエストと応答のメッセージを保存する", func(t *testing.T) {
+		// Arrange
+		mux := setup(t, nil)
+
+		// Act
+		conversationID, data := chat(t, mux, `{"messages": [
+			{"role": "system", "content": "あなたは時間管理アシスタントです"},
+			{"role": "user", "content": "  来週水曜にレポートを提出したい\n詳細は後で"}
+		]}`)
+
+		// Assert
+		assert.Equal(t, "[DONE]", data[len(data)-1])
+		conversations := responseConversations{}
+		assert.Equal(t, http.StatusOK, request(t, mux, http.MethodGet, "/conversations", &conversations))
+		if assert.Len(t, conversations.Conversations, 1) {
+			assert.Equal(t, conversationID, conversations.Conversations[0].ID)
+			assert.Equal(t, "来週水曜にレポートを提出したい", conversations.Conversations[0].Title)
+		}
+		assert.Equal(t, 20, conversations.Limit)
+		messages := responseConversationMessages{}
+		assert.Equal(t, http.StatusOK, request(t, mux, http.MethodGet, "/conversations/"+conversationID+"/messages", &messages))
+		assert.Equal(t, conversationID, messages.Conversation.ID)
+		if assert.Len(t, messages.Messages, 3) {
+			assert.Equal(t, []string{"system", "user", "assistant"}, []string{messages.Messages[0].Role, messages.Messages[1].Role, messages.Messages[2].Role})
+			assert.Equal(t, "  来週水曜にレポートを提出したい\n詳細は後で", messages.Messages[2].Content)
+			assert.Equal(t, []*string{nil, nil, stringPtr("completed")}, finishReasons(messages.Messages))
+			assert.Equal(t, conversationID, messages.Messages[2].ConversationID)
+		}
+	})
+
+	t.Run("POST /llm/chat は conversation_id の会話の履歴を含めて LLM に送る", func(t *testing.T) {
+		// Arrange
+		requests := make(chan ollamaChatRequest, 2)
+		stub := newOllamaStub(t, []string{"はい"}, requests)
+		mux := setup(t, func(cfg *config.Config) {
+			cfg.LLMProvider = llm.ProviderOllama
+			cfg.LLMEndpoint = stub.URL
+		})
+		conversationID, _ := chat(t, mux, `{"messages": [{"role": "user", "content": "最初の質問"}]}`)
+		<-requests
+
+		// Act
+		continuedID, data := chat(t, mux, fmt.Sprintf(`{"conversation_id": %q, "messages": [{"role": "user", "content": "次の質問"}]}`, conversationID))
+
+		// Assert
+		assert.Equal(t, conversationID, continuedID)
+		assert.Equal(t, "[DONE]", data[len(data)-1])
+		request := <-requests
+		// 先頭はサーバーが付けるシステムプロンプト
+		assert.Equal(t, "system", request.Messages[0].Role)
+		contents := []string{}
+		for _, m := range request.Messages[1:] {
+			contents = append(contents, m.Role+":"+m.Content)
+		}
+		assert.Equal(t, []string{"user:最初の質問", "assistant:はい", "user:次の質問"}, contents)
+	})
+
+	t.Run("POST /llm/chat は履歴が閾値を超えると古いメッセージを要約し、要約と新しいメッセージを LLM に送る", func(t *testing.T) {
+		// Arrange
+		requests := make(chan ollamaChatRequest, 5)
+		stub := newOllamaStub(t, []string{"はい"}, requests)
+		mux := setup(t, func(cfg *config.Config) {
+			cfg.LLMProvider = llm.ProviderOllama
+			cfg.LLMEndpoint = stub.URL
+			cfg.LLMSummaryThreshold = 10
+		})
+		contents := func(request ollamaChatRequest) []string {
+			results := []string{}
+			for _, m := range request.Messages {
+				results = append(results, m.Role+":"+m.Content)
+			}
+			return results
+		}
+		conversationID, _ := chat(t, mux, `{"messages": [{"role": "user", "content": "一二三四五六七八九十一二"}]}`)
+		<-requests
+
+		// Act
+		chat(t, mux, fmt.Sprintf(`{"conversation_id": %q, "messages": [{"role": "user", "content": "次の質問"}]}`, conversationID))
+		summaryRequest := <-requests
+		summarizedRequest := <-requests
+		chat(t, mux, fmt.Sprintf(`{"conversation_id": %q, "messages": [{"role": "user", "content": "三つ目"}]}`, conversationID))
+		nextRequest := <-requests
+
+		// Assert
+		if assert.Len(t, summaryRequest.Messages, 2) {
+			assert.Equal(t, "## 会話\nユーザー: 一二三四五六七八九十一二\nアシスタント: はい\n", summaryRequest.Messages[1].Content)
+		}
+		// 先頭のシステムプロンプトの後に、要約と要約していないメッセージが続く
+		assert.Equal(t, []string{"system:これまでの会話の要約:\nはい", "user:次の質問"}, contents(summarizedRequest)[1:])
+		assert.Equal(t, []string{"system:これまでの会話の要約:\nはい", "user:次の質問", "assistant:はい", "user:三つ目"}, contents(nextRequest)[1:])
+		assert.Len(t, requests, 0)
+		// 全ての履歴は残す
+		messages := responseConversationMessages{}
+		assert.Equal(t, http.StatusOK, request(t, mux, http.MethodGet, "/conversations/"+conversationID+"/messages", &messages))
+		assert.Len(t, messages.Messages, 6)
+	})
+
+	t.Run("POST /llm/chat は LLM のエラーまでの応答を finish_reason error で保存する", func(t *testing.T) {
+		// Arrange
+		mux := setup(t, func(cfg *config.Config) {
+			cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{{Chunks: []string{"途中"}, Error: "model unloaded"}})
+		})
+
+		// Act
+		conversationID, _ := chat(t, mux, `{"messages": [{"role": "user", "content": "こんにちは"}]}`)
+
+		// Assert
+		messages := responseConversationMessages{}
+		assert.Equal(t, http.StatusOK, request(t, mux, http.MethodGet, "/conversations/"+conversationID+"/messages", &messages))
+		if assert.Len(t, messages.Messages, 2) {
+			assert.Equal(t, "途中", messages.Messages[1].Content)
+			assert.Equal(t, stringPtr("error"), messages.Messages[1].FinishReason)
+		}
+	})
+
+	t.Run("POST /llm/chat はクライアントが切断するとそれまでの応答を finish_reason aborted で保存する", func(t *testing.T) {
+		// Arrange
+		stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":"考え中"},"done":false}`)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer stub.Close()
+		mux := setup(t, func(cfg *config.Config) {
+			cfg.LLMProvider = llm.ProviderOllama
+			cfg.LLMEndpoint = stub.URL
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/llm/chat", bytes.NewBufferString(`{"messages": [{"role": "user", "content": "こんにちは"}]}`))
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to post: %v", err)
+		}
+		defer res.Body.Close()
+		reader := bufio.NewReader(res.Body)
+		var lines []string
+		for len(lines) < 3 {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("failed to read stream: %v", err)
+			}
+			lines = append(lines, line)
+		}
+		conversationID, _ := splitConversationEvent(t, []string{lines[0][len("data: ") : len(lines[0])-1]})
+
+		// Act
+		cancel()
+
+		// Assert
+		messages := responseConversationMessages{}
+		deadline := time.Now().Add(5 * time.Second)
+		for len(messages.Messages) < 2 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+			request(t, mux, http.MethodGet, "/conversations/"+conversationID+"/messages", &messages)
+		}
+		if assert.Len(t, messages.Messages, 2) {
+			assert.Equal(t, "考え中", messages.Messages[1].Content)
+			assert.Equal(t, stringPtr("aborted"), messages.Messages[1].FinishReason)
+		}
+	})
+
+	t.Run("POST /llm/chat は存在しない conversation_id に404を返す", func(t *testing.T) {
+		// Arrange
+		mux := setup(t, nil)
+		req := httptest.NewRequest(http.MethodPost, "/llm/chat", bytes.NewBufferString(`{"conversation_id": "unknown", "messages": [{"role": "user", "content": "a"}]}`))
+		rec := httptest.NewRecorder()
+
+		// Act
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		var response map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, map[string]interface{}{"code": "NOT_FOUND", "message": "Conversation not found"}, response)
+	})
+
+	t.Run("GET /conversations は最後にメッセージを追加した会話から順に返す", func(t *testing.T) {
+		// Arrange
+		mux := setup(t, nil)
+		firstID, _ := chat(t, mux, `{"messages": [{"role": "user", "content": "一つ目"}]}`)
+		secondID, _ := chat(t, mux, `{"messages": [{"role": "user", "content": "二つ目"}]}`)
+		time.Sleep(10 * time.Millisecond)
+		chat(t, mux, fmt.Sprintf(`{"conversation_id": %q, "messages": [{"role": "user", "content": "続き"}]}`, firstID))
+
+		// Act
+		all := responseConversations{}
+		allCode := request(t, mux, http.MethodGet, "/conversations", &all)
+		paged := responseConversations{}
+		pagedCode := request(t, mux, http.MethodGet, "/conversations?limit=1&offset=1", &paged)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, allCode)
+		ids := []string{}
+		for _, c := range all.Conversations {
+			ids = append(ids, c.ID)
+		}
+		assert.Equal(t, []string{firstID, secondID}, ids)
+		assert.Equal(t, http.StatusOK, pagedCode)
+		if assert.Len(t, paged.Conversations, 1) {
+			assert.Equal(t, secondID, paged.Conversations[0].ID)
+		}
+		assert.Equal(t, 1, paged.Limit)
+		assert.Equal(t, 1, paged.Offset)
+	})
+
+	t.Run("GET /conversations/{id}/messages はメッセージをページングして返す", func(t *testing.T) {
+		// Arrange
+		mux := setup(t, nil)
+		conversationID, _ := chat(t, mux, `{"messages": [{"role": "user", "content": "一つ目"}]}`)
+		chat(t, mux, fmt.Sprintf(`{"conversation_id": %q, "messages": [{"role": "user", "content": "二つ目"}]}`, conversationID))
+
+		// Act
+		messages := responseConversationMessages{}
+		code := request(t, mux, http.MethodGet, "/conversations/"+conversationID+"/messages?limit=2&offset=1", &messages)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, code)
+		contents := []string{}
+		for _, m := range messages.Messages {
+			contents = append(contents, m.Role+":"+m.Content)
+		}
+		assert.Equal(t, []string{"assistant:一つ目", "user:二つ目"}, contents)
+		assert.Equal(t, 2, messages.Limit)
+		assert.Equal(t, 1, messages.Offset)
+	})
+
+	t.Run("DELETE /conversations/{id} は会話とメッセージを削除する", func(t *testing.T) {
+		// Arrange
+		mux := setup(t, nil)
+		conversationID, _ := chat(t, mux, `{"messages": [{"role": "user", "content": "消す会話"}]}`)
+		keptID, _ := chat(t, mux, `{"messages": [{"role": "user", "content": "残す会話"}]}`)
+
+		// Act
+		var response map[string]interface{}
+		code := request(t, mux, http.MethodDelete, "/conversations/"+conversationID, &response)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, map[string]interface{}{"message": "deleted"}, response)
+		conversations := responseConversations{}
+		request(t, mux, http.MethodGet, "/conversations", &conversations)
+		if assert.Len(t, conversations.Conversations, 1) {
+			assert.Equal(t, keptID, conversations.Conversations[0].ID)
+		}
+		var notFound map[string]interface{}
+		assert.Equal(t, http.StatusNotFound, request(t, mux, http.MethodGet, "/conversations/"+conversationID+"/messages", &notFound))
+		assert.Equal(t, map[string]interface{}{"code": "NOT_FOUND", "message": "Conversation not found"}, notFound)
+		kept := responseConversationMessages{}
+		request(t, mux, http.MethodGet, "/conversations/"+keptID+"/messages", &kept)
+		assert.Len(t, kept.Messages, 2)
+	})
+
+	t.Run("DELETE /conversations/{id} は存在しない会話に404を返す", func(t *testing.T) {
+		// Arrange
+		mux := setup(t, nil)
+
+		// Act
+		var response map[string]interface{}
+		code := request(t, mux, http.MethodDelete, "/conversations/unknown", &response)
+
+		// Assert
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Equal(t, map[string]interface{}{"code": "NOT_FOUND", "message": "Conversation not found"}, response)
+	})
+}
@@ -0,0 +1,54 @@
+package integratetest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCaptureRequestsIntegrate(t *testing.T) {
+	t.Run("GET /capture/requests は SSE ストリームを返し、サーバの停止時に終了する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		mux, _ := setuphandlers.SetupHandlers(ctx, db, GetTestConfig(t))
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		// Act
+		res, err := http.Get(server.URL + "/capture/requests")
+		if err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		defer res.Body.Close()
+
+		// Assert
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+		// サーバの停止でストリームが閉じられる
+		done := make(chan error, 1)
+		go func() {
+			_, err := io.ReadAll(res.Body)
+			done <- err
+		}()
+		cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("stream was not closed")
+		}
+	})
+}
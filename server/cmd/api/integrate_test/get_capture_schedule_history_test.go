@@ -0,0 +1,222 @@
+package integratetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseCaptureScheduleSnapshot struct {
+	State       string `json:"state"`
+	IntervalMin int    `json:"interval_min"`
+}
+
+// 履歴の変更前後の設定のうち、状態と間隔以外も含めたもの
+type responseCaptureScheduleSettings struct {
+	State       string `json:"state"`
+	IntervalMin int    `json:"interval_min"`
+	TimeWindows []struct {
+		Weekdays []int  `json:"weekdays"`
+		Start    string `json:"start"`
+		End      string `json:"end"`
+	} `json:"time_windows"`
+	ExcludedDates  []string               `json:"excluded_dates"`
+	JitterPct      int                    `json:"jitter_pct"`
+	CaptureMode    string                 `json:"capture_mode"`
+	Region         *responseCaptureRegion `json:"region"`
+	ExcludeWindows []string               `json:"exclude_windows"`
+}
+
+type responseCaptureScheduleHistoryUnit struct {
+	ID         string                           `json:"id"`
+	ScheduleID string                           `json:"schedule_id"`
+	Action     string                           `json:"action"`
+	ChangedBy  string                           `json:"changed_by"`
+	Old        *responseCaptureScheduleSnapshot `json:"old"`
+	New        responseCaptureScheduleSnapshot  `json:"new"`
+	ChangedAt  string                           `json:"changed_at"`
+}
+
+type responseCaptureScheduleHistory struct {
+	History []responseCaptureScheduleHistoryUnit `json:"history"`
+	Limit   int                                  `json:"limit"`
+	Offset  int                                  `json:"offset"`
+}
+
+func TestGetCaptureScheduleHistoryIntegrate(t *testing.T) {
+	t.Run("GET /capture/schedule/history はクエリパラメータが不正な場合 400 Bad Request を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		queries := []string{"limit=0", "limit=201", "limit=a", "offset=-1", "offset=1.5"}
+
+		for _, query := range queries {
+			t.Logf("query: %s", query)
+			// Act
+			req := httptest.NewRequest(http.MethodGet, "/capture/schedule/history?"+query, nil)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			typedResponse := responseCodedError{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			assert.Equal(t, "INVALID_REQUEST", typedResponse.Code)
+		}
+	})
+
+	t.Run("GET /capture/schedule/history はスケジュールの変更履歴を新しい順に返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		put := func(body string) {
+			t.Helper()
+			req := httptest.NewRequest(http.MethodPut, "/capture/schedule", bytes.NewBuffer([]byte(body)))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("failed to put capture schedule: %d %s", rec.Code, rec.Body.String())
+			}
+		}
+		put(`{"active": true, "interval_min": 5}`)
+		postJSON(t, mux, "/capture/schedule/stop", "")
+		put(`{"active": false, "interval_min": 10}`)
+		postJSON(t, mux, "/capture/schedule/start", "")
+		for range 5 {
+			postJSON(t, mux, "/capture/schedule/failures", `{"reason": "permission denied"}`)
+		}
+
+		// Act
+		req := httptest.NewRequest(http.MethodGet, "/capture/schedule/history", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		typedResponse := responseCaptureScheduleHistory{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, 50, typedResponse.Limit)
+		assert.Equal(t, 0, typedResponse.Offset)
+		type expectedHistory struct {
+			action    string
+			changedBy string
+			old       *responseCaptureScheduleSnapshot
+			new       responseCaptureScheduleSnapshot
+		}
+		expected := []expectedHistory{
+			{action: "pause", changedBy: "system", old: &responseCaptureScheduleSnapshot{State: "active", IntervalMin: 10}, new: responseCaptureScheduleSnapshot{State: "paused", IntervalMin: 10}},
+			{action: "start", changedBy: "user", old: &responseCaptureScheduleSnapshot{State: "inactive", IntervalMin: 10}, new: responseCaptureScheduleSnapshot{State: "active", IntervalMin: 10}},
+			{action: "update", changedBy: "user", old: &responseCaptureScheduleSnapshot{State: "inactive", IntervalMin: 5}, new: responseCaptureScheduleSnapshot{State: "inactive", IntervalMin: 10}},
+			{action: "stop", changedBy: "user", old: &responseCaptureScheduleSnapshot{State: "active", IntervalMin: 5}, new: responseCaptureScheduleSnapshot{State: "inactive", IntervalMin: 5}},
+			{action: "create", changedBy: "user", old: nil, new: responseCaptureScheduleSnapshot{State: "active", IntervalMin: 5}},
+		}
+		if assert.Len(t, typedResponse.History, len(expected)) {
+			for i, e := range expected {
+				history := typedResponse.History[i]
+				assert.Equal(t, e.action, history.Action)
+				assert.Equal(t, e.changedBy, history.ChangedBy)
+				assert.Equal(t, e.old, history.Old)
+				assert.Equal(t, e.new, history.New)
+				assert.Equal(t, typedResponse.History[0].ScheduleID, history.ScheduleID)
+				assert.NotEmpty(t, history.ChangedAt)
+			}
+		}
+
+		// limit/offset で絞り込める
+		req = httptest.NewRequest(http.MethodGet, "/capture/schedule/history?limit=2&offset=1", nil)
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		paged := responseCaptureScheduleHistory{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &paged); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if assert.Len(t, paged.History, 2) {
+			assert.Equal(t, "start", paged.History[0].Action)
+			assert.Equal(t, "update", paged.History[1].Action)
+		}
+	})
+	t.Run("GET /capture/schedule/history は時間帯だけの変更も変更前後の設定として返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		put := func(body string) {
+			t.Helper()
+			req := httptest.NewRequest(http.MethodPut, "/capture/schedule", bytes.NewBuffer([]byte(body)))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("failed to put capture schedule: %d %s", rec.Code, rec.Body.String())
+			}
+		}
+		settings := `"active": false, "interval_min": 5, "excluded_dates": ["2025-11-23"], "jitter_pct": 10, "capture_mode": "region", "region": {"x": 0, "y": 0, "width": 800, "height": 600}, "exclude_windows": ["window-1"]`
+		put(`{` + settings + `, "time_windows": [{"weekdays": [1, 2, 3, 4, 5], "start": "09:00", "end": "18:00"}]}`)
+		put(`{` + settings + `, "time_windows": [{"weekdays": [], "start": "10:00", "end": "12:00"}]}`)
+
+		// Act
+		req := httptest.NewRequest(http.MethodGet, "/capture/schedule/history?limit=1", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		typedResponse := struct {
+			History []struct {
+				Action string                           `json:"action"`
+				Old    *responseCaptureScheduleSettings `json:"old"`
+				New    responseCaptureScheduleSettings  `json:"new"`
+			} `json:"history"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if !assert.Len(t, typedResponse.History, 1) {
+			return
+		}
+		history := typedResponse.History[0]
+		assert.Equal(t, "update", history.Action)
+		if !assert.NotNil(t, history.Old) {
+			return
+		}
+		if assert.Len(t, history.Old.TimeWindows, 1) {
+			assert.Equal(t, []int{1, 2, 3, 4, 5}, history.Old.TimeWindows[0].Weekdays)
+			assert.Equal(t, "09:00", history.Old.TimeWindows[0].Start)
+			assert.Equal(t, "18:00", history.Old.TimeWindows[0].End)
+		}
+		if assert.Len(t, history.New.TimeWindows, 1) {
+			assert.Equal(t, []int{}, history.New.TimeWindows[0].Weekdays)
+			assert.Equal(t, "10:00", history.New.TimeWindows[0].Start)
+			assert.Equal(t, "12:00", history.New.TimeWindows[0].End)
+		}
+		for _, snapshot := range []responseCaptureScheduleSettings{*history.Old, history.New} {
+			assert.Equal(t, "inactive", snapshot.State)
+			assert.Equal(t, 5, snapshot.IntervalMin)
+			assert.Equal(t, []string{"2025-11-23"}, snapshot.ExcludedDates)
+			assert.Equal(t, 10, snapshot.JitterPct)
+			assert.Equal(t, "region", snapshot.CaptureMode)
+			assert.Equal(t, &responseCaptureRegion{X: 0, Y: 0, Width: 800, Height: 600}, snapshot.Region)
+			assert.Equal(t, []string{"window-1"}, snapshot.ExcludeWindows)
+		}
+	})
+}
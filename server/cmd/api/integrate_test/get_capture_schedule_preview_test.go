@@ -0,0 +1,131 @@
+package integratetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseCaptureSchedulePreview struct {
+	ScheduleID string   `json:"schedule_id"`
+	State      string   `json:"state"`
+	JitterSec  int      `json:"jitter_sec"`
+	Times      []string `json:"times"`
+}
+
+func TestGetCaptureSchedulePreviewIntegrate(t *testing.T) {
+	t.Run("PUT /capture/schedule で設定した時間帯・除外日・ジッターを保存し、GET /capture/schedule/preview で今後のキャプチャ時刻を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		putBody := `{
+			"active": false,
+			"interval_min": 60,
+			"time_windows": [{"weekdays": [1, 2, 3, 4, 5], "start": "09:00", "end": "18:00"}],
+			"excluded_dates": ["2025-11-10"],
+			"jitter_pct": 10
+		}`
+		req := httptest.NewRequest(http.MethodPut, "/capture/schedule", bytes.NewBuffer([]byte(putBody)))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		putResponse := struct {
+			Schedule responseCaptureScheduleUnit `json:"schedule"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &putResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if assert.Len(t, putResponse.Schedule.TimeWindows, 1) {
+			assert.Equal(t, []int{1, 2, 3, 4, 5}, putResponse.Schedule.TimeWindows[0].Weekdays)
+			assert.Equal(t, "09:00", putResponse.Schedule.TimeWindows[0].Start)
+			assert.Equal(t, "18:00", putResponse.Schedule.TimeWindows[0].End)
+		}
+		assert.Equal(t, []string{"2025-11-10"}, putResponse.Schedule.ExcludedDates)
+		assert.Equal(t, 10, putResponse.Schedule.JitterPct)
+
+		// Act
+		// 2025-11-07(金) 16:30 JST から
+		req = httptest.NewRequest(http.MethodGet, "/capture/schedule/preview?count=3&from=2025-11-07T16:30:00%2B09:00", nil)
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		typedResponse := responseCaptureSchedulePreview{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, putResponse.Schedule.ID, typedResponse.ScheduleID)
+		assert.Equal(t, "inactive", typedResponse.State)
+		assert.Equal(t, 360, typedResponse.JitterSec)
+		// 土日と除外日の月曜を飛ばす
+		assert.Equal(t, []string{
+			"2025-11-07T17:30:00+09:00",
+			"2025-11-11T09:00:00+09:00",
+			"2025-11-11T10:00:00+09:00",
+		}, typedResponse.Times)
+	})
+
+	t.Run("GET /capture/schedule/preview はスケジュールがない場合 404 Not Found を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+
+		// Act
+		req := httptest.NewRequest(http.MethodGet, "/capture/schedule/preview", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		typedResponse := responseCodedError{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, "NOT_FOUND", typedResponse.Code)
+	})
+
+	t.Run("GET /capture/schedule/preview はクエリが不正な場合 400 Bad Request を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		targets := []string{
+			"/capture/schedule/preview?count=0",
+			"/capture/schedule/preview?count=101",
+			"/capture/schedule/preview?count=a",
+			"/capture/schedule/preview?from=2025-11-07",
+		}
+
+		for _, target := range targets {
+			// Act
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+			typedResponse := responseCodedError{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			assert.Equal(t, "INVALID_REQUEST", typedResponse.Code)
+		}
+	})
+}
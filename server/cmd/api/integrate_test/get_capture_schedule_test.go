@@ -14,14 +14,14 @@ import (
 )
 
 func TestGetCaptureScheduleIntegrate(t *testing.T) {
-	t.Run("GET /capture/schedule はアクティブなスケジュールがなければnullを返す", func(t *testing.T) {
+	t.Run("GET /capture/schedule はスケジュールがなければnullを返す", func(t *testing.T) {
 		// Arrange
 		db, err := BeforeEach()
 		if err != nil {
 			t.Fatalf("failed to set up test: %v", err)
 		}
 		defer AfterEach(db)
-		mux := setuphandlers.SetupHandlers(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
 
 		// Act
 		req := httptest.NewRequest(http.MethodGet, "/capture/schedule", nil)
@@ -43,26 +43,26 @@ func TestGetCaptureScheduleIntegrate(t *testing.T) {
 			t.Fatalf("failed to set up test: %v", err)
 		}
 		defer AfterEach(db)
-		mux := setuphandlers.SetupHandlers(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
 		now := time.Now()
 		schedules := []datamodel.CaptureSchedule{
 			{
 				ID:          "schedule-0",
-				Active:      false,
+				State:       datamodel.CaptureScheduleStateInactive,
 				IntervalMin: 10,
 				CreatedAt:   now,
 				UpdatedAt:   now,
 			},
 			{
 				ID:          "schedule-1",
-				Active:      true,
+				State:       datamodel.CaptureScheduleStateActive,
 				IntervalMin: 5,
 				CreatedAt:   now,
 				UpdatedAt:   now,
 			},
 			{
 				ID:          "schedule-2",
-				Active:      false,
+				State:       datamodel.CaptureScheduleStateInactive,
 				IntervalMin: 15,
 				CreatedAt:   now,
 				UpdatedAt:   now,
@@ -84,9 +84,19 @@ func TestGetCaptureScheduleIntegrate(t *testing.T) {
 		assert.NoError(t, err)
 		expected, err := json.Marshal(map[string]interface{}{
 			"schedule": map[string]interface{}{
-				"id":           schedules[1].ID,
-				"active":       schedules[1].Active,
-				"interval_min": schedules[1].IntervalMin,
+				"id":                   schedules[1].ID,
+				"active":               true,
+				"state":                "active",
+				"interval_min":         schedules[1].IntervalMin,
+				"consecutive_failures": 0,
+				"last_failure_at":      nil,
+				"last_failure_reason":  nil,
+				"time_windows":         []interface{}{},
+				"excluded_dates":       []interface{}{},
+				"jitter_pct":           0,
+				"capture_mode":         "full",
+				"region":               nil,
+				"exclude_windows":      []interface{}{},
 			},
 		})
 		if err != nil {
@@ -102,19 +112,19 @@ func TestGetCaptureScheduleIntegrate(t *testing.T) {
 			t.Fatalf("failed to set up test: %v", err)
 		}
 		defer AfterEach(db)
-		mux := setuphandlers.SetupHandlers(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
 		now := time.Now()
 		schedules := []datamodel.CaptureSchedule{
 			{
 				ID:          "schedule-0",
-				Active:      true,
+				State:       datamodel.CaptureScheduleStateActive,
 				IntervalMin: 10,
 				CreatedAt:   now,
 				UpdatedAt:   now,
 			},
 			{
 				ID:          "schedule-1",
-				Active:      true,
+				State:       datamodel.CaptureScheduleStateActive,
 				IntervalMin: 5,
 				CreatedAt:   now,
 				UpdatedAt:   now,
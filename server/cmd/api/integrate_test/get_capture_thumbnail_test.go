@@ -0,0 +1,73 @@
+package integratetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCaptureThumbnailIntegrate(t *testing.T) {
+	t.Run("GET /captures/:id/thumbnail は capture が存在しない場合 404 Not Found を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+
+		// Act
+		req := httptest.NewRequest(http.MethodGet, "/captures/not-exist/thumbnail", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		typedResponse := responseCodedError{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, "NOT_FOUND", typedResponse.Code)
+	})
+
+	t.Run("GET /captures/:id/thumbnail はサムネイルの JPEG を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(640, 360)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		capture := postScreenshot(t, mux, map[string]string{}, pngImage)
+
+		// Act
+		req := httptest.NewRequest(http.MethodGet, "/captures/"+capture.ID+"/thumbnail", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/jpeg", rec.Header().Get("Content-Type"))
+		thumbnail, format, err := image.Decode(bytes.NewReader(rec.Body.Bytes()))
+		assert.NoError(t, err)
+		assert.Equal(t, "jpeg", format)
+		// アスペクト比を保って幅320pxに縮小されている
+		assert.Equal(t, 320, thumbnail.Bounds().Dx())
+		assert.Equal(t, 180, thumbnail.Bounds().Dy())
+		// 左半分が赤、右半分が青のまま縮小されている
+		r, _, b, _ := thumbnail.At(40, 90).RGBA()
+		assert.Greater(t, r, b)
+		r, _, b, _ = thumbnail.At(280, 90).RGBA()
+		assert.Greater(t, b, r)
+	})
+}
@@ -0,0 +1,160 @@
+package integratetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseCaptures struct {
+	Captures []responseCaptureUnit `json:"captures"`
+	Limit    int                   `json:"limit"`
+	Offset   int                   `json:"offset"`
+}
+
+type responseCaptureDisplayUnit struct {
+	DisplayID       *string `json:"display_id"`
+	CaptureCount    int     `json:"capture_count"`
+	FirstCapturedAt string  `json:"first_captured_at"`
+	LastCapturedAt  string  `json:"last_captured_at"`
+}
+
+type responseCaptureDisplays struct {
+	Displays []responseCaptureDisplayUnit `json:"displays"`
+}
+
+func TestGetCapturesIntegrate(t *testing.T) {
+	// display-1に2件、display-2に1件、ディスプレイ不明で1件のキャプチャを登録する
+	setup := func(t *testing.T) http.Handler {
+		t.Helper()
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		t.Cleanup(func() { AfterEach(db) })
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		postScreenshot(t, mux, map[string]string{"mode": "manual", "captured_at": "2025-11-17T09:00:00+09:00", "display_id": "display-1"}, pngImage)
+		postScreenshot(t, mux, map[string]string{"mode": "manual", "captured_at": "2025-11-17T09:10:00+09:00", "display_id": "display-2"}, pngImage)
+		postScreenshot(t, mux, map[string]string{"mode": "manual", "captured_at": "2025-11-17T09:20:00+09:00", "display_id": "display-1"}, pngImage)
+		postScreenshot(t, mux, map[string]string{"mode": "manual", "captured_at": "2025-11-17T09:30:00+09:00"}, pngImage)
+		return mux
+	}
+	get := func(t *testing.T, mux http.Handler, target string, response any) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), response); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+		}
+		return rec.Code
+	}
+	capturedAts := func(captures []responseCaptureUnit) []string {
+		results := make([]string, 0, len(captures))
+		for _, c := range captures {
+			results = append(results, c.CapturedAt)
+		}
+		return results
+	}
+	stringPtr := func(s string) *string { return &s }
+
+	t.Run("POST /capture/screenshot は撮影したディスプレイを保存する", func(t *testing.T) {
+		// Arrange
+		mux := setup(t)
+
+		// Act
+		response := responseCaptures{}
+		code := get(t, mux, "/captures", &response)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, code)
+		if assert.Len(t, response.Captures, 4) {
+			assert.Nil(t, response.Captures[0].DisplayID)
+			assert.Equal(t, stringPtr("display-1"), response.Captures[1].DisplayID)
+			assert.Equal(t, stringPtr("display-2"), response.Captures[2].DisplayID)
+			assert.Equal(t, stringPtr("display-1"), response.Captures[3].DisplayID)
+		}
+		assert.Equal(t, 50, response.Limit)
+		assert.Equal(t, 0, response.Offset)
+	})
+
+	t.Run("GET /captures はディスプレイで絞り込める", func(t *testing.T) {
+		cases := []struct {
+			name     string
+			query    string
+			expected []string
+		}{
+			{"display_id", "?display_id=display-1", []string{"2025-11-17T09:20:00+09:00", "2025-11-17T09:00:00+09:00"}},
+			{"unknown_display", "?unknown_display=true", []string{"2025-11-17T09:30:00+09:00"}},
+			{"該当なし", "?display_id=display-3", []string{}},
+			{"ページング", "?display_id=display-1&limit=1&offset=1", []string{"2025-11-17T09:00:00+09:00"}},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				// Arrange
+				mux := setup(t)
+
+				// Act
+				response := responseCaptures{}
+				code := get(t, mux, "/captures"+tc.query, &response)
+
+				// Assert
+				assert.Equal(t, http.StatusOK, code)
+				assert.Equal(t, tc.expected, capturedAts(response.Captures))
+			})
+		}
+	})
+
+	t.Run("GET /captures は不正なクエリに400を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+
+		for _, query := range []string{
+			"?display_id=",
+			"?unknown_display=yes",
+			"?display_id=display-1&unknown_display=true",
+			"?limit=0",
+		} {
+			// Act
+			code := get(t, mux, "/captures"+query, &responseCaptures{})
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, code, query)
+		}
+	})
+
+	t.Run("GET /captures/displays はディスプレイごとの件数と撮影期間を返す", func(t *testing.T) {
+		// Arrange
+		mux := setup(t)
+
+		// Act
+		response := responseCaptureDisplays{}
+		code := get(t, mux, "/captures/displays", &response)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []responseCaptureDisplayUnit{
+			{DisplayID: nil, CaptureCount: 1, FirstCapturedAt: "2025-11-17T09:30:00+09:00", LastCapturedAt: "2025-11-17T09:30:00+09:00"},
+			{DisplayID: stringPtr("display-1"), CaptureCount: 2, FirstCapturedAt: "2025-11-17T09:00:00+09:00", LastCapturedAt: "2025-11-17T09:20:00+09:00"},
+			{DisplayID: stringPtr("display-2"), CaptureCount: 1, FirstCapturedAt: "2025-11-17T09:10:00+09:00", LastCapturedAt: "2025-11-17T09:10:00+09:00"},
+		}, response.Displays)
+	})
+}
@@ -21,7 +21,7 @@ func TestGetGoalsIntegrate(t *testing.T) {
 			t.Fatalf("failed to set up test: %v", err)
 		}
 		defer AfterEach(db)
-		mux := setuphandlers.SetupHandlers(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
 
 		// Act
 		req := httptest.NewRequest(http.MethodGet, "/goal", nil)
@@ -45,7 +45,7 @@ func TestGetGoalsIntegrate(t *testing.T) {
 			t.Fatalf("failed to set up test: %v", err)
 		}
 		defer AfterEach(db)
-		mux := setuphandlers.SetupHandlers(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
 
 		// Act
 		req := httptest.NewRequest(http.MethodGet, "/goal?status=invalid", nil)
@@ -63,7 +63,7 @@ func TestGetGoalsIntegrate(t *testing.T) {
 			t.Fatalf("failed to set up test: %v", err)
 		}
 		defer AfterEach(db)
-		mux := setuphandlers.SetupHandlers(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
 		timezone := GetJSTTimezone()
 		createdAt := time.Date(2025, 10, 1, 0, 0, 0, 0, timezone)
 		updatedAt := time.Date(2025, 10, 2, 0, 0, 0, 0, timezone)
@@ -0,0 +1,188 @@
+package integratetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseLLMPromptSection struct {
+	Name     string `json:"name"`
+	Included int    `json:"included"`
+	Omitted  int    `json:"omitted"`
+	Tokens   int    `json:"tokens"`
+}
+
+type responseLLMPromptMessage struct {
+	Role       string  `json:"role"`
+	Content    string  `json:"content"`
+	ToolCallID *string `json:"tool_call_id"`
+}
+
+type responseLLMPrompt struct {
+	Prompt struct {
+		Text     string                     `json:"text"`
+		Tokens   int                        `json:"tokens"`
+		Budget   int                        `json:"budget"`
+		Sections []responseLLMPromptSection `json:"sections"`
+		// テンプレートの名前→バージョン
+		TemplateVersions map[string]int `json:"template_versions"`
+	} `json:"prompt"`
+	Messages []responseLLMPromptMessage `json:"messages"`
+}
+
+func TestGetLLMPromptIntegrate(t *testing.T) {
+	t.Run("GET /llm/prompt は現在の目標・タスクを含むシステムプロンプトを返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		now := time.Now().In(GetJSTTimezone())
+		today := now.Format(time.DateOnly)
+		queries := []string{
+			"INSERT INTO goals (id, status, title, description, start_date, end_date, kpi_name, kpi_target, kpi_unit) VALUES ('goal-1', 'active', '資格を取る', '', '2025-11-01', '2099-12-31', '勉強時間', 40, '時間')",
+			"INSERT INTO goals (id, status, title, description, start_date, end_date) VALUES ('goal-2', 'done', '終わった目標', '', '2025-10-01', '2025-10-31')",
+			"INSERT INTO kpi_entries (id, goal_id, value, recorded_on, created_at) VALUES ('entry-1', 'goal-1', 12.5, '2025-11-02', CURRENT_TIMESTAMP)",
+			"INSERT INTO tasks (id, title, status) VALUES ('task-doing', '設計書を書く', 'doing')",
+			"INSERT INTO tasks (id, title, status, due) VALUES ('task-overdue', '経費精算', 'todo', '" + now.AddDate(0, 0, -2).Format(time.DateOnly) + "')",
+			"INSERT INTO tasks (id, title, status, due) VALUES ('task-today', 'レビューする', 'paused', '" + today + "')",
+			"INSERT INTO tasks (id, title, status, due) VALUES ('task-done', '終わったタスク', 'done', '" + today + "')",
+		}
+		for _, query := range queries {
+			if _, err := db.Exec(query); err != nil {
+				t.Fatalf("failed to insert: %v", err)
+			}
+		}
+		cfg := GetTestConfig(t)
+		cfg.LLMPromptTokenBudget = 1024
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+
+		// Act
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/llm/prompt", nil))
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		response := responseLLMPrompt{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		text := response.Prompt.Text
+		assert.Contains(t, text, "現在日時: "+today)
+		assert.Contains(t, text, "## 作業中のタスク\n- [task-doing] 設計書を書く（状態: doing")
+		assert.Contains(t, text, "## 期日を過ぎたタスク\n- [task-overdue] 経費精算（状態: todo")
+		assert.Contains(t, text, "## 今日が期日のタスク\n- [task-today] レビューする（状態: paused")
+		assert.Contains(t, text, "## 進行中の目標\n- [goal-1] 資格を取る（期間: 2025-11-01〜2099-12-31、KPI: 勉強時間 12.5/40時間）")
+		assert.Contains(t, text, "## 画面キャプチャのスケジュール\n- なし")
+		assert.NotContains(t, text, "終わったタスク")
+		assert.NotContains(t, text, "終わった目標")
+		assert.Equal(t, 1024, response.Prompt.Budget)
+		assert.LessOrEqual(t, response.Prompt.Tokens, response.Prompt.Budget)
+		assert.Equal(t, []string{"instructions", "doing_task", "overdue_tasks", "today_tasks", "goals", "capture_schedule"}, func() []string {
+			names := []string{}
+			for _, section := range response.Prompt.Sections {
+				names = append(names, section.Name)
+			}
+			return names
+		}())
+		assert.Equal(t, []responseLLMPromptMessage{{Role: "system", Content: text}}, response.Messages)
+	})
+
+	t.Run("GET /llm/prompt は予算に収まらない項目を省く", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		due := time.Now().In(GetJSTTimezone()).AddDate(0, 0, -1).Format(time.DateOnly)
+		for _, id := range []string{"task-1", "task-2", "task-3"} {
+			if _, err := db.Exec("INSERT INTO tasks (id, title, status, due) VALUES (?, '期日を過ぎたタスク', 'todo', ?)", id, due); err != nil {
+				t.Fatalf("failed to insert task: %v", err)
+			}
+		}
+		cfg := GetTestConfig(t)
+		// 指示と作業中のタスク、期日を過ぎたタスク1件分
+		cfg.LLMPromptTokenBudget = 220
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+
+		// Act
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/llm/prompt", nil))
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		response := responseLLMPrompt{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.LessOrEqual(t, response.Prompt.Tokens, 220)
+		if assert.Len(t, response.Prompt.Sections, 6) {
+			overdue := response.Prompt.Sections[2]
+			assert.Equal(t, "overdue_tasks", overdue.Name)
+			assert.Equal(t, 1, overdue.Included)
+			assert.Equal(t, 2, overdue.Omitted)
+		}
+		assert.Contains(t, response.Prompt.Text, "- ほか2件\n")
+	})
+
+	t.Run("GET /llm/prompt は conversation_id の会話の履歴をシステムプロンプトの後に続けて返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{{Chunks: []string{"はい"}}})
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		conversationID, _ := postLLMChat(t, server.URL, `{"messages": [{"role": "user", "content": "こんにちは"}]}`)
+
+		// Act
+		res, err := http.Get(server.URL + "/llm/prompt?conversation_id=" + conversationID)
+		if err != nil {
+			t.Fatalf("failed to get: %v", err)
+		}
+		defer res.Body.Close()
+
+		// Assert
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		response := responseLLMPrompt{}
+		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		assert.Equal(t, []responseLLMPromptMessage{
+			{Role: "system", Content: response.Prompt.Text},
+			{Role: "user", Content: "こんにちは"},
+			{Role: "assistant", Content: "はい"},
+		}, response.Messages)
+	})
+
+	t.Run("GET /llm/prompt は存在しない conversation_id に 404 を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+
+		// Act
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/llm/prompt?conversation_id=unknown", nil))
+
+		// Assert
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"code": "NOT_FOUND", "message": "Conversation not found"}`, rec.Body.String())
+	})
+}
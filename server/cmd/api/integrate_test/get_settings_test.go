@@ -0,0 +1,49 @@
+package integratetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSettingsIntegrate(t *testing.T) {
+	t.Run("GET /settings はデフォルトの設定を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+
+		// Act
+		req := httptest.NewRequest(http.MethodGet, "/settings", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", strings.ToLower(rec.Header().Get("Content-Type")))
+		response, err := GetResponseBodyJson(rec)
+		assert.NoError(t, err)
+		expected, _ := json.Marshal(map[string]interface{}{
+			"settings": map[string]interface{}{
+				"capture_storage_path": cfg.CaptureStoragePath,
+				"thumbnail_resolution": 320,
+				"retention_max_items":  100,
+				"retention_max_days":   30,
+				"retention_max_bytes":  nil,
+				"dedup_mode":           "mark",
+				"dedup_threshold":      4,
+				"mask_rules":           []interface{}{},
+			},
+		})
+		assert.JSONEq(t, string(expected), response)
+	})
+}
@@ -0,0 +1,227 @@
+package integratetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTimelineIntegrate(t *testing.T) {
+	t.Run("GET /timeline は解析済みのキャプチャをまとめたブロックと、doingだったタスクのセッションを返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		if _, err := db.Exec("UPDATE settings SET dedup_mode = 'off' WHERE id = 1"); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		first := postScheduledScreenshot(t, mux, "2025-11-17T09:00:00+09:00", pngImage)
+		second := postScheduledScreenshot(t, mux, "2025-11-17T09:05:00+09:00", pngImage)
+		third := postScheduledScreenshot(t, mux, "2025-11-17T09:10:00+09:00", pngImage)
+		// 別の日のキャプチャは含めない
+		postScheduledScreenshot(t, mux, "2025-11-18T09:00:00+09:00", pngImage)
+		for _, c := range []responseCapture{first, second, third} {
+			waitCaptureAnalysis(t, mux, c.Capture.ID)
+		}
+		// ステータスの変更からセッションが記録されることを確認した上で、期間を固定する
+		if _, err := db.Exec("INSERT INTO tasks (id, title, status) VALUES ('task-1', '設計書を書く', 'doing')"); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		if _, err := db.Exec("UPDATE tasks SET status = 'done' WHERE id = 'task-1'"); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		result, err := db.Exec("UPDATE task_sessions SET started_at = '2025-11-17 00:00:00+00:00', ended_at = '2025-11-17 00:07:00+00:00' WHERE task_id = 'task-1' AND ended_at IS NOT NULL")
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		if affected, _ := result.RowsAffected(); affected != 1 {
+			t.Fatalf("expected 1 task session, got %d", affected)
+		}
+		var sessionID string
+		if err := db.QueryRow("SELECT id FROM task_sessions WHERE task_id = 'task-1'").Scan(&sessionID); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/timeline?date=2025-11-17", nil)
+		rec := httptest.NewRecorder()
+
+		// Act
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		expected, _ := json.Marshal(map[string]interface{}{
+			"date": "2025-11-17",
+			"blocks": []map[string]interface{}{
+				{
+					"start":        "2025-11-17T09:00:00+09:00",
+					"end":          "2025-11-17T09:10:00+09:00",
+					"duration_sec": 600,
+					"category":     "other",
+					"summary":      "2025-11-17 09:00 に撮影された 64x48 のスクリーンショット（定期キャプチャ）",
+					"task":         map[string]interface{}{"id": "task-1", "title": "設計書を書く"},
+					"capture_ids":  []string{first.Capture.ID, second.Capture.ID},
+				},
+				{
+					"start":        "2025-11-17T09:10:00+09:00",
+					"end":          "2025-11-17T09:25:00+09:00",
+					"duration_sec": 900,
+					"category":     "other",
+					"summary":      "2025-11-17 09:10 に撮影された 64x48 のスクリーンショット（定期キャプチャ）",
+					"task":         nil,
+					"capture_ids":  []string{third.Capture.ID},
+				},
+			},
+			"sessions": []map[string]interface{}{
+				{
+					"id":           sessionID,
+					"task_id":      "task-1",
+					"task_title":   "設計書を書く",
+					"start":        "2025-11-17T09:00:00+09:00",
+					"end":          "2025-11-17T09:07:00+09:00",
+					"duration_sec": 420,
+					"ongoing":      false,
+				},
+			},
+			"category_totals": map[string]interface{}{
+				"other": 1500,
+			},
+		})
+		response, err := GetResponseBodyJson(rec)
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(expected), response)
+	})
+
+	t.Run("GET /timeline はLLMが解析したカテゴリごとにブロックを分け、カテゴリ別の合計を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		if _, err := db.Exec("UPDATE settings SET dedup_mode = 'off' WHERE id = 1"); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		cfg := GetTestConfig(t)
+		cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{
+			{Chunks: []string{`{"category": "work", "summary": "設計書を書いている", "suggestions": []}`}},
+			{Chunks: []string{`{"category": "work", "summary": "設計書をレビューしている", "suggestions": []}`}},
+			{Chunks: []string{`{"category": "communication", "summary": "チャットに返信している", "suggestions": []}`}},
+		})
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		// 応答の順に解析されるよう、1件ずつ解析を待つ
+		captures := []responseCapture{}
+		for _, capturedAt := range []string{"2025-11-17T09:00:00+09:00", "2025-11-17T09:05:00+09:00", "2025-11-17T09:10:00+09:00"} {
+			c := postScheduledScreenshot(t, mux, capturedAt, pngImage)
+			waitCaptureAnalysis(t, mux, c.Capture.ID)
+			captures = append(captures, c)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/timeline?date=2025-11-17", nil)
+		rec := httptest.NewRecorder()
+
+		// Act
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		expected, _ := json.Marshal(map[string]interface{}{
+			"date": "2025-11-17",
+			"blocks": []map[string]interface{}{
+				{
+					"start":        "2025-11-17T09:00:00+09:00",
+					"end":          "2025-11-17T09:10:00+09:00",
+					"duration_sec": 600,
+					"category":     "work",
+					"summary":      "設計書を書いている",
+					"task":         nil,
+					"capture_ids":  []string{captures[0].Capture.ID, captures[1].Capture.ID},
+				},
+				{
+					"start":        "2025-11-17T09:10:00+09:00",
+					"end":          "2025-11-17T09:25:00+09:00",
+					"duration_sec": 900,
+					"category":     "communication",
+					"summary":      "チャットに返信している",
+					"task":         nil,
+					"capture_ids":  []string{captures[2].Capture.ID},
+				},
+			},
+			"sessions": []map[string]interface{}{},
+			"category_totals": map[string]interface{}{
+				"work":          600,
+				"communication": 900,
+			},
+		})
+		response, err := GetResponseBodyJson(rec)
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(expected), response)
+	})
+
+	t.Run("GET /timeline は記録がない日の場合、空のタイムラインを返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		req := httptest.NewRequest(http.MethodGet, "/timeline?date=2025-11-17", nil)
+		rec := httptest.NewRecorder()
+
+		// Act
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		response, err := GetResponseBodyJson(rec)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"date": "2025-11-17", "blocks": [], "sessions": [], "category_totals": {}}`, response)
+	})
+
+	t.Run("GET /timeline は date が不正な場合 400 Bad Request を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+
+		for _, date := range []string{"2025-11-17T00:00:00Z", "20251117", "2025-13-01"} {
+			req := httptest.NewRequest(http.MethodGet, "/timeline?date="+date, nil)
+			rec := httptest.NewRecorder()
+
+			// Act
+			mux.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, rec.Code, date)
+			typedResponse := responseCodedError{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			assert.Equal(t, "INVALID_REQUEST", typedResponse.Code, date)
+		}
+	})
+}
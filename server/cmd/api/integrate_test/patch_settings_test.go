@@ -0,0 +1,263 @@
+package integratetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseSettingsInvalidParameterValidation struct {
+	Message string `json:"message" validate:"required,eq=invalid parameter"`
+	Target  string `json:"target" validate:"required,oneof=thumbnail_resolution retention_max_items retention_max_days retention_max_bytes dedup_mode dedup_threshold mask_rules"`
+}
+
+func TestPatchSettingsIntegrate(t *testing.T) {
+	t.Run("PATCH /settings はリクエストパラメータが不正な場合 400 Bad Request を返す", func(t *testing.T) {
+		// Arrange
+		// リクエストパターン:
+		// - thumbnail_resolution が非整数
+		// - thumbnail_resolution が文字列
+		// - thumbnail_resolution が下限未満
+		// - thumbnail_resolution が上限超過
+		// - thumbnail_resolution が null
+		// - retention_max_items が非整数
+		// - retention_max_days が0
+		// - retention_max_bytes が負数
+		// - dedup_mode が不正
+		// - dedup_mode が null
+		// - dedup_threshold が上限超過
+		// - dedup_threshold が null
+		// - mask_rules が null
+		// - mask_rules の mode が不正
+		// - mask_rules の width が0
+		// - mask_rules の x が負数
+		// - mask_rules の y が欠落
+		// - mask_rules の display_id が空白のみ
+		requests := []map[string]interface{}{
+			{"thumbnail_resolution": 320.5},
+			{"thumbnail_resolution": "320"},
+			{"thumbnail_resolution": 15},
+			{"thumbnail_resolution": 3841},
+			{"thumbnail_resolution": nil},
+			{"retention_max_items": 1.5},
+			{"retention_max_days": 0},
+			{"retention_max_bytes": -1},
+			{"dedup_mode": "skip"},
+			{"dedup_mode": nil},
+			{"dedup_threshold": 65},
+			{"dedup_threshold": nil},
+			{"mask_rules": nil},
+			{"mask_rules": []interface{}{map[string]interface{}{"x": 0, "y": 0, "width": 10, "height": 10, "mode": "erase"}}},
+			{"mask_rules": []interface{}{map[string]interface{}{"x": 0, "y": 0, "width": 0, "height": 10, "mode": "blur"}}},
+			{"mask_rules": []interface{}{map[string]interface{}{"x": -1, "y": 0, "width": 10, "height": 10, "mode": "blur"}}},
+			{"mask_rules": []interface{}{map[string]interface{}{"x": 0, "width": 10, "height": 10, "mode": "blur"}}},
+			{"mask_rules": []interface{}{map[string]interface{}{"display_id": " ", "x": 0, "y": 0, "width": 10, "height": 10, "mode": "blur"}}},
+		}
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		validator := utils.GetValidator()
+
+		for i, request := range requests {
+			t.Logf("request %d: %v", i, request)
+			// Act
+			body, _ := json.Marshal(request)
+			req := httptest.NewRequest(http.MethodPatch, "/settings", bytes.NewBuffer(body))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, "application/json", strings.ToLower(rec.Header().Get("Content-Type")))
+			typedResponse := responseSettingsInvalidParameterValidation{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if err := validator.Struct(typedResponse); err != nil {
+				t.Fatalf("failed to validate response: %v", err)
+			}
+		}
+	})
+
+	t.Run("PATCH /settings は設定を更新し、解像度が変わった場合は既存のサムネイルを再生成する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		pngImage, err := NewPNG(800, 600)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		capture := postScreenshot(t, mux, map[string]string{}, pngImage)
+
+		// Act
+		req := httptest.NewRequest(http.MethodPatch, "/settings", bytes.NewBuffer([]byte(`{"thumbnail_resolution": 160}`)))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		expected, _ := json.Marshal(map[string]interface{}{
+			"settings": map[string]interface{}{
+				"capture_storage_path": cfg.CaptureStoragePath,
+				"thumbnail_resolution": 160,
+				"retention_max_items":  100,
+				"retention_max_days":   30,
+				"retention_max_bytes":  nil,
+				"dedup_mode":           "mark",
+				"dedup_threshold":      4,
+				"mask_rules":           []interface{}{},
+			},
+		})
+		assert.JSONEq(t, string(expected), rec.Body.String())
+		reqGet := httptest.NewRequest(http.MethodGet, "/settings", nil)
+		recGet := httptest.NewRecorder()
+		mux.ServeHTTP(recGet, reqGet)
+		assert.JSONEq(t, string(expected), recGet.Body.String())
+
+		// バックグラウンドでサムネイルが新しい解像度で再生成される
+		assert.Eventually(t, func() bool {
+			var thumbResolution int
+			if err := db.QueryRow("SELECT thumb_resolution FROM captures WHERE id = ?", capture.ID).Scan(&thumbResolution); err != nil {
+				return false
+			}
+			return thumbResolution == 160
+		}, 5*time.Second, 50*time.Millisecond)
+		thumbnail, err := os.ReadFile(filepath.Join(cfg.CaptureStoragePath, *capture.ThumbPath))
+		assert.NoError(t, err)
+		config, _, err := image.DecodeConfig(bytes.NewReader(thumbnail))
+		assert.NoError(t, err)
+		assert.Equal(t, 160, config.Width)
+		assert.Equal(t, 120, config.Height)
+	})
+	t.Run("PATCH /settings は保持ポリシーを更新し、null で無制限にする", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		type testCase struct {
+			request  string
+			expected map[string]interface{}
+		}
+		// 指定しなかった項目は変更されない
+		testCases := []testCase{
+			{
+				request: `{"retention_max_items": 10, "retention_max_bytes": 1048576}`,
+				expected: map[string]interface{}{
+					"capture_storage_path": cfg.CaptureStoragePath,
+					"thumbnail_resolution": 320,
+					"retention_max_items":  10,
+					"retention_max_days":   30,
+					"retention_max_bytes":  1048576,
+					"dedup_mode":           "mark",
+					"dedup_threshold":      4,
+					"mask_rules":           []interface{}{},
+				},
+			},
+			{
+				request: `{"retention_max_days": null}`,
+				expected: map[string]interface{}{
+					"capture_storage_path": cfg.CaptureStoragePath,
+					"thumbnail_resolution": 320,
+					"retention_max_items":  10,
+					"retention_max_days":   nil,
+					"retention_max_bytes":  1048576,
+					"dedup_mode":           "mark",
+					"dedup_threshold":      4,
+					"mask_rules":           []interface{}{},
+				},
+			},
+		}
+
+		for i, testCase := range testCases {
+			t.Logf("request %d: %s", i, testCase.request)
+			// Act
+			req := httptest.NewRequest(http.MethodPatch, "/settings", bytes.NewBuffer([]byte(testCase.request)))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, http.StatusOK, rec.Code)
+			expected, _ := json.Marshal(map[string]interface{}{"settings": testCase.expected})
+			assert.JSONEq(t, string(expected), rec.Body.String())
+		}
+	})
+	t.Run("PATCH /settings はマスクルールを更新し、空配列で全て削除する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		type testCase struct {
+			request  string
+			expected []interface{}
+		}
+		testCases := []testCase{
+			{
+				request: `{"mask_rules": [{"x": 0, "y": 0, "width": 200, "height": 40, "mode": "blur"}, {"display_id": "display-2", "x": 10, "y": 20, "width": 30, "height": 40, "mode": "pixelate"}]}`,
+				expected: []interface{}{
+					map[string]interface{}{"display_id": nil, "x": 0, "y": 0, "width": 200, "height": 40, "mode": "blur"},
+					map[string]interface{}{"display_id": "display-2", "x": 10, "y": 20, "width": 30, "height": 40, "mode": "pixelate"},
+				},
+			},
+			// 指定しなかった場合は変更されない
+			{
+				request: `{"dedup_threshold": 8}`,
+				expected: []interface{}{
+					map[string]interface{}{"display_id": nil, "x": 0, "y": 0, "width": 200, "height": 40, "mode": "blur"},
+					map[string]interface{}{"display_id": "display-2", "x": 10, "y": 20, "width": 30, "height": 40, "mode": "pixelate"},
+				},
+			},
+			{
+				request:  `{"mask_rules": []}`,
+				expected: []interface{}{},
+			},
+		}
+
+		for i, testCase := range testCases {
+			t.Logf("request %d: %s", i, testCase.request)
+			// Act
+			req := httptest.NewRequest(http.MethodPatch, "/settings", bytes.NewBuffer([]byte(testCase.request)))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, http.StatusOK, rec.Code)
+			var response struct {
+				Settings struct {
+					MaskRules []interface{} `json:"mask_rules"`
+				} `json:"settings"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			expected, _ := json.Marshal(testCase.expected)
+			actual, _ := json.Marshal(response.Settings.MaskRules)
+			assert.JSONEq(t, string(expected), string(actual))
+		}
+	})
+}
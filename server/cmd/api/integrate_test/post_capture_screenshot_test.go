@@ -0,0 +1,295 @@
+package integratetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseCodedError struct {
+	Code    string `json:"code" validate:"required"`
+	Message string `json:"message" validate:"required"`
+}
+
+type responseCaptureUnit struct {
+	ID              string  `json:"id"`
+	Path            string  `json:"path"`
+	Format          string  `json:"format"`
+	Width           int     `json:"width"`
+	Height          int     `json:"height"`
+	SizeBytes       int64   `json:"size_bytes"`
+	SHA256          string  `json:"sha256"`
+	Mode            string  `json:"mode"`
+	CapturedAt      string  `json:"captured_at"`
+	ThumbPath       *string `json:"thumb_path"`
+	ThumbResolution *int    `json:"thumb_resolution"`
+	PHash           *string `json:"phash"`
+	DuplicateOf     *string `json:"duplicate_of"`
+	DuplicateCount  int     `json:"duplicate_count"`
+	LastDuplicateAt *string `json:"last_duplicate_at"`
+	LinkedTaskID    *string `json:"linked_task_id"`
+	LinkedGoalID    *string `json:"linked_goal_id"`
+	DisplayID       *string `json:"display_id"`
+	CreatedAt       string  `json:"created_at"`
+	UpdatedAt       string  `json:"updated_at"`
+}
+
+type responseCapture struct {
+	Capture responseCaptureUnit `json:"capture"`
+	Dropped bool                `json:"dropped"`
+	// 解析ジョブ。重複として解析しない場合はnil
+	AnalysisJob *responseCaptureAnalysisJob `json:"analysis_job"`
+}
+
+func TestPostCaptureScreenshotIntegrate(t *testing.T) {
+	t.Run("POST /capture/screenshot はリクエストが不正な場合 400 Bad Request を返す", func(t *testing.T) {
+		// Arrange
+		// リクエストパターン:
+		// - image が欠如
+		// - image が PNG/JPEG 以外
+		// - image の拡張子だけ PNG でデータが壊れている
+		// - mode が manual|scheduled 以外
+		// - captured_at が RFC3339 でない
+		// - display_id が64文字超
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		validator := utils.GetValidator()
+		pngImage, err := NewPNG(4, 4)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		type badRequest struct {
+			fields map[string]string
+			image  []byte
+		}
+		badRequests := []badRequest{
+			{fields: map[string]string{}, image: nil},
+			{fields: map[string]string{}, image: []byte("this is not an image")},
+			{fields: map[string]string{}, image: pngImage[:16]},
+			{fields: map[string]string{"mode": "periodic"}, image: pngImage},
+			{fields: map[string]string{"captured_at": "2025-11-06 10:00:00"}, image: pngImage},
+			{fields: map[string]string{"display_id": strings.Repeat("a", 65)}, image: pngImage},
+		}
+
+		for i, request := range badRequests {
+			t.Logf("request %d: %v", i, request.fields)
+			req, err := NewMultipartRequest("/capture/screenshot", request.fields, request.image)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			rec := httptest.NewRecorder()
+
+			// Act
+			mux.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, "application/json", strings.ToLower(rec.Header().Get("Content-Type")))
+			response, err := GetResponseBodyJson(rec)
+			assert.NoError(t, err)
+			typedResponse := responseCodedError{}
+			if err := json.Unmarshal([]byte(response), &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if err := validator.Struct(typedResponse); err != nil {
+				t.Fatalf("failed to validate response: %v", err)
+			}
+			assert.Equal(t, "INVALID_REQUEST", typedResponse.Code)
+		}
+
+		// multipart/form-data 以外のリクエスト
+		req := httptest.NewRequest(http.MethodPost, "/capture/screenshot", bytes.NewBuffer([]byte(`{"image": "..."}`)))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		// 保存先には何も書き込まれていない
+		entries, err := os.ReadDir(cfg.CaptureStoragePath)
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("POST /capture/screenshot はサイズ上限を超える場合 413 Request Entity Too Large を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		cfg.CaptureMaxUploadBytes = 1024
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		largeImage, err := NewPNG(4, 4)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		largeImage = append(largeImage, make([]byte, 2048)...)
+		req, err := NewMultipartRequest("/capture/screenshot", map[string]string{}, largeImage)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		rec := httptest.NewRecorder()
+
+		// Act
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		response, err := GetResponseBodyJson(rec)
+		assert.NoError(t, err)
+		typedResponse := responseCodedError{}
+		if err := json.Unmarshal([]byte(response), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, "PAYLOAD_TOO_LARGE", typedResponse.Code)
+	})
+
+	t.Run("POST /capture/screenshot は画像を保存し capture を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if err := DisableCaptureRetention(db); err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		cfg := GetTestConfig(t)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		pngImage, err := NewPNG(64, 48)
+		if err != nil {
+			t.Fatalf("failed to create png: %v", err)
+		}
+		jpegImage, err := NewJPEG(80, 60)
+		if err != nil {
+			t.Fatalf("failed to create jpeg: %v", err)
+		}
+		type testCase struct {
+			fields   map[string]string
+			image    []byte
+			expected responseCaptureUnit
+		}
+		testCases := []testCase{
+			{
+				fields: map[string]string{"captured_at": "2025-11-06T23:30:00Z"},
+				image:  pngImage,
+				expected: responseCaptureUnit{
+					Format:     "png",
+					Width:      64,
+					Height:     48,
+					SizeBytes:  int64(len(pngImage)),
+					Mode:       "manual",
+					CapturedAt: "2025-11-07T08:30:00+09:00",
+				},
+			},
+			{
+				fields: map[string]string{"mode": "scheduled", "captured_at": "2025-11-06T10:00:00+09:00"},
+				image:  jpegImage,
+				expected: responseCaptureUnit{
+					Format:     "jpeg",
+					Width:      80,
+					Height:     60,
+					SizeBytes:  int64(len(jpegImage)),
+					Mode:       "scheduled",
+					CapturedAt: "2025-11-06T10:00:00+09:00",
+				},
+			},
+		}
+
+		for i, testCase := range testCases {
+			t.Logf("request %d: %v", i, testCase.fields)
+			req, err := NewMultipartRequest("/capture/screenshot", testCase.fields, testCase.image)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			rec := httptest.NewRecorder()
+
+			// Act
+			mux.ServeHTTP(rec, req)
+
+			// Assert
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/json", strings.ToLower(rec.Header().Get("Content-Type")))
+			response, err := GetResponseBodyJson(rec)
+			assert.NoError(t, err)
+			typedResponse := responseCapture{}
+			if err := json.Unmarshal([]byte(response), &typedResponse); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			capture := typedResponse.Capture
+			assert.NotEmpty(t, capture.ID)
+			assert.Equal(t, testCase.expected.Format, capture.Format)
+			assert.Equal(t, testCase.expected.Width, capture.Width)
+			assert.Equal(t, testCase.expected.Height, capture.Height)
+			assert.Equal(t, testCase.expected.SizeBytes, capture.SizeBytes)
+			assert.Equal(t, testCase.expected.Mode, capture.Mode)
+			assert.Equal(t, testCase.expected.CapturedAt, capture.CapturedAt)
+			assert.Len(t, capture.SHA256, 64)
+			assert.False(t, typedResponse.Dropped)
+			if assert.NotNil(t, capture.PHash) {
+				assert.Len(t, *capture.PHash, 16)
+			}
+			assert.Nil(t, capture.DuplicateOf)
+
+			// 撮影日(JST)ごとのディレクトリに保存されている
+			assert.True(t, strings.HasPrefix(capture.Path, filepath.Join(testCase.expected.CapturedAt[0:4], testCase.expected.CapturedAt[5:7], testCase.expected.CapturedAt[8:10])))
+			saved, err := os.ReadFile(filepath.Join(cfg.CaptureStoragePath, capture.Path))
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.image, saved)
+
+			// サムネイルが元画像と同じディレクトリにデフォルト解像度(幅320px以下)で生成されている
+			if assert.NotNil(t, capture.ThumbPath) && assert.NotNil(t, capture.ThumbResolution) {
+				assert.Equal(t, filepath.Dir(capture.Path), filepath.Dir(*capture.ThumbPath))
+				assert.Equal(t, 320, *capture.ThumbResolution)
+				thumbnail, err := os.ReadFile(filepath.Join(cfg.CaptureStoragePath, *capture.ThumbPath))
+				assert.NoError(t, err)
+				config, format, err := image.DecodeConfig(bytes.NewReader(thumbnail))
+				assert.NoError(t, err)
+				assert.Equal(t, "jpeg", format)
+				assert.Equal(t, testCase.expected.Width, config.Width)
+				assert.Equal(t, testCase.expected.Height, config.Height)
+			}
+
+			// DBに記録されている
+			var count int
+			err = db.QueryRow("SELECT COUNT(*) FROM captures WHERE id = ? AND path = ? AND sha256 = ?", capture.ID, capture.Path, capture.SHA256).Scan(&count)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, count)
+		}
+	})
+}
+
+// POST /capture/screenshot でimageを送信し、作成されたcaptureを返す
+func postScreenshot(t *testing.T, mux http.Handler, fields map[string]string, image []byte) responseCaptureUnit {
+	t.Helper()
+	req, err := NewMultipartRequest("/capture/screenshot", fields, image)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("failed to post screenshot: %d %s", rec.Code, rec.Body.String())
+	}
+	typedResponse := responseCapture{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return typedResponse.Capture
+}
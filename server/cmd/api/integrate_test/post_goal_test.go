@@ -80,7 +80,7 @@ func TestPostGoalIntegrate(t *testing.T) {
 			t.Fatalf("failed to set up test: %v", err)
 		}
 		defer AfterEach(db)
-		mux := setuphandlers.SetupHandlers(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
 		validator := utils.GetValidator()
 
 		for i, request := range badRequests {
@@ -115,7 +115,7 @@ func TestPostGoalIntegrate(t *testing.T) {
 			t.Fatalf("failed to set up test: %v", err)
 		}
 		defer AfterEach(db)
-		mux := setuphandlers.SetupHandlers(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
 		validator := utils.GetValidator()
 		requestWithKpi := map[string]interface{}{
 			"title":       "title1",
@@ -197,7 +197,7 @@ func TestPostGoalIntegrate(t *testing.T) {
 			t.Fatalf("failed to set up test: %v", err)
 		}
 		defer AfterEach(db)
-		mux := setuphandlers.SetupHandlers(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
 		requestWithKpi := map[string]interface{}{
 			"title":       "title1",
 			"description": "description1",
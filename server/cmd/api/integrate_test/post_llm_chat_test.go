@@ -0,0 +1,323 @@
+package integratetest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ollamaの /api/chat へのリクエスト
+type ollamaChatRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+		// base64の画像
+		Images []string `json:"images"`
+	} `json:"messages"`
+	Stream bool `json:"stream"`
+}
+
+// contentsを1行ずつストリームするOllamaのスタブ。受け取ったリクエストをrequestsに送る
+func newOllamaStub(t *testing.T, contents []string, requests chan<- ollamaChatRequest) *httptest.Server {
+	t.Helper()
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ollamaChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode ollama request: %v", err)
+		}
+		if requests != nil {
+			requests <- request
+		}
+		for _, content := range contents {
+			fmt.Fprintf(w, `{"message":{"role":"assistant","content":%q},"done":false}`+"\n", content)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true}`)
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+// SSEのdata行を順に返す
+func readSSEData(t *testing.T, body io.Reader) []string {
+	t.Helper()
+	var data []string
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		if line, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			data = append(data, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	return data
+}
+
+// 最初の conversation イベントの会話IDと、残りのdata行を返す
+func splitConversationEvent(t *testing.T, data []string) (string, []string) {
+	t.Helper()
+	if len(data) == 0 {
+		t.Fatalf("stream has no events")
+	}
+	var event struct {
+		Type           string `json:"type"`
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := json.Unmarshal([]byte(data[0]), &event); err != nil {
+		t.Fatalf("failed to unmarshal conversation event: %v", err)
+	}
+	if event.Type != "conversation" || event.ConversationID == "" {
+		t.Fatalf("first event is not a conversation event: %s", data[0])
+	}
+	return event.ConversationID, data[1:]
+}
+
+func TestPostLLMChatIntegrate(t *testing.T) {
+	t.Run("POST /llm/chat は LLM の応答を text イベントでストリームし、[DONE] で終える", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		requests := make(chan ollamaChatRequest, 1)
+		stub := newOllamaStub(t, []string{"わかりました", "。"}, requests)
+		cfg := GetTestConfig(t)
+		cfg.LLMProvider = llm.ProviderOllama
+		cfg.LLMEndpoint = stub.URL
+		cfg.LLMModel = "test-model"
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		// Act
+		res, err := http.Post(server.URL+"/llm/chat", "application/json", bytes.NewBufferString(`{"messages": [
+			{"role": "system", "content": "あなたは時間管理アシスタントです"},
+			{"role": "user", "content": "来週水曜にレポートを提出したい"}
+		]}`))
+		if err != nil {
+			t.Fatalf("failed to post: %v", err)
+		}
+		defer res.Body.Close()
+
+		// Assert
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+		_, data := splitConversationEvent(t, readSSEData(t, res.Body))
+		assert.Equal(t, []string{
+			`{"content":"わかりました","type":"text"}`,
+			`{"content":"。","type":"text"}`,
+			"[DONE]",
+		}, data)
+		request := <-requests
+		assert.Equal(t, "test-model", request.Model)
+		assert.True(t, request.Stream)
+		// サーバーのシステムプロンプトの後に、リクエストのメッセージが続く
+		if assert.Len(t, request.Messages, 3) {
+			assert.Equal(t, "system", request.Messages[0].Role)
+			assert.Contains(t, request.Messages[0].Content, "現在日時")
+			assert.Equal(t, "system", request.Messages[1].Role)
+			assert.Equal(t, "あなたは時間管理アシスタントです", request.Messages[1].Content)
+			assert.Equal(t, "user", request.Messages[2].Role)
+			assert.Equal(t, "来週水曜にレポートを提出したい", request.Messages[2].Content)
+		}
+	})
+
+	t.Run("POST /llm/chat は LLM のエラーを error イベントで返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{{Chunks: []string{"こん"}, Error: "model unloaded"}})
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		// Act
+		res, err := http.Post(server.URL+"/llm/chat", "application/json", bytes.NewBufferString(`{"messages": [{"role": "user", "content": "こんにちは"}]}`))
+		if err != nil {
+			t.Fatalf("failed to post: %v", err)
+		}
+		defer res.Body.Close()
+
+		// Assert
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		_, data := splitConversationEvent(t, readSSEData(t, res.Body))
+		assert.Equal(t, []string{
+			`{"content":"こん","type":"text"}`,
+			`{"code":"LLM_ERROR","message":"Failed to generate response","type":"error"}`,
+		}, data)
+	})
+
+	t.Run("POST /llm/chat は応答に埋め込まれたタスクと目標を検証し、entity イベントで送る", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{{Chunks: []string{
+			"タスクです。\n```json\n{\"type\": \"task\", \"title\": \"レポ",
+			"ート提出\", \"due\": \"2025-11-05\", \"priority\": 4}\n```\n",
+			`{"type": "task", "title": "  ", "due": "来週", "priority": 9}`,
+			`{"type": "goal", "title": "英語", "description": "", "start_date": "2025-11-01", "end_date": "2025-12-31"}`,
+			`{"type": "goal", "title": "英語", "description": "", "start_date": "2025-11-01", "end_date": "2025-10-31", "status": "active"}`,
+			`{"type": "task", "title": "壊れた", }`,
+		}}})
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		// Act
+		res, err := http.Post(server.URL+"/llm/chat", "application/json", bytes.NewBufferString(`{"messages": [{"role": "user", "content": "提案して"}]}`))
+		if err != nil {
+			t.Fatalf("failed to post: %v", err)
+		}
+		defer res.Body.Close()
+
+		// Assert
+		_, data := splitConversationEvent(t, readSSEData(t, res.Body))
+		entities := []string{}
+		proposalIDs := []any{}
+		for _, line := range data {
+			if strings.Contains(line, `"type":"entity"`) {
+				var event map[string]any
+				if err := json.Unmarshal([]byte(line), &event); err != nil {
+					t.Fatalf("failed to unmarshal event: %v", err)
+				}
+				// 提案のIDはランダムなので、別に検証する
+				proposalIDs = append(proposalIDs, event["proposal_id"])
+				delete(event, "proposal_id")
+				entity, err := json.Marshal(event)
+				if err != nil {
+					t.Fatalf("failed to marshal event: %v", err)
+				}
+				entities = append(entities, string(entity))
+			}
+		}
+		assert.Equal(t, []string{
+			`{"entity":{"due":"2025-11-05","priority":4,"title":"レポート提出","type":"task"},"problems":[],"type":"entity"}`,
+			`{"entity":{"due":"来週","priority":9,"title":"  ","type":"task"},"problems":[{"field":"title","reason":"not_only_whitespaces"},{"field":"due","reason":"datetime"},{"field":"priority","reason":"max"}],"type":"entity"}`,
+			`{"entity":{"description":"","end_date":"2025-12-31","start_date":"2025-11-01","title":"英語","type":"goal"},"problems":[{"field":"status","reason":"required"}],"type":"entity"}`,
+			`{"entity":{"description":"","end_date":"2025-10-31","start_date":"2025-11-01","status":"active","title":"英語","type":"goal"},"problems":[{"field":"end_date","reason":"before_start_date"}],"type":"entity"}`,
+		}, entities)
+		if assert.Len(t, proposalIDs, 4) {
+			assert.IsType(t, "", proposalIDs[0])
+			assert.Equal(t, []any{nil, nil, nil}, proposalIDs[1:])
+		}
+		assert.Equal(t, "[DONE]", data[len(data)-1])
+	})
+
+	t.Run("POST /llm/chat はクライアントが切断すると LLM へのリクエストを中断する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		canceled := make(chan struct{})
+		stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":"考え中"},"done":false}`)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			close(canceled)
+		}))
+		defer stub.Close()
+		cfg := GetTestConfig(t)
+		cfg.LLMProvider = llm.ProviderOllama
+		cfg.LLMEndpoint = stub.URL
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/llm/chat", bytes.NewBufferString(`{"messages": [{"role": "user", "content": "こんにちは"}]}`))
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to post: %v", err)
+		}
+		defer res.Body.Close()
+		reader := bufio.NewReader(res.Body)
+		var lines []string
+		for len(lines) < 3 {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("failed to read stream: %v", err)
+			}
+			lines = append(lines, line)
+		}
+		assert.True(t, strings.HasPrefix(lines[0], `data: {"conversation_id":`))
+		assert.Equal(t, `data: {"content":"考え中","type":"text"}`+"\n", lines[2])
+
+		// Act
+		cancel()
+
+		// Assert
+		select {
+		case <-canceled:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("llm request was not canceled")
+		}
+	})
+
+	t.Run("POST /llm/chat は不正なリクエストに400を返す", func(t *testing.T) {
+		cases := []struct {
+			name     string
+			body     string
+			expected map[string]interface{}
+		}{
+			{"JSONでない", `{"messages": `, map[string]interface{}{"message": "invalid JSON format"}},
+			{"messagesがない", `{}`, map[string]interface{}{"message": "invalid parameter", "target": "messages"}},
+			{"messagesが空", `{"messages": []}`, map[string]interface{}{"message": "invalid parameter", "target": "messages"}},
+			{"roleが不正", `{"messages": [{"role": "tool", "content": "a"}]}`, map[string]interface{}{"message": "invalid parameter", "target": "messages"}},
+			{"contentが文字列でない", `{"messages": [{"role": "user", "content": 1}]}`, map[string]interface{}{"message": "invalid parameter", "target": "messages"}},
+			{"contentが空", `{"messages": [{"role": "user", "content": ""}]}`, map[string]interface{}{"message": "invalid parameter", "target": "messages"}},
+			{"最後がユーザーのメッセージでない", `{"messages": [{"role": "user", "content": "a"}, {"role": "assistant", "content": "b"}]}`, map[string]interface{}{"message": "invalid parameter", "target": "messages"}},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				// Arrange
+				db, err := BeforeEach()
+				if err != nil {
+					t.Fatalf("failed to set up test: %v", err)
+				}
+				defer AfterEach(db)
+				mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+				req := httptest.NewRequest(http.MethodPost, "/llm/chat", bytes.NewBufferString(tc.body))
+				rec := httptest.NewRecorder()
+
+				// Act
+				mux.ServeHTTP(rec, req)
+
+				// Assert
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				var response map[string]interface{}
+				if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+					t.Fatalf("failed to unmarshal response: %v", err)
+				}
+				assert.Equal(t, tc.expected, response)
+			})
+		}
+	})
+}
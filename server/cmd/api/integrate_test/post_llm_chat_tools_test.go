@@ -0,0 +1,436 @@
+package integratetest
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+// /llm/chat にbodyをPOSTし、会話IDと conversation イベント以降のdata行を返す
+func postLLMChat(t *testing.T, serverURL string, body string) (string, []string) {
+	t.Helper()
+	res, err := http.Post(serverURL+"/llm/chat", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("failed to post: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", res.StatusCode)
+	}
+	return splitConversationEvent(t, readSSEData(t, res.Body))
+}
+
+// data行のうち、typeがeventTypeのイベントを返す
+func filterLLMChatEvents(t *testing.T, data []string, eventType string) []map[string]interface{} {
+	t.Helper()
+	events := []map[string]interface{}{}
+	for _, line := range data {
+		if line == "[DONE]" {
+			continue
+		}
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("failed to unmarshal event: %v", err)
+		}
+		if event["type"] == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func countTasks(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM tasks").Scan(&count); err != nil {
+		t.Fatalf("failed to count tasks: %v", err)
+	}
+	return count
+}
+
+func TestPostLLMChatToolsIntegrate(t *testing.T) {
+	t.Run("POST /llm/chat は読み取りのツールをすぐに実行し、結果を渡して応答を続ける", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		kpiName, kpiTarget, kpiUnit := "読んだページ", 300.0, "ページ"
+		if err := InsertGoals(db, []datamodel.Goal{{
+			ID:        "goal-1",
+			Title:     "技術書を読む",
+			StartDate: time.Date(2025, 11, 1, 0, 0, 0, 0, GetJSTTimezone()),
+			EndDate:   time.Date(2025, 11, 30, 0, 0, 0, 0, GetJSTTimezone()),
+			KpiName:   &kpiName,
+			KpiTarget: &kpiTarget,
+			KpiUnit:   &kpiUnit,
+			Status:    "active",
+		}}); err != nil {
+			t.Fatalf("failed to insert goals: %v", err)
+		}
+		if _, err := db.Exec("INSERT INTO kpi_entries (id, goal_id, value, recorded_on, created_at) VALUES ('entry-1', 'goal-1', 30, '2025-11-02', CURRENT_TIMESTAMP), ('entry-2', 'goal-1', 12.5, '2025-11-03', CURRENT_TIMESTAMP)"); err != nil {
+			t.Fatalf("failed to insert kpi entries: %v", err)
+		}
+		cfg := GetTestConfig(t)
+		cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{
+			{Chunks: []string{"確認します。"}, ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "list_goals", Arguments: json.RawMessage(`{}`)}}},
+			{Chunks: []string{"42.5ページ読みました。"}},
+		})
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		// Act
+		conversationID, data := postLLMChat(t, server.URL, `{"messages": [{"role": "user", "content": "進捗は？"}]}`)
+
+		// Assert
+		assert.Equal(t, []string{
+			`{"content":"確認します。","type":"text"}`,
+			`{"arguments":{},"name":"list_goals","requires_confirmation":false,"tool_call_id":"call_1","type":"tool_call"}`,
+		}, data[:2])
+		results := filterLLMChatEvents(t, data, "tool_result")
+		if assert.Len(t, results, 1) {
+			goals := results[0]["result"].(map[string]interface{})["goals"].([]interface{})
+			if assert.Len(t, goals, 1) {
+				goal := goals[0].(map[string]interface{})
+				assert.Equal(t, "goal-1", goal["id"])
+				assert.Equal(t, 42.5, goal["kpi_progress"])
+				assert.Equal(t, 300.0, goal["kpi_target"])
+			}
+		}
+		assert.Equal(t, []string{`{"content":"42.5ページ読みました。","type":"text"}`, "[DONE]"}, data[3:])
+
+		var roles []string
+		var finishReasons []*string
+		rows, err := db.Query("SELECT role, finish_reason FROM chat_messages WHERE conversation_id = ? ORDER BY created_at, rowid", conversationID)
+		if err != nil {
+			t.Fatalf("failed to query chat messages: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var role string
+			var finishReason *string
+			if err := rows.Scan(&role, &finishReason); err != nil {
+				t.Fatalf("failed to scan chat message: %v", err)
+			}
+			roles = append(roles, role)
+			finishReasons = append(finishReasons, finishReason)
+		}
+		assert.Equal(t, []string{"user", "assistant", "tool", "assistant"}, roles)
+		if assert.Len(t, finishReasons, 4) {
+			assert.Equal(t, datamodel.ChatMessageFinishReasonToolCalls, *finishReasons[1])
+			assert.Nil(t, finishReasons[2])
+			assert.Equal(t, datamodel.ChatMessageFinishReasonCompleted, *finishReasons[3])
+		}
+	})
+
+	t.Run("POST /llm/chat は変更するツールを確認待ちにし、承認されると実行して応答を続ける", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{
+			{ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "create_task", Arguments: json.RawMessage(`{"title": "レポート提出", "due": "2025-11-05", "priority": 4}`)}}},
+			{Chunks: []string{"作成しました。"}},
+		})
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		conversationID, data := postLLMChat(t, server.URL, `{"messages": [{"role": "user", "content": "レポート提出のタスクを作って"}]}`)
+		assert.Equal(t, []string{
+			`{"arguments":{"title":"レポート提出","due":"2025-11-05","priority":4},"name":"create_task","requires_confirmation":true,"tool_call_id":"call_1","type":"tool_call"}`,
+			"[DONE]",
+		}, data)
+		assert.Equal(t, 0, countTasks(t, db))
+
+		// Act
+		_, data = postLLMChat(t, server.URL, `{"conversation_id": "`+conversationID+`", "tool_confirmations": [{"tool_call_id": "call_1", "approved": true}]}`)
+
+		// Assert
+		results := filterLLMChatEvents(t, data, "tool_result")
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, "call_1", results[0]["tool_call_id"])
+			task := results[0]["result"].(map[string]interface{})["task"].(map[string]interface{})
+			assert.Equal(t, "レポート提出", task["title"])
+			assert.Equal(t, "todo", task["status"])
+		}
+		assert.Equal(t, []string{`{"content":"作成しました。","type":"text"}`, "[DONE]"}, data[1:])
+		var title, due string
+		var priority int
+		if err := db.QueryRow("SELECT title, due, priority FROM tasks").Scan(&title, &due, &priority); err != nil {
+			t.Fatalf("failed to query task: %v", err)
+		}
+		assert.Equal(t, "レポート提出", title)
+		assert.Contains(t, due, "2025-11-05")
+		assert.Equal(t, 4, priority)
+	})
+
+	t.Run("POST /llm/chat は拒否された呼び出しを実行せず、拒否を結果として渡す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{
+			{ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "create_task", Arguments: json.RawMessage(`{"title": "レポート提出"}`)}}},
+			{Chunks: []string{"作成をやめました。"}},
+		})
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		conversationID, _ := postLLMChat(t, server.URL, `{"messages": [{"role": "user", "content": "レポート提出のタスクを作って"}]}`)
+
+		// Act
+		_, data := postLLMChat(t, server.URL, `{"conversation_id": "`+conversationID+`", "tool_confirmations": [{"tool_call_id": "call_1", "approved": false}]}`)
+
+		// Assert
+		assert.Equal(t, []string{
+			`{"name":"create_task","result":{"error":"rejected by user"},"tool_call_id":"call_1","type":"tool_result"}`,
+			`{"content":"作成をやめました。","type":"text"}`,
+			"[DONE]",
+		}, data)
+		assert.Equal(t, 0, countTasks(t, db))
+	})
+
+	t.Run("POST /llm/chat は確認せずにメッセージを送ると、確認待ちの呼び出しを拒否として記録する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{
+			{ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "update_task_status", Arguments: json.RawMessage(`{"task_id": "task-1", "status": "done"}`)}}},
+			{Chunks: []string{"わかりました。"}},
+		})
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		conversationID, _ := postLLMChat(t, server.URL, `{"messages": [{"role": "user", "content": "終わった"}]}`)
+
+		// Act
+		_, data := postLLMChat(t, server.URL, `{"conversation_id": "`+conversationID+`", "messages": [{"role": "user", "content": "やっぱりいい"}]}`)
+
+		// Assert
+		assert.Equal(t, `{"name":"update_task_status","result":{"error":"rejected by user"},"tool_call_id":"call_1","type":"tool_result"}`, data[0])
+		var roles []string
+		rows, err := db.Query("SELECT role FROM chat_messages WHERE conversation_id = ? ORDER BY created_at, rowid", conversationID)
+		if err != nil {
+			t.Fatalf("failed to query chat messages: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var role string
+			if err := rows.Scan(&role); err != nil {
+				t.Fatalf("failed to scan chat message: %v", err)
+			}
+			roles = append(roles, role)
+		}
+		assert.Equal(t, []string{"user", "assistant", "tool", "user", "assistant"}, roles)
+	})
+
+	t.Run("POST /llm/chat は引数の誤りを結果としてLLMに返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{
+			{ToolCalls: []llm.ToolCall{
+				{ID: "call_1", Name: "list_tasks", Arguments: json.RawMessage(`{"limit": 100}`)},
+				{ID: "call_2", Name: "delete_everything", Arguments: json.RawMessage(`{}`)},
+				{ID: "call_3", Name: "list_tasks", Arguments: json.RawMessage(`{"limit": 0}`)},
+			}},
+			{Chunks: []string{"失敗しました。"}},
+		})
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		// Act
+		_, data := postLLMChat(t, server.URL, `{"messages": [{"role": "user", "content": "タスクを見せて"}]}`)
+
+		// Assert
+		results := filterLLMChatEvents(t, data, "tool_result")
+		if assert.Len(t, results, 3) {
+			assert.Contains(t, results[0]["result"].(map[string]interface{})["error"], "limit")
+			assert.Equal(t, map[string]interface{}{"error": "unknown tool: delete_everything"}, results[1]["result"])
+			assert.Contains(t, results[2]["result"].(map[string]interface{})["error"], "limit: min")
+		}
+		assert.Equal(t, "[DONE]", data[len(data)-1])
+	})
+
+	t.Run("POST /llm/chat は add_kpi_entry の value が 0 でも記録し、value がない場合は引数の誤りを返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		kpiName, kpiTarget, kpiUnit := "読んだページ", 300.0, "ページ"
+		if err := InsertGoals(db, []datamodel.Goal{{
+			ID:        "goal-1",
+			Title:     "技術書を読む",
+			StartDate: time.Date(2025, 11, 1, 0, 0, 0, 0, GetJSTTimezone()),
+			EndDate:   time.Date(2025, 11, 30, 0, 0, 0, 0, GetJSTTimezone()),
+			KpiName:   &kpiName,
+			KpiTarget: &kpiTarget,
+			KpiUnit:   &kpiUnit,
+			Status:    "active",
+		}}); err != nil {
+			t.Fatalf("failed to insert goals: %v", err)
+		}
+		cfg := GetTestConfig(t)
+		cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{
+			{ToolCalls: []llm.ToolCall{
+				{ID: "call_1", Name: "add_kpi_entry", Arguments: json.RawMessage(`{"goal_id": "goal-1", "value": 0, "recorded_on": "2025-11-04"}`)},
+				{ID: "call_2", Name: "add_kpi_entry", Arguments: json.RawMessage(`{"goal_id": "goal-1"}`)},
+			}},
+			{Chunks: []string{"記録しました。"}},
+		})
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		conversationID, _ := postLLMChat(t, server.URL, `{"messages": [{"role": "user", "content": "今日は読めなかった"}]}`)
+
+		// Act
+		_, data := postLLMChat(t, server.URL, `{"conversation_id": "`+conversationID+`", "tool_confirmations": [{"tool_call_id": "call_1", "approved": true}, {"tool_call_id": "call_2", "approved": true}]}`)
+
+		// Assert
+		results := filterLLMChatEvents(t, data, "tool_result")
+		if assert.Len(t, results, 2) {
+			entry := results[0]["result"].(map[string]interface{})["kpi_entry"].(map[string]interface{})
+			assert.Equal(t, 0.0, entry["value"])
+			assert.Contains(t, results[1]["result"].(map[string]interface{})["error"], "value: required")
+		}
+		var values []float64
+		rows, err := db.Query("SELECT value FROM kpi_entries WHERE goal_id = 'goal-1'")
+		if err != nil {
+			t.Fatalf("failed to query kpi entries: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var value float64
+			if err := rows.Scan(&value); err != nil {
+				t.Fatalf("failed to scan kpi entry: %v", err)
+			}
+			values = append(values, value)
+		}
+		assert.Equal(t, []float64{0}, values)
+	})
+
+	t.Run("POST /llm/chat はツールの呼び出しが上限に達するとツールを渡さずに応答させる", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		// 最後の応答はツールを渡されるたびにlist_tasksを呼ぶ
+		cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{
+			{Chunks: []string{"確認します。"}, ToolCalls: []llm.ToolCall{{Name: "list_tasks", Arguments: json.RawMessage(`{}`)}}},
+		})
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		// Act
+		_, data := postLLMChat(t, server.URL, `{"messages": [{"role": "user", "content": "タスクを見せて"}]}`)
+
+		// Assert
+		assert.Len(t, filterLLMChatEvents(t, data, "tool_call"), 5)
+		assert.Len(t, filterLLMChatEvents(t, data, "tool_result"), 5)
+		assert.Len(t, filterLLMChatEvents(t, data, "text"), 6)
+		assert.Equal(t, "[DONE]", data[len(data)-1])
+	})
+
+	t.Run("POST /llm/chat は不正なツールの確認に400を返す", func(t *testing.T) {
+		cases := []struct {
+			name     string
+			body     func(conversationID string) string
+			expected map[string]interface{}
+		}{
+			{
+				"確認待ちでない呼び出しを確認した",
+				func(conversationID string) string {
+					return `{"conversation_id": "` + conversationID + `", "tool_confirmations": [{"tool_call_id": "call_x", "approved": true}]}`
+				},
+				map[string]interface{}{"message": "invalid parameter", "target": "tool_confirmations"},
+			},
+			{
+				"conversation_idがない",
+				func(string) string {
+					return `{"tool_confirmations": [{"tool_call_id": "call_1", "approved": true}]}`
+				},
+				map[string]interface{}{"message": "invalid parameter", "target": "conversation_id"},
+			},
+			{
+				"messagesと同時に送った",
+				func(conversationID string) string {
+					return `{"conversation_id": "` + conversationID + `", "messages": [{"role": "user", "content": "a"}], "tool_confirmations": [{"tool_call_id": "call_1", "approved": true}]}`
+				},
+				map[string]interface{}{"message": "invalid parameter", "target": "tool_confirmations"},
+			},
+			{
+				"approvedがない",
+				func(conversationID string) string {
+					return `{"conversation_id": "` + conversationID + `", "tool_confirmations": [{"tool_call_id": "call_1"}]}`
+				},
+				map[string]interface{}{"message": "invalid parameter", "target": "tool_confirmations"},
+			},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				// Arrange
+				db, err := BeforeEach()
+				if err != nil {
+					t.Fatalf("failed to set up test: %v", err)
+				}
+				defer AfterEach(db)
+				cfg := GetTestConfig(t)
+				cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{
+					{ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "create_task", Arguments: json.RawMessage(`{"title": "a"}`)}}},
+				})
+				mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+				server := httptest.NewServer(mux)
+				defer server.Close()
+				conversationID, _ := postLLMChat(t, server.URL, `{"messages": [{"role": "user", "content": "タスクを作って"}]}`)
+
+				// Act
+				res, err := http.Post(server.URL+"/llm/chat", "application/json", bytes.NewBufferString(tc.body(conversationID)))
+				if err != nil {
+					t.Fatalf("failed to post: %v", err)
+				}
+				defer res.Body.Close()
+
+				// Assert
+				assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+				var response map[string]interface{}
+				if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				assert.Equal(t, tc.expected, response)
+				assert.Equal(t, 0, countTasks(t, db))
+			})
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package integratetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/stretchr/testify/assert"
+)
+
+type responsePromptTemplateUnit struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Version   int    `json:"version"`
+	Body      string `json:"body"`
+	CreatedAt string `json:"created_at"`
+}
+
+type responsePromptTemplates struct {
+	Templates []responsePromptTemplateUnit `json:"templates"`
+}
+
+type responsePromptTemplate struct {
+	Template responsePromptTemplateUnit `json:"template"`
+}
+
+// targetをGETし、ステータスコードとレスポンスをvに読み込む
+func getPromptTemplate(t *testing.T, mux http.Handler, target string, v any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return rec.Code
+}
+
+func TestPromptTemplatesIntegrate(t *testing.T) {
+	t.Run("GET /prompt-templates は初期のテンプレートのバージョン1を名前の順に返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+
+		// Act
+		response := responsePromptTemplates{}
+		status := getPromptTemplate(t, mux, "/prompt-templates", &response)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, status)
+		names := []string{}
+		for _, template := range response.Templates {
+			names = append(names, template.Name)
+			assert.Equal(t, 1, template.Version, template.Name)
+			assert.NotEmpty(t, template.Body, template.Name)
+		}
+		assert.Equal(t, []string{"chat", "extraction", "report", "screenshot_analysis"}, names)
+	})
+
+	t.Run("POST /prompt-templates/{name}/versions は新しいバージョンを追加し、以降の応答はそのバージョンで生成して記録する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		body := `あなたは丁寧なアシスタントです。今日は{{.Weekday}}曜日です。\n{{.Extraction}}`
+
+		// Act
+		status, created := postJSON(t, mux, "/prompt-templates/chat/versions", `{"body": "`+body+`"}`)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, status)
+		createdResponse := responsePromptTemplate{}
+		if err := json.Unmarshal(created, &createdResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, "chat", createdResponse.Template.Name)
+		assert.Equal(t, 2, createdResponse.Template.Version)
+
+		latest := responsePromptTemplate{}
+		assert.Equal(t, http.StatusOK, getPromptTemplate(t, mux, "/prompt-templates/chat", &latest))
+		assert.Equal(t, createdResponse.Template, latest.Template)
+		versions := responsePromptTemplates{}
+		assert.Equal(t, http.StatusOK, getPromptTemplate(t, mux, "/prompt-templates/chat/versions", &versions))
+		if assert.Len(t, versions.Templates, 2) {
+			assert.Equal(t, []int{2, 1}, []int{versions.Templates[0].Version, versions.Templates[1].Version})
+		}
+		first := responsePromptTemplate{}
+		assert.Equal(t, http.StatusOK, getPromptTemplate(t, mux, "/prompt-templates/chat/versions/1", &first))
+		assert.Contains(t, first.Template.Body, "現在日時: ")
+
+		built := responseLLMPrompt{}
+		assert.Equal(t, http.StatusOK, getPromptTemplate(t, mux, "/llm/prompt", &built))
+		assert.True(t, strings.HasPrefix(built.Prompt.Text, "あなたは丁寧なアシスタントです。今日は"), built.Prompt.Text)
+		assert.NotContains(t, built.Prompt.Text, "現在日時: ")
+		assert.Equal(t, map[string]int{"chat": 2, "extraction": 1}, built.Prompt.TemplateVersions)
+
+		conversationID, _ := postLLMChat(t, server.URL, `{"messages": [{"role": "user", "content": "こんにちは"}]}`)
+		messages := responseConversationMessages{}
+		assert.Equal(t, http.StatusOK, getPromptTemplate(t, mux, "/conversations/"+conversationID+"/messages", &messages))
+		if assert.Len(t, messages.Messages, 2) {
+			assert.Nil(t, messages.Messages[0].PromptVersions)
+			assert.Equal(t, map[string]int{"chat": 2, "extraction": 1}, messages.Messages[1].PromptVersions)
+		}
+	})
+
+	t.Run("POST /prompt-templates/{name}/versions は描画できないテンプレートを 400 で拒否する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+
+		for _, body := range []string{`{"body": "{{.Now"}`, `{"body": "{{.Today}}"}`} {
+			// Act
+			status, response := postJSON(t, mux, "/prompt-templates/chat/versions", body)
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, status, body)
+			assert.Contains(t, string(response), `"code":"INVALID_TEMPLATE"`, body)
+		}
+		status, response := postJSON(t, mux, "/prompt-templates/chat/versions", `{"body": "  "}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.JSONEq(t, `{"code": "INVALID_REQUEST", "message": "Invalid parameter: body"}`, string(response))
+		versions := responsePromptTemplates{}
+		getPromptTemplate(t, mux, "/prompt-templates/chat/versions", &versions)
+		assert.Len(t, versions.Templates, 1)
+	})
+
+	t.Run("存在しないテンプレート・バージョンは 404 Not Found を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		notFound := map[string]interface{}{"code": "NOT_FOUND", "message": "Prompt template not found"}
+
+		for _, target := range []string{"/prompt-templates/unknown", "/prompt-templates/unknown/versions", "/prompt-templates/chat/versions/2", "/prompt-templates/chat/versions/latest"} {
+			// Act
+			response := map[string]interface{}{}
+			status := getPromptTemplate(t, mux, target, &response)
+
+			// Assert
+			assert.Equal(t, http.StatusNotFound, status, target)
+			assert.Equal(t, notFound, response, target)
+		}
+		status, response := postJSON(t, mux, "/prompt-templates/unknown/versions", `{"body": "本文"}`)
+		assert.Equal(t, http.StatusNotFound, status)
+		assert.JSONEq(t, `{"code": "NOT_FOUND", "message": "Prompt template not found"}`, string(response))
+	})
+}
@@ -0,0 +1,292 @@
+package integratetest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+type responseProposalFieldDiff struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+type responseProposalChangeDiff struct {
+	Entity string                      `json:"entity"`
+	Action string                      `json:"action"`
+	ID     string                      `json:"id"`
+	Fields []responseProposalFieldDiff `json:"fields"`
+}
+
+type responseProposalUnit struct {
+	ID             string                       `json:"id"`
+	ConversationID *string                      `json:"conversation_id"`
+	Diff           []responseProposalChangeDiff `json:"diff"`
+	Status         string                       `json:"status"`
+	ExpiresAt      string                       `json:"expires_at"`
+	ResolvedAt     *string                      `json:"resolved_at"`
+	CreatedAt      string                       `json:"created_at"`
+}
+
+type responseProposal struct {
+	Proposal responseProposalUnit `json:"proposal"`
+}
+
+type responseProposals struct {
+	Proposals []responseProposalUnit `json:"proposals"`
+	Limit     int                    `json:"limit"`
+	Offset    int                    `json:"offset"`
+}
+
+type responseProposalError struct {
+	Code string `json:"code"`
+}
+
+// targetにリクエストを送り、ステータスコードとレスポンスボディをvにデコードする
+func requestProposal(t *testing.T, method string, target string, v any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to request: %v", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatalf("failed to unmarshal body %s: %v", body, err)
+	}
+	return res.StatusCode
+}
+
+// LLMに応答させ、entityイベントの提案IDを返す
+func proposeByLLMChat(t *testing.T, db *sql.DB, entity string) (*httptest.Server, string, string) {
+	t.Helper()
+	cfg := GetTestConfig(t)
+	cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{{Chunks: []string{"提案です。\n", entity}}})
+	mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	conversationID, data := postLLMChat(t, server.URL, `{"messages": [{"role": "user", "content": "提案して"}]}`)
+	events := filterLLMChatEvents(t, data, "entity")
+	if len(events) != 1 {
+		t.Fatalf("unexpected entity events: %v", events)
+	}
+	proposalID, ok := events[0]["proposal_id"].(string)
+	if !ok {
+		t.Fatalf("proposal was not created: %v", events[0])
+	}
+	return server, conversationID, proposalID
+}
+
+func getTaskTitle(t *testing.T, db *sql.DB, id string) string {
+	t.Helper()
+	var title string
+	if err := db.QueryRow("SELECT title FROM tasks WHERE id = ?", id).Scan(&title); err != nil {
+		t.Fatalf("failed to get task: %v", err)
+	}
+	return title
+}
+
+func TestProposalsIntegrate(t *testing.T) {
+	t.Run("POST /proposals/{id}/accept は提案されたタスクを作成し、提案を accepted にする", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		server, conversationID, proposalID := proposeByLLMChat(t, db, `{"type": "task", "title": "レポート提出", "due": "2025-11-05", "priority": 4}`)
+
+		// Act
+		var listed responseProposals
+		listStatus := requestProposal(t, http.MethodGet, server.URL+"/proposals?status=pending&conversation_id="+conversationID, &listed)
+		tasksBefore := countTasks(t, db)
+		var accepted responseProposal
+		acceptStatus := requestProposal(t, http.MethodPost, server.URL+"/proposals/"+proposalID+"/accept", &accepted)
+		var again responseProposalError
+		againStatus := requestProposal(t, http.MethodPost, server.URL+"/proposals/"+proposalID+"/accept", &again)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, listStatus)
+		if assert.Len(t, listed.Proposals, 1) {
+			proposal := listed.Proposals[0]
+			assert.Equal(t, proposalID, proposal.ID)
+			assert.Equal(t, &conversationID, proposal.ConversationID)
+			assert.Equal(t, "pending", proposal.Status)
+			assert.Nil(t, proposal.ResolvedAt)
+			if assert.Len(t, proposal.Diff, 1) {
+				assert.Equal(t, "task", proposal.Diff[0].Entity)
+				assert.Equal(t, "create", proposal.Diff[0].Action)
+				assert.Contains(t, proposal.Diff[0].Fields, responseProposalFieldDiff{Field: "title", Before: nil, After: "レポート提出"})
+			}
+			createdAt, err := time.Parse(time.RFC3339, proposal.CreatedAt)
+			assert.NoError(t, err)
+			expiresAt, err := time.Parse(time.RFC3339, proposal.ExpiresAt)
+			assert.NoError(t, err)
+			assert.Equal(t, 7*24*time.Hour, expiresAt.Sub(createdAt))
+		}
+		assert.Equal(t, 0, tasksBefore)
+		assert.Equal(t, http.StatusOK, acceptStatus)
+		assert.Equal(t, "accepted", accepted.Proposal.Status)
+		assert.NotNil(t, accepted.Proposal.ResolvedAt)
+		assert.Equal(t, 1, countTasks(t, db))
+		if assert.Len(t, accepted.Proposal.Diff, 1) {
+			assert.Equal(t, "レポート提出", getTaskTitle(t, db, accepted.Proposal.Diff[0].ID))
+		}
+		assert.Equal(t, http.StatusConflict, againStatus)
+		assert.Equal(t, "INVALID_STATE", again.Code)
+	})
+
+	t.Run("POST /proposals/{id}/reject は既存のタスクの変更の提案を適用せずに rejected にする", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if _, err := db.Exec("INSERT INTO tasks (id, title, priority) VALUES ('task-1', '設計書を書く', 3)"); err != nil {
+			t.Fatalf("failed to insert task: %v", err)
+		}
+		server, _, proposalID := proposeByLLMChat(t, db, `{"type": "task", "id": "task-1", "title": "設計書を仕上げる"}`)
+
+		// Act
+		var rejected responseProposal
+		status := requestProposal(t, http.MethodPost, server.URL+"/proposals/"+proposalID+"/reject", &rejected)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "rejected", rejected.Proposal.Status)
+		assert.Equal(t, []responseProposalChangeDiff{{
+			Entity: "task",
+			Action: "update",
+			ID:     "task-1",
+			Fields: []responseProposalFieldDiff{{Field: "title", Before: "設計書を書く", After: "設計書を仕上げる"}},
+		}}, rejected.Proposal.Diff)
+		assert.Equal(t, "設計書を書く", getTaskTitle(t, db, "task-1"))
+	})
+
+	t.Run("POST /proposals/{id}/accept は提案後に対象が変更されていると409を返し、何も変更しない", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		if _, err := db.Exec("INSERT INTO tasks (id, title) VALUES ('task-1', '設計書を書く')"); err != nil {
+			t.Fatalf("failed to insert task: %v", err)
+		}
+		server, _, proposalID := proposeByLLMChat(t, db, `{"type": "task", "id": "task-1", "title": "設計書を仕上げる"}`)
+		if _, err := db.Exec("UPDATE tasks SET title = 'レビューする' WHERE id = 'task-1'"); err != nil {
+			t.Fatalf("failed to update task: %v", err)
+		}
+
+		// Act
+		var conflict responseProposalError
+		status := requestProposal(t, http.MethodPost, server.URL+"/proposals/"+proposalID+"/accept", &conflict)
+		var proposal responseProposal
+		getStatus := requestProposal(t, http.MethodGet, server.URL+"/proposals/"+proposalID, &proposal)
+
+		// Assert
+		assert.Equal(t, http.StatusConflict, status)
+		assert.Equal(t, "CONFLICT", conflict.Code)
+		assert.Equal(t, "レビューする", getTaskTitle(t, db, "task-1"))
+		assert.Equal(t, http.StatusOK, getStatus)
+		assert.Equal(t, "pending", proposal.Proposal.Status)
+	})
+
+	t.Run("GET /proposals は期限を過ぎた提案を expired にし、承認できなくする", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		server, _, proposalID := proposeByLLMChat(t, db, `{"type": "task", "title": "レポート提出"}`)
+		if _, err := db.Exec("UPDATE proposals SET expires_at = ? WHERE id = ?", time.Now().Add(-time.Minute).UTC(), proposalID); err != nil {
+			t.Fatalf("failed to update proposal: %v", err)
+		}
+
+		// Act
+		var listed responseProposals
+		listStatus := requestProposal(t, http.MethodGet, server.URL+"/proposals?status=expired", &listed)
+		var accepted responseProposalError
+		acceptStatus := requestProposal(t, http.MethodPost, server.URL+"/proposals/"+proposalID+"/accept", &accepted)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, listStatus)
+		if assert.Len(t, listed.Proposals, 1) {
+			assert.Equal(t, proposalID, listed.Proposals[0].ID)
+			assert.Equal(t, "expired", listed.Proposals[0].Status)
+			assert.NotNil(t, listed.Proposals[0].ResolvedAt)
+		}
+		assert.Equal(t, http.StatusConflict, acceptStatus)
+		assert.Equal(t, "INVALID_STATE", accepted.Code)
+		assert.Equal(t, 0, countTasks(t, db))
+	})
+
+	t.Run("DELETE /conversations/{id} は会話の提案を残し、conversation_id を null にする", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		server, conversationID, proposalID := proposeByLLMChat(t, db, `{"type": "task", "title": "レポート提出"}`)
+
+		// Act
+		var deleted map[string]interface{}
+		deleteStatus := requestProposal(t, http.MethodDelete, server.URL+"/conversations/"+conversationID, &deleted)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, deleteStatus)
+		var got responseProposal
+		assert.Equal(t, http.StatusOK, requestProposal(t, http.MethodGet, server.URL+"/proposals/"+proposalID, &got))
+		assert.Equal(t, proposalID, got.Proposal.ID)
+		assert.Nil(t, got.Proposal.ConversationID)
+		assert.Equal(t, "pending", got.Proposal.Status)
+		var messages int
+		if err := db.QueryRow("SELECT COUNT(*) FROM chat_messages WHERE conversation_id = ?", conversationID).Scan(&messages); err != nil {
+			t.Fatalf("failed to count messages: %v", err)
+		}
+		assert.Equal(t, 0, messages)
+	})
+
+	t.Run("GET /proposals・POST /proposals/{id}/accept は不正な status に400、存在しない提案に404を返す", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		// Act
+		var invalid responseProposalError
+		invalidStatus := requestProposal(t, http.MethodGet, server.URL+"/proposals?status=done", &invalid)
+		var notFound responseProposalError
+		notFoundStatus := requestProposal(t, http.MethodPost, server.URL+"/proposals/unknown/accept", &notFound)
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, invalidStatus)
+		assert.Equal(t, "INVALID_REQUEST", invalid.Code)
+		assert.Equal(t, http.StatusNotFound, notFoundStatus)
+		assert.Equal(t, "NOT_FOUND", notFound.Code)
+	})
+}
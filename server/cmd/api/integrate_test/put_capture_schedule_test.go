@@ -17,11 +17,7 @@ import (
 
 type ResponseInvalidParameterValidation struct {
 	Message string `json:"message" validate:"required,eq=invalid parameter"`
-	Target  string `json:"target" validate:"required,oneof=active interval_min"`
-}
-
-type ResponseBadRequestValidation struct {
-	Message string `json:"message" validate:"required,eq=no active capture schedule found"`
+	Target  string `json:"target" validate:"required,oneof=active interval_min time_windows excluded_dates jitter_pct capture_mode region exclude_windows"`
 }
 
 func TestPutCaptureScheduleIntegrate(t *testing.T) {
@@ -34,6 +30,19 @@ func TestPutCaptureScheduleIntegrate(t *testing.T) {
 		// - intervalMin が非整数
 		// - intervalMin が負数
 		// - intervalMin が 0
+		// - intervalMin が 1441
+		// - time_windows の weekdays が範囲外
+		// - time_windows の start が欠如
+		// - time_windows の start が "HH:MM" でない
+		// - time_windows の start が end 以降
+		// - excluded_dates が日付でない
+		// - jitter_pct が 50 を超える
+		// - jitter_pct が負数
+		// - capture_mode が不正
+		// - capture_mode が region で region が欠如
+		// - capture_mode が full で region を指定
+		// - region の width が 0
+		// - exclude_windows が文字列でない
 		requests := []map[string]interface{}{
 			{
 				"interval_min": 5,
@@ -61,13 +70,75 @@ func TestPutCaptureScheduleIntegrate(t *testing.T) {
 				"active":       true,
 				"interval_min": 1441,
 			},
+			{
+				"active":       true,
+				"interval_min": 5,
+				"time_windows": []map[string]interface{}{{"weekdays": []int{7}, "start": "09:00", "end": "18:00"}},
+			},
+			{
+				"active":       true,
+				"interval_min": 5,
+				"time_windows": []map[string]interface{}{{"end": "18:00"}},
+			},
+			{
+				"active":       true,
+				"interval_min": 5,
+				"time_windows": []map[string]interface{}{{"start": "9:00", "end": "18:00"}},
+			},
+			{
+				"active":       true,
+				"interval_min": 5,
+				"time_windows": []map[string]interface{}{{"start": "18:00", "end": "09:00"}},
+			},
+			{
+				"active":         true,
+				"interval_min":   5,
+				"excluded_dates": []string{"2025/11/10"},
+			},
+			{
+				"active":       true,
+				"interval_min": 5,
+				"jitter_pct":   51,
+			},
+			{
+				"active":       true,
+				"interval_min": 5,
+				"jitter_pct":   -1,
+			},
+			{
+				"active":       true,
+				"interval_min": 5,
+				"capture_mode": "screen",
+			},
+			{
+				"active":       true,
+				"interval_min": 5,
+				"capture_mode": "region",
+			},
+			{
+				"active":       true,
+				"interval_min": 5,
+				"capture_mode": "full",
+				"region":       map[string]interface{}{"x": 0, "y": 0, "width": 100, "height": 100},
+			},
+			{
+				"active":       true,
+				"interval_min": 5,
+				"capture_mode": "region",
+				"region":       map[string]interface{}{"x": 0, "y": 0, "width": 0, "height": 100},
+			},
+			{
+				"active":          true,
+				"interval_min":    5,
+				"exclude_windows": []interface{}{42},
+			},
 		}
 		db, err := BeforeEach()
 		if err != nil {
 			t.Fatalf("failed to set up test: %v", err)
 		}
 		defer AfterEach(db)
-		mux := setuphandlers.SetupHandlers(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
 		validator := validator.New()
 
 		for _, request := range requests {
@@ -92,15 +163,14 @@ func TestPutCaptureScheduleIntegrate(t *testing.T) {
 		}
 	})
 
-	t.Run("PUT /capture/schedule はスケジュールがない場合 400 Bad Request を返す", func(t *testing.T) {
+	t.Run("PUT /capture/schedule はスケジュールがない場合作成し、履歴に記録する", func(t *testing.T) {
 		// Arrange
 		db, err := BeforeEach()
 		if err != nil {
 			t.Fatalf("failed to set up test: %v", err)
 		}
 		defer AfterEach(db)
-		mux := setuphandlers.SetupHandlers(db)
-		validator := validator.New()
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
 
 		// Act
 		req := httptest.NewRequest(http.MethodPut, "/capture/schedule", bytes.NewBuffer([]byte(`{"active": true, "interval_min": 5}`)))
@@ -108,35 +178,49 @@ func TestPutCaptureScheduleIntegrate(t *testing.T) {
 		mux.ServeHTTP(rec, req)
 
 		// Assert
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, http.StatusOK, rec.Code)
 		assert.Equal(t, "application/json", strings.ToLower(rec.Header().Get("Content-Type")))
-		response, err := GetResponseBodyJson(rec)
-		assert.NoError(t, err)
-		typedResponse := ResponseBadRequestValidation{}
-		if err := json.Unmarshal([]byte(response), &typedResponse); err != nil {
+		typedResponse := struct {
+			Schedule responseCaptureScheduleUnit `json:"schedule"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
 			t.Fatalf("failed to unmarshal response: %v", err)
 		}
-		if err := validator.Struct(typedResponse); err != nil {
-			t.Fatalf("failed to validate response: %v", err)
-		}
+		assert.NotEmpty(t, typedResponse.Schedule.ID)
+		assert.True(t, typedResponse.Schedule.Active)
+		assert.Equal(t, "active", typedResponse.Schedule.State)
+		assert.Equal(t, 5, typedResponse.Schedule.IntervalMin)
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM capture_schedules WHERE id = ? AND state = 'active'", typedResponse.Schedule.ID).Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		err = db.QueryRow("SELECT COUNT(*) FROM capture_schedule_history WHERE schedule_id = ? AND action = 'create' AND old_state IS NULL", typedResponse.Schedule.ID).Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
 	})
 
-	t.Run("PUT /capture/schedule はアクティブなスケジュールが無い場合 400 Bad Request を返す", func(t *testing.T) {
+	t.Run("PUT /capture/schedule はアクティブなスケジュールが無い場合、最後に更新されたスケジュールを更新する", func(t *testing.T) {
 		// Arrange
 		db, err := BeforeEach()
 		if err != nil {
 			t.Fatalf("failed to set up test: %v", err)
 		}
 		defer AfterEach(db)
-		mux := setuphandlers.SetupHandlers(db)
-		validator := validator.New()
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
 		timezone := GetJSTTimezone()
 		now := time.Now().In(timezone)
 		schedules := []datamodel.CaptureSchedule{
 			{
 				ID:          "schedule-0",
-				Active:      false,
+				State:       datamodel.CaptureScheduleStateInactive,
 				IntervalMin: 10,
+				CreatedAt:   now.Add(-time.Hour),
+				UpdatedAt:   now.Add(-time.Hour),
+			},
+			{
+				ID:          "schedule-1",
+				State:       datamodel.CaptureScheduleStateInactive,
+				IntervalMin: 15,
 				CreatedAt:   now,
 				UpdatedAt:   now,
 			},
@@ -151,17 +235,31 @@ func TestPutCaptureScheduleIntegrate(t *testing.T) {
 		mux.ServeHTTP(rec, req)
 
 		// Assert
-		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.Equal(t, "application/json", strings.ToLower(rec.Header().Get("Content-Type")))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		expected, _ := json.Marshal(map[string]interface{}{
+			"schedule": map[string]interface{}{
+				"id":                   "schedule-1",
+				"active":               true,
+				"state":                "active",
+				"interval_min":         5,
+				"consecutive_failures": 0,
+				"last_failure_at":      nil,
+				"last_failure_reason":  nil,
+				"time_windows":         []interface{}{},
+				"excluded_dates":       []interface{}{},
+				"jitter_pct":           0,
+				"capture_mode":         "full",
+				"region":               nil,
+				"exclude_windows":      []interface{}{},
+			},
+		})
 		response, err := GetResponseBodyJson(rec)
 		assert.NoError(t, err)
-		typedResponse := ResponseBadRequestValidation{}
-		if err := json.Unmarshal([]byte(response), &typedResponse); err != nil {
-			t.Fatalf("failed to unmarshal response: %v", err)
-		}
-		if err := validator.Struct(typedResponse); err != nil {
-			t.Fatalf("failed to validate response: %v", err)
-		}
+		assert.JSONEq(t, string(expected), response)
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM capture_schedules").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
 	})
 
 	t.Run("PUT /capture/schedule はアクティブなスケジュールがある場合更新し更新後のスケジュールを返す", func(t *testing.T) {
@@ -171,13 +269,13 @@ func TestPutCaptureScheduleIntegrate(t *testing.T) {
 			t.Fatalf("failed to set up test: %v", err)
 		}
 		defer AfterEach(db)
-		mux := setuphandlers.SetupHandlers(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
 		timezone := GetJSTTimezone()
 		now := time.Now().In(timezone)
 		schedules := []datamodel.CaptureSchedule{
 			{
 				ID:          "schedule-0",
-				Active:      true,
+				State:       datamodel.CaptureScheduleStateActive,
 				IntervalMin: 10,
 				CreatedAt:   now,
 				UpdatedAt:   now,
@@ -199,9 +297,19 @@ func TestPutCaptureScheduleIntegrate(t *testing.T) {
 		assert.NoError(t, err)
 		expected, _ := json.Marshal(map[string]interface{}{
 			"schedule": map[string]interface{}{
-				"id":           "schedule-0",
-				"active":       true,
-				"interval_min": 1440,
+				"id":                   "schedule-0",
+				"active":               true,
+				"state":                "active",
+				"interval_min":         1440,
+				"consecutive_failures": 0,
+				"last_failure_at":      nil,
+				"last_failure_reason":  nil,
+				"time_windows":         []interface{}{},
+				"excluded_dates":       []interface{}{},
+				"jitter_pct":           0,
+				"capture_mode":         "full",
+				"region":               nil,
+				"exclude_windows":      []interface{}{},
 			},
 		})
 		assert.JSONEq(t, string(expected), response)
@@ -222,8 +330,77 @@ func TestPutCaptureScheduleIntegrate(t *testing.T) {
 		response, err = GetResponseBodyJson(rec)
 		assert.NoError(t, err)
 		expected, _ = json.Marshal(map[string]interface{}{
-			"schedule": nil,
+			"schedule": map[string]interface{}{
+				"id":                   "schedule-0",
+				"active":               false,
+				"state":                "inactive",
+				"interval_min":         5,
+				"consecutive_failures": 0,
+				"last_failure_at":      nil,
+				"last_failure_reason":  nil,
+				"time_windows":         []interface{}{},
+				"excluded_dates":       []interface{}{},
+				"jitter_pct":           0,
+				"capture_mode":         "full",
+				"region":               nil,
+				"exclude_windows":      []interface{}{},
+			},
 		})
 		assert.JSONEq(t, string(expected), response)
+		// 非アクティブにしてもGETで取得できる
+		reqGet = httptest.NewRequest(http.MethodGet, "/capture/schedule", nil)
+		recGet = httptest.NewRecorder()
+		mux.ServeHTTP(recGet, reqGet)
+		responseGet, _ = GetResponseBodyJson(recGet)
+		assert.JSONEq(t, string(expected), responseGet)
+	})
+	t.Run("PUT /capture/schedule は撮影範囲と除外ウィンドウを保存する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, GetTestConfig(t))
+		body := `{
+			"active": false,
+			"interval_min": 5,
+			"capture_mode": "region",
+			"region": {"x": 10, "y": 20, "width": 800, "height": 600},
+			"exclude_windows": ["window-1", "window-2"]
+		}`
+
+		// Act
+		req := httptest.NewRequest(http.MethodPut, "/capture/schedule", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		typedResponse := struct {
+			Schedule responseCaptureScheduleUnit `json:"schedule"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, "region", typedResponse.Schedule.CaptureMode)
+		assert.Equal(t, &responseCaptureRegion{X: 10, Y: 20, Width: 800, Height: 600}, typedResponse.Schedule.Region)
+		assert.Equal(t, []string{"window-1", "window-2"}, typedResponse.Schedule.ExcludeWindows)
+		reqGet := httptest.NewRequest(http.MethodGet, "/capture/schedule", nil)
+		recGet := httptest.NewRecorder()
+		mux.ServeHTTP(recGet, reqGet)
+		assert.JSONEq(t, rec.Body.String(), recGet.Body.String())
+
+		// 全画面に戻すと領域は消える
+		req = httptest.NewRequest(http.MethodPut, "/capture/schedule", bytes.NewBufferString(`{"active": false, "interval_min": 5, "capture_mode": "window"}`))
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		if err := json.Unmarshal(rec.Body.Bytes(), &typedResponse); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		assert.Equal(t, "window", typedResponse.Schedule.CaptureMode)
+		assert.Nil(t, typedResponse.Schedule.Region)
+		assert.Equal(t, []string{}, typedResponse.Schedule.ExcludeWindows)
 	})
 }
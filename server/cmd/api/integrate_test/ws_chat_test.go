@@ -0,0 +1,215 @@
+package integratetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/stretchr/testify/assert"
+)
+
+// /ws/chat に接続する
+func dialChatSocket(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws/chat", nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+	return conn
+}
+
+func writeChatSocket(t *testing.T, conn *websocket.Conn, message string) {
+	t.Helper()
+	if err := conn.Write(t.Context(), websocket.MessageText, []byte(message)); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+}
+
+// until が true を返すまでイベントを読み、読んだイベントを返す
+func readChatSocketUntil(t *testing.T, conn *websocket.Conn, until func(event map[string]interface{}) bool) []map[string]interface{} {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	events := []map[string]interface{}{}
+	for {
+		var event map[string]interface{}
+		if err := wsjson.Read(ctx, conn, &event); err != nil {
+			t.Fatalf("failed to read: %v (events: %v)", err, events)
+		}
+		events = append(events, event)
+		if until(event) {
+			return events
+		}
+	}
+}
+
+// request_id が requestID のイベントの type を順に返す
+func chatSocketEventTypes(events []map[string]interface{}, requestID string) []string {
+	types := []string{}
+	for _, event := range events {
+		if event["request_id"] == requestID {
+			types = append(types, event["type"].(string))
+		}
+	}
+	return types
+}
+
+func TestWebSocketChatIntegrate(t *testing.T) {
+	t.Run("/ws/chat は /llm/chat と同じイベントに request_id を付けて送り、done で終える", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{{Chunks: []string{"こん", "にちは"}}})
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		conn := dialChatSocket(t, server)
+
+		// Act
+		writeChatSocket(t, conn, `{"type": "chat", "request_id": "r1", "messages": [{"role": "user", "content": "こんにちは"}]}`)
+		events := readChatSocketUntil(t, conn, func(event map[string]interface{}) bool { return event["type"] == "done" })
+		writeChatSocket(t, conn, `{"type": "ping"}`)
+		pong := readChatSocketUntil(t, conn, func(event map[string]interface{}) bool { return true })
+
+		// Assert
+		if assert.Len(t, events, 4) {
+			assert.Equal(t, "conversation", events[0]["type"])
+			assert.Equal(t, "r1", events[0]["request_id"])
+			assert.NotEmpty(t, events[0]["conversation_id"])
+			assert.Equal(t, map[string]interface{}{"type": "text", "content": "こん", "request_id": "r1"}, events[1])
+			assert.Equal(t, map[string]interface{}{"type": "text", "content": "にちは", "request_id": "r1"}, events[2])
+			assert.Equal(t, map[string]interface{}{"type": "done", "request_id": "r1"}, events[3])
+		}
+		assert.Equal(t, []map[string]interface{}{{"type": "pong"}}, pong)
+	})
+
+	t.Run("/ws/chat は1つの接続で複数のリクエストを並行して扱う", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		cfg.LLMFakeScriptPath = WriteFakeLLMScript(t, []llm.FakeResponse{{Chunks: []string{"はい"}}})
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		conn := dialChatSocket(t, server)
+
+		// Act
+		writeChatSocket(t, conn, `{"type": "chat", "request_id": "r1", "messages": [{"role": "user", "content": "一つ目"}]}`)
+		writeChatSocket(t, conn, `{"type": "chat", "request_id": "r2", "messages": [{"role": "user", "content": "二つ目"}]}`)
+		done := map[string]bool{}
+		events := readChatSocketUntil(t, conn, func(event map[string]interface{}) bool {
+			if event["type"] == "done" {
+				done[event["request_id"].(string)] = true
+			}
+			return len(done) == 2
+		})
+
+		// Assert
+		assert.Equal(t, []string{"conversation", "text", "done"}, chatSocketEventTypes(events, "r1"))
+		assert.Equal(t, []string{"conversation", "text", "done"}, chatSocketEventTypes(events, "r2"))
+	})
+
+	t.Run("/ws/chat は cancel で LLM へのリクエストを中断し、応答を finish_reason aborted で保存する", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		canceled := make(chan struct{})
+		stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":"考え中"},"done":false}`)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			close(canceled)
+		}))
+		defer stub.Close()
+		cfg := GetTestConfig(t)
+		cfg.LLMProvider = llm.ProviderOllama
+		cfg.LLMEndpoint = stub.URL
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		conn := dialChatSocket(t, server)
+		writeChatSocket(t, conn, `{"type": "chat", "request_id": "r1", "messages": [{"role": "user", "content": "こんにちは"}]}`)
+		started := readChatSocketUntil(t, conn, func(event map[string]interface{}) bool { return event["type"] == "text" })
+		conversationID := started[0]["conversation_id"].(string)
+
+		// Act
+		writeChatSocket(t, conn, `{"type": "cancel", "request_id": "r1"}`)
+		events := readChatSocketUntil(t, conn, func(event map[string]interface{}) bool { return event["type"] == "canceled" })
+
+		// Assert
+		select {
+		case <-canceled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("request to LLM was not canceled")
+		}
+		assert.Equal(t, []map[string]interface{}{{"type": "canceled", "request_id": "r1"}}, events)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/conversations/"+conversationID+"/messages", nil))
+		messages := responseConversationMessages{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &messages); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if assert.Len(t, messages.Messages, 2) {
+			assert.Equal(t, "考え中", messages.Messages[1].Content)
+			if assert.NotNil(t, messages.Messages[1].FinishReason) {
+				assert.Equal(t, "aborted", *messages.Messages[1].FinishReason)
+			}
+		}
+	})
+
+	t.Run("/ws/chat は不正なメッセージに error イベントを送り、接続を続ける", func(t *testing.T) {
+		// Arrange
+		db, err := BeforeEach()
+		if err != nil {
+			t.Fatalf("failed to set up test: %v", err)
+		}
+		defer AfterEach(db)
+		cfg := GetTestConfig(t)
+		mux, _ := setuphandlers.SetupHandlers(t.Context(), db, cfg)
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		conn := dialChatSocket(t, server)
+		readOne := func() map[string]interface{} {
+			return readChatSocketUntil(t, conn, func(event map[string]interface{}) bool { return true })[0]
+		}
+
+		// Act
+		writeChatSocket(t, conn, `{"type": "chat", "messages": [{"role": "user", "content": "a"}]}`)
+		missingRequestID := readOne()
+		writeChatSocket(t, conn, `{"type": "chat", "request_id": "r1", "messages": []}`)
+		invalidBody := readOne()
+		writeChatSocket(t, conn, `{"type": "chat", "request_id": "r2", "conversation_id": "unknown", "messages": [{"role": "user", "content": "a"}]}`)
+		notFound := readOne()
+		writeChatSocket(t, conn, `{"type": "ping"}`)
+		pong := readOne()
+
+		// Assert
+		assert.Equal(t, map[string]interface{}{"type": "error", "request_id": nil, "status": 400.0, "message": "invalid parameter", "target": "request_id"}, missingRequestID)
+		assert.Equal(t, map[string]interface{}{"type": "error", "request_id": "r1", "status": 400.0, "message": "invalid parameter", "target": "messages"}, invalidBody)
+		assert.Equal(t, map[string]interface{}{"type": "error", "request_id": "r2", "status": 404.0, "code": "NOT_FOUND", "message": "Conversation not found"}, notFound)
+		assert.Equal(t, map[string]interface{}{"type": "pong"}, pong)
+	})
+}
@@ -1,21 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
+	"time"
 
 	setuphandlers "github.com/ano333333/llm-time-manager/server/cmd/api/setup"
+	"github.com/ano333333/llm-time-manager/server/internal/config"
 	"github.com/ano333333/llm-time-manager/server/internal/database"
 	"github.com/joho/godotenv"
 )
 
-const (
-	defaultDBPath = "./data/dev.db"
-	defaultPort   = 8080
-)
+const shutdownTimeout = 10 * time.Second
 
 func main() {
 	log.Println("LLM時間管理ツール - Server starting...")
@@ -26,14 +28,11 @@ func main() {
 		}
 	}
 
-	// データベースパスの設定
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = defaultDBPath
-	}
+	// 設定の読み込み
+	cfg := config.Load()
 
 	// データベース接続の初期化
-	db, err := database.Open(dbPath)
+	db, err := database.Open(cfg.DBPath)
 	if err != nil {
 		log.Fatalf("failed to open database: %v", err)
 	}
@@ -43,7 +42,7 @@ func main() {
 		}
 	}()
 
-	log.Printf("Database opened: %s", dbPath)
+	log.Printf("Database opened: %s", cfg.DBPath)
 
 	// マイグレーションの実行
 	migrationsDir := "./migrations"
@@ -63,17 +62,30 @@ func main() {
 		log.Printf("failed to write to stdout: %v", err)
 	}
 
-	// PORTの取得
-	port, err := strconv.Atoi(os.Getenv("PORT"))
-	if err != nil {
-		port = defaultPort
-	}
-	log.Printf("Server will be running on port %d", port)
+	log.Printf("Server will be running on port %d", cfg.Port)
 
-	mux := setuphandlers.SetupHandlers(db)
+	// SIGINT/SIGTERMでキャンセルされ、サーバーとバックグラウンドワーカーを停止する
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mux, waitWorkers := setuphandlers.SetupHandlers(ctx, db, cfg)
 	server := http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
 		Handler: mux,
 	}
-	server.ListenAndServe()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("failed to shutdown server: %v", err)
+		}
+	}()
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("failed to serve: %v", err)
+	}
+	// データベースを閉じる前に、バックグラウンドワーカーの終了を待つ
+	stop()
+	waitWorkers()
+	log.Println("Server stopped")
 }
@@ -1,30 +1,295 @@
 package setuphandlers
 
 import (
+	"context"
 	"database/sql"
+	"log"
 	"net/http"
+	"sync"
 
+	"github.com/ano333333/llm-time-manager/server/internal/analysis"
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	"github.com/ano333333/llm-time-manager/server/internal/config"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
 	"github.com/ano333333/llm-time-manager/server/internal/handler"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
 	"github.com/ano333333/llm-time-manager/server/internal/store"
 )
 
-func SetupHandlers(db *sql.DB) *http.ServeMux {
-	mux := http.NewServeMux()
+// ハンドラを登録したServeMuxと、バックグラウンドワーカーの終了を待つ関数を返す。
+//
+// バックグラウンドワーカーもここで起動し、ctxがキャンセルされると終了する。dbを閉じる前にwaitを呼ぶこと。
+func SetupHandlers(ctx context.Context, db *sql.DB, cfg config.Config) (mux *http.ServeMux, wait func()) {
+	mux = http.NewServeMux()
+	var workers sync.WaitGroup
 
 	// リポジトリ
+	captureAnalysisJobStore := store.DefaultCaptureAnalysisJobStore{DB: db}
+	captureEventStore := store.DefaultCaptureEventStore{DB: db}
 	captureScheduleStore := store.DefaultCaptureScheduleStore{DB: db}
+	captureScheduleHistoryStore := store.DefaultCaptureScheduleHistoryStore{DB: db}
+	captureStore := store.DefaultCaptureStore{DB: db}
+	chatMessageStore := store.DefaultChatMessageStore{DB: db}
+	conversationStore := store.DefaultConversationStore{DB: db}
+	conversationSummaryStore := store.DefaultConversationSummaryStore{DB: db}
 	goalStore := store.DefaultGoalStore{DB: db}
+	kpiEntryStore := store.DefaultKpiEntryStore{DB: db}
+	promptTemplateStore := store.DefaultPromptTemplateStore{DB: db}
+	proposalStore := store.DefaultProposalStore{DB: db}
+	settingsStore := store.DefaultSettingsStore{DB: db}
+	taskSessionStore := store.DefaultTaskSessionStore{DB: db}
+	taskStore := store.DefaultTaskStore{DB: db}
 	transactionStore := store.DefaultTransactionStore{DB: db}
 
+	// ストレージ
+	captureStorage := capture.Storage{Dir: cfg.CaptureStoragePath}
+	if cfg.CaptureEncryptionKeyPath != "" {
+		encryptor, err := capture.LoadOrCreateEncryptor(cfg.CaptureEncryptionKeyPath)
+		if err != nil {
+			log.Fatalf("failed to load capture encryption key: %v", err)
+		}
+		captureStorage.Encryptor = encryptor
+	}
+
+	// LLM
+	llmProvider, err := llm.NewProvider(llm.ProviderConfig{
+		Provider:       cfg.LLMProvider,
+		Endpoint:       cfg.LLMEndpoint,
+		Model:          cfg.LLMModel,
+		APIKey:         cfg.LLMAPIKey,
+		MaxTokens:      cfg.LLMMaxTokens,
+		Timeout:        cfg.LLMTimeout,
+		FakeScriptPath: cfg.LLMFakeScriptPath,
+	})
+	if err != nil {
+		log.Fatalf("failed to set up llm provider: %v", err)
+	}
+
+	// バックグラウンドワーカー
+	thumbnailRegenerator := capture.NewThumbnailRegenerator(&captureStore, &settingsStore, &transactionStore, &captureStorage)
+	workers.Go(func() { thumbnailRegenerator.Run(ctx) })
+	retentionCleaner := capture.NewRetentionCleaner(&captureStore, &settingsStore, &transactionStore, &captureStorage)
+	workers.Go(func() { retentionCleaner.Run(ctx) })
+	captureRequestHub := capture.NewCaptureRequestHub()
+	scheduler := capture.NewScheduler(&captureScheduleStore, &captureEventStore, &transactionStore, captureRequestHub)
+	workers.Go(func() { scheduler.Run(ctx) })
+	var analyzer analysis.Analyzer = &analysis.LLMAnalyzer{
+		LLM:                 llmProvider,
+		PromptTemplateStore: &promptTemplateStore,
+		TaskStore:           &taskStore,
+		TransactionStore:    &transactionStore,
+	}
+	// 応答スクリプトのないfakeプロバイダは解析の結果を返せないため、メタデータだけで解析する
+	if cfg.LLMProvider == llm.ProviderFake && cfg.LLMFakeScriptPath == "" {
+		analyzer = analysis.MetadataAnalyzer{}
+	}
+	analysisPool := analysis.NewPool(&captureStore, &captureAnalysisJobStore, &transactionStore, &captureStorage, analyzer, cfg.AnalysisWorkers)
+	workers.Go(func() { analysisPool.Run(ctx) })
+
 	// ハンドラ
 	mux.Handle("/capture/schedule", &handler.CaptureScheduleHandler{
+		CaptureScheduleStore:        &captureScheduleStore,
+		CaptureScheduleHistoryStore: &captureScheduleHistoryStore,
+		TransactionStore:            &transactionStore,
+		Scheduler:                   scheduler,
+	})
+	mux.Handle("/capture/schedule/history", &handler.CaptureScheduleHistoryHandler{
+		CaptureScheduleHistoryStore: &captureScheduleHistoryStore,
+		TransactionStore:            &transactionStore,
+	})
+	mux.Handle("/capture/schedule/preview", &handler.CaptureSchedulePreviewHandler{
+		CaptureScheduleStore: &captureScheduleStore,
+		TransactionStore:     &transactionStore,
+	})
+	mux.Handle("/capture/schedule/start", &handler.CaptureScheduleTransitionHandler{
+		CaptureScheduleStore:        &captureScheduleStore,
+		CaptureScheduleHistoryStore: &captureScheduleHistoryStore,
+		TransactionStore:            &transactionStore,
+		Scheduler:                   scheduler,
+		From:                        datamodel.CaptureScheduleStateInactive,
+		To:                          datamodel.CaptureScheduleStateActive,
+		Action:                      datamodel.CaptureScheduleActionStart,
+		Message:                     "started",
+	})
+	mux.Handle("/capture/schedule/stop", &handler.CaptureScheduleTransitionHandler{
+		CaptureScheduleStore:        &captureScheduleStore,
+		CaptureScheduleHistoryStore: &captureScheduleHistoryStore,
+		TransactionStore:            &transactionStore,
+		Scheduler:                   scheduler,
+		From:                        datamodel.CaptureScheduleStateActive,
+		To:                          datamodel.CaptureScheduleStateInactive,
+		Action:                      datamodel.CaptureScheduleActionStop,
+		Message:                     "stopped",
+	})
+	mux.Handle("/capture/schedule/resume", &handler.CaptureScheduleTransitionHandler{
+		CaptureScheduleStore:        &captureScheduleStore,
+		CaptureScheduleHistoryStore: &captureScheduleHistoryStore,
+		TransactionStore:            &transactionStore,
+		Scheduler:                   scheduler,
+		From:                        datamodel.CaptureScheduleStatePaused,
+		To:                          datamodel.CaptureScheduleStateActive,
+		Action:                      datamodel.CaptureScheduleActionResume,
+		Message:                     "resumed",
+	})
+	mux.Handle("/capture/schedule/failures", &handler.CaptureScheduleFailureHandler{
+		CaptureScheduleStore:        &captureScheduleStore,
+		CaptureScheduleHistoryStore: &captureScheduleHistoryStore,
+		TransactionStore:            &transactionStore,
+		Scheduler:                   scheduler,
+	})
+	mux.Handle("/capture/requests", &handler.CaptureRequestsHandler{
+		Hub: captureRequestHub,
+	})
+	mux.Handle("/capture/retention/dry-run", &handler.CaptureRetentionDryRunHandler{
+		RetentionCleaner: retentionCleaner,
+	})
+	mux.Handle("/capture/events", &handler.CaptureEventsHandler{
+		CaptureEventStore: &captureEventStore,
+		TransactionStore:  &transactionStore,
+	})
+	mux.Handle("/capture/screenshot", &handler.CaptureScreenshotHandler{
+		CaptureStore:         &captureStore,
 		CaptureScheduleStore: &captureScheduleStore,
+		CaptureEventStore:    &captureEventStore,
+		SettingsStore:        &settingsStore,
+		TaskStore:            &taskStore,
 		TransactionStore:     &transactionStore,
+		Storage:              &captureStorage,
+		RetentionCleaner:     retentionCleaner,
+		AnalysisPool:         analysisPool,
+		MaxUploadBytes:       cfg.CaptureMaxUploadBytes,
+	})
+	mux.Handle("/captures", &handler.CapturesHandler{
+		CaptureStore:     &captureStore,
+		TransactionStore: &transactionStore,
+	})
+	mux.Handle("/captures/displays", &handler.CaptureDisplaysHandler{
+		CaptureStore:     &captureStore,
+		TransactionStore: &transactionStore,
+	})
+	mux.Handle("/captures/idle-periods", &handler.CaptureIdlePeriodsHandler{
+		CaptureStore:     &captureStore,
+		TransactionStore: &transactionStore,
+	})
+	mux.Handle("/captures/{id}", &handler.CaptureHandler{
+		CaptureStore:     &captureStore,
+		TaskStore:        &taskStore,
+		GoalStore:        &goalStore,
+		TransactionStore: &transactionStore,
+	})
+	mux.Handle("/captures/{id}/analysis", &handler.CaptureAnalysisHandler{
+		CaptureStore:            &captureStore,
+		CaptureAnalysisJobStore: &captureAnalysisJobStore,
+		TransactionStore:        &transactionStore,
+		AnalysisPool:            analysisPool,
+	})
+	mux.Handle("/captures/{id}/thumbnail", &handler.CaptureThumbnailHandler{
+		CaptureStore:     &captureStore,
+		TransactionStore: &transactionStore,
+		Storage:          &captureStorage,
+	})
+	mux.Handle("/conversations", &handler.ConversationsHandler{
+		ConversationStore: &conversationStore,
+		TransactionStore:  &transactionStore,
+	})
+	mux.Handle("/conversations/{id}", &handler.ConversationHandler{
+		ConversationStore: &conversationStore,
+		TransactionStore:  &transactionStore,
+	})
+	mux.Handle("/conversations/{id}/messages", &handler.ConversationMessagesHandler{
+		ConversationStore: &conversationStore,
+		ChatMessageStore:  &chatMessageStore,
+		TransactionStore:  &transactionStore,
 	})
 	mux.Handle("/goal", &handler.GoalHandler{
 		GoalStore:        &goalStore,
 		TransactionStore: &transactionStore,
 	})
+	mux.Handle("/goal/{id}", &handler.GoalDetailHandler{
+		GoalStore:        &goalStore,
+		CaptureStore:     &captureStore,
+		TransactionStore: &transactionStore,
+	})
+	llmChatHandler := &handler.LLMChatHandler{
+		LLM:                         llmProvider,
+		ConversationStore:           &conversationStore,
+		ChatMessageStore:            &chatMessageStore,
+		ConversationSummaryStore:    &conversationSummaryStore,
+		TaskStore:                   &taskStore,
+		GoalStore:                   &goalStore,
+		KpiEntryStore:               &kpiEntryStore,
+		ProposalStore:               &proposalStore,
+		PromptTemplateStore:         &promptTemplateStore,
+		CaptureScheduleStore:        &captureScheduleStore,
+		CaptureScheduleHistoryStore: &captureScheduleHistoryStore,
+		TransactionStore:            &transactionStore,
+		Scheduler:                   scheduler,
+		PromptTokenBudget:           cfg.LLMPromptTokenBudget,
+		SummaryThreshold:            cfg.LLMSummaryThreshold,
+	}
+	mux.Handle("/llm/chat", llmChatHandler)
+	mux.Handle("/llm/prompt", &handler.LLMPromptHandler{
+		Chat: llmChatHandler,
+	})
+	mux.Handle("/ws/chat", &handler.LLMChatSocketHandler{
+		Chat: llmChatHandler,
+	})
+	mux.Handle("/prompt-templates", &handler.PromptTemplatesHandler{
+		PromptTemplateStore: &promptTemplateStore,
+		TransactionStore:    &transactionStore,
+	})
+	mux.Handle("/prompt-templates/{name}", &handler.PromptTemplateHandler{
+		PromptTemplateStore: &promptTemplateStore,
+		TransactionStore:    &transactionStore,
+	})
+	mux.Handle("/prompt-templates/{name}/versions", &handler.PromptTemplateVersionsHandler{
+		PromptTemplateStore: &promptTemplateStore,
+		TransactionStore:    &transactionStore,
+	})
+	mux.Handle("/prompt-templates/{name}/versions/{version}", &handler.PromptTemplateVersionHandler{
+		PromptTemplateStore: &promptTemplateStore,
+		TransactionStore:    &transactionStore,
+	})
+	mux.Handle("/proposals", &handler.ProposalsHandler{
+		ProposalStore:    &proposalStore,
+		TransactionStore: &transactionStore,
+	})
+	mux.Handle("/proposals/{id}", &handler.ProposalHandler{
+		ProposalStore:    &proposalStore,
+		TransactionStore: &transactionStore,
+	})
+	mux.Handle("/proposals/{id}/accept", &handler.ProposalResolveHandler{
+		ProposalStore:    &proposalStore,
+		TaskStore:        &taskStore,
+		GoalStore:        &goalStore,
+		TransactionStore: &transactionStore,
+		Status:           datamodel.ProposalStatusAccepted,
+	})
+	mux.Handle("/proposals/{id}/reject", &handler.ProposalResolveHandler{
+		ProposalStore:    &proposalStore,
+		TaskStore:        &taskStore,
+		GoalStore:        &goalStore,
+		TransactionStore: &transactionStore,
+		Status:           datamodel.ProposalStatusRejected,
+	})
+	mux.Handle("/settings", &handler.SettingsHandler{
+		SettingsStore:        &settingsStore,
+		TransactionStore:     &transactionStore,
+		ThumbnailRegenerator: thumbnailRegenerator,
+		RetentionCleaner:     retentionCleaner,
+		CaptureStoragePath:   cfg.CaptureStoragePath,
+	})
+	mux.Handle("/tasks/{id}", &handler.TaskHandler{
+		TaskStore:        &taskStore,
+		CaptureStore:     &captureStore,
+		TransactionStore: &transactionStore,
+	})
+	mux.Handle("/timeline", &handler.TimelineHandler{
+		CaptureStore:            &captureStore,
+		CaptureAnalysisJobStore: &captureAnalysisJobStore,
+		TaskSessionStore:        &taskSessionStore,
+		TransactionStore:        &transactionStore,
+	})
 
-	return mux
+	return mux, workers.Wait
 }
@@ -0,0 +1,77 @@
+// キャプチャ画像の暗号化鍵をローテーションする。
+//
+// 新しい鍵を <鍵ファイル>.new に生成し、保存済みの画像・サムネイルを全て新しい鍵で暗号化し直してから鍵ファイルを置き換える。
+// 途中で中断した場合は、再実行すると <鍵ファイル>.new の鍵で続きから処理する。
+// 実行中にサーバーがファイルを書き込まないよう、サーバーを停止してから実行すること。
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	"github.com/ano333333/llm-time-manager/server/internal/config"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(".env"); err != nil {
+			log.Fatalf("failed to load .env file: %v", err)
+		}
+	}
+
+	cfg := config.Load()
+	keyPath := cfg.CaptureEncryptionKeyPath
+	if keyPath == "" {
+		log.Fatalf("CAPTURE_ENCRYPTION_KEY_PATH is not set")
+	}
+
+	storage := capture.Storage{Dir: cfg.CaptureStoragePath}
+	// 鍵ファイルを画像と一緒に暗号化したり削除したりしないよう、保存先の外に置かせる
+	for _, path := range []string{keyPath, keyPath + ".new"} {
+		inside, err := storage.Contains(path)
+		if err != nil {
+			log.Fatalf("failed to resolve encryption key path: %v", err)
+		}
+		if inside {
+			log.Fatalf("encryption key %s must not be inside CAPTURE_STORAGE_PATH %s", path, cfg.CaptureStoragePath)
+		}
+	}
+
+	oldKey, err := capture.LoadEncryptionKey(keyPath)
+	if err != nil {
+		log.Fatalf("failed to load current encryption key: %v", err)
+	}
+	oldEncryptor, err := capture.NewEncryptor(oldKey)
+	if err != nil {
+		log.Fatalf("failed to create encryptor: %v", err)
+	}
+
+	// 前回中断した場合は、その時に生成した鍵で続ける
+	newKeyPath := keyPath + ".new"
+	newKey, err := capture.LoadEncryptionKey(newKeyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		newKey, err = capture.CreateEncryptionKey(newKeyPath)
+	} else if err == nil {
+		log.Printf("Resuming rotation with %s", newKeyPath)
+	}
+	if err != nil {
+		log.Fatalf("failed to prepare new encryption key: %v", err)
+	}
+	newEncryptor, err := capture.NewEncryptor(newKey)
+	if err != nil {
+		log.Fatalf("failed to create encryptor: %v", err)
+	}
+
+	storage.Encryptor = oldEncryptor
+	count, err := storage.Reencrypt(newEncryptor)
+	if err != nil {
+		log.Fatalf("failed to re-encrypt capture files (re-encrypted %d files; run again to resume): %v", count, err)
+	}
+	if err := os.Rename(newKeyPath, keyPath); err != nil {
+		log.Fatalf("failed to replace encryption key file: %v", err)
+	}
+	log.Printf("Re-encrypted %d capture files in %s", count, cfg.CaptureStoragePath)
+}
@@ -0,0 +1,63 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+// MetadataAnalyzerのプロンプトのバージョン（プロンプトを使わないため固定）
+const metadataPromptVersion = 1
+
+// 解析の入力
+type Input struct {
+	Capture datamodel.Capture
+	// 元画像のデータ
+	Image []byte
+}
+
+// 解析の結果
+type Result struct {
+	// 解析に使ったプロンプトのバージョン
+	PromptVersion int
+	// 活動カテゴリ（datamodel.ActivityCategory*）。それ以外の値はotherとして記録する。
+	Category string
+	// 画面に写っている作業の要約
+	Summary string
+	// ユーザーへの提案
+	Suggestions []string
+}
+
+// キャプチャを解析し、要約と提案を返す
+type Analyzer interface {
+	// 現在のプロンプトのバージョン。新しいジョブはこのバージョンで作成する。
+	PromptVersion(tx store.Transaction) (int, error)
+	Analyze(ctx context.Context, input Input) (Result, error)
+}
+
+// LLMを使わず、キャプチャのメタデータだけから要約を作るAnalyzer。
+//
+// カテゴリは常にotherになる。LLMの応答を用意していないfakeプロバイダの場合（テストや開発）に使う。
+type MetadataAnalyzer struct{}
+
+func (MetadataAnalyzer) PromptVersion(tx store.Transaction) (int, error) {
+	return metadataPromptVersion, nil
+}
+
+func (MetadataAnalyzer) Analyze(ctx context.Context, input Input) (Result, error) {
+	c := input.Capture
+	mode := "手動キャプチャ"
+	if c.Mode == datamodel.CaptureModeScheduled {
+		mode = "定期キャプチャ"
+	}
+	capturedAt := c.CapturedAt.In(utils.GetJSTTimezone()).Format("2006-01-02 15:04")
+	return Result{
+		PromptVersion: metadataPromptVersion,
+		Category:      datamodel.ActivityCategoryOther,
+		Summary:       fmt.Sprintf("%s に撮影された %dx%d のスクリーンショット（%s）", capturedAt, c.Width, c.Height, mode),
+		Suggestions:   []string{},
+	}, nil
+}
@@ -0,0 +1,143 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/ano333333/llm-time-manager/server/internal/prompt"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+const (
+	// 解析の結果の提案の件数の上限
+	llmAnalysisMaxSuggestions = 3
+	// 解析の結果の形式。テンプレートを編集しても結果を読み取れるよう、テンプレートとは別に送る
+	llmAnalysisOutputFormat = "このスクリーンショットを解析してください。\n" +
+		`結果は {"category": "カテゴリ", "summary": "画面に写っている作業の要約", "suggestions": ["ユーザーへの提案"]} のJSONオブジェクトだけを出力してください。` + "\n" +
+		"summaryは日本語で1〜2文、suggestionsは0〜3件にしてください。"
+)
+
+// スクリーンショットをLLMに送り、活動のカテゴリ・要約・提案を答えさせるAnalyzer。
+//
+// 画像を入力できるモデル（llavaなど）を使うこと。
+// 指示は最新のscreenshot_analysisテンプレートを描画したもので、そのバージョンをプロンプトのバージョンとする。
+type LLMAnalyzer struct {
+	LLM                 llm.Provider
+	PromptTemplateStore store.PromptTemplateStore
+	TaskStore           store.TaskStore
+	TransactionStore    store.TransactionStore
+}
+
+// LLMの応答のJSONオブジェクト
+type llmAnalysisOutput struct {
+	Category    string   `json:"category"`
+	Summary     string   `json:"summary"`
+	Suggestions []string `json:"suggestions"`
+}
+
+func (a *LLMAnalyzer) PromptVersion(tx store.Transaction) (int, error) {
+	template, err := a.latestTemplate(tx)
+	if err != nil {
+		return 0, err
+	}
+	return template.Version, nil
+}
+
+func (a *LLMAnalyzer) Analyze(ctx context.Context, input Input) (Result, error) {
+	template, doing, err := a.load(input.Capture)
+	if err != nil {
+		return Result{}, err
+	}
+	data := prompt.ScreenshotAnalysisData{
+		CapturedAt: input.Capture.CapturedAt.In(utils.GetJSTTimezone()),
+		Width:      input.Capture.Width,
+		Height:     input.Capture.Height,
+		Categories: datamodel.ActivityCategories,
+	}
+	if doing != nil {
+		data.DoingTask = doing.Title
+	}
+	instructions, err := prompt.RenderScreenshotAnalysis(template.Body, data)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to render screenshot analysis template: %w", err)
+	}
+	output, err := a.LLM.Chat(ctx, []llm.Message{
+		{Role: llm.RoleSystem, Content: instructions},
+		{Role: llm.RoleUser, Content: llmAnalysisOutputFormat, Images: [][]byte{input.Image}},
+	})
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to generate analysis: %w", err)
+	}
+	result, err := parseLLMAnalysis(output)
+	if err != nil {
+		return Result{}, err
+	}
+	result.PromptVersion = template.Version
+	return result, nil
+}
+
+// 最新のテンプレートと、撮影時に作業中だったタスク（なければnil）を読み込む。
+//
+// LLMの応答を待つ間にトランザクションを開いたままにしないよう、読み込みだけで閉じる。
+func (a *LLMAnalyzer) load(c datamodel.Capture) (*datamodel.PromptTemplate, *datamodel.Task, error) {
+	tx, err := a.TransactionStore.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	template, err := a.latestTemplate(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	doing, err := a.TaskStore.GetDoingTaskAt(tx, c.CapturedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get doing task: %w", err)
+	}
+	return template, doing, nil
+}
+
+func (a *LLMAnalyzer) latestTemplate(tx store.Transaction) (*datamodel.PromptTemplate, error) {
+	template, err := a.PromptTemplateStore.GetLatestPromptTemplate(tx, prompt.TemplateScreenshotAnalysis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get screenshot analysis template: %w", err)
+	}
+	if template == nil {
+		return nil, errors.New("screenshot analysis template not found")
+	}
+	return template, nil
+}
+
+// LLMの応答から解析の結果を読み取る。応答の前後の文章やコードブロックの囲みは無視する。
+//
+// カテゴリは小文字にするだけで検証しない（Poolが不明なカテゴリをotherとして記録する）。
+func parseLLMAnalysis(output string) (Result, error) {
+	start, end := strings.Index(output, "{"), strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return Result{}, fmt.Errorf("analysis response has no JSON object: %q", output)
+	}
+	var parsed llmAnalysisOutput
+	if err := json.Unmarshal([]byte(output[start:end+1]), &parsed); err != nil {
+		return Result{}, fmt.Errorf("failed to parse analysis response: %w", err)
+	}
+	summary := strings.TrimSpace(parsed.Summary)
+	if summary == "" {
+		return Result{}, fmt.Errorf("analysis response has no summary: %q", output)
+	}
+	suggestions := []string{}
+	for _, suggestion := range parsed.Suggestions {
+		if suggestion = strings.TrimSpace(suggestion); suggestion != "" && len(suggestions) < llmAnalysisMaxSuggestions {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return Result{
+		Category:    strings.ToLower(strings.TrimSpace(parsed.Category)),
+		Summary:     summary,
+		Suggestions: suggestions,
+	}, nil
+}
@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/ano333333/llm-time-manager/server/internal/prompt"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// 最新のテンプレートだけを返す
+type fakePromptTemplateStore struct {
+	store.PromptTemplateStore
+	latest *datamodel.PromptTemplate
+}
+
+func (s *fakePromptTemplateStore) GetLatestPromptTemplate(tx store.Transaction, name string) (*datamodel.PromptTemplate, error) {
+	if s.latest == nil || s.latest.Name != name {
+		return nil, nil
+	}
+	return s.latest, nil
+}
+
+// 常にdoingを作業中のタスクとして返す
+type fakeTaskStore struct {
+	store.TaskStore
+	doing *datamodel.Task
+}
+
+func (s *fakeTaskStore) GetDoingTaskAt(tx store.Transaction, at time.Time) (*datamodel.Task, error) {
+	return s.doing, nil
+}
+
+func TestLLMAnalyzer(t *testing.T) {
+	template := &datamodel.PromptTemplate{
+		Name:    prompt.TemplateScreenshotAnalysis,
+		Version: 3,
+		Body:    `{{.CapturedAt.Format "15:04"}} {{.Width}}x{{.Height}} {{.DoingTask}} {{range .Categories}}{{.}},{{end}}`,
+	}
+	input := Input{
+		Capture: datamodel.Capture{ID: "capture-1", Width: 64, Height: 48, CapturedAt: time.Date(2025, 11, 18, 0, 0, 0, 0, time.UTC)},
+		Image:   []byte("png image"),
+	}
+
+	t.Run("描画したテンプレートと画像を送り、応答のJSONから結果を読み取る", func(t *testing.T) {
+		// Arrange
+		provider := &llm.FakeProvider{Responses: []llm.FakeResponse{{Chunks: []string{
+			"```json\n" + `{"category": " Work ", "summary": " 設計書を書いている ", "suggestions": ["休憩する", " ", "a", "b", "c"]}` + "\n```",
+		}}}}
+		analyzer := &LLMAnalyzer{
+			LLM:                 provider,
+			PromptTemplateStore: &fakePromptTemplateStore{latest: template},
+			TaskStore:           &fakeTaskStore{doing: &datamodel.Task{ID: "task-1", Title: "設計書を書く"}},
+			TransactionStore:    fakeTransactionStore{},
+		}
+
+		// Act
+		version, versionErr := analyzer.PromptVersion(fakeTransaction{})
+		result, err := analyzer.Analyze(t.Context(), input)
+
+		// Assert
+		assert.NoError(t, versionErr)
+		assert.Equal(t, 3, version)
+		assert.NoError(t, err)
+		assert.Equal(t, Result{PromptVersion: 3, Category: "work", Summary: "設計書を書いている", Suggestions: []string{"休憩する", "a", "b"}}, result)
+		requests := provider.Requests()
+		if assert.Len(t, requests, 1) && assert.Len(t, requests[0], 2) {
+			assert.Equal(t, llm.Message{Role: llm.RoleSystem, Content: "09:00 64x48 設計書を書く work,communication,learning,break,other,"}, requests[0][0])
+			assert.Equal(t, llm.RoleUser, requests[0][1].Role)
+			assert.Equal(t, [][]byte{input.Image}, requests[0][1].Images)
+		}
+	})
+
+	t.Run("応答から結果を読み取れない場合はエラーを返す", func(t *testing.T) {
+		for _, output := range []string{"わかりません", `{"category": "work"}`, `{"summary": `} {
+			// Arrange
+			analyzer := &LLMAnalyzer{
+				LLM:                 &llm.FakeProvider{Responses: []llm.FakeResponse{{Chunks: []string{output}}}},
+				PromptTemplateStore: &fakePromptTemplateStore{latest: template},
+				TaskStore:           &fakeTaskStore{},
+				TransactionStore:    fakeTransactionStore{},
+			}
+
+			// Act
+			_, err := analyzer.Analyze(t.Context(), input)
+
+			// Assert
+			assert.Error(t, err, output)
+		}
+	})
+
+	t.Run("テンプレートがない場合はエラーを返す", func(t *testing.T) {
+		// Arrange
+		analyzer := &LLMAnalyzer{
+			LLM:                 &llm.FakeProvider{},
+			PromptTemplateStore: &fakePromptTemplateStore{},
+			TaskStore:           &fakeTaskStore{},
+			TransactionStore:    fakeTransactionStore{},
+		}
+
+		// Act
+		_, versionErr := analyzer.PromptVersion(fakeTransaction{})
+		_, err := analyzer.Analyze(t.Context(), input)
+
+		// Assert
+		assert.Error(t, versionErr)
+		assert.Error(t, err)
+	})
+}
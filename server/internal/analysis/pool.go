@@ -0,0 +1,300 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+	// 失敗したジョブを含めて実行する回数の上限
+	DefaultMaxAttempts = 3
+	// 1回の解析の制限時間
+	analyzeTimeout = 2 * time.Minute
+	// 再試行までの待ち時間。失敗するたびに倍にし、maxRetryDelayで打ち止めにする。
+	baseRetryDelay = 30 * time.Second
+	maxRetryDelay  = 10 * time.Minute
+	// 次の実行予定を待つ最短の間隔（DBエラー時に空回りしないため）
+	minPollInterval = time.Second
+)
+
+var errCaptureNotFound = errors.New("capture not found")
+
+// キャプチャの解析ジョブを、同時実行数を制限したワーカーでバックグラウンド実行する。
+//
+// ジョブはDBに永続化されるため、サーバーを再起動しても未完了のジョブは再開される。
+type Pool struct {
+	CaptureStore            store.CaptureStore
+	CaptureAnalysisJobStore store.CaptureAnalysisJobStore
+	TransactionStore        store.TransactionStore
+	Storage                 *capture.Storage
+	Analyzer                Analyzer
+	workers                 int
+	trigger                 chan struct{}
+	// n回目の失敗の後、再試行するまでの待ち時間（テストで差し替える）
+	retryDelay func(n int) time.Duration
+}
+
+func NewPool(captureStore store.CaptureStore, captureAnalysisJobStore store.CaptureAnalysisJobStore, transactionStore store.TransactionStore, storage *capture.Storage, analyzer Analyzer, workers int) *Pool {
+	return &Pool{
+		CaptureStore:            captureStore,
+		CaptureAnalysisJobStore: captureAnalysisJobStore,
+		TransactionStore:        transactionStore,
+		Storage:                 storage,
+		Analyzer:                analyzer,
+		workers:                 max(workers, 1),
+		trigger:                 make(chan struct{}, 1),
+		retryDelay:              RetryDelay,
+	}
+}
+
+// captureIDの解析ジョブを現在のプロンプトで作成する。実行するには、txをコミットした後にTriggerを呼ぶこと。
+func (p *Pool) Enqueue(tx store.Transaction, captureID string) (datamodel.CaptureAnalysisJob, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return datamodel.CaptureAnalysisJob{}, fmt.Errorf("failed to generate analysis job id: %w", err)
+	}
+	promptVersion, err := p.Analyzer.PromptVersion(tx)
+	if err != nil {
+		return datamodel.CaptureAnalysisJob{}, fmt.Errorf("failed to get prompt version: %w", err)
+	}
+	now := time.Now()
+	job, err := p.CaptureAnalysisJobStore.CreateCaptureAnalysisJob(tx, datamodel.CaptureAnalysisJob{
+		ID:            id.String(),
+		CaptureID:     captureID,
+		PromptVersion: promptVersion,
+		MaxAttempts:   DefaultMaxAttempts,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return datamodel.CaptureAnalysisJob{}, fmt.Errorf("failed to create analysis job: %w", err)
+	}
+	return job, nil
+}
+
+// 実行待ちのジョブの確認を要求する。
+//
+// ブロックしない。
+func (p *Pool) Trigger() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// ctxがキャンセルされるまで、空いているワーカーに実行できるジョブを割り当てる。
+//
+// 起動時に、前回終了時に実行中だったジョブを実行待ちに戻す。終了時は実行中のジョブの終了を待つ。
+func (p *Pool) Run(ctx context.Context) {
+	if err := p.requeueRunning(); err != nil {
+		log.Printf("failed to requeue running analysis jobs: %v", err)
+	}
+	slots := make(chan struct{}, p.workers)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		p.dispatch(ctx, slots, &wg)
+
+		// ワーカーが埋まっている場合は、ワーカーの終了時のTriggerを待つ
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if len(slots) < cap(slots) {
+			if next, err := p.nextAttemptAt(); err != nil {
+				log.Printf("failed to get next analysis job: %v", err)
+			} else if next != nil {
+				timer = time.NewTimer(max(time.Until(*next), minPollInterval))
+				timerC = timer.C
+			}
+		}
+		select {
+		case <-ctx.Done():
+		case <-p.trigger:
+		case <-timerC:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// ワーカーが空いている間、実行できるジョブを取り出して実行を始める
+func (p *Pool) dispatch(ctx context.Context, slots chan struct{}, wg *sync.WaitGroup) {
+	for ctx.Err() == nil {
+		select {
+		case slots <- struct{}{}:
+		default:
+			return
+		}
+		job, err := p.claim()
+		if err != nil {
+			log.Printf("failed to claim analysis job: %v", err)
+		}
+		if job == nil {
+			<-slots
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.process(ctx, *job)
+			<-slots
+			p.Trigger()
+		}()
+	}
+}
+
+// jobを実行し、結果を記録する
+func (p *Pool) process(ctx context.Context, job datamodel.CaptureAnalysisJob) {
+	result, err := p.analyze(ctx, job)
+	// 終了時に中断したジョブは実行中のまま残し、次の起動時に再実行する
+	if ctx.Err() != nil {
+		return
+	}
+	if err == nil {
+		err = p.complete(job, result)
+		if err == nil {
+			return
+		}
+	}
+	log.Printf("failed to analyze capture %s (job %s, attempt %d/%d): %v", job.CaptureID, job.ID, job.Attempts, job.MaxAttempts, err)
+	var retryAt *time.Time
+	if job.Attempts < job.MaxAttempts && !errors.Is(err, errCaptureNotFound) {
+		next := time.Now().Add(p.retryDelay(job.Attempts))
+		retryAt = &next
+	}
+	if err := p.fail(job, err, retryAt); err != nil {
+		log.Printf("failed to record analysis job failure %s: %v", job.ID, err)
+	}
+}
+
+func (p *Pool) analyze(ctx context.Context, job datamodel.CaptureAnalysisJob) (Result, error) {
+	tx, err := p.TransactionStore.Begin()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	c, err := p.CaptureStore.GetCapture(tx, job.CaptureID)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to get capture: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return Result{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if c == nil {
+		return Result{}, errCaptureNotFound
+	}
+	data, err := p.Storage.Read(c.Path)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read capture file: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, analyzeTimeout)
+	defer cancel()
+	return p.Analyzer.Analyze(ctx, Input{Capture: *c, Image: data})
+}
+
+// n回目の失敗の後、再試行するまでの待ち時間
+func RetryDelay(n int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < n && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+func (p *Pool) claim() (*datamodel.CaptureAnalysisJob, error) {
+	tx, err := p.TransactionStore.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	job, err := p.CaptureAnalysisJobStore.ClaimNextCaptureAnalysisJob(tx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return job, nil
+}
+
+func (p *Pool) complete(job datamodel.CaptureAnalysisJob, result Result) error {
+	tx, err := p.TransactionStore.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	category := result.Category
+	if !datamodel.IsActivityCategory(category) {
+		category = datamodel.ActivityCategoryOther
+	}
+	if err := p.CaptureAnalysisJobStore.CompleteCaptureAnalysisJob(tx, job.ID, store.CaptureAnalysisResult{
+		PromptVersion: result.PromptVersion,
+		Category:      category,
+		Summary:       result.Summary,
+		Suggestions:   result.Suggestions,
+	}, time.Now()); err != nil {
+		return fmt.Errorf("failed to complete analysis job: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (p *Pool) fail(job datamodel.CaptureAnalysisJob, cause error, retryAt *time.Time) error {
+	tx, err := p.TransactionStore.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := p.CaptureAnalysisJobStore.FailCaptureAnalysisJob(tx, job.ID, cause.Error(), retryAt, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *Pool) nextAttemptAt() (*time.Time, error) {
+	tx, err := p.TransactionStore.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	next, err := p.CaptureAnalysisJobStore.GetNextCaptureAnalysisJobAt(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return next, nil
+}
+
+func (p *Pool) requeueRunning() error {
+	tx, err := p.TransactionStore.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	requeued, err := p.CaptureAnalysisJobStore.RequeueRunningCaptureAnalysisJobs(tx, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if requeued > 0 {
+		log.Printf("requeued %d interrupted analysis jobs", requeued)
+	}
+	return nil
+}
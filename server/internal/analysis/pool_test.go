@@ -0,0 +1,287 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTransaction struct{}
+
+func (fakeTransaction) Commit() error   { return nil }
+func (fakeTransaction) Rollback() error { return nil }
+
+type fakeTransactionStore struct{}
+
+func (fakeTransactionStore) Begin() (store.Transaction, error) { return fakeTransaction{}, nil }
+
+// Poolが使うメソッドだけを実装する。それ以外を呼ぶとpanicする。
+type fakeCaptureStore struct {
+	store.CaptureStore
+	captures map[string]datamodel.Capture
+}
+
+func (s *fakeCaptureStore) GetCapture(tx store.Transaction, id string) (*datamodel.Capture, error) {
+	c, ok := s.captures[id]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+// ジョブをメモリに保持する。Poolが使うメソッドだけを実装する。
+type fakeCaptureAnalysisJobStore struct {
+	store.CaptureAnalysisJobStore
+	mu   sync.Mutex
+	jobs []datamodel.CaptureAnalysisJob
+	// FailCaptureAnalysisJobで記録されたエラーメッセージ
+	failures []string
+}
+
+func (s *fakeCaptureAnalysisJobStore) CreateCaptureAnalysisJob(tx store.Transaction, job datamodel.CaptureAnalysisJob) (datamodel.CaptureAnalysisJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.Status = datamodel.CaptureAnalysisJobStatusQueued
+	job.Suggestions = []string{}
+	s.jobs = append(s.jobs, job)
+	return job, nil
+}
+
+func (s *fakeCaptureAnalysisJobStore) ClaimNextCaptureAnalysisJob(tx store.Transaction, now time.Time) (*datamodel.CaptureAnalysisJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.jobs {
+		job := &s.jobs[i]
+		if job.Status == datamodel.CaptureAnalysisJobStatusQueued && !job.NextAttemptAt.After(now) {
+			job.Status = datamodel.CaptureAnalysisJobStatusRunning
+			job.Attempts++
+			job.StartedAt = &now
+			claimed := *job
+			return &claimed, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeCaptureAnalysisJobStore) GetNextCaptureAnalysisJobAt(tx store.Transaction) (*time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next *time.Time
+	for _, job := range s.jobs {
+		if job.Status == datamodel.CaptureAnalysisJobStatusQueued && (next == nil || job.NextAttemptAt.Before(*next)) {
+			at := job.NextAttemptAt
+			next = &at
+		}
+	}
+	return next, nil
+}
+
+func (s *fakeCaptureAnalysisJobStore) CompleteCaptureAnalysisJob(tx store.Transaction, id string, result store.CaptureAnalysisResult, now time.Time) error {
+	return s.update(id, func(job *datamodel.CaptureAnalysisJob) {
+		job.Status = datamodel.CaptureAnalysisJobStatusSucceeded
+		job.PromptVersion = result.PromptVersion
+		job.Category = &result.Category
+		job.Summary = &result.Summary
+		job.Suggestions = result.Suggestions
+		job.ErrorMessage = nil
+		job.FinishedAt = &now
+	})
+}
+
+func (s *fakeCaptureAnalysisJobStore) FailCaptureAnalysisJob(tx store.Transaction, id string, errorMessage string, retryAt *time.Time, now time.Time) error {
+	return s.update(id, func(job *datamodel.CaptureAnalysisJob) {
+		s.failures = append(s.failures, errorMessage)
+		job.ErrorMessage = &errorMessage
+		if retryAt != nil {
+			job.Status = datamodel.CaptureAnalysisJobStatusQueued
+			job.NextAttemptAt = *retryAt
+			return
+		}
+		job.Status = datamodel.CaptureAnalysisJobStatusFailed
+		job.FinishedAt = &now
+	})
+}
+
+func (s *fakeCaptureAnalysisJobStore) RequeueRunningCaptureAnalysisJobs(tx store.Transaction, now time.Time) (int, error) {
+	return 0, nil
+}
+
+func (s *fakeCaptureAnalysisJobStore) update(id string, apply func(job *datamodel.CaptureAnalysisJob)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.jobs {
+		if s.jobs[i].ID == id && s.jobs[i].Status == datamodel.CaptureAnalysisJobStatusRunning {
+			apply(&s.jobs[i])
+		}
+	}
+	return nil
+}
+
+func (s *fakeCaptureAnalysisJobStore) get(id string) datamodel.CaptureAnalysisJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return datamodel.CaptureAnalysisJob{}
+}
+
+// errorsを先頭から1つずつ返し、使い切った後はresultを返すAnalyzer
+type fakeAnalyzer struct {
+	mu     sync.Mutex
+	errors []error
+	result Result
+	inputs []Input
+}
+
+func (a *fakeAnalyzer) PromptVersion(tx store.Transaction) (int, error) {
+	return a.result.PromptVersion, nil
+}
+
+func (a *fakeAnalyzer) Analyze(ctx context.Context, input Input) (Result, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.inputs = append(a.inputs, input)
+	if len(a.errors) > 0 {
+		err := a.errors[0]
+		a.errors = a.errors[1:]
+		return Result{}, err
+	}
+	return a.result, nil
+}
+
+func (a *fakeAnalyzer) calls() []Input {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Input{}, a.inputs...)
+}
+
+// 画像を保存したキャプチャを1件持つPoolを作り、そのキャプチャの解析ジョブを作成する
+func newTestPool(t *testing.T, analyzer Analyzer, image []byte) (*Pool, *fakeCaptureAnalysisJobStore, datamodel.CaptureAnalysisJob) {
+	t.Helper()
+	storage := &capture.Storage{Dir: t.TempDir()}
+	capturedAt := time.Date(2025, 11, 18, 9, 0, 0, 0, time.UTC)
+	path, err := storage.Save("capture-1", "png", capturedAt, image)
+	if err != nil {
+		t.Fatalf("failed to save capture: %v", err)
+	}
+	captureStore := &fakeCaptureStore{captures: map[string]datamodel.Capture{
+		"capture-1": {ID: "capture-1", Path: path, Format: "png", Width: 64, Height: 48, CapturedAt: capturedAt},
+	}}
+	jobStore := &fakeCaptureAnalysisJobStore{}
+	pool := NewPool(captureStore, jobStore, fakeTransactionStore{}, storage, analyzer, 1)
+	pool.retryDelay = func(int) time.Duration { return 0 }
+	job, err := pool.Enqueue(fakeTransaction{}, "capture-1")
+	if err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	return pool, jobStore, job
+}
+
+// poolを実行し、jobIDのジョブが終了（succeeded/failed）するまで待つ
+func runPoolUntilFinished(t *testing.T, pool *Pool, jobStore *fakeCaptureAnalysisJobStore, jobID string) datamodel.CaptureAnalysisJob {
+	t.Helper()
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pool.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job := jobStore.get(jobID)
+		if job.Status == datamodel.CaptureAnalysisJobStatusSucceeded || job.Status == datamodel.CaptureAnalysisJobStatusFailed {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("analysis job did not finish: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPool(t *testing.T) {
+	image := []byte("png image")
+
+	t.Run("ジョブを取り出して保存した画像を解析し、結果を記録する", func(t *testing.T) {
+		// Arrange
+		analyzer := &fakeAnalyzer{result: Result{PromptVersion: 2, Category: datamodel.ActivityCategoryWork, Summary: "設計書を書いている", Suggestions: []string{"休憩する"}}}
+		pool, jobStore, job := newTestPool(t, analyzer, image)
+
+		// Act
+		finished := runPoolUntilFinished(t, pool, jobStore, job.ID)
+
+		// Assert
+		assert.Equal(t, 2, job.PromptVersion)
+		assert.Equal(t, datamodel.CaptureAnalysisJobStatusSucceeded, finished.Status)
+		assert.Equal(t, 1, finished.Attempts)
+		assert.Equal(t, 2, finished.PromptVersion)
+		assert.Equal(t, datamodel.ActivityCategoryWork, *finished.Category)
+		assert.Equal(t, "設計書を書いている", *finished.Summary)
+		assert.Equal(t, []string{"休憩する"}, finished.Suggestions)
+		calls := analyzer.calls()
+		if assert.Len(t, calls, 1) {
+			assert.Equal(t, "capture-1", calls[0].Capture.ID)
+			assert.Equal(t, image, calls[0].Image)
+		}
+	})
+
+	t.Run("解析に失敗したジョブは再試行し、不明なカテゴリはotherとして記録する", func(t *testing.T) {
+		// Arrange
+		analyzer := &fakeAnalyzer{
+			errors: []error{errors.New("llm unavailable")},
+			result: Result{PromptVersion: 1, Category: "gaming", Summary: "ゲームをしている", Suggestions: []string{}},
+		}
+		pool, jobStore, job := newTestPool(t, analyzer, image)
+
+		// Act
+		finished := runPoolUntilFinished(t, pool, jobStore, job.ID)
+
+		// Assert
+		assert.Equal(t, datamodel.CaptureAnalysisJobStatusSucceeded, finished.Status)
+		assert.Equal(t, 2, finished.Attempts)
+		assert.Equal(t, datamodel.ActivityCategoryOther, *finished.Category)
+		assert.Equal(t, "ゲームをしている", *finished.Summary)
+		assert.Nil(t, finished.ErrorMessage)
+		assert.Equal(t, []string{"llm unavailable"}, jobStore.failures)
+		assert.Len(t, analyzer.calls(), 2)
+	})
+
+	t.Run("実行回数の上限まで失敗したジョブはfailedにする", func(t *testing.T) {
+		// Arrange
+		failure := errors.New("invalid response")
+		analyzer := &fakeAnalyzer{errors: []error{failure, failure, failure, failure}}
+		pool, jobStore, job := newTestPool(t, analyzer, image)
+
+		// Act
+		finished := runPoolUntilFinished(t, pool, jobStore, job.ID)
+
+		// Assert
+		assert.Equal(t, datamodel.CaptureAnalysisJobStatusFailed, finished.Status)
+		assert.Equal(t, DefaultMaxAttempts, finished.Attempts)
+		assert.Equal(t, "invalid response", *finished.ErrorMessage)
+		assert.Len(t, analyzer.calls(), DefaultMaxAttempts)
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, RetryDelay(1))
+	assert.Equal(t, time.Minute, RetryDelay(2))
+	assert.Equal(t, 2*time.Minute, RetryDelay(3))
+	assert.Equal(t, 8*time.Minute, RetryDelay(5))
+	assert.Equal(t, 10*time.Minute, RetryDelay(6))
+	assert.Equal(t, 10*time.Minute, RetryDelay(100))
+}
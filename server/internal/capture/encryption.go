@@ -0,0 +1,169 @@
+package capture
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// AES-256の鍵の長さ（バイト）
+	encryptionKeySize = 32
+	// 暗号化したファイルに記録する鍵IDの長さ（バイト）
+	encryptionKeyIDSize = 8
+)
+
+// 暗号化したファイルの先頭に付けるマジックナンバー。PNG/JPEGのシグネチャとは重ならない
+var encryptedFileMagic = []byte("LTMENC01")
+
+var (
+	// 暗号化されたファイルを、鍵を設定していないStorageで読もうとした
+	ErrEncryptionKeyRequired = errors.New("capture file is encrypted but no encryption key is configured")
+	// ファイルを暗号化した鍵と復号に使う鍵が異なる
+	ErrEncryptionKeyMismatch = errors.New("capture file is encrypted with a different key")
+)
+
+// キャプチャ画像のファイルをAES-256-GCMで暗号化・復号する。
+//
+// 暗号化したファイルは マジックナンバー | 鍵ID | nonce | 暗号文 の形式で保存する。
+// 鍵IDは鍵のSHA-256の先頭8バイトで、鍵のローテーション時にどちらの鍵で暗号化したかを判別するために使う。
+type Encryptor struct {
+	aead  cipher.AEAD
+	keyID []byte
+}
+
+func NewEncryptor(key []byte) (*Encryptor, error) {
+	if len(key) != encryptionKeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes", encryptionKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	sum := sha256.Sum256(key)
+	return &Encryptor{aead: aead, keyID: sum[:encryptionKeyIDSize]}, nil
+}
+
+// plaintextを暗号化したファイルの内容を返す
+func (e *Encryptor) Encrypt(plaintext []byte) ([]byte, error) {
+	header := e.header()
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	data := make([]byte, 0, len(header)+len(nonce)+len(plaintext)+e.aead.Overhead())
+	data = append(data, header...)
+	data = append(data, nonce...)
+	// ヘッダも認証対象にして、鍵IDの改ざんを検出する
+	return e.aead.Seal(data, nonce, plaintext, header), nil
+}
+
+// Encryptで暗号化したファイルの内容を復号する。
+//
+// 別の鍵で暗号化されている場合はErrEncryptionKeyMismatchを返す。
+func (e *Encryptor) Decrypt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, errors.New("capture file is not encrypted")
+	}
+	if !e.Encrypted(data) {
+		return nil, ErrEncryptionKeyMismatch
+	}
+	headerSize := len(encryptedFileMagic) + encryptionKeyIDSize
+	nonceSize := e.aead.NonceSize()
+	if len(data) < headerSize+nonceSize {
+		return nil, errors.New("encrypted capture file is truncated")
+	}
+	nonce := data[headerSize : headerSize+nonceSize]
+	plaintext, err := e.aead.Open(nil, nonce, data[headerSize+nonceSize:], data[:headerSize])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt capture file: %w", err)
+	}
+	return plaintext, nil
+}
+
+// dataがこの鍵で暗号化されているかを返す
+func (e *Encryptor) Encrypted(data []byte) bool {
+	return bytes.HasPrefix(data, e.header())
+}
+
+func (e *Encryptor) header() []byte {
+	return append(append([]byte{}, encryptedFileMagic...), e.keyID...)
+}
+
+// dataが暗号化されたファイルの内容かを返す
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedFileMagic)
+}
+
+// 鍵ファイルpathから鍵を読み込む。
+//
+// 鍵ファイルには鍵をBase64で記録する。所有者以外が読み書きできるパーミッションの場合はエラーを返す。
+func LoadEncryptionKey(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat encryption key file: %w", err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("encryption key file %s must not be accessible by group or others (mode %04o)", path, info.Mode().Perm())
+	}
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encryption key: %w", err)
+	}
+	if len(key) != encryptionKeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes", encryptionKeySize)
+	}
+	return key, nil
+}
+
+// 新しい鍵を生成して鍵ファイルpathに書き込み、その鍵を返す。既にファイルが存在する場合はエラーを返す。
+func CreateEncryptionKey(path string) ([]byte, error) {
+	key := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create encryption key directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encryption key file: %w", err)
+	}
+	if _, err := file.WriteString(base64.StdEncoding.EncodeToString(key) + "\n"); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write encryption key file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to close encryption key file: %w", err)
+	}
+	return key, nil
+}
+
+// 鍵ファイルpathの鍵でEncryptorを作成する。鍵ファイルが存在しない場合は新しい鍵を生成する。
+func LoadOrCreateEncryptor(path string) (*Encryptor, error) {
+	key, err := LoadEncryptionKey(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err = CreateEncryptionKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewEncryptor(key)
+}
@@ -0,0 +1,215 @@
+package capture
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEncryptor(t *testing.T, seed byte) *Encryptor {
+	encryptor, err := NewEncryptor(bytes.Repeat([]byte{seed}, encryptionKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encryptor
+}
+
+func TestEncryptor(t *testing.T) {
+	t.Run("暗号化したデータを復号すると元に戻る", func(t *testing.T) {
+		// Arrange
+		encryptor := newTestEncryptor(t, 1)
+		plaintext := []byte("\x89PNG\r\n\x1a\n image data")
+
+		// Act
+		encrypted, err := encryptor.Encrypt(plaintext)
+		assert.NoError(t, err)
+		decrypted, err := encryptor.Decrypt(encrypted)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+		assert.True(t, IsEncrypted(encrypted))
+		assert.False(t, bytes.Contains(encrypted, []byte("image data")))
+	})
+
+	t.Run("改ざんされたデータは復号できない", func(t *testing.T) {
+		// Arrange
+		encryptor := newTestEncryptor(t, 1)
+		encrypted, err := encryptor.Encrypt([]byte("image data"))
+		assert.NoError(t, err)
+		encrypted[len(encrypted)-1] ^= 0xff
+
+		// Act
+		_, err = encryptor.Decrypt(encrypted)
+
+		// Assert
+		assert.Error(t, err)
+	})
+
+	t.Run("別の鍵で暗号化したデータはErrEncryptionKeyMismatchを返す", func(t *testing.T) {
+		// Arrange
+		encrypted, err := newTestEncryptor(t, 1).Encrypt([]byte("image data"))
+		assert.NoError(t, err)
+
+		// Act
+		_, err = newTestEncryptor(t, 2).Decrypt(encrypted)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrEncryptionKeyMismatch)
+	})
+}
+
+func TestEncryptionKeyFile(t *testing.T) {
+	t.Run("鍵ファイルを所有者のみ読み書きできるパーミッションで生成し、読み込める", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "keys", "capture.key")
+
+		// Act
+		created, err := CreateEncryptionKey(path)
+		assert.NoError(t, err)
+		loaded, err := LoadEncryptionKey(path)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, created, loaded)
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		// 既存の鍵を上書きしない
+		_, err = CreateEncryptionKey(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("所有者以外が読める鍵ファイルは読み込まない", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "capture.key")
+		_, err := CreateEncryptionKey(path)
+		assert.NoError(t, err)
+		assert.NoError(t, os.Chmod(path, 0o644))
+
+		// Act
+		_, err = LoadEncryptionKey(path)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestStorageEncryption(t *testing.T) {
+	capturedAt := time.Date(2025, 11, 21, 9, 0, 0, 0, time.UTC)
+
+	t.Run("Encryptorを設定すると暗号化して保存し、読み込み時に復号する", func(t *testing.T) {
+		// Arrange
+		storage := Storage{Dir: t.TempDir(), Encryptor: newTestEncryptor(t, 1)}
+		plaintext := []byte("image data")
+
+		// Act
+		relPath, err := storage.Save("c1", FormatPNG, capturedAt, plaintext)
+		assert.NoError(t, err)
+		read, err := storage.Read(relPath)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, plaintext, read)
+		raw, err := os.ReadFile(storage.Path(relPath))
+		assert.NoError(t, err)
+		assert.True(t, IsEncrypted(raw))
+	})
+
+	t.Run("暗号化前に保存した平文のファイルも読み込め、鍵のないStorageは暗号化されたファイルを読めない", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		plain := Storage{Dir: dir}
+		encrypted := Storage{Dir: dir, Encryptor: newTestEncryptor(t, 1)}
+		plainPath, err := plain.Save("c1", FormatPNG, capturedAt, []byte("plain"))
+		assert.NoError(t, err)
+		encryptedPath, err := encrypted.Save("c2", FormatPNG, capturedAt, []byte("secret"))
+		assert.NoError(t, err)
+
+		// Act
+		readPlain, errPlain := encrypted.Read(plainPath)
+		_, errEncrypted := plain.Read(encryptedPath)
+
+		// Assert
+		assert.NoError(t, errPlain)
+		assert.Equal(t, []byte("plain"), readPlain)
+		assert.ErrorIs(t, errEncrypted, ErrEncryptionKeyRequired)
+	})
+
+	t.Run("Reencryptは全てのファイルを新しい鍵で暗号化し直し、再実行時は処理済みのファイルを飛ばす", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		oldStorage := Storage{Dir: dir, Encryptor: newTestEncryptor(t, 1)}
+		newEncryptor := newTestEncryptor(t, 2)
+		imagePath, err := oldStorage.Save("c1", FormatPNG, capturedAt, []byte("image"))
+		assert.NoError(t, err)
+		thumbPath, err := oldStorage.SaveThumbnail(imagePath, []byte("thumbnail"))
+		assert.NoError(t, err)
+		plainPath, err := (&Storage{Dir: dir}).Save("c2", FormatJPEG, capturedAt, []byte("plain"))
+		assert.NoError(t, err)
+
+		// Act
+		count, err := oldStorage.Reencrypt(newEncryptor)
+		assert.NoError(t, err)
+		recount, err := oldStorage.Reencrypt(newEncryptor)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+		assert.Equal(t, 0, recount)
+		newStorage := Storage{Dir: dir, Encryptor: newEncryptor}
+		for relPath, expected := range map[string]string{imagePath: "image", thumbPath: "thumbnail", plainPath: "plain"} {
+			read, err := newStorage.Read(relPath)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte(expected), read)
+			_, err = oldStorage.Read(relPath)
+			assert.ErrorIs(t, err, ErrEncryptionKeyMismatch)
+		}
+	})
+
+	t.Run("Reencryptは画像・サムネイル以外のファイルに触れない", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		oldStorage := Storage{Dir: dir, Encryptor: newTestEncryptor(t, 1)}
+		imagePath, err := oldStorage.Save("c1", FormatPNG, capturedAt, []byte("image"))
+		assert.NoError(t, err)
+		keyPath := filepath.Join(dir, "capture.key")
+		_, err = CreateEncryptionKey(keyPath)
+		assert.NoError(t, err)
+		key, err := os.ReadFile(keyPath)
+		assert.NoError(t, err)
+
+		// Act
+		count, err := oldStorage.Reencrypt(newTestEncryptor(t, 2))
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		afterKey, err := os.ReadFile(keyPath)
+		assert.NoError(t, err)
+		assert.Equal(t, key, afterKey)
+		_, err = oldStorage.Read(imagePath)
+		assert.ErrorIs(t, err, ErrEncryptionKeyMismatch)
+	})
+}
+
+func TestStorageContains(t *testing.T) {
+	dir := t.TempDir()
+	storage := Storage{Dir: filepath.Join(dir, "captures")}
+
+	for path, expected := range map[string]bool{
+		filepath.Join(dir, "captures", "capture.key"):          true,
+		filepath.Join(dir, "captures", "2025", "11", "c1.png"): true,
+		filepath.Join(dir, "captures", "..", "captures.key"):   false,
+		filepath.Join(dir, "capture.key"):                      false,
+		filepath.Join(dir, "captures-keys", "capture.key"):     false,
+	} {
+		inside, err := storage.Contains(path)
+		assert.NoError(t, err, path)
+		assert.Equal(t, expected, inside, path)
+	}
+}
@@ -0,0 +1,51 @@
+package capture
+
+import (
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+// 画面が変化しなかった期間
+type IdlePeriod struct {
+	Start time.Time
+	End   time.Time
+	// 期間の最初のキャプチャ
+	CaptureID string
+	// 期間中に撮影されたキャプチャの数（保存せずに捨てた重複を含む）
+	CaptureCount int
+}
+
+func (p IdlePeriod) Duration() time.Duration {
+	return p.End.Sub(p.Start)
+}
+
+// 撮影日時の古い順のcapturesから、直前のキャプチャとの重複が続いた期間のうちminDuration以上のものを返す。
+//
+// 期間は重複が始まる前のキャプチャの撮影日時から、最後の重複の撮影日時まで。
+func DetectIdlePeriods(captures []datamodel.Capture, minDuration time.Duration) []IdlePeriod {
+	periods := []IdlePeriod{}
+	var current *IdlePeriod
+	flush := func() {
+		if current != nil && current.CaptureCount > 1 && current.Duration() >= minDuration {
+			periods = append(periods, *current)
+		}
+		current = nil
+	}
+	for i, c := range captures {
+		continues := current != nil && c.DuplicateOf != nil && *c.DuplicateOf == captures[i-1].ID
+		if !continues {
+			flush()
+			current = &IdlePeriod{Start: c.CapturedAt, End: c.CapturedAt, CaptureID: c.ID}
+		}
+		current.CaptureCount += 1 + c.DuplicateCount
+		if c.CapturedAt.After(current.End) {
+			current.End = c.CapturedAt
+		}
+		if c.LastDuplicateAt != nil && c.LastDuplicateAt.After(current.End) {
+			current.End = *c.LastDuplicateAt
+		}
+	}
+	flush()
+	return periods
+}
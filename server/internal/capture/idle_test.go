@@ -0,0 +1,59 @@
+package capture
+
+import (
+	"testing"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectIdlePeriods(t *testing.T) {
+	base := time.Date(2025, 11, 17, 9, 0, 0, 0, time.UTC)
+	ptr := func(s string) *string { return &s }
+	at := func(min int) time.Time { return base.Add(time.Duration(min) * time.Minute) }
+
+	t.Run("重複が続いた期間を返し、短い期間は含めない", func(t *testing.T) {
+		// Arrange
+		lastDuplicateAt := at(40)
+		captures := []datamodel.Capture{
+			{ID: "c0", CapturedAt: at(0)},
+			{ID: "c1", CapturedAt: at(5), DuplicateOf: ptr("c0")},
+			{ID: "c2", CapturedAt: at(10), DuplicateOf: ptr("c1"), DuplicateCount: 2, LastDuplicateAt: &lastDuplicateAt},
+			{ID: "c3", CapturedAt: at(45)},
+			{ID: "c4", CapturedAt: at(50)},
+			{ID: "c5", CapturedAt: at(55), DuplicateOf: ptr("c4")},
+		}
+
+		// Act
+		periods := DetectIdlePeriods(captures, 10*time.Minute)
+
+		// Assert
+		assert.Equal(t, []IdlePeriod{
+			{Start: at(0), End: at(40), CaptureID: "c0", CaptureCount: 5},
+		}, periods)
+		assert.Equal(t, 40*time.Minute, periods[0].Duration())
+	})
+
+	t.Run("重複元が直前のキャプチャでない場合は期間を分ける", func(t *testing.T) {
+		// Arrange
+		captures := []datamodel.Capture{
+			{ID: "c0", CapturedAt: at(0)},
+			{ID: "c1", CapturedAt: at(10), DuplicateOf: ptr("c0")},
+			{ID: "c2", CapturedAt: at(20)},
+			{ID: "c3", CapturedAt: at(30), DuplicateOf: ptr("c0")},
+		}
+
+		// Act
+		periods := DetectIdlePeriods(captures, time.Minute)
+
+		// Assert
+		assert.Equal(t, []IdlePeriod{
+			{Start: at(0), End: at(10), CaptureID: "c0", CaptureCount: 2},
+		}, periods)
+	})
+
+	t.Run("キャプチャがない場合は空を返す", func(t *testing.T) {
+		assert.Empty(t, DetectIdlePeriods(nil, time.Minute))
+	})
+}
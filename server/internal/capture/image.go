@@ -0,0 +1,60 @@
+package capture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// 画像ファイルのメタデータ
+type ImageInfo struct {
+	Format string
+	Width  int
+	Height int
+	SHA256 string
+}
+
+// dataの内容からPNG/JPEGを判定し、メタデータを返す。
+//
+// 拡張子やContent-Typeヘッダは信用せず、先頭バイトから形式を判定する。
+// PNG/JPEG以外の場合はErrUnsupportedFormatを返す。
+func Inspect(data []byte) (ImageInfo, error) {
+	var format string
+	switch http.DetectContentType(data) {
+	case "image/png":
+		format = FormatPNG
+	case "image/jpeg":
+		format = FormatJPEG
+	default:
+		return ImageInfo{}, ErrUnsupportedFormat
+	}
+
+	config, decodedFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ImageInfo{}, fmt.Errorf("failed to decode image config: %w", err)
+	}
+	if decodedFormat != format {
+		return ImageInfo{}, ErrUnsupportedFormat
+	}
+
+	sum := sha256.Sum256(data)
+	return ImageInfo{
+		Format: format,
+		Width:  config.Width,
+		Height: config.Height,
+		SHA256: hex.EncodeToString(sum[:]),
+	}, nil
+}
@@ -0,0 +1,159 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+const (
+	// モザイクの1ブロックの大きさ（px）
+	pixelateBlockSize = 16
+	// ぼかしの半径（px）。箱型フィルタをblurPasses回重ねてガウスぼかしに近づける
+	blurRadius = 12
+	blurPasses = 3
+	// マスクをかけたJPEGを再エンコードする際の品質
+	maskJPEGQuality = 90
+)
+
+// displayIDのディスプレイで撮影したキャプチャに適用するルールを返す。
+//
+// ディスプレイを指定しないルールは常に適用する。displayIDが空の場合はディスプレイを指定したルールを適用しない。
+func MaskRulesForDisplay(rules []datamodel.MaskRule, displayID string) []datamodel.MaskRule {
+	matched := []datamodel.MaskRule{}
+	for _, rule := range rules {
+		if rule.DisplayID == nil || (displayID != "" && *rule.DisplayID == displayID) {
+			matched = append(matched, rule)
+		}
+	}
+	return matched
+}
+
+// dataの画像のうちrulesの矩形をぼかし、元と同じ形式（PNG/JPEG）で返す。
+//
+// 画像の範囲外は無視する。画像と重なるルールがない場合は再エンコードせずdataをそのまま返す。
+func ApplyMasks(data []byte, rules []datamodel.MaskRule) ([]byte, error) {
+	if len(rules) == 0 {
+		return data, nil
+	}
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	bounds := src.Bounds()
+	type mask struct {
+		rect image.Rectangle
+		mode string
+	}
+	masks := []mask{}
+	for _, rule := range rules {
+		rect := image.Rect(rule.X, rule.Y, rule.X+rule.Width, rule.Y+rule.Height).Add(bounds.Min).Intersect(bounds)
+		if !rect.Empty() {
+			masks = append(masks, mask{rect: rect, mode: rule.Mode})
+		}
+	}
+	if len(masks) == 0 {
+		return data, nil
+	}
+
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, src, bounds.Min, draw.Src)
+	for _, m := range masks {
+		switch m.mode {
+		case datamodel.MaskModePixelate:
+			pixelate(dst, m.rect)
+		case datamodel.MaskModeBlur:
+			blur(dst, m.rect)
+		default:
+			return nil, fmt.Errorf("unknown mask mode: %s", m.mode)
+		}
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case FormatPNG:
+		err = png.Encode(&buf, dst)
+	case FormatJPEG:
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: maskJPEGQuality})
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode masked image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// rectをpixelateBlockSize四方のブロックに分け、各ブロックをその平均色で塗りつぶす
+func pixelate(img *image.RGBA, rect image.Rectangle) {
+	for by := rect.Min.Y; by < rect.Max.Y; by += pixelateBlockSize {
+		for bx := rect.Min.X; bx < rect.Max.X; bx += pixelateBlockSize {
+			block := image.Rect(bx, by, bx+pixelateBlockSize, by+pixelateBlockSize).Intersect(rect)
+			var sum [4]int
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				offset := img.PixOffset(block.Min.X, y)
+				for i := 0; i < block.Dx()*4; i++ {
+					sum[i%4] += int(img.Pix[offset+i])
+				}
+			}
+			count := block.Dx() * block.Dy()
+			var average [4]uint8
+			for c := range average {
+				average[c] = uint8((sum[c] + count/2) / count)
+			}
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				offset := img.PixOffset(block.Min.X, y)
+				for i := 0; i < block.Dx()*4; i++ {
+					img.Pix[offset+i] = average[i%4]
+				}
+			}
+		}
+	}
+}
+
+// rectを箱型フィルタでぼかす。rectの外の画素は参照も変更もしない
+func blur(img *image.RGBA, rect image.Rectangle) {
+	width, height := rect.Dx(), rect.Dy()
+	values := make([]int, width*height*4)
+	for y := 0; y < height; y++ {
+		offset := img.PixOffset(rect.Min.X, rect.Min.Y+y)
+		for i := 0; i < width*4; i++ {
+			values[y*width*4+i] = int(img.Pix[offset+i])
+		}
+	}
+	prefix := make([]int, max(width, height)+1)
+	for range blurPasses {
+		// 横方向、縦方向の順にぼかす
+		boxBlur(values, prefix, width, 4, height, width*4)
+		boxBlur(values, prefix, height, width*4, width, 4)
+	}
+	for y := 0; y < height; y++ {
+		offset := img.PixOffset(rect.Min.X, rect.Min.Y+y)
+		for i := 0; i < width*4; i++ {
+			img.Pix[offset+i] = uint8(values[y*width*4+i])
+		}
+	}
+}
+
+// valuesに並んだlines本の画素の列（長さlength、画素の間隔step、列の先頭の間隔lineStep）を
+// チャンネルごとに半径blurRadiusの移動平均で置き換える。列の端では列内の画素だけで平均する。
+func boxBlur(values []int, prefix []int, length int, step int, lines int, lineStep int) {
+	for line := 0; line < lines; line++ {
+		base := line * lineStep
+		for c := 0; c < 4; c++ {
+			for i := 0; i < length; i++ {
+				prefix[i+1] = prefix[i] + values[base+i*step+c]
+			}
+			for i := 0; i < length; i++ {
+				lo, hi := max(i-blurRadius, 0), min(i+blurRadius+1, length)
+				count := hi - lo
+				values[base+i*step+c] = (prefix[hi] - prefix[lo] + count/2) / count
+			}
+		}
+	}
+}
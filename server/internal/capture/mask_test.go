@@ -0,0 +1,187 @@
+package capture
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/stretchr/testify/assert"
+)
+
+// 画素ごとに色が異なるwidth x heightの画像を返す
+func newPatternImage(width int, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 4), B: uint8((x + y) % 256), A: 255})
+		}
+	}
+	return img
+}
+
+// 1px単位の白黒の市松模様の画像を返す
+func newCheckerImage(width int, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if (x+y)%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodeImage(t *testing.T, data []byte) image.Image {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func toRGBA(c color.Color) color.RGBA {
+	return color.RGBAModel.Convert(c).(color.RGBA)
+}
+
+func TestApplyMasks(t *testing.T) {
+	t.Run("pixelateは矩形内をブロックごとの平均色で塗り、矩形外は変更しない", func(t *testing.T) {
+		// Arrange
+		src := newPatternImage(64, 64)
+		rect := image.Rect(16, 16, 48, 48)
+		rules := []datamodel.MaskRule{{X: 16, Y: 16, Width: 32, Height: 32, Mode: datamodel.MaskModePixelate}}
+
+		// Act
+		masked, err := ApplyMasks(encodePNG(t, src), rules)
+
+		// Assert
+		assert.NoError(t, err)
+		out := decodeImage(t, masked)
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				if !image.Pt(x, y).In(rect) {
+					assert.Equal(t, src.RGBAAt(x, y), toRGBA(out.At(x, y)), "(%d, %d)", x, y)
+				}
+			}
+		}
+		for by := 16; by < 48; by += pixelateBlockSize {
+			for bx := 16; bx < 48; bx += pixelateBlockSize {
+				var sumR, sumG int
+				for y := by; y < by+pixelateBlockSize; y++ {
+					for x := bx; x < bx+pixelateBlockSize; x++ {
+						sumR += int(src.RGBAAt(x, y).R)
+						sumG += int(src.RGBAAt(x, y).G)
+					}
+				}
+				count := pixelateBlockSize * pixelateBlockSize
+				for y := by; y < by+pixelateBlockSize; y++ {
+					for x := bx; x < bx+pixelateBlockSize; x++ {
+						c := toRGBA(out.At(x, y))
+						assert.Equal(t, uint8((sumR+count/2)/count), c.R, "(%d, %d)", x, y)
+						assert.Equal(t, uint8((sumG+count/2)/count), c.G, "(%d, %d)", x, y)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("blurは矩形内の模様を平滑化し、矩形外は変更しない", func(t *testing.T) {
+		// Arrange
+		src := newCheckerImage(64, 64)
+		rect := image.Rect(8, 8, 56, 56)
+		rules := []datamodel.MaskRule{{X: 8, Y: 8, Width: 48, Height: 48, Mode: datamodel.MaskModeBlur}}
+
+		// Act
+		masked, err := ApplyMasks(encodePNG(t, src), rules)
+
+		// Assert
+		assert.NoError(t, err)
+		out := decodeImage(t, masked)
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				got := toRGBA(out.At(x, y))
+				if !image.Pt(x, y).In(rect) {
+					assert.Equal(t, src.GrayAt(x, y).Y, got.R, "(%d, %d)", x, y)
+					continue
+				}
+				// 白黒が混ざって中間の灰色に近づく
+				assert.InDelta(t, 127, int(got.R), 16, "(%d, %d)", x, y)
+			}
+		}
+	})
+
+	t.Run("画像からはみ出したルールは画像内の部分だけに適用する", func(t *testing.T) {
+		// Arrange
+		src := newPatternImage(40, 40)
+		rules := []datamodel.MaskRule{{X: 32, Y: 32, Width: 100, Height: 100, Mode: datamodel.MaskModePixelate}}
+
+		// Act
+		masked, err := ApplyMasks(encodePNG(t, src), rules)
+
+		// Assert
+		assert.NoError(t, err)
+		out := decodeImage(t, masked)
+		assert.Equal(t, src.Bounds(), out.Bounds())
+		assert.Equal(t, src.RGBAAt(31, 31), toRGBA(out.At(31, 31)))
+		assert.Equal(t, toRGBA(out.At(32, 32)), toRGBA(out.At(39, 39)))
+	})
+
+	t.Run("画像と重なるルールがない場合は元のデータをそのまま返す", func(t *testing.T) {
+		// Arrange
+		data := encodePNG(t, newPatternImage(40, 40))
+		rules := []datamodel.MaskRule{{X: 100, Y: 100, Width: 10, Height: 10, Mode: datamodel.MaskModeBlur}}
+
+		// Act
+		masked, err := ApplyMasks(data, rules)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, data, masked)
+	})
+
+	t.Run("JPEGはJPEGのまま返す", func(t *testing.T) {
+		// Arrange
+		var buf bytes.Buffer
+		assert.NoError(t, jpeg.Encode(&buf, newCheckerImage(64, 48), nil))
+		rules := []datamodel.MaskRule{{X: 0, Y: 0, Width: 32, Height: 32, Mode: datamodel.MaskModePixelate}}
+
+		// Act
+		masked, err := ApplyMasks(buf.Bytes(), rules)
+
+		// Assert
+		assert.NoError(t, err)
+		info, err := Inspect(masked)
+		assert.NoError(t, err)
+		assert.Equal(t, FormatJPEG, info.Format)
+		assert.Equal(t, 64, info.Width)
+		assert.Equal(t, 48, info.Height)
+	})
+}
+
+func TestMaskRulesForDisplay(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	all := datamodel.MaskRule{X: 0, Y: 0, Width: 10, Height: 10, Mode: datamodel.MaskModeBlur}
+	primary := datamodel.MaskRule{DisplayID: ptr("primary"), X: 10, Y: 10, Width: 10, Height: 10, Mode: datamodel.MaskModeBlur}
+	secondary := datamodel.MaskRule{DisplayID: ptr("secondary"), X: 20, Y: 20, Width: 10, Height: 10, Mode: datamodel.MaskModePixelate}
+	rules := []datamodel.MaskRule{all, primary, secondary}
+
+	t.Run("ディスプレイ指定のないルールと一致するルールを返す", func(t *testing.T) {
+		assert.Equal(t, []datamodel.MaskRule{all, secondary}, MaskRulesForDisplay(rules, "secondary"))
+	})
+
+	t.Run("ディスプレイIDが空の場合はディスプレイ指定のないルールだけを返す", func(t *testing.T) {
+		assert.Equal(t, []datamodel.MaskRule{all}, MaskRulesForDisplay(rules, ""))
+	})
+}
@@ -0,0 +1,60 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math/bits"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// dHashで比較する隣接ピクセルの格子サイズ。横に1px多く縮小し、8x8=64bitのハッシュにする。
+const (
+	dHashWidth  = 9
+	dHashHeight = 8
+)
+
+// dataの画像の知覚ハッシュ(dHash)を返す。
+//
+// 画像を9x8のグレースケールに縮小し、各行で左のピクセルが右より明るいかを1bitとする。
+// 見た目が近い画像ほどハッシュのハミング距離が小さくなる。
+func PerceptualHash(data []byte) (uint64, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	gray := image.NewGray(image.Rect(0, 0, dHashWidth, dHashHeight))
+	draw.CatmullRom.Scale(gray, gray.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < dHashHeight; y++ {
+		for x := 0; x < dHashWidth-1; x++ {
+			hash <<= 1
+			if gray.GrayAt(x, y).Y > gray.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+// 2つのハッシュのハミング距離（異なるbit数）を返す
+func HammingDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// ハッシュをDBに保存する16桁の16進表現にする
+func FormatPerceptualHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// FormatPerceptualHashの16進表現をハッシュに戻す
+func ParsePerceptualHash(s string) (uint64, error) {
+	hash, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid perceptual hash %q: %w", s, err)
+	}
+	return hash, nil
+}
@@ -0,0 +1,78 @@
+package capture
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 左からx/width の明るさになる横グラデーションの画像を返す。reverseの場合は右から明るくなる。
+func newGradientImage(width int, height int, reverse bool) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8(x * 255 / (width - 1))
+			if reverse {
+				v = 255 - v
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func TestPerceptualHash(t *testing.T) {
+	t.Run("同じ画像は形式やサイズが違ってもハミング距離が小さい", func(t *testing.T) {
+		// Arrange
+		var pngBuf, jpegBuf bytes.Buffer
+		assert.NoError(t, png.Encode(&pngBuf, newGradientImage(320, 240, false)))
+		assert.NoError(t, jpeg.Encode(&jpegBuf, newGradientImage(640, 480, false), nil))
+
+		// Act
+		pngHash, err := PerceptualHash(pngBuf.Bytes())
+		assert.NoError(t, err)
+		jpegHash, err := PerceptualHash(jpegBuf.Bytes())
+		assert.NoError(t, err)
+
+		// Assert
+		assert.LessOrEqual(t, HammingDistance(pngHash, jpegHash), 4)
+	})
+
+	t.Run("見た目の異なる画像はハミング距離が大きい", func(t *testing.T) {
+		// Arrange
+		var a, b bytes.Buffer
+		assert.NoError(t, png.Encode(&a, newGradientImage(320, 240, false)))
+		assert.NoError(t, png.Encode(&b, newGradientImage(320, 240, true)))
+
+		// Act
+		hashA, err := PerceptualHash(a.Bytes())
+		assert.NoError(t, err)
+		hashB, err := PerceptualHash(b.Bytes())
+		assert.NoError(t, err)
+
+		// Assert
+		assert.Greater(t, HammingDistance(hashA, hashB), 32)
+	})
+
+	t.Run("画像でない場合はエラーを返す", func(t *testing.T) {
+		_, err := PerceptualHash([]byte("not an image"))
+		assert.Error(t, err)
+	})
+}
+
+func TestFormatPerceptualHash(t *testing.T) {
+	for _, hash := range []uint64{0, 1, 0xdeadbeefcafebabe, ^uint64(0)} {
+		s := FormatPerceptualHash(hash)
+		assert.Len(t, s, 16)
+		parsed, err := ParsePerceptualHash(s)
+		assert.NoError(t, err)
+		assert.Equal(t, hash, parsed)
+	}
+	_, err := ParsePerceptualHash("xyz")
+	assert.Error(t, err)
+}
@@ -0,0 +1,171 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+)
+
+const retentionCleanupInterval = time.Hour
+
+// キャプチャの保持ポリシー。nilの項目は無制限。
+type RetentionPolicy struct {
+	MaxItems *int
+	MaxDays  *int
+	MaxBytes *int64
+}
+
+func RetentionPolicyFromSettings(settings datamodel.Settings) RetentionPolicy {
+	return RetentionPolicy{
+		MaxItems: settings.RetentionMaxItems,
+		MaxDays:  settings.RetentionMaxDays,
+		MaxBytes: settings.RetentionMaxBytes,
+	}
+}
+
+// capturesのうちpolicyで削除対象になるものを返す。
+//
+// capturesは撮影日時の新しい順に並んでいること。新しいものから順に保持していき、
+// 件数・合計サイズ（元画像のみ）の上限を超えたもの、またはnowからMaxDays日より前に撮影されたものを削除対象とする。
+func SelectExpiredCaptures(captures []datamodel.Capture, policy RetentionPolicy, now time.Time) []datamodel.Capture {
+	expired := []datamodel.Capture{}
+	var totalBytes int64
+	for i, c := range captures {
+		totalBytes += c.SizeBytes
+		switch {
+		case policy.MaxItems != nil && i >= *policy.MaxItems:
+			expired = append(expired, c)
+		case policy.MaxDays != nil && c.CapturedAt.Before(now.AddDate(0, 0, -*policy.MaxDays)):
+			expired = append(expired, c)
+		case policy.MaxBytes != nil && totalBytes > *policy.MaxBytes:
+			expired = append(expired, c)
+		}
+	}
+	return expired
+}
+
+// 保持ポリシーを超えたキャプチャのファイルとレコードを定期的に削除する
+type RetentionCleaner struct {
+	CaptureStore     store.CaptureStore
+	SettingsStore    store.SettingsStore
+	TransactionStore store.TransactionStore
+	Storage          *Storage
+	trigger          chan struct{}
+}
+
+func NewRetentionCleaner(captureStore store.CaptureStore, settingsStore store.SettingsStore, transactionStore store.TransactionStore, storage *Storage) *RetentionCleaner {
+	return &RetentionCleaner{
+		CaptureStore:     captureStore,
+		SettingsStore:    settingsStore,
+		TransactionStore: transactionStore,
+		Storage:          storage,
+		trigger:          make(chan struct{}, 1),
+	}
+}
+
+// 削除を要求する。実行中の場合は現在の実行が終わった後にもう一度実行される。
+//
+// ブロックしない。
+func (c *RetentionCleaner) Trigger() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// ctxがキャンセルされるまで、起動直後・一定間隔ごと・Triggerされるたびに削除を行う
+func (c *RetentionCleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(retentionCleanupInterval)
+	defer ticker.Stop()
+	c.Trigger()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.trigger:
+		}
+		// 複数のcaseが同時に準備できた場合はランダムに選ばれるため、キャンセル済みなら削除を始めない
+		if ctx.Err() != nil {
+			return
+		}
+		if err := c.cleanup(ctx); err != nil {
+			log.Printf("failed to clean up captures: %v", err)
+		}
+	}
+}
+
+// 現在の設定で削除対象になるキャプチャと、その判定に使ったポリシーを返す
+func (c *RetentionCleaner) Plan(now time.Time) (RetentionPolicy, []datamodel.Capture, error) {
+	tx, err := c.TransactionStore.Begin()
+	if err != nil {
+		return RetentionPolicy{}, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	settings, err := c.SettingsStore.GetSettings(tx)
+	if err != nil {
+		return RetentionPolicy{}, nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+	captures, err := c.CaptureStore.GetAllCaptures(tx)
+	if err != nil {
+		return RetentionPolicy{}, nil, fmt.Errorf("failed to get captures: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return RetentionPolicy{}, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	policy := RetentionPolicyFromSettings(settings)
+	return policy, SelectExpiredCaptures(captures, policy, now), nil
+}
+
+func (c *RetentionCleaner) cleanup(ctx context.Context) error {
+	_, expired, err := c.Plan(time.Now())
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, capture := range expired {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := c.delete(capture); err != nil {
+			errs = append(errs, fmt.Errorf("capture %s: %w", capture.ID, err))
+		}
+	}
+	if len(expired) > 0 {
+		log.Printf("deleted %d expired captures", len(expired)-len(errs))
+	}
+	return errors.Join(errs...)
+}
+
+// レコードとファイルをまとめて削除する。
+//
+// ファイルの削除に失敗した場合はレコードの削除もロールバックする。
+func (c *RetentionCleaner) delete(capture datamodel.Capture) error {
+	tx, err := c.TransactionStore.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := c.CaptureStore.DeleteCapture(tx, capture.ID); err != nil {
+		return fmt.Errorf("failed to delete capture: %w", err)
+	}
+	if capture.ThumbPath != nil {
+		if err := c.Storage.Remove(*capture.ThumbPath); err != nil {
+			return err
+		}
+	}
+	if err := c.Storage.Remove(capture.Path); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
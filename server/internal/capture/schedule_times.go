@@ -0,0 +1,153 @@
+package capture
+
+import (
+	"fmt"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+// 次のキャプチャ時刻を探す日数の上限。これを超えて許可される時刻がない場合は予定なしとする。
+const captureSearchDays = 366
+
+// "HH:MM"を0時からの分に変換する。"24:00"は1440になる。
+func ParseClock(s string) (int, error) {
+	if len(s) != 5 || s[2] != ':' {
+		return 0, fmt.Errorf("invalid clock format: %q", s)
+	}
+	for _, i := range []int{0, 1, 3, 4} {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, fmt.Errorf("invalid clock format: %q", s)
+		}
+	}
+	hour := int(s[0]-'0')*10 + int(s[1]-'0')
+	minute := int(s[3]-'0')*10 + int(s[4]-'0')
+	if hour == 24 && minute == 0 {
+		return 24 * 60, nil
+	}
+	if hour > 23 || minute > 59 {
+		return 0, fmt.Errorf("invalid clock: %q", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// tがスケジュールの時間帯・除外日の条件でキャプチャを許可されているか
+func IsCaptureAllowed(schedule datamodel.CaptureSchedule, t time.Time) bool {
+	t = t.In(utils.GetJSTTimezone())
+	start, ok := nextAllowedOnDay(schedule, t, dayStart(t))
+	return ok && start.Equal(t)
+}
+
+// afterからinterval_min後以降で、最初にキャプチャを許可される時刻を返す。ジッターは含まない。
+//
+// captureSearchDays日以内に許可される時刻がない場合はfalseを返す。
+func NextCaptureTime(schedule datamodel.CaptureSchedule, after time.Time) (time.Time, bool) {
+	t := after.In(utils.GetJSTTimezone()).Add(time.Duration(schedule.IntervalMin) * time.Minute)
+	day := dayStart(t)
+	for i := 0; i <= captureSearchDays; i++ {
+		if next, ok := nextAllowedOnDay(schedule, t, day); ok {
+			return next, true
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}, false
+}
+
+// fromより後のキャプチャ時刻を最大count件返す。ジッターは含まない。
+func NextCaptureTimes(schedule datamodel.CaptureSchedule, from time.Time, count int) []time.Time {
+	times := []time.Time{}
+	t := from
+	for len(times) < count {
+		next, ok := NextCaptureTime(schedule, t)
+		if !ok {
+			break
+		}
+		times = append(times, next)
+		t = next
+	}
+	return times
+}
+
+// ジッターでずらせる最大の幅
+func JitterRange(schedule datamodel.CaptureSchedule) time.Duration {
+	return time.Duration(schedule.IntervalMin) * time.Minute * time.Duration(schedule.JitterPct) / 100
+}
+
+// tをジッターの幅にratio(-1〜1)を掛けた分だけずらす。ずらした時刻が許可されない場合はtを返す。
+func ApplyJitter(schedule datamodel.CaptureSchedule, t time.Time, ratio float64) time.Time {
+	if JitterRange(schedule) == 0 {
+		return t
+	}
+	jittered := t.Add(time.Duration(float64(JitterRange(schedule)) * ratio)).Truncate(time.Second)
+	if !IsCaptureAllowed(schedule, jittered) {
+		return t
+	}
+	return jittered
+}
+
+// day(JSTの0時)の中で、t以降にキャプチャを許可される最初の時刻を返す
+func nextAllowedOnDay(schedule datamodel.CaptureSchedule, t time.Time, day time.Time) (time.Time, bool) {
+	dayEnd := day.AddDate(0, 0, 1)
+	if !t.Before(dayEnd) {
+		return time.Time{}, false
+	}
+	date := day.Format(time.DateOnly)
+	for _, excluded := range schedule.ExcludedDates {
+		if excluded == date {
+			return time.Time{}, false
+		}
+	}
+	if len(schedule.TimeWindows) == 0 {
+		return latest(t, day), true
+	}
+
+	var next time.Time
+	found := false
+	for _, window := range schedule.TimeWindows {
+		if !containsWeekday(window.Weekdays, day.Weekday()) {
+			continue
+		}
+		startMin, err := ParseClock(window.Start)
+		if err != nil {
+			continue
+		}
+		endMin, err := ParseClock(window.End)
+		if err != nil {
+			continue
+		}
+		start := day.Add(time.Duration(startMin) * time.Minute)
+		end := day.Add(time.Duration(endMin) * time.Minute)
+		if !t.Before(end) {
+			continue
+		}
+		candidate := latest(t, start)
+		if !found || candidate.Before(next) {
+			next, found = candidate, true
+		}
+	}
+	return next, found
+}
+
+func containsWeekday(weekdays []int, weekday time.Weekday) bool {
+	if len(weekdays) == 0 {
+		return true
+	}
+	for _, w := range weekdays {
+		if w == int(weekday) {
+			return true
+		}
+	}
+	return false
+}
+
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,108 @@
+package capture
+
+import (
+	"testing"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseClock(t *testing.T) {
+	valid := map[string]int{"00:00": 0, "09:30": 570, "23:59": 1439, "24:00": 1440}
+	for s, expected := range valid {
+		minutes, err := ParseClock(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, minutes, s)
+	}
+	for _, s := range []string{"", "9:00", "24:01", "12:60", "ab:cd", "12-00", "+1:00"} {
+		_, err := ParseClock(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestNextCaptureTimes(t *testing.T) {
+	jst := utils.GetJSTTimezone()
+
+	t.Run("時間帯・除外日がない場合は interval_min ごとの時刻を返す", func(t *testing.T) {
+		// Arrange
+		schedule := datamodel.CaptureSchedule{IntervalMin: 30}
+		from := time.Date(2025, 11, 6, 23, 20, 0, 0, jst)
+
+		// Act
+		times := NextCaptureTimes(schedule, from, 3)
+
+		// Assert
+		assert.Equal(t, []time.Time{
+			time.Date(2025, 11, 6, 23, 50, 0, 0, jst),
+			time.Date(2025, 11, 7, 0, 20, 0, 0, jst),
+			time.Date(2025, 11, 7, 0, 50, 0, 0, jst),
+		}, times)
+	})
+
+	t.Run("時間帯の外と除外日を飛ばし、次の時間帯の開始から数える", func(t *testing.T) {
+		// Arrange
+		// 平日 9:00〜12:00 と 13:00〜18:00、2025-11-10(月)は除外
+		schedule := datamodel.CaptureSchedule{
+			IntervalMin: 60,
+			TimeWindows: []datamodel.CaptureTimeWindow{
+				{Weekdays: []int{1, 2, 3, 4, 5}, Start: "13:00", End: "18:00"},
+				{Weekdays: []int{1, 2, 3, 4, 5}, Start: "09:00", End: "12:00"},
+			},
+			ExcludedDates: []string{"2025-11-10"},
+		}
+		// 2025-11-07(金) 16:30
+		from := time.Date(2025, 11, 7, 16, 30, 0, 0, jst)
+
+		// Act
+		times := NextCaptureTimes(schedule, from, 6)
+
+		// Assert
+		assert.Equal(t, []time.Time{
+			time.Date(2025, 11, 7, 17, 30, 0, 0, jst),
+			time.Date(2025, 11, 11, 9, 0, 0, 0, jst),
+			time.Date(2025, 11, 11, 10, 0, 0, 0, jst),
+			time.Date(2025, 11, 11, 11, 0, 0, 0, jst),
+			time.Date(2025, 11, 11, 13, 0, 0, 0, jst),
+			time.Date(2025, 11, 11, 14, 0, 0, 0, jst),
+		}, times)
+	})
+
+	t.Run("許可される時刻がない場合は空を返す", func(t *testing.T) {
+		// Arrange
+		schedule := datamodel.CaptureSchedule{
+			IntervalMin: 60,
+			TimeWindows: []datamodel.CaptureTimeWindow{
+				{Weekdays: []int{}, Start: "09:00", End: "18:00"},
+			},
+		}
+		from := time.Date(2025, 11, 7, 16, 30, 0, 0, jst)
+		for d := 0; d <= captureSearchDays+1; d++ {
+			schedule.ExcludedDates = append(schedule.ExcludedDates, from.AddDate(0, 0, d).Format(time.DateOnly))
+		}
+
+		// Act
+		times := NextCaptureTimes(schedule, from, 3)
+
+		// Assert
+		assert.Empty(t, times)
+	})
+}
+
+func TestApplyJitter(t *testing.T) {
+	jst := utils.GetJSTTimezone()
+	schedule := datamodel.CaptureSchedule{
+		IntervalMin: 10,
+		TimeWindows: []datamodel.CaptureTimeWindow{{Start: "09:00", End: "18:00"}},
+		JitterPct:   20,
+	}
+	base := time.Date(2025, 11, 7, 12, 0, 0, 0, jst)
+
+	assert.Equal(t, 2*time.Minute, JitterRange(schedule))
+	assert.Equal(t, base.Add(2*time.Minute), ApplyJitter(schedule, base, 1))
+	assert.Equal(t, base.Add(-time.Minute), ApplyJitter(schedule, base, -0.5))
+	// 時間帯の外にずれる場合はずらさない
+	start := time.Date(2025, 11, 7, 9, 0, 0, 0, jst)
+	assert.Equal(t, start, ApplyJitter(schedule, start, -1))
+}
@@ -0,0 +1,302 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/google/uuid"
+)
+
+// クライアントごとの未送信キャプチャ要求のバッファ数。溢れた要求は捨てる。
+const captureRequestBufferSize = 4
+
+// ネイティブクライアントへのキャプチャ要求
+type CaptureRequest struct {
+	ID          string
+	ScheduleID  string
+	RequestedAt time.Time
+	// クライアントがキャプチャする際にブリッジへ渡すパラメータ
+	Params CaptureParams
+}
+
+// ブリッジのCaptureParamsに対応する撮影範囲の指定
+type CaptureParams struct {
+	Mode           string
+	Region         *datamodel.CaptureRegion // Modeがregionの場合のみ非nil
+	ExcludeWindows []string
+}
+
+// スケジュールに設定された撮影範囲を返す
+func CaptureParamsFromSchedule(schedule datamodel.CaptureSchedule) CaptureParams {
+	excludeWindows := schedule.ExcludeWindows
+	if excludeWindows == nil {
+		excludeWindows = []string{}
+	}
+	return CaptureParams{
+		Mode:           schedule.CaptureMode,
+		Region:         schedule.Region,
+		ExcludeWindows: excludeWindows,
+	}
+}
+
+// 接続中のネイティブクライアントにキャプチャ要求を配信する
+type CaptureRequestHub struct {
+	mu          sync.Mutex
+	subscribers map[chan CaptureRequest]struct{}
+	closed      bool
+}
+
+func NewCaptureRequestHub() *CaptureRequestHub {
+	return &CaptureRequestHub{
+		subscribers: map[chan CaptureRequest]struct{}{},
+	}
+}
+
+// キャプチャ要求の受信を開始し、受信用のチャネルと受信を終了する関数を返す。
+//
+// Closeされるとチャネルは閉じられる。
+func (h *CaptureRequestHub) Subscribe() (<-chan CaptureRequest, func()) {
+	ch := make(chan CaptureRequest, captureRequestBufferSize)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	h.subscribers[ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[ch]; ok {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// 全てのクライアントに要求を送り、受け付けたクライアント数を返す。
+//
+// ブロックしない。受信が追いついていないクライアントには送らない。
+func (h *CaptureRequestHub) Publish(request CaptureRequest) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delivered := 0
+	for ch := range h.subscribers {
+		select {
+		case ch <- request:
+			delivered++
+		default:
+		}
+	}
+	return delivered
+}
+
+// 接続中のクライアント数を返す
+func (h *CaptureRequestHub) SubscriberCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
+
+// 全ての受信用チャネルを閉じ、以降のSubscribeには閉じたチャネルを返す
+func (h *CaptureRequestHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for ch := range h.subscribers {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// アクティブなキャプチャスケジュールのinterval_minごとに、ネイティブクライアントへキャプチャ要求を送る。
+//
+// 時間帯・除外日の外では送らず、ジッターが設定されている場合は送る時刻をずらす。
+type Scheduler struct {
+	CaptureScheduleStore store.CaptureScheduleStore
+	CaptureEventStore    store.CaptureEventStore
+	TransactionStore     store.TransactionStore
+	Hub                  *CaptureRequestHub
+	reload               chan struct{}
+	// 以下はテストで差し替えられるようにする
+	// 戻り値は時刻を受け取るチャネルと停止する関数
+	newTimer func(d time.Duration) (<-chan time.Time, func())
+	now      func() time.Time
+	// -1〜1の乱数
+	jitterRatio func() float64
+}
+
+func NewScheduler(captureScheduleStore store.CaptureScheduleStore, captureEventStore store.CaptureEventStore, transactionStore store.TransactionStore, hub *CaptureRequestHub) *Scheduler {
+	return &Scheduler{
+		CaptureScheduleStore: captureScheduleStore,
+		CaptureEventStore:    captureEventStore,
+		TransactionStore:     transactionStore,
+		Hub:                  hub,
+		reload:               make(chan struct{}, 1),
+		newTimer: func(d time.Duration) (<-chan time.Time, func()) {
+			timer := time.NewTimer(d)
+			return timer.C, func() { timer.Stop() }
+		},
+		now: time.Now,
+		jitterRatio: func() float64 {
+			return rand.Float64()*2 - 1
+		},
+	}
+}
+
+// スケジュールの再読み込みを要求する。
+//
+// ブロックしない。
+func (s *Scheduler) Reload() {
+	select {
+	case s.reload <- struct{}{}:
+	default:
+	}
+}
+
+// ctxがキャンセルされるまでスケジュールに従ってキャプチャ要求を送る。
+//
+// 終了時にHubを閉じ、接続中のクライアントとのストリームを終わらせる。
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.Hub.Close()
+	var current *datamodel.CaptureSchedule
+	// ジッターを含まない次の要求時刻。ジッターが累積しないようにこれを基準に次の時刻を決める。
+	var next time.Time
+	var fire <-chan time.Time
+	stop := func() {}
+	defer func() { stop() }()
+
+	// afterの次の要求時刻にタイマーを設定する
+	scheduleNext := func(after time.Time) {
+		stop()
+		fire, stop = nil, func() {}
+		var ok bool
+		next, ok = NextCaptureTime(*current, after)
+		if !ok {
+			log.Printf("capture scheduler idle: schedule %s has no upcoming capture time", current.ID)
+			return
+		}
+		now := s.now()
+		d := ApplyJitter(*current, next, s.jitterRatio()).Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		fire, stop = s.newTimer(d)
+	}
+
+	s.Reload()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.reload:
+			loaded, err := s.loadActiveSchedule()
+			if err != nil {
+				log.Printf("failed to load capture schedule: %v", err)
+				continue
+			}
+			// 要求時刻の条件が変わらない場合は次の要求時刻を後ろ倒しにしないようタイマーを維持する。
+			// 撮影範囲の変更は次の要求から反映する
+			if current != nil && loaded != nil && sameCaptureTiming(*current, *loaded) {
+				current = loaded
+				continue
+			}
+			stop()
+			fire, stop = nil, func() {}
+			current = loaded
+			if loaded == nil {
+				log.Printf("capture scheduler idle: no active capture schedule")
+				continue
+			}
+			log.Printf("capture scheduler started: schedule %s every %d min", loaded.ID, loaded.IntervalMin)
+			scheduleNext(s.now())
+		case firedAt := <-fire:
+			if ctx.Err() != nil {
+				return
+			}
+			s.publish(CaptureRequest{
+				ID:          uuid.New().String(),
+				ScheduleID:  current.ID,
+				RequestedAt: firedAt,
+				Params:      CaptureParamsFromSchedule(*current),
+			})
+			// スリープ等で遅れた場合は溜まった分をまとめて送らず、現在時刻から数え直す
+			scheduleNext(latest(next, s.now()))
+		}
+	}
+}
+
+// 要求の受付を記録してからクライアントへ送る。クライアントがいない場合は記録せずに捨てる。
+func (s *Scheduler) publish(request CaptureRequest) {
+	if s.Hub.SubscriberCount() == 0 {
+		log.Printf("no capture client connected, capture request %s dropped", request.ID)
+		return
+	}
+	// クライアントが状態遷移を報告する前に要求が記録されているよう、先に記録する
+	if err := s.recordRequested(request); err != nil {
+		log.Printf("failed to record capture request %s: %v", request.ID, err)
+	}
+	if s.Hub.Publish(request) == 0 {
+		log.Printf("no capture client received capture request %s", request.ID)
+	}
+}
+
+func (s *Scheduler) recordRequested(request CaptureRequest) error {
+	tx, err := s.TransactionStore.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	scheduleID := request.ScheduleID
+	if err := s.CaptureEventStore.CreateCaptureEvent(tx, datamodel.CaptureEvent{
+		ID:         uuid.New().String(),
+		RequestID:  request.ID,
+		State:      datamodel.CaptureEventStateRequested,
+		Mode:       datamodel.CaptureModeScheduled,
+		ScheduleID: &scheduleID,
+		OccurredAt: request.RequestedAt,
+	}); err != nil {
+		return fmt.Errorf("failed to create capture event: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// 要求時刻の決定に関わる設定が同じか
+func sameCaptureTiming(a, b datamodel.CaptureSchedule) bool {
+	return a.ID == b.ID &&
+		a.IntervalMin == b.IntervalMin &&
+		a.JitterPct == b.JitterPct &&
+		reflect.DeepEqual(a.TimeWindows, b.TimeWindows) &&
+		reflect.DeepEqual(a.ExcludedDates, b.ExcludedDates)
+}
+
+func (s *Scheduler) loadActiveSchedule() (*datamodel.CaptureSchedule, error) {
+	tx, err := s.TransactionStore.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	schedule, err := s.CaptureScheduleStore.GetActiveCaptureSchedule(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active capture schedule: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return schedule, nil
+}
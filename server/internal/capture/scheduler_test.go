@@ -0,0 +1,249 @@
+package capture
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTransaction struct{}
+
+func (fakeTransaction) Commit() error   { return nil }
+func (fakeTransaction) Rollback() error { return nil }
+
+type fakeTransactionStore struct{}
+
+func (fakeTransactionStore) Begin() (store.Transaction, error) { return fakeTransaction{}, nil }
+
+// スケジューラが使うメソッドだけを実装する。それ以外を呼ぶとpanicする。
+type fakeCaptureScheduleStore struct {
+	store.CaptureScheduleStore
+	mu       sync.Mutex
+	schedule *datamodel.CaptureSchedule
+}
+
+func (s *fakeCaptureScheduleStore) GetActiveCaptureSchedule(tx store.Transaction) (*datamodel.CaptureSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.schedule == nil || !s.schedule.IsActive() {
+		return nil, nil
+	}
+	schedule := *s.schedule
+	return &schedule, nil
+}
+
+// アクティブなスケジュールの状態と間隔を変更する
+func (s *fakeCaptureScheduleStore) update(active bool, intervalMin int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schedule.State = datamodel.CaptureScheduleStateInactive
+	if active {
+		s.schedule.State = datamodel.CaptureScheduleStateActive
+	}
+	s.schedule.IntervalMin = intervalMin
+}
+
+// 記録された状態遷移を保持する
+type fakeCaptureEventStore struct {
+	store.CaptureEventStore
+	mu     sync.Mutex
+	events []datamodel.CaptureEvent
+}
+
+func (s *fakeCaptureEventStore) CreateCaptureEvent(tx store.Transaction, event datamodel.CaptureEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *fakeCaptureEventStore) recorded() []datamodel.CaptureEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]datamodel.CaptureEvent{}, s.events...)
+}
+
+// 手動で時刻を送るタイマー
+type fakeTimer struct {
+	c       chan time.Time
+	stopped chan struct{}
+}
+
+// 現在時刻をnowに固定し、ジッターは常に最大幅(ratio=1)にする。
+//
+// newTimerが呼ばれるたびに、待ち時間とタイマーをtimersに送る。
+func newFakeScheduler(t *testing.T, scheduleStore *fakeCaptureScheduleStore, now time.Time) (*Scheduler, chan time.Duration, chan *fakeTimer) {
+	t.Helper()
+	intervals := make(chan time.Duration, 8)
+	timers := make(chan *fakeTimer, 8)
+	scheduler := NewScheduler(scheduleStore, &fakeCaptureEventStore{}, fakeTransactionStore{}, NewCaptureRequestHub())
+	scheduler.now = func() time.Time { return now }
+	scheduler.jitterRatio = func() float64 { return 1 }
+	scheduler.newTimer = func(d time.Duration) (<-chan time.Time, func()) {
+		timer := &fakeTimer{c: make(chan time.Time), stopped: make(chan struct{})}
+		intervals <- d
+		timers <- timer
+		return timer.c, func() { close(timer.stopped) }
+	}
+	return scheduler, intervals, timers
+}
+
+func receiveWithin[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		t.Fatalf("timed out")
+		var zero T
+		return zero
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	t.Run("アクティブなスケジュールの間隔ごとに接続中のクライアントへキャプチャ要求を送る", func(t *testing.T) {
+		// Arrange
+		scheduleStore := &fakeCaptureScheduleStore{schedule: &datamodel.CaptureSchedule{ID: "schedule-1", State: datamodel.CaptureScheduleStateActive, IntervalMin: 5}}
+		now := time.Date(2025, 11, 6, 10, 0, 0, 0, time.UTC)
+		scheduler, intervals, timers := newFakeScheduler(t, scheduleStore, now)
+		requests, unsubscribe := scheduler.Hub.Subscribe()
+		defer unsubscribe()
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		go scheduler.Run(ctx)
+
+		// Act
+		assert.Equal(t, 5*time.Minute, receiveWithin(t, intervals))
+		timer := receiveWithin(t, timers)
+		firedAt := now.Add(5 * time.Minute)
+		timer.c <- firedAt
+
+		// Assert
+		request := receiveWithin(t, requests)
+		assert.NotEmpty(t, request.ID)
+		assert.Equal(t, "schedule-1", request.ScheduleID)
+		assert.Equal(t, firedAt, request.RequestedAt)
+		// 送る前に要求の受付を記録する
+		events := scheduler.CaptureEventStore.(*fakeCaptureEventStore).recorded()
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, request.ID, events[0].RequestID)
+			assert.Equal(t, datamodel.CaptureEventStateRequested, events[0].State)
+			assert.Equal(t, datamodel.CaptureModeScheduled, events[0].Mode)
+			if assert.NotNil(t, events[0].ScheduleID) {
+				assert.Equal(t, "schedule-1", *events[0].ScheduleID)
+			}
+		}
+		// 次の要求は前回の予定時刻からinterval_min後
+		assert.Equal(t, 10*time.Minute, receiveWithin(t, intervals))
+	})
+
+	t.Run("キャプチャ要求にスケジュールの撮影範囲と除外ウィンドウを含める", func(t *testing.T) {
+		// Arrange
+		region := &datamodel.CaptureRegion{X: 0, Y: 100, Width: 800, Height: 600}
+		scheduleStore := &fakeCaptureScheduleStore{schedule: &datamodel.CaptureSchedule{
+			ID:             "schedule-1",
+			State:          datamodel.CaptureScheduleStateActive,
+			IntervalMin:    5,
+			CaptureMode:    datamodel.CaptureScheduleModeRegion,
+			Region:         region,
+			ExcludeWindows: []string{"1Password"},
+		}}
+		now := time.Date(2025, 11, 6, 10, 0, 0, 0, time.UTC)
+		scheduler, _, timers := newFakeScheduler(t, scheduleStore, now)
+		requests, unsubscribe := scheduler.Hub.Subscribe()
+		defer unsubscribe()
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		go scheduler.Run(ctx)
+
+		// Act
+		receiveWithin(t, timers).c <- now.Add(5 * time.Minute)
+
+		// Assert
+		request := receiveWithin(t, requests)
+		assert.Equal(t, CaptureParams{
+			Mode:           datamodel.CaptureScheduleModeRegion,
+			Region:         region,
+			ExcludeWindows: []string{"1Password"},
+		}, request.Params)
+	})
+
+	t.Run("時間帯の外では次の時間帯の開始まで待ち、ジッターの分だけ時刻をずらす", func(t *testing.T) {
+		// Arrange
+		// 平日9:00〜18:00のみ、間隔10分、ジッター10%(±1分)
+		scheduleStore := &fakeCaptureScheduleStore{schedule: &datamodel.CaptureSchedule{
+			ID:          "schedule-1",
+			State:       datamodel.CaptureScheduleStateActive,
+			IntervalMin: 10,
+			TimeWindows: []datamodel.CaptureTimeWindow{
+				{Weekdays: []int{1, 2, 3, 4, 5}, Start: "09:00", End: "18:00"},
+			},
+			JitterPct: 10,
+		}}
+		// 2025-11-08(土) 20:00 JST
+		now := time.Date(2025, 11, 8, 11, 0, 0, 0, time.UTC)
+		scheduler, intervals, _ := newFakeScheduler(t, scheduleStore, now)
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		// Act
+		go scheduler.Run(ctx)
+
+		// Assert
+		// 2025-11-10(月) 9:00 JST の1分後
+		assert.Equal(t, 37*time.Hour+time.Minute, receiveWithin(t, intervals))
+	})
+
+	t.Run("Reload でスケジュールの変更を反映し、非アクティブになるとタイマーを止める", func(t *testing.T) {
+		// Arrange
+		scheduleStore := &fakeCaptureScheduleStore{schedule: &datamodel.CaptureSchedule{ID: "schedule-1", State: datamodel.CaptureScheduleStateActive, IntervalMin: 5}}
+		scheduler, intervals, timers := newFakeScheduler(t, scheduleStore, time.Now())
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		go scheduler.Run(ctx)
+		receiveWithin(t, intervals)
+		first := receiveWithin(t, timers)
+
+		// Act & Assert
+		// 間隔の変更で新しいタイマーに切り替わる
+		scheduleStore.update(true, 10)
+		scheduler.Reload()
+		assert.Equal(t, 10*time.Minute, receiveWithin(t, intervals))
+		second := receiveWithin(t, timers)
+		receiveWithin(t, first.stopped)
+
+		// 非アクティブになるとタイマーが止まる
+		scheduleStore.update(false, 10)
+		scheduler.Reload()
+		receiveWithin(t, second.stopped)
+	})
+
+	t.Run("ctx がキャンセルされるとタイマーを止め、クライアントのチャネルを閉じる", func(t *testing.T) {
+		// Arrange
+		scheduleStore := &fakeCaptureScheduleStore{schedule: &datamodel.CaptureSchedule{ID: "schedule-1", State: datamodel.CaptureScheduleStateActive, IntervalMin: 5}}
+		scheduler, _, timers := newFakeScheduler(t, scheduleStore, time.Now())
+		requests, unsubscribe := scheduler.Hub.Subscribe()
+		defer unsubscribe()
+		ctx, cancel := context.WithCancel(t.Context())
+		done := make(chan struct{})
+		go func() {
+			scheduler.Run(ctx)
+			close(done)
+		}()
+		timer := receiveWithin(t, timers)
+
+		// Act
+		cancel()
+
+		// Assert
+		receiveWithin(t, done)
+		receiveWithin(t, timer.stopped)
+		_, ok := <-requests
+		assert.False(t, ok)
+	})
+}
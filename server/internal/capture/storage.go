@@ -0,0 +1,184 @@
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+// キャプチャ画像をファイルシステム上に保存する。
+//
+// 画像は Dir/YYYY/MM/DD/<id>.<ext> に保存される（日付は撮影日時のJST）。
+// サムネイルは元画像と同じディレクトリの <id>.thumb.jpg に保存される。
+// DBにはDirからの相対パスを保存する。
+//
+// Encryptorを設定した場合、画像・サムネイルとも暗号化して書き込み、読み込み時に復号する。
+// 暗号化を有効にする前に保存した平文のファイルもそのまま読み込める。
+type Storage struct {
+	Dir       string
+	Encryptor *Encryptor // nilの場合は暗号化しない
+}
+
+// dataを撮影日ごとのディレクトリに書き込み、Dirからの相対パスを返す。
+//
+// 一時ファイルに書き込んでからリネームするため、書き込み途中のファイルが残ることはない。
+func (s *Storage) Save(id string, format string, capturedAt time.Time, data []byte) (string, error) {
+	relPath := filepath.Join(capturedAt.In(utils.GetJSTTimezone()).Format("2006/01/02"), id+"."+extension(format))
+	if err := s.write(relPath, data); err != nil {
+		return "", err
+	}
+	return relPath, nil
+}
+
+// 元画像relPathのサムネイルを書き込み、Dirからの相対パスを返す。既存のサムネイルは上書きされる。
+func (s *Storage) SaveThumbnail(relPath string, data []byte) (string, error) {
+	thumbPath := strings.TrimSuffix(relPath, filepath.Ext(relPath)) + ".thumb.jpg"
+	if err := s.write(thumbPath, data); err != nil {
+		return "", err
+	}
+	return thumbPath, nil
+}
+
+// relPathのファイルを読み込む。暗号化されている場合は復号した内容を返す。
+func (s *Storage) Read(relPath string) ([]byte, error) {
+	data, err := os.ReadFile(s.Path(relPath))
+	if err != nil {
+		return nil, err
+	}
+	return s.decrypt(data)
+}
+
+// relPathのファイルを削除する。ファイルが存在しない場合はエラーとしない。
+func (s *Storage) Remove(relPath string) error {
+	if err := os.Remove(s.Path(relPath)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove capture file: %w", err)
+	}
+	return nil
+}
+
+// relPathの絶対パスを返す
+func (s *Storage) Path(relPath string) string {
+	return filepath.Join(s.Dir, relPath)
+}
+
+// Dir以下の画像・サムネイル（撮影日ごとのディレクトリ直下のファイル）を読み込み、newEncryptorで暗号化し直す。
+// 暗号化し直したファイルの数を返す。Dirに置かれた鍵ファイルなど、それ以外のファイルには触れない。
+//
+// 既にnewEncryptorの鍵で暗号化されているファイルは飛ばすため、中断しても同じnewEncryptorで再実行できる。
+// 平文のファイルもnewEncryptorで暗号化する。実行中に他からファイルを書き込まないこと。
+func (s *Storage) Reencrypt(newEncryptor *Encryptor) (int, error) {
+	count := 0
+	err := filepath.WalkDir(s.Dir, func(path string, entry fs.DirEntry, err error) error {
+		// まだ何も保存していない
+		if path == s.Dir && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".tmp-") {
+			return nil
+		}
+		relPath, err := filepath.Rel(s.Dir, path)
+		if err != nil {
+			return err
+		}
+		if !isCaptureFile(relPath) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read capture file %s: %w", relPath, err)
+		}
+		if newEncryptor.Encrypted(data) {
+			return nil
+		}
+		plaintext, err := s.decrypt(data)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt capture file %s: %w", relPath, err)
+		}
+		reencrypted := Storage{Dir: s.Dir, Encryptor: newEncryptor}
+		if err := reencrypted.write(relPath, plaintext); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// pathがDir以下を指すかどうかを返す
+func (s *Storage) Contains(path string) (bool, error) {
+	dir, err := filepath.Abs(s.Dir)
+	if err != nil {
+		return false, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil {
+		return false, err
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
+
+// relPathがSave・SaveThumbnailの書き込むパス（YYYY/MM/DD/<ファイル>）かどうかを返す
+func isCaptureFile(relPath string) bool {
+	_, err := time.Parse("2006/01/02", filepath.ToSlash(filepath.Dir(relPath)))
+	return err == nil
+}
+
+func (s *Storage) decrypt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if s.Encryptor == nil {
+		return nil, ErrEncryptionKeyRequired
+	}
+	return s.Encryptor.Decrypt(data)
+}
+
+func (s *Storage) write(relPath string, data []byte) error {
+	if s.Encryptor != nil {
+		encrypted, err := s.Encryptor.Encrypt(data)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt capture file: %w", err)
+		}
+		data = encrypted
+	}
+	path := s.Path(relPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write capture file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close capture file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename capture file: %w", err)
+	}
+	return nil
+}
+
+func extension(format string) string {
+	if format == FormatJPEG {
+		return "jpg"
+	}
+	return format
+}
@@ -0,0 +1,38 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"golang.org/x/image/draw"
+)
+
+const thumbnailJPEGQuality = 80
+
+// dataの画像を幅widthに縮小したJPEGを返す。高さは元画像のアスペクト比に従う。
+//
+// 元画像の幅がwidth以下の場合は拡大せず元のサイズのままJPEGに変換する。
+func GenerateThumbnail(data []byte, width int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	if width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+	// 四捨五入した上で最低1pxを確保する
+	height := max((bounds.Dy()*width+bounds.Dx()/2)/bounds.Dx(), 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,133 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+)
+
+const thumbnailRegenerationBatchSize = 20
+
+// サムネイルが未生成、または現在の設定と異なる解像度のCaptureのサムネイルをバックグラウンドで再生成する
+type ThumbnailRegenerator struct {
+	CaptureStore     store.CaptureStore
+	SettingsStore    store.SettingsStore
+	TransactionStore store.TransactionStore
+	Storage          *Storage
+	trigger          chan struct{}
+}
+
+func NewThumbnailRegenerator(captureStore store.CaptureStore, settingsStore store.SettingsStore, transactionStore store.TransactionStore, storage *Storage) *ThumbnailRegenerator {
+	return &ThumbnailRegenerator{
+		CaptureStore:     captureStore,
+		SettingsStore:    settingsStore,
+		TransactionStore: transactionStore,
+		Storage:          storage,
+		trigger:          make(chan struct{}, 1),
+	}
+}
+
+// 再生成を要求する。実行中の場合は現在の実行が終わった後にもう一度実行される。
+//
+// ブロックしない。
+func (g *ThumbnailRegenerator) Trigger() {
+	select {
+	case g.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// ctxがキャンセルされるまで、Triggerされるたびに再生成を行う。
+//
+// 起動直後にも一度再生成を行う（前回終了時に処理しきれなかった分を拾うため）。
+func (g *ThumbnailRegenerator) Run(ctx context.Context) {
+	g.Trigger()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-g.trigger:
+			if err := g.regenerate(ctx); err != nil {
+				log.Printf("failed to regenerate thumbnails: %v", err)
+			}
+		}
+	}
+}
+
+func (g *ThumbnailRegenerator) regenerate(ctx context.Context) error {
+	// 失敗したCaptureは今回の実行では再試行しない
+	failed := map[string]bool{}
+	for ctx.Err() == nil {
+		resolution, captures, err := g.nextBatch(thumbnailRegenerationBatchSize + len(failed))
+		if err != nil {
+			return err
+		}
+		processed := 0
+		for _, c := range captures {
+			if failed[c.ID] || ctx.Err() != nil {
+				continue
+			}
+			processed++
+			if err := g.regenerateOne(c, resolution); err != nil {
+				log.Printf("failed to regenerate thumbnail of capture %s: %v", c.ID, err)
+				failed[c.ID] = true
+			}
+		}
+		if processed == 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (g *ThumbnailRegenerator) nextBatch(limit int) (int, []datamodel.Capture, error) {
+	tx, err := g.TransactionStore.Begin()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	settings, err := g.SettingsStore.GetSettings(tx)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+	captures, err := g.CaptureStore.GetCapturesWithStaleThumbnail(tx, settings.ThumbnailResolution, limit)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get captures: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return settings.ThumbnailResolution, captures, nil
+}
+
+func (g *ThumbnailRegenerator) regenerateOne(c datamodel.Capture, resolution int) error {
+	data, err := g.Storage.Read(c.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read capture file: %w", err)
+	}
+	thumbnail, err := GenerateThumbnail(data, resolution)
+	if err != nil {
+		return err
+	}
+	thumbPath, err := g.Storage.SaveThumbnail(c.Path, thumbnail)
+	if err != nil {
+		return err
+	}
+
+	tx, err := g.TransactionStore.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := g.CaptureStore.UpdateCaptureThumbnail(tx, c.ID, thumbPath, resolution); err != nil {
+		return fmt.Errorf("failed to update capture thumbnail: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
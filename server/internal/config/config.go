@@ -0,0 +1,185 @@
+package config
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultPort                  = 8080
+	defaultDBPath                = "./data/dev.db"
+	defaultCaptureStoragePath    = "./data/captures"
+	defaultCaptureMaxUploadBytes = 20 * 1024 * 1024
+	defaultAnalysisWorkers       = 2
+	defaultConfigPath            = "config.local.yaml"
+	defaultLLMProvider           = "ollama"
+	defaultLLMEndpoint           = "http://localhost:11434"
+	defaultLLMModel              = "llama2"
+	defaultLLMMaxTokens          = 2048
+	defaultLLMTimeout            = 120 * time.Second
+	defaultLLMPromptTokenBudget  = 1024
+	defaultLLMSummaryThreshold   = 4096
+)
+
+// サーバーの設定値
+type Config struct {
+	Port   int
+	DBPath string
+	// キャプチャ画像の保存先ディレクトリ
+	CaptureStoragePath string
+	// POST /capture/screenshot で受け付けるリクエストボディの上限（バイト）
+	CaptureMaxUploadBytes int64
+	// キャプチャ解析ジョブを同時に実行するワーカー数
+	AnalysisWorkers int
+	// キャプチャ画像の暗号化に使う鍵ファイルのパス。空の場合は暗号化しない
+	CaptureEncryptionKeyPath string
+	// LLMのプロバイダ（ollama/openai/fake）
+	LLMProvider string
+	// LLMのAPIのベースURL
+	LLMEndpoint string
+	// チャット・キャプチャの解析に使うモデル名
+	LLMModel string
+	// OpenAI互換APIのAPIキー
+	LLMAPIKey string
+	// 1回の応答で生成するトークン数の上限
+	LLMMaxTokens int
+	// 1回の応答の生成にかける時間の上限
+	LLMTimeout time.Duration
+	// fakeプロバイダの応答スクリプト（JSON）のパス
+	LLMFakeScriptPath string
+	// システムプロンプトに含める目標・タスクなどの状況のトークン数の上限（概算）
+	LLMPromptTokenBudget int
+	// 会話の履歴（要約していない部分）のトークン数がこれを超えたら、古いメッセージを要約する（概算）。0の場合は要約しない
+	LLMSummaryThreshold int
+}
+
+// 設定ファイル（YAML）のうち読み込む項目
+type fileConfig struct {
+	LLM struct {
+		Provider          string        `yaml:"provider"`
+		Endpoint          string        `yaml:"endpoint"`
+		Model             string        `yaml:"model"`
+		APIKey            string        `yaml:"api_key"`
+		MaxTokens         int           `yaml:"max_tokens"`
+		Timeout           time.Duration `yaml:"timeout"`
+		FakeScriptPath    string        `yaml:"fake_script_path"`
+		PromptTokenBudget int           `yaml:"prompt_token_budget"`
+		// 0（要約しない）と未設定を区別するためポインタにする
+		SummaryThreshold *int `yaml:"summary_threshold"`
+	} `yaml:"llm"`
+}
+
+// 設定ファイルと環境変数から設定値を読み込む。未設定または不正な値の項目はデフォルト値になる。
+//
+// 設定ファイルは環境変数 CONFIG_PATH（デフォルト config.local.yaml）のYAMLで、llm セクションのみ読み込む。
+// 同じ項目は環境変数が優先される。
+//
+// 環境変数:
+// - PORT
+// - DB_PATH
+// - CAPTURE_STORAGE_PATH
+// - CAPTURE_MAX_UPLOAD_BYTES
+// - ANALYSIS_WORKERS
+// - CAPTURE_ENCRYPTION_KEY_PATH
+// - LLM_PROVIDER
+// - LLM_ENDPOINT
+// - LLM_MODEL
+// - LLM_API_KEY
+// - LLM_MAX_TOKENS
+// - LLM_TIMEOUT
+// - LLM_FAKE_SCRIPT_PATH
+// - LLM_PROMPT_TOKEN_BUDGET
+// - LLM_SUMMARY_THRESHOLD
+func Load() Config {
+	file := loadFileConfig(getEnvString("CONFIG_PATH", defaultConfigPath))
+	return Config{
+		Port:                  getEnvInt("PORT", defaultPort),
+		DBPath:                getEnvString("DB_PATH", defaultDBPath),
+		CaptureStoragePath:    getEnvString("CAPTURE_STORAGE_PATH", defaultCaptureStoragePath),
+		CaptureMaxUploadBytes: int64(getEnvInt("CAPTURE_MAX_UPLOAD_BYTES", defaultCaptureMaxUploadBytes)),
+		AnalysisWorkers:       getEnvInt("ANALYSIS_WORKERS", defaultAnalysisWorkers),
+		// 既定では暗号化しない
+		CaptureEncryptionKeyPath: os.Getenv("CAPTURE_ENCRYPTION_KEY_PATH"),
+		LLMProvider:              getEnvString("LLM_PROVIDER", orDefault(file.LLM.Provider, defaultLLMProvider)),
+		LLMEndpoint:              getEnvString("LLM_ENDPOINT", orDefault(file.LLM.Endpoint, defaultLLMEndpoint)),
+		LLMModel:                 getEnvString("LLM_MODEL", orDefault(file.LLM.Model, defaultLLMModel)),
+		LLMAPIKey:                getEnvString("LLM_API_KEY", file.LLM.APIKey),
+		LLMMaxTokens:             getEnvInt("LLM_MAX_TOKENS", orDefault(file.LLM.MaxTokens, defaultLLMMaxTokens)),
+		LLMTimeout:               getEnvDuration("LLM_TIMEOUT", orDefault(file.LLM.Timeout, defaultLLMTimeout)),
+		LLMFakeScriptPath:        getEnvString("LLM_FAKE_SCRIPT_PATH", file.LLM.FakeScriptPath),
+		LLMPromptTokenBudget:     getEnvInt("LLM_PROMPT_TOKEN_BUDGET", orDefault(file.LLM.PromptTokenBudget, defaultLLMPromptTokenBudget)),
+		LLMSummaryThreshold:      getEnvNonNegativeInt("LLM_SUMMARY_THRESHOLD", nonNegativeOrDefault(file.LLM.SummaryThreshold, defaultLLMSummaryThreshold)),
+	}
+}
+
+// pathの設定ファイルを読み込む。存在しない場合や不正な場合は空の設定を返す。
+func loadFileConfig(path string) fileConfig {
+	var file fileConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("failed to read config file %s: %v", path, err)
+		}
+		return fileConfig{}
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		log.Printf("failed to parse config file %s: %v", path, err)
+		return fileConfig{}
+	}
+	return file
+}
+
+// valueがゼロ値の場合はdefaultValueを、それ以外はvalueを返す
+func orDefault[T string | int | time.Duration](value T, defaultValue T) T {
+	var zero T
+	if value == zero {
+		return defaultValue
+	}
+	return value
+}
+
+// valueが設定されていて0以上の場合はvalueを、それ以外はdefaultValueを返す
+func nonNegativeOrDefault(value *int, defaultValue int) int {
+	if value == nil || *value < 0 {
+		return defaultValue
+	}
+	return *value
+}
+
+func getEnvString(key string, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvIntと同じだが、0も有効な値として受け付ける
+func getEnvNonNegativeInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
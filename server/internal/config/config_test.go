@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// contentの設定ファイルを書き込み、CONFIG_PATHに設定する
+func setConfigFile(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	t.Setenv("CONFIG_PATH", path)
+}
+
+func TestLoadLLMSummaryThreshold(t *testing.T) {
+	t.Run("未設定の場合はデフォルト値になる", func(t *testing.T) {
+		setConfigFile(t, "llm:\n  model: llama3\n")
+		t.Setenv("LLM_SUMMARY_THRESHOLD", "")
+
+		assert.Equal(t, defaultLLMSummaryThreshold, Load().LLMSummaryThreshold)
+	})
+
+	t.Run("環境変数の0は要約しない設定として受け付ける", func(t *testing.T) {
+		setConfigFile(t, "llm:\n  summary_threshold: 2048\n")
+		t.Setenv("LLM_SUMMARY_THRESHOLD", "0")
+
+		assert.Equal(t, 0, Load().LLMSummaryThreshold)
+	})
+
+	t.Run("設定ファイルの0は要約しない設定として受け付ける", func(t *testing.T) {
+		setConfigFile(t, "llm:\n  summary_threshold: 0\n")
+		t.Setenv("LLM_SUMMARY_THRESHOLD", "")
+
+		assert.Equal(t, 0, Load().LLMSummaryThreshold)
+	})
+
+	t.Run("負の値や数値でない値はデフォルト値になる", func(t *testing.T) {
+		setConfigFile(t, "llm:\n  summary_threshold: -1\n")
+		for _, value := range []string{"-1", "many"} {
+			t.Setenv("LLM_SUMMARY_THRESHOLD", value)
+
+			assert.Equal(t, defaultLLMSummaryThreshold, Load().LLMSummaryThreshold, value)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package datamodel
+
+import "time"
+
+const (
+	CaptureModeManual    = "manual"
+	CaptureModeScheduled = "scheduled"
+)
+
+type Capture struct {
+	ID              string    `json:"id"`
+	Path            string    `json:"path"` // ストレージディレクトリからの相対パス
+	Format          string    `json:"format"`
+	Width           int       `json:"width"`
+	Height          int       `json:"height"`
+	SizeBytes       int64     `json:"size_bytes"`
+	SHA256          string    `json:"sha256"`
+	Mode            string    `json:"mode"`
+	CapturedAt      time.Time `json:"captured_at"`
+	ThumbPath       *string   `json:"thumb_path"`       // サムネイル未生成の場合nil
+	ThumbResolution *int      `json:"thumb_resolution"` // サムネイル生成時の解像度。未生成の場合nil
+	PHash           *string   `json:"phash"`            // 知覚ハッシュ(dHash)の16進表現。計算前のキャプチャはnil
+	DuplicateOf     *string   `json:"duplicate_of"`     // 直前のキャプチャと重複していた場合そのID
+	// 保存せずに捨てた重複キャプチャの数と、その最後の撮影日時（未発生の場合nil）
+	DuplicateCount  int        `json:"duplicate_count"`
+	LastDuplicateAt *time.Time `json:"last_duplicate_at"`
+	// 紐付けたタスクと目標。撮影時にdoingだったタスクとその目標を自動で紐付ける
+	LinkedTaskID *string   `json:"linked_task_id"`
+	LinkedGoalID *string   `json:"linked_goal_id"`
+	DisplayID    *string   `json:"display_id"` // 撮影したディスプレイ。クライアントが報告しなかった場合nil
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,60 @@
+package datamodel
+
+import (
+	"slices"
+	"time"
+)
+
+// キャプチャ解析ジョブの状態（docs/state-machines.md 参照）
+const (
+	CaptureAnalysisJobStatusQueued    = "queued"
+	CaptureAnalysisJobStatusRunning   = "running"
+	CaptureAnalysisJobStatusSucceeded = "succeeded"
+	CaptureAnalysisJobStatusFailed    = "failed"
+)
+
+// 解析結果の活動カテゴリ
+const (
+	ActivityCategoryWork          = "work"
+	ActivityCategoryCommunication = "communication"
+	ActivityCategoryLearning      = "learning"
+	ActivityCategoryBreak         = "break"
+	ActivityCategoryOther         = "other"
+)
+
+// 全ての活動カテゴリ（解析で選ばせる順）
+var ActivityCategories = []string{ActivityCategoryWork, ActivityCategoryCommunication, ActivityCategoryLearning, ActivityCategoryBreak, ActivityCategoryOther}
+
+// 解析結果として有効なカテゴリか
+func IsActivityCategory(category string) bool {
+	return slices.Contains(ActivityCategories, category)
+}
+
+// キャプチャ1件の解析ジョブ。成功したジョブが解析結果（カテゴリ・要約・提案）を持つ。
+//
+// 再解析するときは新しいジョブを作成し、過去のジョブと結果は残す。
+type CaptureAnalysisJob struct {
+	ID        string `json:"id"`
+	CaptureID string `json:"capture_id"`
+	Status    string `json:"status"`
+	// 解析に使うプロンプトのバージョン
+	PromptVersion int `json:"prompt_version"`
+	// 実行した回数と、失敗時に再試行する上限
+	Attempts    int `json:"attempts"`
+	MaxAttempts int `json:"max_attempts"`
+	// queuedのジョブを次に実行できる日時
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	Category      *string    `json:"category"`    // succeeded以外はnil
+	Summary       *string    `json:"summary"`     // succeeded以外はnil
+	Suggestions   []string   `json:"suggestions"` // succeeded以外は空
+	ErrorMessage  *string    `json:"error_message"`
+	StartedAt     *time.Time `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// 実行待ちまたは実行中か
+func (j CaptureAnalysisJob) IsPending() bool {
+	return j.Status == CaptureAnalysisJobStatusQueued || j.Status == CaptureAnalysisJobStatusRunning
+}
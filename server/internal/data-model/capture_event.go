@@ -0,0 +1,41 @@
+package datamodel
+
+import "time"
+
+// キャプチャ要求の状態（docs/state-machines.md 参照）
+const (
+	CaptureEventStateRequested = "requested"
+	CaptureEventStateGranted   = "granted"
+	CaptureEventStateDenied    = "denied"
+	CaptureEventStateCompleted = "completed"
+	CaptureEventStateFailed    = "failed"
+)
+
+// 各状態から遷移できる状態
+var captureEventTransitions = map[string][]string{
+	CaptureEventStateRequested: {CaptureEventStateGranted, CaptureEventStateDenied},
+	CaptureEventStateGranted:   {CaptureEventStateCompleted, CaptureEventStateFailed},
+}
+
+// キャプチャ要求がfromからtoへ遷移できるか
+func CanTransitionCaptureEvent(from string, to string) bool {
+	for _, next := range captureEventTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// キャプチャ要求の状態遷移の記録。1つの要求(RequestID)に対して遷移ごとに1件記録する。
+type CaptureEvent struct {
+	ID           string    `json:"id"`
+	RequestID    string    `json:"request_id"`
+	State        string    `json:"state"`
+	Mode         string    `json:"mode"`
+	ScheduleID   *string   `json:"schedule_id"`   // 手動キャプチャの場合nil
+	CaptureID    *string   `json:"capture_id"`    // completed以外はnil
+	ErrorCode    *string   `json:"error_code"`    // denied・failed以外はnil
+	ErrorMessage *string   `json:"error_message"` // denied・failed以外はnil
+	OccurredAt   time.Time `json:"occurred_at"`
+}
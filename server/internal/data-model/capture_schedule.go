@@ -2,10 +2,116 @@ package datamodel
 
 import "time"
 
+const (
+	CaptureScheduleStateInactive = "inactive"
+	CaptureScheduleStateActive   = "active"
+	CaptureScheduleStatePaused   = "paused" // 連続失敗による自動停止
+)
+
+// 連続失敗がこの回数に達するとスケジュールをPAUSEDにする
+const CaptureScheduleMaxConsecutiveFailures = 5
+
+// ジッターの上限(interval_minに対する%)
+const CaptureScheduleMaxJitterPct = 50
+
+// 定期キャプチャで撮影する範囲。ブリッジのCaptureParams.modeに対応する
+const (
+	CaptureScheduleModeFull   = "full"   // 画面全体
+	CaptureScheduleModeWindow = "window" // ウィンドウ
+	CaptureScheduleModeRegion = "region" // Regionで指定した領域
+)
+
+// 撮影する領域(px)
+type CaptureRegion struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// キャプチャを許可する時間帯(JST)
+type CaptureTimeWindow struct {
+	Weekdays []int  `json:"weekdays"` // 0=日曜〜6=土曜。空の場合は毎日
+	Start    string `json:"start"`    // "HH:MM"
+	End      string `json:"end"`      // "HH:MM"。Startより後で、"24:00"も可
+}
+
 type CaptureSchedule struct {
-	ID          string    `json:"id"`
-	Active      bool      `json:"active"`
-	IntervalMin int       `json:"interval_min"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                  string     `json:"id"`
+	State               string     `json:"state"`
+	IntervalMin         int        `json:"interval_min"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastFailureAt       *time.Time `json:"last_failure_at"`     // 失敗がない場合nil
+	LastFailureReason   *string    `json:"last_failure_reason"` // 失敗がない場合nil
+	// 空の場合は終日キャプチャする
+	TimeWindows []CaptureTimeWindow `json:"time_windows"`
+	// キャプチャしない日付(JST, "YYYY-MM-DD")
+	ExcludedDates []string `json:"excluded_dates"`
+	// キャプチャ時刻をinterval_minの±jitter_pct%の範囲でずらす
+	JitterPct int `json:"jitter_pct"`
+	// 撮影する範囲と、その際にクライアントへ渡すパラメータ
+	CaptureMode    string         `json:"capture_mode"`
+	Region         *CaptureRegion `json:"region"`          // CaptureModeがregionの場合のみ非nil
+	ExcludeWindows []string       `json:"exclude_windows"` // 撮影から除外するウィンドウID
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+func (s CaptureSchedule) IsActive() bool {
+	return s.State == CaptureScheduleStateActive
+}
+
+// 履歴に記録するスケジュールの設定
+type CaptureScheduleSnapshot struct {
+	State          string              `json:"state"`
+	IntervalMin    int                 `json:"interval_min"`
+	TimeWindows    []CaptureTimeWindow `json:"time_windows"`
+	ExcludedDates  []string            `json:"excluded_dates"`
+	JitterPct      int                 `json:"jitter_pct"`
+	CaptureMode    string              `json:"capture_mode"`
+	Region         *CaptureRegion      `json:"region"`
+	ExcludeWindows []string            `json:"exclude_windows"`
+}
+
+func (s CaptureSchedule) Snapshot() CaptureScheduleSnapshot {
+	return CaptureScheduleSnapshot{
+		State:          s.State,
+		IntervalMin:    s.IntervalMin,
+		TimeWindows:    s.TimeWindows,
+		ExcludedDates:  s.ExcludedDates,
+		JitterPct:      s.JitterPct,
+		CaptureMode:    s.CaptureMode,
+		Region:         s.Region,
+		ExcludeWindows: s.ExcludeWindows,
+	}
+}
+
+const (
+	CaptureScheduleActionCreate = "create"
+	CaptureScheduleActionUpdate = "update"
+	CaptureScheduleActionStart  = "start"
+	CaptureScheduleActionStop   = "stop"
+	CaptureScheduleActionResume = "resume"
+	CaptureScheduleActionPause  = "pause" // 連続失敗による自動停止
+)
+
+const (
+	CaptureScheduleChangedByUser   = "user"
+	CaptureScheduleChangedBySystem = "system"
+)
+
+// キャプチャスケジュールの変更履歴
+type CaptureScheduleHistory struct {
+	ID             string  `json:"id"`
+	ScheduleID     string  `json:"schedule_id"`
+	Action         string  `json:"action"`
+	ChangedBy      string  `json:"changed_by"`
+	OldState       *string `json:"old_state"`        // 作成時はnil
+	OldIntervalMin *int    `json:"old_interval_min"` // 作成時はnil
+	NewState       string  `json:"new_state"`
+	NewIntervalMin int     `json:"new_interval_min"`
+	// 変更前後の設定。OldSnapshotは作成時にnil。スナップショットを記録する前の履歴はどちらもnil
+	OldSnapshot *CaptureScheduleSnapshot `json:"old_snapshot"`
+	NewSnapshot *CaptureScheduleSnapshot `json:"new_snapshot"`
+	ChangedAt   time.Time                `json:"changed_at"`
 }
@@ -0,0 +1,67 @@
+package datamodel
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// アシスタントの応答の終わり方
+const (
+	// 最後まで生成した
+	ChatMessageFinishReasonCompleted = "completed"
+	// クライアントの切断で中断した
+	ChatMessageFinishReasonAborted = "aborted"
+	// LLMのエラーで中断した
+	ChatMessageFinishReasonError = "error"
+	// ツールを呼び出した
+	ChatMessageFinishReasonToolCalls = "tool_calls"
+)
+
+// チャットの会話
+type Conversation struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// 作成日時
+	CreatedAt time.Time `json:"created_at"`
+	// 最後にメッセージを追加した日時
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 会話の1メッセージ
+type ChatMessage struct {
+	ID             string `json:"id"`
+	ConversationID string `json:"conversation_id"`
+	// llm.Role*のいずれか
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// ChatMessageFinishReason*のいずれか。アシスタントの応答以外はnil
+	FinishReason *string `json:"finish_reason"`
+	// アシスタントが呼び出したツール。呼び出しがない場合はnil
+	ToolCalls []ChatToolCall `json:"tool_calls"`
+	// 結果を返した呼び出しのID。ツールの結果以外はnil
+	ToolCallID *string `json:"tool_call_id"`
+	// 応答を生成したプロンプトのテンプレートのバージョン（テンプレートの名前→バージョン）。アシスタントの応答以外はnil
+	PromptVersions map[string]int `json:"prompt_versions"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// 会話の古いメッセージの要約
+type ConversationSummary struct {
+	ID             string `json:"id"`
+	ConversationID string `json:"conversation_id"`
+	// 会話の最初からLastMessageIDまでのメッセージの要約
+	Content string `json:"content"`
+	// 要約した最後のメッセージのID
+	LastMessageID string `json:"last_message_id"`
+	// 要約したメッセージの数
+	MessageCount int       `json:"message_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// アシスタントによるツールの呼び出し
+type ChatToolCall struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// 引数のJSONオブジェクト
+	Arguments json.RawMessage `json:"arguments"`
+}
@@ -0,0 +1,14 @@
+package datamodel
+
+import "time"
+
+// 目標のKPIの実績
+type KpiEntry struct {
+	ID     string  `json:"id"`
+	GoalID string  `json:"goal_id"`
+	Value  float64 `json:"value"`
+	Note   string  `json:"note"`
+	// 実績の日付（JSTの0時）
+	RecordedOn time.Time `json:"recorded_on"`
+	CreatedAt  time.Time `json:"created_at"`
+}
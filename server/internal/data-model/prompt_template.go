@@ -0,0 +1,15 @@
+package datamodel
+
+import "time"
+
+// LLMに送るプロンプトのテンプレートの1バージョン
+type PromptTemplate struct {
+	ID string `json:"id"`
+	// prompt.Template*のいずれか
+	Name string `json:"name"`
+	// 名前ごとに1から連番
+	Version int `json:"version"`
+	// Goのtext/templateの本文
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,70 @@
+package datamodel
+
+import "time"
+
+const (
+	// 承認・却下を待っている
+	ProposalStatusPending = "pending"
+	// 承認され、変更を適用した
+	ProposalStatusAccepted = "accepted"
+	// 却下された
+	ProposalStatusRejected = "rejected"
+	// 承認・却下されないまま期限を過ぎた
+	ProposalStatusExpired = "expired"
+)
+
+// 変更の対象
+const (
+	ProposalEntityTask = "task"
+	ProposalEntityGoal = "goal"
+)
+
+// 変更の種類
+const (
+	ProposalActionCreate = "create"
+	ProposalActionUpdate = "update"
+)
+
+// LLMが提案したタスク・目標の変更
+type Proposal struct {
+	ID string `json:"id"`
+	// 提案した会話。会話の削除後はnil
+	ConversationID *string `json:"conversation_id"`
+	// 承認時に適用する変更
+	Changeset []ProposalChange `json:"changeset"`
+	// 提案した時点の状態との差分。Changesetと同じ順
+	Diff   []ProposalChangeDiff `json:"diff"`
+	Status string               `json:"status"`
+	// この日時を過ぎるとpendingからexpiredになる
+	ExpiresAt time.Time `json:"expires_at"`
+	// pending以外になった日時。pendingの間はnil
+	ResolvedAt *time.Time `json:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// 1つのタスク・目標への変更
+type ProposalChange struct {
+	// ProposalEntity*のいずれか
+	Entity string `json:"entity"`
+	// ProposalAction*のいずれか
+	Action string `json:"action"`
+	// 変更するタスク・目標のID。createの場合は作成するID
+	ID string `json:"id"`
+	// 変更後の全てのフィールド（POST /goal などのリクエストと同じ形式）
+	Fields map[string]any `json:"fields"`
+}
+
+// ProposalChangeで変わるフィールド
+type ProposalChangeDiff struct {
+	Entity string              `json:"entity"`
+	Action string              `json:"action"`
+	ID     string              `json:"id"`
+	Fields []ProposalFieldDiff `json:"fields"`
+}
+
+type ProposalFieldDiff struct {
+	Field string `json:"field"`
+	// 変更前の値。createの場合はnil
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
@@ -0,0 +1,38 @@
+package datamodel
+
+import "time"
+
+// 重複したキャプチャの扱い
+const (
+	DedupModeOff  = "off"  // 重複を判定しない
+	DedupModeMark = "mark" // 保存し、重複として記録する
+	DedupModeDrop = "drop" // 保存せず、重複元のキャプチャに回数を記録する
+)
+
+// マスクのかけ方
+const (
+	MaskModeBlur     = "blur"     // ぼかす
+	MaskModePixelate = "pixelate" // モザイクにする
+)
+
+// キャプチャの保存・解析の前に隠す矩形。座標は元画像のピクセル単位
+type MaskRule struct {
+	DisplayID *string `json:"display_id"` // 対象のディスプレイ。nilの場合はすべてのディスプレイ
+	X         int     `json:"x"`
+	Y         int     `json:"y"`
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Mode      string  `json:"mode"`
+}
+
+type Settings struct {
+	ThumbnailResolution int        `json:"thumbnail_resolution"` // サムネイルの幅（px）。高さは元画像のアスペクト比に従う
+	RetentionMaxItems   *int       `json:"retention_max_items"`  // キャプチャの最大保持件数。無制限の場合nil
+	RetentionMaxDays    *int       `json:"retention_max_days"`   // キャプチャの最大保持日数。無制限の場合nil
+	RetentionMaxBytes   *int64     `json:"retention_max_bytes"`  // キャプチャ（元画像）の最大合計サイズ。無制限の場合nil
+	DedupMode           string     `json:"dedup_mode"`
+	DedupThreshold      int        `json:"dedup_threshold"` // 知覚ハッシュのハミング距離がこれ以下なら重複とみなす
+	MaskRules           []MaskRule `json:"mask_rules"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,26 @@
+package datamodel
+
+import "time"
+
+const (
+	TaskStatusTodo     = "todo"
+	TaskStatusDoing    = "doing"
+	TaskStatusPaused   = "paused"
+	TaskStatusDone     = "done"
+	TaskStatusArchived = "archived"
+)
+
+type Task struct {
+	ID          string     `json:"id"`
+	GoalID      *string    `json:"goal_id"` // 目標に紐付いていない場合nil
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Due         *time.Time `json:"due"` // 期日未設定の場合nil
+	EstimateMin int        `json:"estimate_min"`
+	Priority    int        `json:"priority"`
+	Status      string     `json:"status"`
+	Tags        []string   `json:"tags"`
+	Attachments []any      `json:"attachments"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,13 @@
+package datamodel
+
+import "time"
+
+// タスクがdoingだった期間。tasks.statusの変更からDBのトリガーで記録される。
+type TaskSession struct {
+	ID     string `json:"id"`
+	TaskID string `json:"task_id"`
+	// tasksから結合したタスクのタイトル
+	TaskTitle string     `json:"task_title"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"` // doingのままの場合nil
+}
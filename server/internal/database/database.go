@@ -3,13 +3,14 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // Open はSQLiteデータベースに接続する
 func Open(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", dsn(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -19,11 +20,6 @@ func Open(dbPath string) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// 外部キー制約を有効化
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
-	}
-
 	// WALモードを有効化（パフォーマンス向上）
 	if _, err := db.Exec("PRAGMA journal_mode = WAL"); err != nil {
 		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
@@ -31,3 +27,15 @@ func Open(dbPath string) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// dbPathに接続のパラメータを加える。dbPathが既にクエリパラメータを持つ場合はそれに続ける。
+// PRAGMAは接続ごとの設定のため、外部キー制約はプールのすべての接続で有効になるようパラメータで指定する。
+// バックグラウンドワーカーとハンドラが同時に書き込むため、ロックの解放を待つ。
+// 読み取りから書き込みへの昇格はロックを待たずに失敗するため、トランザクションの開始時に書き込みロックを取る。
+func dsn(dbPath string) string {
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	return dbPath + separator + "_foreign_keys=1&_busy_timeout=5000&_txlock=immediate"
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	t.Run("クエリパラメータを持つパスでも接続のパラメータを加えて開く", func(t *testing.T) {
+		// Arrange
+		dbPath := "file:" + filepath.Join(t.TempDir(), "app.db") + "?cache=shared"
+
+		// Act
+		db, err := Open(dbPath)
+
+		// Assert
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer db.Close()
+		var busyTimeout int
+		assert.NoError(t, db.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout))
+		assert.Equal(t, 5000, busyTimeout)
+	})
+
+	t.Run("プールのすべての接続で外部キー制約を有効にする", func(t *testing.T) {
+		// Arrange
+		db, err := Open(filepath.Join(t.TempDir(), "app.db"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer db.Close()
+
+		// Act
+		// 接続を返さずに取得し続け、プールに別々の接続を作らせる
+		conns := []*sql.Conn{}
+		for range 3 {
+			conn, err := db.Conn(t.Context())
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+			conns = append(conns, conn)
+		}
+
+		// Assert
+		for i, conn := range conns {
+			var foreignKeys int
+			assert.NoError(t, conn.QueryRowContext(t.Context(), "PRAGMA foreign_keys").Scan(&foreignKeys))
+			assert.Equal(t, 1, foreignKeys, "connection %d", i)
+		}
+	})
+}
+
+func TestDSN(t *testing.T) {
+	assert.Equal(t, "app.db?_foreign_keys=1&_busy_timeout=5000&_txlock=immediate", dsn("app.db"))
+	assert.Equal(t, "file:app.db?cache=shared&_foreign_keys=1&_busy_timeout=5000&_txlock=immediate", dsn("file:app.db?cache=shared"))
+}
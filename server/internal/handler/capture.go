@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+)
+
+// キャプチャに紐付けるタスクと目標の変更を受け付ける
+type CaptureHandler struct {
+	CaptureStore     store.CaptureStore
+	TaskStore        store.TaskStore
+	GoalStore        store.GoalStore
+	TransactionStore store.TransactionStore
+}
+
+func (h *CaptureHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "PATCH":
+		body, errResponse = h.patch(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+// 省略したキーは変更せず、nullの場合は紐付けを外す。
+// linked_task_idだけを指定した場合、目標はそのタスクの目標にする。
+func (h *CaptureHandler) patch(r *http.Request) (map[string]interface{}, *errorResponse) {
+	id := r.PathValue("id")
+
+	rawBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Invalid JSON format", "failed to read request body", err)
+	}
+	var presentKeys map[string]json.RawMessage
+	if err := json.Unmarshal(rawBody, &presentKeys); err != nil {
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Invalid JSON format", "failed to decode request body", err)
+	}
+	links := map[string]*string{}
+	for _, key := range []string{"linked_task_id", "linked_goal_id"} {
+		raw, ok := presentKeys[key]
+		if !ok {
+			continue
+		}
+		var value *string
+		if err := json.Unmarshal(raw, &value); err != nil || (value != nil && *value == "") {
+			return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", key+" must be a non-empty string or null", "invalid "+key, err)
+		}
+		links[key] = value
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update capture", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	c, err := h.CaptureStore.GetCapture(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update capture", "failed to get capture", err)
+	}
+	if c == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Capture not found", "capture not found", nil)
+	}
+	linkedTaskID, linkedGoalID := c.LinkedTaskID, c.LinkedGoalID
+	if taskID, ok := links["linked_task_id"]; ok {
+		linkedTaskID = taskID
+		if taskID != nil {
+			task, err := h.TaskStore.GetTask(tx, *taskID)
+			if err != nil {
+				return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update capture", "failed to get task", err)
+			}
+			if task == nil {
+				return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Linked task not found", "linked task not found", nil)
+			}
+			if _, ok := links["linked_goal_id"]; !ok {
+				linkedGoalID = task.GoalID
+			}
+		}
+	}
+	if goalID, ok := links["linked_goal_id"]; ok {
+		linkedGoalID = goalID
+		if goalID != nil {
+			goal, err := h.GoalStore.GetGoalByID(tx, *goalID)
+			if err != nil {
+				return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update capture", "failed to get goal", err)
+			}
+			if goal == nil {
+				return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Linked goal not found", "linked goal not found", nil)
+			}
+		}
+	}
+	updated, err := h.CaptureStore.UpdateCaptureLinks(tx, id, linkedTaskID, linkedGoalID)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update capture", "failed to update capture links", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update capture", "failed to commit transaction", err)
+	}
+
+	return map[string]interface{}{
+		"capture": captureToResponse(updated),
+	}, nil
+}
+
+// キャプチャの一覧のレスポンス
+func capturesToResponse(captures []datamodel.Capture) []map[string]interface{} {
+	results := make([]map[string]interface{}, 0, len(captures))
+	for _, c := range captures {
+		results = append(results, captureToResponse(c))
+	}
+	return results
+}
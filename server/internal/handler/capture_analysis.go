@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/analysis"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+// キャプチャの解析ジョブの状態と解析結果の取得、および再解析の要求を受け付ける
+type CaptureAnalysisHandler struct {
+	CaptureStore            store.CaptureStore
+	CaptureAnalysisJobStore store.CaptureAnalysisJobStore
+	TransactionStore        store.TransactionStore
+	AnalysisPool            *analysis.Pool
+}
+
+func (h *CaptureAnalysisHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	case "POST":
+		body, errResponse = h.post(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *CaptureAnalysisHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	id := r.PathValue("id")
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture analysis", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	c, err := h.CaptureStore.GetCapture(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture analysis", "failed to get capture", err)
+	}
+	if c == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Capture not found", "capture not found", nil)
+	}
+	job, err := h.CaptureAnalysisJobStore.GetLatestCaptureAnalysisJob(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture analysis", "failed to get latest analysis job", err)
+	}
+	succeeded, err := h.CaptureAnalysisJobStore.GetLatestSucceededCaptureAnalysisJob(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture analysis", "failed to get latest succeeded analysis job", err)
+	}
+	promptVersion, err := h.AnalysisPool.Analyzer.PromptVersion(tx)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture analysis", "failed to get prompt version", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture analysis", "failed to commit transaction", err)
+	}
+
+	var jobResponse, analysisResponse map[string]interface{}
+	if job != nil {
+		jobResponse = captureAnalysisJobToResponse(*job)
+	}
+	if succeeded != nil {
+		analysisResponse = captureAnalysisResultToResponse(*succeeded)
+	}
+	return map[string]interface{}{
+		"capture_id":             id,
+		"current_prompt_version": promptVersion,
+		"job":                    jobResponse,
+		"analysis":               analysisResponse,
+	}, nil
+}
+
+// 現在のプロンプトで再解析するジョブを作成する。失敗したジョブの手動での再試行にも使う。
+func (h *CaptureAnalysisHandler) post(r *http.Request) (map[string]interface{}, *errorResponse) {
+	id := r.PathValue("id")
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to enqueue capture analysis", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	c, err := h.CaptureStore.GetCapture(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to enqueue capture analysis", "failed to get capture", err)
+	}
+	if c == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Capture not found", "capture not found", nil)
+	}
+	latest, err := h.CaptureAnalysisJobStore.GetLatestCaptureAnalysisJob(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to enqueue capture analysis", "failed to get latest analysis job", err)
+	}
+	if latest != nil && latest.IsPending() {
+		return nil, newCodedErrorResponse(http.StatusConflict, "INVALID_STATE", "Capture analysis is already queued or running", "analysis job already pending", fmt.Errorf("job %s is %s", latest.ID, latest.Status))
+	}
+	job, err := h.AnalysisPool.Enqueue(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to enqueue capture analysis", "failed to enqueue analysis job", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to enqueue capture analysis", "failed to commit transaction", err)
+	}
+	h.AnalysisPool.Trigger()
+
+	return map[string]interface{}{
+		"job": captureAnalysisJobToResponse(job),
+	}, nil
+}
+
+func captureAnalysisJobToResponse(job datamodel.CaptureAnalysisJob) map[string]interface{} {
+	timezone := utils.GetJSTTimezone()
+	return map[string]interface{}{
+		"id":              job.ID,
+		"status":          job.Status,
+		"prompt_version":  job.PromptVersion,
+		"attempts":        job.Attempts,
+		"max_attempts":    job.MaxAttempts,
+		"next_attempt_at": job.NextAttemptAt.In(timezone).Format(time.RFC3339),
+		"error_message":   job.ErrorMessage,
+		"started_at":      formatOptionalTime(job.StartedAt),
+		"finished_at":     formatOptionalTime(job.FinishedAt),
+		"created_at":      job.CreatedAt.In(timezone).Format(time.RFC3339),
+	}
+}
+
+func captureAnalysisResultToResponse(job datamodel.CaptureAnalysisJob) map[string]interface{} {
+	summary := ""
+	if job.Summary != nil {
+		summary = *job.Summary
+	}
+	return map[string]interface{}{
+		"job_id":         job.ID,
+		"prompt_version": job.PromptVersion,
+		"category":       job.Category,
+		"summary":        summary,
+		"suggestions":    job.Suggestions,
+		"analyzed_at":    formatOptionalTime(job.FinishedAt),
+	}
+}
@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	captureEventsDefaultLimit = 50
+	captureEventsMaxLimit     = 200
+)
+
+var (
+	errCaptureRequestNotFound        = errors.New("capture request not found")
+	errInvalidCaptureEventTransition = errors.New("invalid capture event transition")
+)
+
+// キャプチャ要求の状態遷移の一覧取得と、クライアントからの状態遷移の報告を受け付ける
+type CaptureEventsHandler struct {
+	CaptureEventStore store.CaptureEventStore
+	TransactionStore  store.TransactionStore
+}
+
+func (h *CaptureEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	case "POST":
+		body, errResponse = h.post(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *CaptureEventsHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	limit, offset, errResponse := parsePagination(r, captureEventsDefaultLimit, captureEventsMaxLimit)
+	if errResponse != nil {
+		return nil, errResponse
+	}
+	filter := store.CaptureEventFilter{}
+	query := r.URL.Query()
+	for _, param := range []struct {
+		name   string
+		target **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		raw := query.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", fmt.Sprintf("%s must be RFC3339", param.name), "invalid "+param.name, err)
+		}
+		*param.target = &parsed
+	}
+	if raw := query.Get("outcome"); raw != "" {
+		for _, state := range strings.Split(raw, ",") {
+			switch state {
+			case datamodel.CaptureEventStateRequested, datamodel.CaptureEventStateGranted, datamodel.CaptureEventStateDenied, datamodel.CaptureEventStateCompleted, datamodel.CaptureEventStateFailed:
+				filter.States = append(filter.States, state)
+			default:
+				return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "outcome must be a comma-separated list of requested, granted, denied, completed or failed", "invalid outcome", fmt.Errorf("unknown outcome: %q", state))
+			}
+		}
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture events", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	events, err := h.CaptureEventStore.GetCaptureEvents(tx, filter, limit, offset)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture events", "failed to get capture events", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture events", "failed to commit transaction", err)
+	}
+
+	results := make([]map[string]interface{}, 0, len(events))
+	for _, event := range events {
+		results = append(results, captureEventToResponse(event))
+	}
+	return map[string]interface{}{
+		"events": results,
+		"limit":  limit,
+		"offset": offset,
+	}, nil
+}
+
+// クライアントが要求の受付(requested)・権限確認の結果(granted/denied)・実行失敗(failed)を報告する。
+//
+// 実行成功(completed)はPOST /capture/screenshotで記録する。
+func (h *CaptureEventsHandler) post(r *http.Request) (map[string]interface{}, *errorResponse) {
+	validator := utils.GetValidator()
+	type postRequestBodyValidation struct {
+		RequestID    any `json:"request_id" validate:"omitempty,is_string,not_only_whitespaces,max=64"`
+		State        any `json:"state" validate:"required,is_string,oneof=requested granted denied failed"`
+		Mode         any `json:"mode" validate:"omitempty,is_string,oneof=manual scheduled"`
+		ErrorCode    any `json:"error_code" validate:"omitempty,is_string,not_only_whitespaces,max=64"`
+		ErrorMessage any `json:"error_message" validate:"omitempty,is_string,max=1000"`
+	}
+	var requestBodyValidation postRequestBodyValidation
+	if err := json.NewDecoder(r.Body).Decode(&requestBodyValidation); err != nil {
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Invalid JSON format", "failed to decode request body", err)
+	}
+	if err := validator.Struct(requestBodyValidation); err != nil {
+		target := utils.GetFirstValidationErrorTarget(err)
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", fmt.Sprintf("Invalid parameter: %s", target), "failed to validate request body", err)
+	}
+	event := datamodel.CaptureEvent{
+		State:      requestBodyValidation.State.(string),
+		Mode:       datamodel.CaptureModeManual,
+		OccurredAt: time.Now(),
+	}
+	if requestBodyValidation.RequestID != nil {
+		event.RequestID = requestBodyValidation.RequestID.(string)
+	}
+	if requestBodyValidation.Mode != nil {
+		event.Mode = requestBodyValidation.Mode.(string)
+	}
+	if requestBodyValidation.ErrorCode != nil {
+		errorCode := requestBodyValidation.ErrorCode.(string)
+		event.ErrorCode = &errorCode
+	}
+	if requestBodyValidation.ErrorMessage != nil {
+		errorMessage := requestBodyValidation.ErrorMessage.(string)
+		event.ErrorMessage = &errorMessage
+	}
+	isError := event.State == datamodel.CaptureEventStateDenied || event.State == datamodel.CaptureEventStateFailed
+	if isError && event.ErrorCode == nil {
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Invalid parameter: error_code", "error_code is required", nil)
+	}
+	if !isError && (event.ErrorCode != nil || event.ErrorMessage != nil) {
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Invalid parameter: error_code", "error_code is only allowed for denied or failed", nil)
+	}
+	if event.State != datamodel.CaptureEventStateRequested && event.RequestID == "" {
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Invalid parameter: request_id", "request_id is required", nil)
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to record capture event", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	if event.State == datamodel.CaptureEventStateRequested {
+		event, err = startCaptureRequest(h.CaptureEventStore, tx, event)
+	} else {
+		event, err = transitionCaptureRequest(h.CaptureEventStore, tx, event)
+	}
+	if errResponse := captureEventErrorToResponse(err); errResponse != nil {
+		return nil, errResponse
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to record capture event", "failed to commit transaction", err)
+	}
+
+	return map[string]interface{}{
+		"event": captureEventToResponse(event),
+	}, nil
+}
+
+// 要求をrequestedとして記録する。event.RequestIDが空の場合は採番する。既に記録済みの要求の場合はerrInvalidCaptureEventTransitionを返す。
+func startCaptureRequest(eventStore store.CaptureEventStore, tx store.Transaction, event datamodel.CaptureEvent) (datamodel.CaptureEvent, error) {
+	if event.RequestID == "" {
+		event.RequestID = uuid.New().String()
+	}
+	latest, err := eventStore.GetLatestCaptureEvent(tx, event.RequestID)
+	if err != nil {
+		return datamodel.CaptureEvent{}, fmt.Errorf("failed to get latest capture event: %w", err)
+	}
+	if latest != nil {
+		return datamodel.CaptureEvent{}, fmt.Errorf("%w: request %s already exists", errInvalidCaptureEventTransition, event.RequestID)
+	}
+	event.ID = uuid.New().String()
+	event.State = datamodel.CaptureEventStateRequested
+	if err := eventStore.CreateCaptureEvent(tx, event); err != nil {
+		return datamodel.CaptureEvent{}, fmt.Errorf("failed to create capture event: %w", err)
+	}
+	return event, nil
+}
+
+// event.RequestIDの要求をevent.Stateへ遷移させて記録する。modeとschedule_idは要求の記録から引き継ぐ。
+//
+// 要求が存在しない場合はerrCaptureRequestNotFound、遷移できない場合はerrInvalidCaptureEventTransitionを返す。
+func transitionCaptureRequest(eventStore store.CaptureEventStore, tx store.Transaction, event datamodel.CaptureEvent) (datamodel.CaptureEvent, error) {
+	latest, err := eventStore.GetLatestCaptureEvent(tx, event.RequestID)
+	if err != nil {
+		return datamodel.CaptureEvent{}, fmt.Errorf("failed to get latest capture event: %w", err)
+	}
+	if latest == nil {
+		return datamodel.CaptureEvent{}, fmt.Errorf("%w: %s", errCaptureRequestNotFound, event.RequestID)
+	}
+	if !datamodel.CanTransitionCaptureEvent(latest.State, event.State) {
+		return datamodel.CaptureEvent{}, fmt.Errorf("%w: %s -> %s", errInvalidCaptureEventTransition, latest.State, event.State)
+	}
+	event.ID = uuid.New().String()
+	event.Mode = latest.Mode
+	event.ScheduleID = latest.ScheduleID
+	if err := eventStore.CreateCaptureEvent(tx, event); err != nil {
+		return datamodel.CaptureEvent{}, fmt.Errorf("failed to create capture event: %w", err)
+	}
+	return event, nil
+}
+
+// startCaptureRequest・transitionCaptureRequestのエラーをレスポンスに変換する。errがnilの場合はnilを返す。
+func captureEventErrorToResponse(err error) *errorResponse {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, errCaptureRequestNotFound):
+		return newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Capture request not found", "capture request not found", err)
+	case errors.Is(err, errInvalidCaptureEventTransition):
+		return newCodedErrorResponse(http.StatusConflict, "INVALID_STATE", "Invalid capture request state transition", "invalid capture event transition", err)
+	default:
+		return newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to record capture event", "failed to record capture event", err)
+	}
+}
+
+func captureEventToResponse(e datamodel.CaptureEvent) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            e.ID,
+		"request_id":    e.RequestID,
+		"state":         e.State,
+		"mode":          e.Mode,
+		"schedule_id":   e.ScheduleID,
+		"capture_id":    e.CaptureID,
+		"error_code":    e.ErrorCode,
+		"error_message": e.ErrorMessage,
+		"occurred_at":   e.OccurredAt.In(utils.GetJSTTimezone()).Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+const (
+	captureIdlePeriodsDefaultMinDurationMin = 10
+	captureIdlePeriodsMaxMinDurationMin     = 1440
+	captureIdlePeriodsDefaultRange          = 24 * time.Hour
+)
+
+// 重複したキャプチャが続いた期間を、画面が変化しなかった(idle)期間として返す
+type CaptureIdlePeriodsHandler struct {
+	CaptureStore     store.CaptureStore
+	TransactionStore store.TransactionStore
+}
+
+func (h *CaptureIdlePeriodsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *CaptureIdlePeriodsHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	query := r.URL.Query()
+	minDurationMin := captureIdlePeriodsDefaultMinDurationMin
+	if raw := query.Get("min_duration_min"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > captureIdlePeriodsMaxMinDurationMin {
+			return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "min_duration_min must be an integer between 1 and 1440", "invalid min_duration_min", err)
+		}
+		minDurationMin = parsed
+	}
+	to := time.Now()
+	from := to.Add(-captureIdlePeriodsDefaultRange)
+	for _, param := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		raw := query.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", fmt.Sprintf("%s must be RFC3339", param.name), "invalid "+param.name, err)
+		}
+		*param.target = parsed
+	}
+	if !from.Before(to) {
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "from must be before to", "invalid range", fmt.Errorf("from %s is not before to %s", from.Format(time.RFC3339), to.Format(time.RFC3339)))
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get idle periods", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	captures, err := h.CaptureStore.GetCapturesInRange(tx, from, to)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get idle periods", "failed to get captures", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get idle periods", "failed to commit transaction", err)
+	}
+
+	timezone := utils.GetJSTTimezone()
+	periods := capture.DetectIdlePeriods(captures, time.Duration(minDurationMin)*time.Minute)
+	results := make([]map[string]interface{}, 0, len(periods))
+	for _, period := range periods {
+		results = append(results, map[string]interface{}{
+			"start":         period.Start.In(timezone).Format(time.RFC3339),
+			"end":           period.End.In(timezone).Format(time.RFC3339),
+			"duration_sec":  int(period.Duration().Seconds()),
+			"capture_id":    period.CaptureID,
+			"capture_count": period.CaptureCount,
+		})
+	}
+	return map[string]interface{}{
+		"idle_periods": results,
+	}, nil
+}
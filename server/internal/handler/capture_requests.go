@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+// 接続維持のためのコメント送信間隔
+const captureRequestsHeartbeatInterval = 30 * time.Second
+
+// ネイティブクライアントにスケジュールされたキャプチャ要求をSSEで配信する
+type CaptureRequestsHandler struct {
+	Hub *capture.CaptureRequestHub
+}
+
+func (h *CaptureRequestsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.stream(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *CaptureRequestsHandler) stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeResponse(w, nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Streaming is not supported", "response writer does not support flushing", nil))
+		return
+	}
+	requests, unsubscribe := h.Hub.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(captureRequestsHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case request, ok := <-requests:
+			// サーバの停止時に閉じられる
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(captureRequestToResponse(request))
+			if err != nil {
+				log.Printf("failed to marshal capture request: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: capture\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func captureRequestToResponse(request capture.CaptureRequest) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           request.ID,
+		"schedule_id":  request.ScheduleID,
+		"mode":         datamodel.CaptureModeScheduled,
+		"requested_at": request.RequestedAt.In(utils.GetJSTTimezone()).Format(time.RFC3339),
+		"capture_params": map[string]interface{}{
+			"mode":            request.Params.Mode,
+			"region":          captureRegionToResponse(request.Params.Region),
+			"exclude_windows": request.Params.ExcludeWindows,
+		},
+	}
+}
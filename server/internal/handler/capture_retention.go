@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+)
+
+type CaptureRetentionDryRunHandler struct {
+	RetentionCleaner *capture.RetentionCleaner
+}
+
+func (h *CaptureRetentionDryRunHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+// 現在の保持ポリシーで削除されるキャプチャを返す。実際には削除しない。
+func (h *CaptureRetentionDryRunHandler) get() (map[string]interface{}, *errorResponse) {
+	policy, expired, err := h.RetentionCleaner.Plan(time.Now())
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to plan capture cleanup", "failed to plan capture cleanup", err)
+	}
+
+	captures := make([]map[string]interface{}, 0, len(expired))
+	var totalBytes int64
+	for _, c := range expired {
+		captures = append(captures, captureToResponse(c))
+		totalBytes += c.SizeBytes
+	}
+	return map[string]interface{}{
+		"policy": map[string]interface{}{
+			"max_items": policy.MaxItems,
+			"max_days":  policy.MaxDays,
+			"max_bytes": policy.MaxBytes,
+		},
+		"captures":    captures,
+		"total_count": len(captures),
+		"total_bytes": totalBytes,
+	}, nil
+}
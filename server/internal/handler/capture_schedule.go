@@ -2,149 +2,354 @@ package handler
 
 import (
 	"encoding/json"
-	"log"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
 	repositories "github.com/ano333333/llm-time-manager/server/internal/store"
 	"github.com/ano333333/llm-time-manager/server/internal/utils"
+	"github.com/google/uuid"
 )
 
 type CaptureScheduleHandler struct {
-	CaptureScheduleStore repositories.CaptureScheduleStore
-	TransactionStore     repositories.TransactionStore
+	CaptureScheduleStore        repositories.CaptureScheduleStore
+	CaptureScheduleHistoryStore repositories.CaptureScheduleHistoryStore
+	TransactionStore            repositories.TransactionStore
+	Scheduler                   *capture.Scheduler
 }
 
 func (h *CaptureScheduleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
 	switch r.Method {
 	case "GET":
-		h.get(w)
+		body, errResponse = h.get()
 	case "PUT":
-		h.put(w, r)
+		body, errResponse = h.put(r)
 	default:
 		http.NotFound(w, r)
+		return
 	}
+	writeResponse(w, body, errResponse)
 }
 
-func (h *CaptureScheduleHandler) get(w http.ResponseWriter) {
+func (h *CaptureScheduleHandler) get() (map[string]interface{}, *errorResponse) {
 	tx, err := h.TransactionStore.Begin()
 	if err != nil {
-		log.Printf("failed to begin transaction: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+		return nil, newInternalServerErrorResponse("failed to begin transaction", err)
 	}
 	defer tx.Rollback()
-	captureSchedule, err := h.CaptureScheduleStore.GetActiveCaptureSchedule(tx)
+	captureSchedule, err := getTargetCaptureSchedule(h.CaptureScheduleStore, tx)
 	if err != nil {
-		log.Printf("failed to get active capture schedule: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+		return nil, newInternalServerErrorResponse("failed to get capture schedule", err)
 	}
 	if err := tx.Commit(); err != nil {
-		log.Printf("failed to commit transaction: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+		return nil, newInternalServerErrorResponse("failed to commit transaction", err)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if captureSchedule != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"schedule": map[string]interface{}{
-				"id":           captureSchedule.ID,
-				"active":       captureSchedule.Active,
-				"interval_min": captureSchedule.IntervalMin,
-			},
-		})
-	} else {
-		json.NewEncoder(w).Encode(map[string]interface{}{
+	if captureSchedule == nil {
+		return map[string]interface{}{
 			"schedule": nil,
-		})
+		}, nil
 	}
+	return map[string]interface{}{
+		"schedule": captureScheduleToResponse(*captureSchedule),
+	}, nil
 }
 
-func (h *CaptureScheduleHandler) put(w http.ResponseWriter, r *http.Request) {
+// スケジュールを更新する。スケジュールが存在しない場合は作成する。
+func (h *CaptureScheduleHandler) put(r *http.Request) (map[string]interface{}, *errorResponse) {
 	validator := utils.GetValidator()
 
+	type TimeWindowValidation struct {
+		Weekdays []any `json:"weekdays" validate:"omitempty,max=7,dive,is_integer,min=0,max=6"`
+		Start    any   `json:"start" validate:"required,is_string"`
+		End      any   `json:"end" validate:"required,is_string"`
+	}
+	type RegionValidation struct {
+		X      any `json:"x" validate:"is_integer,min=0"`
+		Y      any `json:"y" validate:"is_integer,min=0"`
+		Width  any `json:"width" validate:"is_integer,min=1"`
+		Height any `json:"height" validate:"is_integer,min=1"`
+	}
 	type RequestBodyValidation struct {
-		Active      any `json:"active" validate:"required,is_boolean"`
-		IntervalMin any `json:"interval_min" validate:"required,is_integer,min=1,max=1440"`
+		Active         any                    `json:"active" validate:"required,is_boolean"`
+		IntervalMin    any                    `json:"interval_min" validate:"required,is_integer,min=1,max=1440"`
+		TimeWindows    []TimeWindowValidation `json:"time_windows" validate:"omitempty,max=20,dive"`
+		ExcludedDates  []any                  `json:"excluded_dates" validate:"omitempty,max=366,dive,is_string,datetime=2006-01-02"`
+		JitterPct      any                    `json:"jitter_pct" validate:"omitempty,is_integer,min=0,max=50"`
+		CaptureMode    any                    `json:"capture_mode" validate:"omitempty,is_string,oneof=full window region"`
+		Region         *RegionValidation      `json:"region" validate:"omitempty"`
+		ExcludeWindows []any                  `json:"exclude_windows" validate:"omitempty,max=100,dive,is_string,not_only_whitespaces,max=256"`
 	}
 	type RequestBody struct {
-		Active      bool `json:"active"`
-		IntervalMin int  `json:"interval_min"`
+		Active         bool                          `json:"active"`
+		IntervalMin    int                           `json:"interval_min"`
+		TimeWindows    []datamodel.CaptureTimeWindow `json:"time_windows"`
+		ExcludedDates  []string                      `json:"excluded_dates"`
+		JitterPct      int                           `json:"jitter_pct"`
+		CaptureMode    string                        `json:"capture_mode"`
+		Region         *datamodel.CaptureRegion      `json:"region"`
+		ExcludeWindows []string                      `json:"exclude_windows"`
 	}
 	var requestBodyValidation RequestBodyValidation
 	if err := json.NewDecoder(r.Body).Decode(&requestBodyValidation); err != nil {
-		log.Printf("failed to decode request body: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"message": "invalid JSON format",
-		})
-		return
+		return nil, &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid JSON format",
+			},
+			LogMessage: "failed to decode request body",
+			Err:        err,
+		}
 	}
 	if err := validator.Struct(requestBodyValidation); err != nil {
-		log.Printf("failed to validate request body: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"message": "invalid parameter",
-			"target":  utils.GetFirstValidationErrorTarget(err),
-		})
-		return
+		return nil, &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid parameter",
+				"target":  utils.GetFirstValidationErrorTarget(err),
+			},
+			LogMessage: "failed to validate request body",
+			Err:        err,
+		}
 	}
 	requestBody := RequestBody{
-		Active:      requestBodyValidation.Active.(bool),
-		IntervalMin: (int)(requestBodyValidation.IntervalMin.(float64)),
+		Active:         requestBodyValidation.Active.(bool),
+		IntervalMin:    (int)(requestBodyValidation.IntervalMin.(float64)),
+		TimeWindows:    []datamodel.CaptureTimeWindow{},
+		ExcludedDates:  []string{},
+		CaptureMode:    datamodel.CaptureScheduleModeFull,
+		ExcludeWindows: []string{},
+	}
+	for _, window := range requestBodyValidation.TimeWindows {
+		timeWindow := datamodel.CaptureTimeWindow{
+			Weekdays: []int{},
+			Start:    window.Start.(string),
+			End:      window.End.(string),
+		}
+		for _, weekday := range window.Weekdays {
+			timeWindow.Weekdays = append(timeWindow.Weekdays, (int)(weekday.(float64)))
+		}
+		if err := validateCaptureTimeWindow(timeWindow); err != nil {
+			return nil, &errorResponse{
+				StatusCode: http.StatusBadRequest,
+				Body: map[string]interface{}{
+					"message": "invalid parameter",
+					"target":  "time_windows",
+				},
+				LogMessage: "failed to validate request body",
+				Err:        err,
+			}
+		}
+		requestBody.TimeWindows = append(requestBody.TimeWindows, timeWindow)
+	}
+	for _, date := range requestBodyValidation.ExcludedDates {
+		requestBody.ExcludedDates = append(requestBody.ExcludedDates, date.(string))
+	}
+	if requestBodyValidation.JitterPct != nil {
+		requestBody.JitterPct = (int)(requestBodyValidation.JitterPct.(float64))
+	}
+	if requestBodyValidation.CaptureMode != nil {
+		requestBody.CaptureMode = requestBodyValidation.CaptureMode.(string)
+	}
+	// regionはcapture_modeがregionの場合に必須で、それ以外では指定できない
+	if (requestBody.CaptureMode == datamodel.CaptureScheduleModeRegion) != (requestBodyValidation.Region != nil) {
+		return nil, &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid parameter",
+				"target":  "region",
+			},
+			LogMessage: "region must be specified only when capture_mode is region",
+			Err:        nil,
+		}
+	}
+	if region := requestBodyValidation.Region; region != nil {
+		requestBody.Region = &datamodel.CaptureRegion{
+			X:      (int)(region.X.(float64)),
+			Y:      (int)(region.Y.(float64)),
+			Width:  (int)(region.Width.(float64)),
+			Height: (int)(region.Height.(float64)),
+		}
+	}
+	for _, windowID := range requestBodyValidation.ExcludeWindows {
+		requestBody.ExcludeWindows = append(requestBody.ExcludeWindows, windowID.(string))
+	}
+	state := datamodel.CaptureScheduleStateInactive
+	if requestBody.Active {
+		state = datamodel.CaptureScheduleStateActive
 	}
 
 	tx, err := h.TransactionStore.Begin()
 	if err != nil {
-		log.Printf("failed to begin transaction: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+		return nil, newInternalServerErrorResponse("failed to begin transaction", err)
 	}
 	defer tx.Rollback()
 
-	affectedRows, err := h.CaptureScheduleStore.UpdateActiveCaptureSchedule(tx, requestBody.Active, requestBody.IntervalMin)
+	previous, err := getTargetCaptureSchedule(h.CaptureScheduleStore, tx)
 	if err != nil {
-		log.Printf("failed to update active capture schedule: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+		return nil, newInternalServerErrorResponse("failed to get capture schedule", err)
 	}
-	if affectedRows == 0 {
-		log.Printf("no active capture schedule found")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"message": "no active capture schedule found",
+	var captureSchedule datamodel.CaptureSchedule
+	action := datamodel.CaptureScheduleActionUpdate
+	if previous == nil {
+		action = datamodel.CaptureScheduleActionCreate
+		captureSchedule, err = h.CaptureScheduleStore.CreateCaptureSchedule(tx, datamodel.CaptureSchedule{
+			ID:             uuid.New().String(),
+			State:          state,
+			IntervalMin:    requestBody.IntervalMin,
+			TimeWindows:    requestBody.TimeWindows,
+			ExcludedDates:  requestBody.ExcludedDates,
+			JitterPct:      requestBody.JitterPct,
+			CaptureMode:    requestBody.CaptureMode,
+			Region:         requestBody.Region,
+			ExcludeWindows: requestBody.ExcludeWindows,
 		})
-		return
+		if err != nil {
+			return nil, newInternalServerErrorResponse("failed to create capture schedule", err)
+		}
+	} else {
+		captureSchedule, err = h.CaptureScheduleStore.UpdateCaptureSchedule(tx, datamodel.CaptureSchedule{
+			ID:             previous.ID,
+			State:          state,
+			IntervalMin:    requestBody.IntervalMin,
+			TimeWindows:    requestBody.TimeWindows,
+			ExcludedDates:  requestBody.ExcludedDates,
+			JitterPct:      requestBody.JitterPct,
+			CaptureMode:    requestBody.CaptureMode,
+			Region:         requestBody.Region,
+			ExcludeWindows: requestBody.ExcludeWindows,
+		})
+		if err != nil {
+			return nil, newInternalServerErrorResponse("failed to update capture schedule", err)
+		}
 	}
-	captureSchedule, err := h.CaptureScheduleStore.GetActiveCaptureSchedule(tx)
-	if err != nil {
-		log.Printf("failed to get active capture schedule: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+	if err := recordCaptureScheduleHistory(h.CaptureScheduleHistoryStore, tx, action, datamodel.CaptureScheduleChangedByUser, previous, captureSchedule); err != nil {
+		return nil, newInternalServerErrorResponse("failed to record capture schedule history", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("failed to commit transaction: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+		return nil, newInternalServerErrorResponse("failed to commit transaction", err)
 	}
+	h.Scheduler.Reload()
 
-	w.Header().Set("Content-Type", "application/json")
-	if captureSchedule != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"schedule": map[string]interface{}{
-				"id":           captureSchedule.ID,
-				"active":       captureSchedule.Active,
-				"interval_min": captureSchedule.IntervalMin,
-			},
-		})
-	} else {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"schedule": nil,
+	return map[string]interface{}{
+		"schedule": captureScheduleToResponse(captureSchedule),
+	}, nil
+}
+
+// start・endが"HH:MM"形式で、start < endであることを確認する
+func validateCaptureTimeWindow(window datamodel.CaptureTimeWindow) error {
+	start, err := capture.ParseClock(window.Start)
+	if err != nil {
+		return err
+	}
+	end, err := capture.ParseClock(window.End)
+	if err != nil {
+		return err
+	}
+	if start >= end {
+		return fmt.Errorf("time window start %s must be before end %s", window.Start, window.End)
+	}
+	return nil
+}
+
+// 実行中・一時停止中のスケジュールを返す。なければ最後に更新されたスケジュールを返す。
+func getTargetCaptureSchedule(captureScheduleStore repositories.CaptureScheduleStore, tx repositories.Transaction) (*datamodel.CaptureSchedule, error) {
+	schedule, err := captureScheduleStore.GetCurrentCaptureSchedule(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current capture schedule: %w", err)
+	}
+	if schedule != nil {
+		return schedule, nil
+	}
+	schedule, err = captureScheduleStore.GetLatestCaptureSchedule(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest capture schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// previousからcurrentへの変更を履歴に記録する。previousがnilの場合は作成として記録する。
+func recordCaptureScheduleHistory(historyStore repositories.CaptureScheduleHistoryStore, tx repositories.Transaction, action string, changedBy string, previous *datamodel.CaptureSchedule, current datamodel.CaptureSchedule) error {
+	newSnapshot := current.Snapshot()
+	history := datamodel.CaptureScheduleHistory{
+		ID:             uuid.New().String(),
+		ScheduleID:     current.ID,
+		Action:         action,
+		ChangedBy:      changedBy,
+		NewState:       current.State,
+		NewIntervalMin: current.IntervalMin,
+		NewSnapshot:    &newSnapshot,
+	}
+	if previous != nil {
+		oldSnapshot := previous.Snapshot()
+		history.OldState = &previous.State
+		history.OldIntervalMin = &previous.IntervalMin
+		history.OldSnapshot = &oldSnapshot
+	}
+	return historyStore.CreateCaptureScheduleHistory(tx, history)
+}
+
+func captureScheduleToResponse(s datamodel.CaptureSchedule) map[string]interface{} {
+	var lastFailureAt *string
+	if s.LastFailureAt != nil {
+		formatted := s.LastFailureAt.In(utils.GetJSTTimezone()).Format(time.RFC3339)
+		lastFailureAt = &formatted
+	}
+	response := captureScheduleSnapshotToResponse(s.Snapshot())
+	response["id"] = s.ID
+	response["active"] = s.IsActive()
+	response["consecutive_failures"] = s.ConsecutiveFailures
+	response["last_failure_at"] = lastFailureAt
+	response["last_failure_reason"] = s.LastFailureReason
+	return response
+}
+
+func captureScheduleSnapshotToResponse(s datamodel.CaptureScheduleSnapshot) map[string]interface{} {
+	timeWindows := []map[string]interface{}{}
+	for _, window := range s.TimeWindows {
+		weekdays := window.Weekdays
+		if weekdays == nil {
+			weekdays = []int{}
+		}
+		timeWindows = append(timeWindows, map[string]interface{}{
+			"weekdays": weekdays,
+			"start":    window.Start,
+			"end":      window.End,
 		})
 	}
+	excludedDates := s.ExcludedDates
+	if excludedDates == nil {
+		excludedDates = []string{}
+	}
+	excludeWindows := s.ExcludeWindows
+	if excludeWindows == nil {
+		excludeWindows = []string{}
+	}
+	return map[string]interface{}{
+		"state":           s.State,
+		"interval_min":    s.IntervalMin,
+		"time_windows":    timeWindows,
+		"excluded_dates":  excludedDates,
+		"jitter_pct":      s.JitterPct,
+		"capture_mode":    s.CaptureMode,
+		"region":          captureRegionToResponse(s.Region),
+		"exclude_windows": excludeWindows,
+	}
+}
+
+func captureRegionToResponse(region *datamodel.CaptureRegion) map[string]interface{} {
+	if region == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"x":      region.X,
+		"y":      region.Y,
+		"width":  region.Width,
+		"height": region.Height,
+	}
 }
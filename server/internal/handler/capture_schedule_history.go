@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+const (
+	captureScheduleHistoryDefaultLimit = 50
+	captureScheduleHistoryMaxLimit     = 200
+)
+
+type CaptureScheduleHistoryHandler struct {
+	CaptureScheduleHistoryStore store.CaptureScheduleHistoryStore
+	TransactionStore            store.TransactionStore
+}
+
+func (h *CaptureScheduleHistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *CaptureScheduleHistoryHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	limit, offset, errResponse := parsePagination(r, captureScheduleHistoryDefaultLimit, captureScheduleHistoryMaxLimit)
+	if errResponse != nil {
+		return nil, errResponse
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture schedule history", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	history, err := h.CaptureScheduleHistoryStore.GetCaptureScheduleHistory(tx, limit, offset)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture schedule history", "failed to get capture schedule history", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture schedule history", "failed to commit transaction", err)
+	}
+
+	results := make([]map[string]interface{}, 0, len(history))
+	for _, entry := range history {
+		results = append(results, captureScheduleHistoryToResponse(entry))
+	}
+	return map[string]interface{}{
+		"history": results,
+		"limit":   limit,
+		"offset":  offset,
+	}, nil
+}
+
+func captureScheduleHistoryToResponse(h datamodel.CaptureScheduleHistory) map[string]interface{} {
+	var previous, current map[string]interface{}
+	if h.NewSnapshot != nil {
+		if h.OldSnapshot != nil {
+			previous = captureScheduleSnapshotToResponse(*h.OldSnapshot)
+		}
+		current = captureScheduleSnapshotToResponse(*h.NewSnapshot)
+	} else {
+		// スナップショットを記録する前の履歴は、状態と間隔だけを返す
+		if h.OldState != nil && h.OldIntervalMin != nil {
+			previous = map[string]interface{}{
+				"state":        *h.OldState,
+				"interval_min": *h.OldIntervalMin,
+			}
+		}
+		current = map[string]interface{}{
+			"state":        h.NewState,
+			"interval_min": h.NewIntervalMin,
+		}
+	}
+	return map[string]interface{}{
+		"id":          h.ID,
+		"schedule_id": h.ScheduleID,
+		"action":      h.Action,
+		"changed_by":  h.ChangedBy,
+		"old":         previous,
+		"new":         current,
+		"changed_at":  h.ChangedAt.In(utils.GetJSTTimezone()).Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+const (
+	captureSchedulePreviewDefaultCount = 10
+	captureSchedulePreviewMaxCount     = 100
+)
+
+// スケジュールの時間帯・除外日を反映した今後のキャプチャ時刻を返す。ジッターは含めず、ずれ幅をjitter_secで返す。
+type CaptureSchedulePreviewHandler struct {
+	CaptureScheduleStore store.CaptureScheduleStore
+	TransactionStore     store.TransactionStore
+}
+
+func (h *CaptureSchedulePreviewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *CaptureSchedulePreviewHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	query := r.URL.Query()
+	count := captureSchedulePreviewDefaultCount
+	if raw := query.Get("count"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > captureSchedulePreviewMaxCount {
+			return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "count must be an integer between 1 and 100", "invalid count", err)
+		}
+		count = parsed
+	}
+	from := time.Now()
+	if raw := query.Get("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "from must be RFC3339", "invalid from", err)
+		}
+		from = parsed
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to preview capture schedule", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+	schedule, err := getTargetCaptureSchedule(h.CaptureScheduleStore, tx)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to preview capture schedule", "failed to get capture schedule", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to preview capture schedule", "failed to commit transaction", err)
+	}
+	if schedule == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Capture schedule not found", "capture schedule not found", nil)
+	}
+
+	times := []string{}
+	for _, t := range capture.NextCaptureTimes(*schedule, from, count) {
+		times = append(times, t.In(utils.GetJSTTimezone()).Format(time.RFC3339))
+	}
+	return map[string]interface{}{
+		"schedule_id": schedule.ID,
+		"state":       schedule.State,
+		"jitter_sec":  int(capture.JitterRange(*schedule).Seconds()),
+		"times":       times,
+	}, nil
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+// キャプチャスケジュールをFromからToの状態に遷移させる（start/stop/resume）
+type CaptureScheduleTransitionHandler struct {
+	CaptureScheduleStore        store.CaptureScheduleStore
+	CaptureScheduleHistoryStore store.CaptureScheduleHistoryStore
+	TransactionStore            store.TransactionStore
+	Scheduler                   *capture.Scheduler
+	From                        string
+	To                          string
+	// 変更履歴に記録する操作
+	Action string
+	// 成功時のレスポンスのmessage
+	Message string
+}
+
+func (h *CaptureScheduleTransitionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "POST":
+		body, errResponse = h.post()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *CaptureScheduleTransitionHandler) post() (map[string]interface{}, *errorResponse) {
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update capture schedule", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	schedule, err := getTargetCaptureSchedule(h.CaptureScheduleStore, tx)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update capture schedule", "failed to get capture schedule", err)
+	}
+	if schedule == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Capture schedule not found", "capture schedule not found", nil)
+	}
+	if schedule.State != h.From {
+		return nil, newCodedErrorResponse(http.StatusConflict, "INVALID_STATE", fmt.Sprintf("Capture schedule is %s", schedule.State), "invalid capture schedule state transition", fmt.Errorf("%s -> %s", schedule.State, h.To))
+	}
+	if err := h.CaptureScheduleStore.UpdateCaptureScheduleState(tx, schedule.ID, h.To); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update capture schedule", "failed to update capture schedule state", err)
+	}
+	updated := *schedule
+	updated.State = h.To
+	updated.ConsecutiveFailures = 0
+	if err := recordCaptureScheduleHistory(h.CaptureScheduleHistoryStore, tx, h.Action, datamodel.CaptureScheduleChangedByUser, schedule, updated); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update capture schedule", "failed to record capture schedule history", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update capture schedule", "failed to commit transaction", err)
+	}
+	h.Scheduler.Reload()
+
+	return map[string]interface{}{
+		"message":  h.Message,
+		"schedule": captureScheduleToResponse(updated),
+	}, nil
+}
+
+// クライアントからスケジュール実行の失敗報告を受け付ける。連続失敗が上限に達するとスケジュールをPAUSEDにする。
+type CaptureScheduleFailureHandler struct {
+	CaptureScheduleStore        store.CaptureScheduleStore
+	CaptureScheduleHistoryStore store.CaptureScheduleHistoryStore
+	TransactionStore            store.TransactionStore
+	Scheduler                   *capture.Scheduler
+}
+
+func (h *CaptureScheduleFailureHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "POST":
+		body, errResponse = h.post(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *CaptureScheduleFailureHandler) post(r *http.Request) (map[string]interface{}, *errorResponse) {
+	validator := utils.GetValidator()
+	type postRequestBodyValidation struct {
+		Reason any `json:"reason" validate:"required,is_string,not_only_whitespaces,max=1000"`
+	}
+	var requestBodyValidation postRequestBodyValidation
+	if err := json.NewDecoder(r.Body).Decode(&requestBodyValidation); err != nil {
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Invalid JSON format", "failed to decode request body", err)
+	}
+	if err := validator.Struct(requestBodyValidation); err != nil {
+		target := utils.GetFirstValidationErrorTarget(err)
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", fmt.Sprintf("Invalid parameter: %s", target), "failed to validate request body", err)
+	}
+	reason := requestBodyValidation.Reason.(string)
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to record failure", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	schedule, err := h.CaptureScheduleStore.RecordActiveCaptureScheduleFailure(tx, reason, time.Now(), datamodel.CaptureScheduleMaxConsecutiveFailures)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to record failure", "failed to record capture schedule failure", err)
+	}
+	if schedule == nil {
+		return nil, newCodedErrorResponse(http.StatusConflict, "INVALID_STATE", "No active capture schedule", "no active capture schedule", nil)
+	}
+	paused := schedule.State == datamodel.CaptureScheduleStatePaused
+	if paused {
+		previous := *schedule
+		previous.State = datamodel.CaptureScheduleStateActive
+		if err := recordCaptureScheduleHistory(h.CaptureScheduleHistoryStore, tx, datamodel.CaptureScheduleActionPause, datamodel.CaptureScheduleChangedBySystem, &previous, *schedule); err != nil {
+			return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to record failure", "failed to record capture schedule history", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to record failure", "failed to commit transaction", err)
+	}
+	if paused {
+		h.Scheduler.Reload()
+	}
+
+	return map[string]interface{}{
+		"schedule": captureScheduleToResponse(*schedule),
+	}, nil
+}
@@ -0,0 +1,421 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/analysis"
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+	"github.com/google/uuid"
+)
+
+type CaptureScreenshotHandler struct {
+	CaptureStore         store.CaptureStore
+	CaptureScheduleStore store.CaptureScheduleStore
+	CaptureEventStore    store.CaptureEventStore
+	SettingsStore        store.SettingsStore
+	TaskStore            store.TaskStore
+	TransactionStore     store.TransactionStore
+	Storage              *capture.Storage
+	RetentionCleaner     *capture.RetentionCleaner
+	AnalysisPool         *analysis.Pool
+	// リクエストボディの上限（バイト）
+	MaxUploadBytes int64
+}
+
+func (h *CaptureScreenshotHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "POST":
+		body, errResponse = h.post(w, r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+type screenshotUpload struct {
+	Data       []byte
+	Info       capture.ImageInfo
+	Mode       string
+	CapturedAt time.Time
+	// GET /capture/requestsで受け取った要求のID。クライアントが自発的にキャプチャした場合は空
+	RequestID string
+	// 撮影したディスプレイのID。マスクルールの選択に使う。不明な場合は空
+	DisplayID string
+}
+
+func (h *CaptureScreenshotHandler) post(w http.ResponseWriter, r *http.Request) (map[string]interface{}, *errorResponse) {
+	upload, errResponse := h.parseUpload(w, r)
+	if errResponse != nil {
+		return nil, errResponse
+	}
+
+	settings, err := h.getSettings()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save screenshot", "failed to get settings", err)
+	}
+	// マスク前の画像は保存もLLMへの送信もしない
+	if errResponse := maskUpload(&upload, settings.MaskRules); errResponse != nil {
+		return nil, errResponse
+	}
+	resolution := settings.ThumbnailResolution
+	thumbnail, err := capture.GenerateThumbnail(upload.Data, resolution)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Image could not be decoded", "failed to generate thumbnail", err)
+	}
+	hash, err := capture.PerceptualHash(upload.Data)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Image could not be decoded", "failed to compute perceptual hash", err)
+	}
+	phash := capture.FormatPerceptualHash(hash)
+	duplicateOf, err := h.findDuplicate(upload, hash, settings)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save screenshot", "failed to find duplicate capture", err)
+	}
+	if duplicateOf != nil && settings.DedupMode == datamodel.DedupModeDrop {
+		updated, err := h.recordDroppedDuplicate(*duplicateOf, upload)
+		if errors.Is(err, errCaptureRequestNotFound) || errors.Is(err, errInvalidCaptureEventTransition) {
+			return nil, captureEventErrorToResponse(err)
+		}
+		if err != nil {
+			return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save screenshot", "failed to record duplicate capture", err)
+		}
+		return map[string]interface{}{
+			"capture":      captureToResponse(updated),
+			"dropped":      true,
+			"analysis_job": nil,
+		}, nil
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save screenshot", "failed to generate capture id", err)
+	}
+	relPath, err := h.Storage.Save(id.String(), upload.Info.Format, upload.CapturedAt, upload.Data)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save screenshot", "failed to save capture file", err)
+	}
+	thumbPath, err := h.Storage.SaveThumbnail(relPath, thumbnail)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save screenshot", "failed to save thumbnail file", h.removeFiles(err, relPath))
+	}
+	created, job, err := h.createCapture(datamodel.Capture{
+		ID:              id.String(),
+		Path:            relPath,
+		Format:          upload.Info.Format,
+		Width:           upload.Info.Width,
+		Height:          upload.Info.Height,
+		SizeBytes:       int64(len(upload.Data)),
+		SHA256:          upload.Info.SHA256,
+		Mode:            upload.Mode,
+		CapturedAt:      upload.CapturedAt,
+		ThumbPath:       &thumbPath,
+		ThumbResolution: &resolution,
+		PHash:           &phash,
+		DuplicateOf:     duplicateIDOrNil(duplicateOf),
+		DisplayID:       displayIDOrNil(upload.DisplayID),
+	}, upload.RequestID)
+	if err != nil {
+		err = h.removeFiles(err, relPath, thumbPath)
+		if errors.Is(err, errCaptureRequestNotFound) || errors.Is(err, errInvalidCaptureEventTransition) {
+			return nil, captureEventErrorToResponse(err)
+		}
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save screenshot", "failed to create capture", err)
+	}
+	// 追加により保持ポリシーを超えた古いキャプチャを削除する
+	h.RetentionCleaner.Trigger()
+	var jobResponse map[string]interface{}
+	if job != nil {
+		h.AnalysisPool.Trigger()
+		jobResponse = captureAnalysisJobToResponse(*job)
+	}
+
+	return map[string]interface{}{
+		"capture":      captureToResponse(created),
+		"dropped":      false,
+		"analysis_job": jobResponse,
+	}, nil
+}
+
+// multipart/form-dataを検証し、画像データとメタデータを取り出す
+func (h *CaptureScreenshotHandler) parseUpload(w http.ResponseWriter, r *http.Request) (screenshotUpload, *errorResponse) {
+	emptyUpload := screenshotUpload{}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes)
+	if err := r.ParseMultipartForm(h.MaxUploadBytes); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return emptyUpload, newCodedErrorResponse(http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE", fmt.Sprintf("Image must be %d bytes or less", h.MaxUploadBytes), "request body too large", err)
+		}
+		return emptyUpload, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Request must be multipart/form-data", "failed to parse multipart form", err)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		return emptyUpload, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Image file is required", "image file is missing", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return emptyUpload, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save screenshot", "failed to read image file", err)
+	}
+	info, err := capture.Inspect(data)
+	if err != nil {
+		return emptyUpload, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Image must be PNG or JPEG", "failed to inspect image", err)
+	}
+
+	mode := r.FormValue("mode")
+	if mode == "" {
+		mode = datamodel.CaptureModeManual
+	}
+	if mode != datamodel.CaptureModeManual && mode != datamodel.CaptureModeScheduled {
+		return emptyUpload, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "mode must be manual or scheduled", "invalid mode", nil)
+	}
+
+	capturedAt := time.Now()
+	if capturedAtRaw := r.FormValue("captured_at"); capturedAtRaw != "" {
+		capturedAt, err = time.Parse(time.RFC3339, capturedAtRaw)
+		if err != nil {
+			return emptyUpload, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "captured_at must be RFC3339", "invalid captured_at", err)
+		}
+	}
+
+	requestID := r.FormValue("request_id")
+	if len(requestID) > 64 {
+		return emptyUpload, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "request_id must be 64 characters or less", "invalid request_id", nil)
+	}
+
+	displayID := r.FormValue("display_id")
+	if len(displayID) > 64 {
+		return emptyUpload, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "display_id must be 64 characters or less", "invalid display_id", nil)
+	}
+
+	return screenshotUpload{
+		Data:       data,
+		Info:       info,
+		Mode:       mode,
+		CapturedAt: capturedAt,
+		RequestID:  requestID,
+		DisplayID:  displayID,
+	}, nil
+}
+
+// uploadの画像に撮影したディスプレイのマスクルールを適用し、データとハッシュ等を置き換える
+func maskUpload(upload *screenshotUpload, rules []datamodel.MaskRule) *errorResponse {
+	masked, err := capture.ApplyMasks(upload.Data, capture.MaskRulesForDisplay(rules, upload.DisplayID))
+	if err != nil {
+		return newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Image could not be decoded", "failed to apply mask rules", err)
+	}
+	info, err := capture.Inspect(masked)
+	if err != nil {
+		return newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save screenshot", "failed to inspect masked image", err)
+	}
+	upload.Data = masked
+	upload.Info = info
+	return nil
+}
+
+func (h *CaptureScreenshotHandler) getSettings() (datamodel.Settings, error) {
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return datamodel.Settings{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	settings, err := h.SettingsStore.GetSettings(tx)
+	if err != nil {
+		return datamodel.Settings{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return datamodel.Settings{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return settings, nil
+}
+
+// 定期キャプチャが直前のキャプチャと重複している場合、直前のキャプチャを返す。重複していない場合はnilを返す。
+//
+// 手動キャプチャはユーザーが意図して撮影したものなので重複を判定しない。
+func (h *CaptureScreenshotHandler) findDuplicate(upload screenshotUpload, hash uint64, settings datamodel.Settings) (*datamodel.Capture, error) {
+	if upload.Mode != datamodel.CaptureModeScheduled || settings.DedupMode == datamodel.DedupModeOff {
+		return nil, nil
+	}
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	previous, err := h.CaptureStore.GetPreviousCapture(tx, upload.CapturedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if previous == nil || previous.PHash == nil {
+		return nil, nil
+	}
+	previousHash, err := capture.ParsePerceptualHash(*previous.PHash)
+	if err != nil {
+		return nil, err
+	}
+	if capture.HammingDistance(hash, previousHash) > settings.DedupThreshold {
+		return nil, nil
+	}
+	return previous, nil
+}
+
+// 保存せずに捨てる重複キャプチャを重複元のduplicateOfに記録し、更新後の重複元を返す
+func (h *CaptureScreenshotHandler) recordDroppedDuplicate(duplicateOf datamodel.Capture, upload screenshotUpload) (datamodel.Capture, error) {
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return datamodel.Capture{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	updated, err := h.CaptureStore.RecordDuplicateCapture(tx, duplicateOf.ID, upload.CapturedAt)
+	if err != nil {
+		return datamodel.Capture{}, err
+	}
+	// 画面が変わっていないだけで、スケジュール実行は成功している
+	if err := h.CaptureScheduleStore.ResetActiveCaptureScheduleFailures(tx); err != nil {
+		return datamodel.Capture{}, fmt.Errorf("failed to reset capture schedule failures: %w", err)
+	}
+	if err := h.recordCaptureCompleted(tx, upload.RequestID, updated); err != nil {
+		return datamodel.Capture{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return datamodel.Capture{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return updated, nil
+}
+
+func duplicateIDOrNil(duplicateOf *datamodel.Capture) *string {
+	if duplicateOf == nil {
+		return nil
+	}
+	return &duplicateOf.ID
+}
+
+func displayIDOrNil(displayID string) *string {
+	if displayID == "" {
+		return nil
+	}
+	return &displayID
+}
+
+// Captureを作成し、キャプチャ要求requestIDの実行成功を記録する。
+//
+// 重複でないCaptureは解析ジョブも作成して返す。重複の場合、ジョブはnilになる。
+func (h *CaptureScreenshotHandler) createCapture(c datamodel.Capture, requestID string) (datamodel.Capture, *datamodel.CaptureAnalysisJob, error) {
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return datamodel.Capture{}, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	task, err := h.TaskStore.GetDoingTaskAt(tx, c.CapturedAt)
+	if err != nil {
+		return datamodel.Capture{}, nil, fmt.Errorf("failed to get doing task: %w", err)
+	}
+	if task != nil {
+		c.LinkedTaskID = &task.ID
+		c.LinkedGoalID = task.GoalID
+	}
+	created, err := h.CaptureStore.CreateCapture(tx, c)
+	if err != nil {
+		return datamodel.Capture{}, nil, err
+	}
+	// スケジュール実行が成功したので連続失敗回数を戻す
+	if c.Mode == datamodel.CaptureModeScheduled {
+		if err := h.CaptureScheduleStore.ResetActiveCaptureScheduleFailures(tx); err != nil {
+			return datamodel.Capture{}, nil, fmt.Errorf("failed to reset capture schedule failures: %w", err)
+		}
+	}
+	if err := h.recordCaptureCompleted(tx, requestID, created); err != nil {
+		return datamodel.Capture{}, nil, err
+	}
+	// 画面が変わっていない重複は解析しても結果が変わらないため、解析しない
+	var job *datamodel.CaptureAnalysisJob
+	if created.DuplicateOf == nil {
+		enqueued, err := h.AnalysisPool.Enqueue(tx, created.ID)
+		if err != nil {
+			return datamodel.Capture{}, nil, err
+		}
+		job = &enqueued
+	}
+	if err := tx.Commit(); err != nil {
+		return datamodel.Capture{}, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return created, job, nil
+}
+
+// requestIDの要求をcompletedとして記録する。requestIDが空の場合は要求の受付から記録する。
+func (h *CaptureScreenshotHandler) recordCaptureCompleted(tx store.Transaction, requestID string, c datamodel.Capture) error {
+	now := time.Now()
+	if requestID == "" {
+		requested, err := startCaptureRequest(h.CaptureEventStore, tx, datamodel.CaptureEvent{Mode: c.Mode, OccurredAt: now})
+		if err != nil {
+			return err
+		}
+		requestID = requested.RequestID
+	}
+	latest, err := h.CaptureEventStore.GetLatestCaptureEvent(tx, requestID)
+	if err != nil {
+		return fmt.Errorf("failed to get latest capture event: %w", err)
+	}
+	// 権限確認の結果を報告しないクライアントでも、キャプチャできたので許可されていたとみなす
+	if latest != nil && latest.State == datamodel.CaptureEventStateRequested {
+		if _, err := transitionCaptureRequest(h.CaptureEventStore, tx, datamodel.CaptureEvent{RequestID: requestID, State: datamodel.CaptureEventStateGranted, OccurredAt: now}); err != nil {
+			return err
+		}
+	}
+	_, err = transitionCaptureRequest(h.CaptureEventStore, tx, datamodel.CaptureEvent{RequestID: requestID, State: datamodel.CaptureEventStateCompleted, CaptureID: &c.ID, OccurredAt: now})
+	return err
+}
+
+// DBに記録できなかったファイルは孤立するため削除する。削除に失敗した場合はそのエラーをerrに加えて返す。
+func (h *CaptureScreenshotHandler) removeFiles(err error, relPaths ...string) error {
+	for _, relPath := range relPaths {
+		if removeErr := h.Storage.Remove(relPath); removeErr != nil {
+			err = errors.Join(err, removeErr)
+		}
+	}
+	return err
+}
+
+func captureToResponse(c datamodel.Capture) map[string]interface{} {
+	timezone := utils.GetJSTTimezone()
+	return map[string]interface{}{
+		"id":                c.ID,
+		"path":              c.Path,
+		"format":            c.Format,
+		"width":             c.Width,
+		"height":            c.Height,
+		"size_bytes":        c.SizeBytes,
+		"sha256":            c.SHA256,
+		"mode":              c.Mode,
+		"captured_at":       c.CapturedAt.In(timezone).Format(time.RFC3339),
+		"thumb_path":        c.ThumbPath,
+		"thumb_resolution":  c.ThumbResolution,
+		"phash":             c.PHash,
+		"duplicate_of":      c.DuplicateOf,
+		"duplicate_count":   c.DuplicateCount,
+		"last_duplicate_at": formatOptionalTime(c.LastDuplicateAt),
+		"linked_task_id":    c.LinkedTaskID,
+		"linked_goal_id":    c.LinkedGoalID,
+		"display_id":        c.DisplayID,
+		"created_at":        c.CreatedAt.In(timezone).Format(time.RFC3339),
+		"updated_at":        c.UpdatedAt.In(timezone).Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+)
+
+type CaptureThumbnailHandler struct {
+	CaptureStore     store.CaptureStore
+	TransactionStore store.TransactionStore
+	Storage          *capture.Storage
+}
+
+func (h *CaptureThumbnailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		data, errResponse := h.get(r)
+		if errResponse != nil {
+			writeResponse(w, nil, errResponse)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(data)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *CaptureThumbnailHandler) get(r *http.Request) ([]byte, *errorResponse) {
+	id := r.PathValue("id")
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get thumbnail", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	c, err := h.CaptureStore.GetCapture(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get thumbnail", "failed to get capture", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get thumbnail", "failed to commit transaction", err)
+	}
+	if c == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Capture not found", "capture not found", nil)
+	}
+	if c.ThumbPath == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Thumbnail not generated yet", "thumbnail not generated yet", nil)
+	}
+
+	data, err := h.Storage.Read(*c.ThumbPath)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get thumbnail", "failed to read thumbnail file", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+const (
+	capturesDefaultLimit = 50
+	capturesMaxLimit     = 200
+)
+
+// キャプチャの一覧を、撮影日時の新しい順に返す
+type CapturesHandler struct {
+	CaptureStore     store.CaptureStore
+	TransactionStore store.TransactionStore
+}
+
+func (h *CapturesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *CapturesHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	limit, offset, errResponse := parsePagination(r, capturesDefaultLimit, capturesMaxLimit)
+	if errResponse != nil {
+		return nil, errResponse
+	}
+	filter := store.CaptureFilter{}
+	query := r.URL.Query()
+	if raw := query.Get("unknown_display"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "unknown_display must be a boolean", "invalid unknown_display", err)
+		}
+		filter.UnknownDisplay = parsed
+	}
+	if query.Has("display_id") {
+		displayID := query.Get("display_id")
+		if displayID == "" || len(displayID) > 64 {
+			return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "display_id must be 1 to 64 characters", "invalid display_id", nil)
+		}
+		if filter.UnknownDisplay {
+			return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "display_id and unknown_display cannot be specified together", "conflicting display filters", errors.New("both display_id and unknown_display are specified"))
+		}
+		filter.DisplayID = &displayID
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get captures", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	captures, err := h.CaptureStore.GetCaptures(tx, filter, limit, offset)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get captures", "failed to get captures", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get captures", "failed to commit transaction", err)
+	}
+
+	return map[string]interface{}{
+		"captures": capturesToResponse(captures),
+		"limit":    limit,
+		"offset":   offset,
+	}, nil
+}
+
+// 撮影したディスプレイごとのキャプチャの件数と撮影期間を返す
+type CaptureDisplaysHandler struct {
+	CaptureStore     store.CaptureStore
+	TransactionStore store.TransactionStore
+}
+
+func (h *CaptureDisplaysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *CaptureDisplaysHandler) get() (map[string]interface{}, *errorResponse) {
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture displays", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	displays, err := h.CaptureStore.GetCaptureDisplays(tx)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture displays", "failed to get capture displays", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get capture displays", "failed to commit transaction", err)
+	}
+
+	timezone := utils.GetJSTTimezone()
+	results := make([]map[string]interface{}, 0, len(displays))
+	for _, display := range displays {
+		results = append(results, map[string]interface{}{
+			"display_id":        display.DisplayID,
+			"capture_count":     display.CaptureCount,
+			"first_captured_at": display.FirstCapturedAt.In(timezone).Format(time.RFC3339),
+			"last_captured_at":  display.LastCapturedAt.In(timezone).Format(time.RFC3339),
+		})
+	}
+	return map[string]interface{}{
+		"displays": results,
+	}, nil
+}
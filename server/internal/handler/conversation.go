@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+const (
+	conversationsDefaultLimit = 20
+	conversationsMaxLimit     = 100
+	chatMessagesDefaultLimit  = 50
+	chatMessagesMaxLimit      = 200
+)
+
+// チャットの会話の一覧を返す
+type ConversationsHandler struct {
+	ConversationStore store.ConversationStore
+	TransactionStore  store.TransactionStore
+}
+
+func (h *ConversationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *ConversationsHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	limit, offset, errResponse := parsePagination(r, conversationsDefaultLimit, conversationsMaxLimit)
+	if errResponse != nil {
+		return nil, errResponse
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get conversations", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	conversations, err := h.ConversationStore.GetConversations(tx, limit, offset)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get conversations", "failed to get conversations", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get conversations", "failed to commit transaction", err)
+	}
+
+	results := make([]map[string]interface{}, 0, len(conversations))
+	for _, conversation := range conversations {
+		results = append(results, conversationToResponse(conversation))
+	}
+	return map[string]interface{}{
+		"conversations": results,
+		"limit":         limit,
+		"offset":        offset,
+	}, nil
+}
+
+// チャットの会話を削除する
+type ConversationHandler struct {
+	ConversationStore store.ConversationStore
+	TransactionStore  store.TransactionStore
+}
+
+func (h *ConversationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "DELETE":
+		body, errResponse = h.delete(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *ConversationHandler) delete(r *http.Request) (map[string]interface{}, *errorResponse) {
+	id := r.PathValue("id")
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete conversation", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	deleted, err := h.ConversationStore.DeleteConversation(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete conversation", "failed to delete conversation", err)
+	}
+	if !deleted {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Conversation not found", "conversation not found", nil)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete conversation", "failed to commit transaction", err)
+	}
+
+	return map[string]interface{}{
+		"message": "deleted",
+	}, nil
+}
+
+// チャットの会話のメッセージを古い順に返す
+type ConversationMessagesHandler struct {
+	ConversationStore store.ConversationStore
+	ChatMessageStore  store.ChatMessageStore
+	TransactionStore  store.TransactionStore
+}
+
+func (h *ConversationMessagesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *ConversationMessagesHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	id := r.PathValue("id")
+	limit, offset, errResponse := parsePagination(r, chatMessagesDefaultLimit, chatMessagesMaxLimit)
+	if errResponse != nil {
+		return nil, errResponse
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get messages", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	conversation, err := h.ConversationStore.GetConversation(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get messages", "failed to get conversation", err)
+	}
+	if conversation == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Conversation not found", "conversation not found", nil)
+	}
+	messages, err := h.ChatMessageStore.GetChatMessages(tx, id, limit, offset)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get messages", "failed to get chat messages", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get messages", "failed to commit transaction", err)
+	}
+
+	results := make([]map[string]interface{}, 0, len(messages))
+	for _, message := range messages {
+		results = append(results, chatMessageToResponse(message))
+	}
+	return map[string]interface{}{
+		"conversation": conversationToResponse(*conversation),
+		"messages":     results,
+		"limit":        limit,
+		"offset":       offset,
+	}, nil
+}
+
+func conversationToResponse(conversation datamodel.Conversation) map[string]interface{} {
+	timezone := utils.GetJSTTimezone()
+	return map[string]interface{}{
+		"id":         conversation.ID,
+		"title":      conversation.Title,
+		"created_at": conversation.CreatedAt.In(timezone).Format(time.RFC3339),
+		"updated_at": conversation.UpdatedAt.In(timezone).Format(time.RFC3339),
+	}
+}
+
+func chatMessageToResponse(message datamodel.ChatMessage) map[string]interface{} {
+	return map[string]interface{}{
+		"id":              message.ID,
+		"conversation_id": message.ConversationID,
+		"role":            message.Role,
+		"content":         message.Content,
+		"finish_reason":   message.FinishReason,
+		"tool_calls":      message.ToolCalls,
+		"tool_call_id":    message.ToolCallID,
+		"prompt_versions": message.PromptVersions,
+		"created_at":      message.CreatedAt.In(utils.GetJSTTimezone()).Format(time.RFC3339),
+	}
+}
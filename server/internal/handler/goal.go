@@ -3,7 +3,6 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -18,13 +17,6 @@ type GoalHandler struct {
 	TransactionStore store.TransactionStore
 }
 
-type errorResponse struct {
-	StatusCode int
-	Body       map[string]interface{}
-	LogMessage string
-	Err        error
-}
-
 func (h *GoalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var body map[string]interface{}
 	var errResponse *errorResponse
@@ -37,15 +29,7 @@ func (h *GoalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	if errResponse != nil {
-		log.Printf("%s: %v", errResponse.LogMessage, errResponse.Err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(errResponse.StatusCode)
-		json.NewEncoder(w).Encode(errResponse.Body)
-	} else {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(body)
-	}
+	writeResponse(w, body, errResponse)
 }
 
 func (h *GoalHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
@@ -200,21 +184,22 @@ func (h *GoalHandler) post(r *http.Request) (map[string]interface{}, *errorRespo
 	}, nil
 }
 
+// 目標の入力値の検証ルール。POST /goal とLLMが提案する目標で共通
+type goalInputValidation struct {
+	Title       any `json:"title" validate:"required,min=1,max=255,is_string,not_only_whitespaces"`
+	Description any `json:"description" validate:"required,is_string"`
+	StartDate   any `json:"start_date" validate:"required,is_string,datetime=2006-01-02"`
+	EndDate     any `json:"end_date" validate:"required,is_string,datetime=2006-01-02"`
+	KpiName     any `json:"kpi_name" validate:"required_with_all=KpiTarget KpiUnit,is_nullable_string"`
+	KpiTarget   any `json:"kpi_target" validate:"required_with_all=KpiName KpiUnit,is_nullable_float64"`
+	KpiUnit     any `json:"kpi_unit" validate:"required_with_all=KpiName KpiTarget,is_nullable_string"`
+	Status      any `json:"status" validate:"required,is_string,oneof=active paused done"`
+}
+
 func validatePostRequestBody(r *http.Request) (postRequestBody, *errorResponse) {
 	emptyRequestBody := postRequestBody{}
 
-	validator := utils.GetValidator()
-	type postRequestBodyValidation struct {
-		Title       any `json:"title" validate:"required,min=1,max=255,is_string,not_only_whitespaces"`
-		Description any `json:"description" validate:"required,is_string"`
-		StartDate   any `json:"start_date" validate:"required,is_string,datetime=2006-01-02"`
-		EndDate     any `json:"end_date" validate:"required,is_string,datetime=2006-01-02"`
-		KpiName     any `json:"kpi_name" validate:"required_with_all=KpiTarget KpiUnit,is_nullable_string"`
-		KpiTarget   any `json:"kpi_target" validate:"required_with_all=KpiName KpiUnit,is_nullable_float64"`
-		KpiUnit     any `json:"kpi_unit" validate:"required_with_all=KpiName KpiTarget,is_nullable_string"`
-		Status      any `json:"status" validate:"required,is_string,oneof=active paused done"`
-	}
-	var requestBodyValidation postRequestBodyValidation
+	var requestBodyValidation goalInputValidation
 	if err := json.NewDecoder(r.Body).Decode(&requestBodyValidation); err != nil {
 		return emptyRequestBody, &errorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -225,70 +210,57 @@ func validatePostRequestBody(r *http.Request) (postRequestBody, *errorResponse)
 			Err:        err,
 		}
 	}
-	if err := validator.Struct(requestBodyValidation); err != nil {
+	requestBody, problems := validateGoalInput(requestBodyValidation)
+	if len(problems) > 0 {
 		return emptyRequestBody, &errorResponse{
 			StatusCode: http.StatusBadRequest,
 			Body: map[string]interface{}{
 				"message": "invalid parameter",
-				"target":  utils.GetFirstValidationErrorTarget(err),
+				"target":  problems[0].Field,
 			},
 			LogMessage: "failed to validate request body",
-			Err:        err,
+			Err:        problemsToError(problems),
 		}
 	}
+	return requestBody, nil
+}
+
+// 目標の入力値を検証する。問題がある場合は問題のあるフィールドを全て返す
+func validateGoalInput(input goalInputValidation) (postRequestBody, []fieldProblem) {
+	if err := utils.GetValidator().Struct(input); err != nil {
+		return postRequestBody{}, validationErrorToProblems(err)
+	}
 	var requestBody postRequestBody
-	requestBody.Title = requestBodyValidation.Title.(string)
-	requestBody.Description = requestBodyValidation.Description.(string)
-	requestBody.StartDate = requestBodyValidation.StartDate.(string)
-	requestBody.EndDate = requestBodyValidation.EndDate.(string)
-	if requestBodyValidation.KpiName != nil {
+	requestBody.Title = input.Title.(string)
+	requestBody.Description = input.Description.(string)
+	requestBody.StartDate = input.StartDate.(string)
+	requestBody.EndDate = input.EndDate.(string)
+	if input.KpiName != nil {
 		requestBody.KpiName = new(string)
-		*requestBody.KpiName = requestBodyValidation.KpiName.(string)
+		*requestBody.KpiName = input.KpiName.(string)
 	}
-	if requestBodyValidation.KpiTarget != nil {
+	if input.KpiTarget != nil {
 		requestBody.KpiTarget = new(float64)
-		*requestBody.KpiTarget = requestBodyValidation.KpiTarget.(float64)
+		*requestBody.KpiTarget = input.KpiTarget.(float64)
 	}
-	if requestBodyValidation.KpiUnit != nil {
+	if input.KpiUnit != nil {
 		requestBody.KpiUnit = new(string)
-		*requestBody.KpiUnit = requestBodyValidation.KpiUnit.(string)
+		*requestBody.KpiUnit = input.KpiUnit.(string)
 	}
-	requestBody.Status = requestBodyValidation.Status.(string)
+	requestBody.Status = input.Status.(string)
+
+	var problems []fieldProblem
 	// validationでのタグチェックが面倒なものは自前チェック
 	// validationのgtefieldはNumbersまたはtime.~にしか効かない
 	if requestBody.StartDate > requestBody.EndDate {
-		return emptyRequestBody, &errorResponse{
-			StatusCode: http.StatusBadRequest,
-			Body: map[string]interface{}{
-				"message": "invalid parameter",
-				"target":  "end_date",
-			},
-			LogMessage: "start date is after end date",
-			Err:        nil,
-		}
+		problems = append(problems, fieldProblem{Field: "end_date", Reason: "before_start_date"})
 	}
 	// kpi_nameとkpi_unitの非空白文字チェックもvalidatorだとnullableの処理が面倒
 	if requestBody.KpiName != nil && strings.TrimSpace(*requestBody.KpiName) == "" {
-		return emptyRequestBody, &errorResponse{
-			StatusCode: http.StatusBadRequest,
-			Body: map[string]interface{}{
-				"message": "invalid parameter",
-				"target":  "kpi_name",
-			},
-			LogMessage: "kpi name is empty",
-			Err:        nil,
-		}
+		problems = append(problems, fieldProblem{Field: "kpi_name", Reason: "not_only_whitespaces"})
 	}
 	if requestBody.KpiUnit != nil && strings.TrimSpace(*requestBody.KpiUnit) == "" {
-		return emptyRequestBody, &errorResponse{
-			StatusCode: http.StatusBadRequest,
-			Body: map[string]interface{}{
-				"message": "invalid parameter",
-				"target":  "kpi_unit",
-			},
-			LogMessage: "kpi unit is empty",
-			Err:        nil,
-		}
+		problems = append(problems, fieldProblem{Field: "kpi_unit", Reason: "not_only_whitespaces"})
 	}
 	// kpi_*のnull/非nullが揃っているか
 	target := ""
@@ -308,15 +280,10 @@ func validatePostRequestBody(r *http.Request) (postRequestBody, *errorResponse)
 		}
 	}
 	if target != "" {
-		return emptyRequestBody, &errorResponse{
-			StatusCode: http.StatusBadRequest,
-			Body: map[string]interface{}{
-				"message": "invalid parameter",
-				"target":  target,
-			},
-			LogMessage: "kpi_* is not consistent with kpi_name",
-			Err:        nil,
-		}
+		problems = append(problems, fieldProblem{Field: target, Reason: "kpi_inconsistent"})
+	}
+	if len(problems) > 0 {
+		return postRequestBody{}, problems
 	}
 	return requestBody, nil
 }
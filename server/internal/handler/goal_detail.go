@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+// 目標の詳細と、紐付いたキャプチャを返す
+type GoalDetailHandler struct {
+	GoalStore        store.GoalStore
+	CaptureStore     store.CaptureStore
+	TransactionStore store.TransactionStore
+}
+
+func (h *GoalDetailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *GoalDetailHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	id := r.PathValue("id")
+	limit, offset, errResponse := parsePagination(r, linkedCapturesDefaultLimit, linkedCapturesMaxLimit)
+	if errResponse != nil {
+		return nil, errResponse
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get goal", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	goal, err := h.GoalStore.GetGoalByID(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get goal", "failed to get goal", err)
+	}
+	if goal == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Goal not found", "goal not found", nil)
+	}
+	captures, err := h.CaptureStore.GetCaptures(tx, store.CaptureFilter{LinkedGoalID: &id}, limit, offset)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get goal", "failed to get linked captures", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get goal", "failed to commit transaction", err)
+	}
+
+	timezone := utils.GetJSTTimezone()
+	return map[string]interface{}{
+		"goal": map[string]interface{}{
+			"id":          goal.ID,
+			"title":       goal.Title,
+			"description": goal.Description,
+			"start_date":  goal.StartDate.Format("2006-01-02"),
+			"end_date":    goal.EndDate.Format("2006-01-02"),
+			"kpi_name":    goal.KpiName,
+			"kpi_target":  goal.KpiTarget,
+			"kpi_unit":    goal.KpiUnit,
+			"status":      goal.Status,
+			"created_at":  goal.CreatedAt.In(timezone).Format(time.RFC3339),
+			"updated_at":  goal.UpdatedAt.In(timezone).Format(time.RFC3339),
+		},
+		"captures": capturesToResponse(captures),
+		"limit":    limit,
+		"offset":   offset,
+	}, nil
+}
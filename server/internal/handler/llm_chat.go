@@ -0,0 +1,698 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+	"github.com/google/uuid"
+)
+
+// 接続維持のためのコメント送信間隔
+const llmChatHeartbeatInterval = 15 * time.Second
+
+// 会話のタイトルにする最初のユーザーのメッセージの長さ（文字数）
+const conversationTitleMaxLength = 50
+
+// LLMとのチャットの応答をSSEでストリームし、会話として保存する。
+//
+// LLMはツールを呼び出せる。データを変更するツールはユーザーの確認を受けてから実行する。
+type LLMChatHandler struct {
+	LLM                         llm.Provider
+	ConversationStore           store.ConversationStore
+	ChatMessageStore            store.ChatMessageStore
+	ConversationSummaryStore    store.ConversationSummaryStore
+	TaskStore                   store.TaskStore
+	GoalStore                   store.GoalStore
+	KpiEntryStore               store.KpiEntryStore
+	ProposalStore               store.ProposalStore
+	PromptTemplateStore         store.PromptTemplateStore
+	CaptureScheduleStore        store.CaptureScheduleStore
+	CaptureScheduleHistoryStore store.CaptureScheduleHistoryStore
+	TransactionStore            store.TransactionStore
+	Scheduler                   *capture.Scheduler
+	// システムプロンプトのトークン数の上限（概算）
+	PromptTokenBudget int
+	// 会話の履歴のトークン数（概算）がこれを超えたら古いメッセージを要約する。0の場合は要約しない
+	SummaryThreshold int
+}
+
+type llmChatRequestBody struct {
+	// 続ける会話。nilの場合は新しい会話を作る
+	ConversationID *string
+	// 会話に追加するメッセージ
+	Messages []llm.Message
+	// 確認待ちのツールの呼び出しに対するユーザーの判断
+	ToolConfirmations []llmToolConfirmation
+}
+
+type llmToolConfirmation struct {
+	ToolCallID string
+	Approved   bool
+}
+
+// ユーザーが拒否した、または確認しなかった呼び出しの結果
+var llmToolRejectedResult = map[string]interface{}{"error": "rejected by user"}
+
+func (h *LLMChatHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		h.post(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *LLMChatHandler) post(w http.ResponseWriter, r *http.Request) {
+	requestBody, errResponse := validateLLMChatRequestBody(r.Body)
+	if errResponse != nil {
+		writeResponse(w, nil, errResponse)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeResponse(w, nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Streaming is not supported", "response writer does not support flushing", nil))
+		return
+	}
+	prepared, errResponse := h.prepareConversation(requestBody)
+	if errResponse != nil {
+		writeResponse(w, nil, errResponse)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// クライアントの切断でLLMの生成も中断する
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	h.chat(ctx, cancel, &sseLLMChatEventWriter{w: w, flusher: flusher}, prepared)
+}
+
+// 準備した会話のイベントを送り、承認された呼び出しを実行してから応答を生成する。
+//
+// イベントを送れない場合はcancelを呼んで生成を中断する。
+func (h *LLMChatHandler) chat(ctx context.Context, cancel context.CancelFunc, events llmChatEventWriter, prepared preparedConversation) {
+	if err := events.WriteEvent(map[string]interface{}{"type": "conversation", "conversation_id": prepared.conversationID}); err != nil {
+		return
+	}
+	messages := prepared.messages
+	for _, rejected := range prepared.rejected {
+		if err := events.WriteEvent(llmToolResultEvent(rejected, llmToolRejectedResult)); err != nil {
+			cancel()
+		}
+	}
+	for _, call := range prepared.approved {
+		message, result, err := h.runLLMToolCall(prepared.conversationID, call)
+		if err != nil {
+			log.Printf("failed to save tool result: %v", err)
+			events.WriteEvent(map[string]interface{}{"type": "error", "code": "INTERNAL_ERROR", "message": "Failed to save tool result"})
+			events.Flush()
+			return
+		}
+		messages = append(messages, message)
+		if err := events.WriteEvent(llmToolResultEvent(call, result)); err != nil {
+			cancel()
+		}
+	}
+	events.Flush()
+	// 要約に失敗した場合は、要約せずに続ける
+	if summarized, err := h.summarizeConversation(ctx, prepared.conversationID); err != nil {
+		log.Printf("failed to summarize conversation: %v", err)
+	} else if summarized != nil {
+		messages = summarized
+	}
+	h.respond(ctx, cancel, events, prepared.conversationID, messages)
+}
+
+// 応答を生成してストリームする。
+//
+// LLMが呼び出したツールを実行して結果を渡し、ツールを呼ばない応答が返るまで繰り返す。
+// llmToolMaxSteps回呼び出した後はツールを渡さずに応答させる。
+// 確認が必要なツールが呼ばれた場合は、実行せずにストリームを終える。
+func (h *LLMChatHandler) respond(ctx context.Context, cancel context.CancelFunc, events llmChatEventWriter, conversationID string, messages []llm.Message) {
+	for step := 0; ; step++ {
+		var tools []llm.Tool
+		if step < llmToolMaxSteps {
+			tools = llmToolDefinitions()
+		}
+		// ツールの実行で状況が変わるため、システムプロンプトは呼び出しごとに組み立てる
+		sent, built, err := h.withCurrentSystemPrompt(messages)
+		if err != nil {
+			log.Printf("failed to build prompt: %v", err)
+			events.WriteEvent(map[string]interface{}{"type": "error", "code": "INTERNAL_ERROR", "message": "Failed to build prompt"})
+			events.Flush()
+			return
+		}
+		// 中断した場合も、それまでの応答を保存する
+		content, toolCalls, err := h.streamLLMResponse(ctx, cancel, events, conversationID, sent, tools)
+		switch {
+		case err == nil:
+		case ctx.Err() != nil:
+			log.Printf("llm chat canceled by client: %v", err)
+			if err := h.appendAssistantMessage(conversationID, content, datamodel.ChatMessageFinishReasonAborted, nil, built.TemplateVersions); err != nil {
+				log.Printf("failed to save aborted chat response: %v", err)
+			}
+			return
+		default:
+			log.Printf("failed to generate chat response: %v", err)
+			if err := h.appendAssistantMessage(conversationID, content, datamodel.ChatMessageFinishReasonError, nil, built.TemplateVersions); err != nil {
+				log.Printf("failed to save failed chat response: %v", err)
+			}
+			// 200を返した後なので、エラーはイベントとして送る
+			events.WriteEvent(map[string]interface{}{"type": "error", "code": "LLM_ERROR", "message": "Failed to generate response"})
+			events.Flush()
+			return
+		}
+
+		calls := toChatToolCalls(toolCalls)
+		finishReason := datamodel.ChatMessageFinishReasonCompleted
+		if len(calls) > 0 {
+			finishReason = datamodel.ChatMessageFinishReasonToolCalls
+		}
+		if err := h.appendAssistantMessage(conversationID, content, finishReason, calls, built.TemplateVersions); err != nil {
+			log.Printf("failed to save chat response: %v", err)
+			events.WriteEvent(map[string]interface{}{"type": "error", "code": "INTERNAL_ERROR", "message": "Failed to save response"})
+			events.Flush()
+			return
+		}
+		if len(calls) == 0 {
+			events.WriteDone()
+			events.Flush()
+			return
+		}
+
+		messages = append(messages, llm.Message{Role: llm.RoleAssistant, Content: content, ToolCalls: toolCalls})
+		waiting := false
+		for _, call := range calls {
+			tool, ok := findLLMTool(call.Name)
+			requiresConfirmation := ok && tool.requiresConfirmation
+			if err := events.WriteEvent(map[string]interface{}{
+				"type":                  "tool_call",
+				"tool_call_id":          call.ID,
+				"name":                  call.Name,
+				"arguments":             call.Arguments,
+				"requires_confirmation": requiresConfirmation,
+			}); err != nil {
+				cancel()
+			}
+			if requiresConfirmation {
+				waiting = true
+				continue
+			}
+			message, result, err := h.runLLMToolCall(conversationID, call)
+			if err != nil {
+				log.Printf("failed to save tool result: %v", err)
+				events.WriteEvent(map[string]interface{}{"type": "error", "code": "INTERNAL_ERROR", "message": "Failed to save tool result"})
+				events.Flush()
+				return
+			}
+			messages = append(messages, message)
+			if err := events.WriteEvent(llmToolResultEvent(call, result)); err != nil {
+				cancel()
+			}
+		}
+		// 確認待ちの呼び出しは、tool_confirmationsを送るリクエストで実行して続ける
+		if waiting {
+			events.WriteDone()
+			events.Flush()
+			return
+		}
+		events.Flush()
+	}
+}
+
+// LLMの応答をtext・entityイベントとしてストリームし、応答の全文とツールの呼び出しを返す。
+//
+// エラーの場合も、それまでに受け取った応答を返す。
+func (h *LLMChatHandler) streamLLMResponse(ctx context.Context, cancel context.CancelFunc, events llmChatEventWriter, conversationID string, messages []llm.Message, tools []llm.Tool) (string, []llm.ToolCall, error) {
+	type result struct {
+		toolCalls []llm.ToolCall
+		err       error
+	}
+	texts := make(chan string)
+	done := make(chan result, 1)
+	go func() {
+		toolCalls, err := h.LLM.StreamChatWithTools(ctx, messages, tools, func(text string) error {
+			select {
+			case texts <- text:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		done <- result{toolCalls: toolCalls, err: err}
+	}()
+
+	var content strings.Builder
+	var entities llm.EntityScanner
+	heartbeat := time.NewTicker(llmChatHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-heartbeat.C:
+			if err := events.WriteHeartbeat(); err != nil {
+				cancel()
+			}
+		case text := <-texts:
+			content.WriteString(text)
+			if err := events.WriteEvent(map[string]interface{}{"type": "text", "content": text}); err != nil {
+				cancel()
+			}
+			for _, entity := range entities.Write(text) {
+				event, err := h.llmEntityEvent(conversationID, entity)
+				if err != nil {
+					log.Printf("failed to build entity event: %v", err)
+					continue
+				}
+				if err := events.WriteEvent(event); err != nil {
+					cancel()
+				}
+			}
+		case result := <-done:
+			return content.String(), result.toolCalls, result.err
+		}
+		events.Flush()
+	}
+}
+
+// prepareConversationの結果
+type preparedConversation struct {
+	conversationID string
+	// LLMに送るメッセージ（これまでの履歴を含む。要約がある場合は要約と要約していない部分）
+	messages []llm.Message
+	// ユーザーが承認した、これから実行する呼び出し
+	approved []datamodel.ChatToolCall
+	// ユーザーが拒否した、または確認しなかった呼び出し。結果は保存済み
+	rejected []datamodel.ChatToolCall
+}
+
+// 会話にリクエストのメッセージを追加し、LLMに送るメッセージを返す。
+//
+// ConversationIDがnilの場合は新しい会話を作る。
+// 確認待ちのツールの呼び出しのうち、承認されなかったものは拒否した結果を保存する。
+func (h *LLMChatHandler) prepareConversation(requestBody llmChatRequestBody) (preparedConversation, *errorResponse) {
+	emptyPrepared := preparedConversation{}
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return emptyPrepared, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save message", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	prepared := preparedConversation{messages: []llm.Message{}}
+	var pending []datamodel.ChatToolCall
+	if requestBody.ConversationID != nil {
+		conversation, err := h.ConversationStore.GetConversation(tx, *requestBody.ConversationID)
+		if err != nil {
+			return emptyPrepared, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save message", "failed to get conversation", err)
+		}
+		if conversation == nil {
+			return emptyPrepared, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Conversation not found", "conversation not found", nil)
+		}
+		history, err := h.ChatMessageStore.GetAllChatMessages(tx, conversation.ID)
+		if err != nil {
+			return emptyPrepared, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save message", "failed to get chat messages", err)
+		}
+		if prepared.messages, err = h.conversationLLMMessages(tx, conversation.ID, history); err != nil {
+			return emptyPrepared, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save message", "failed to get conversation summary", err)
+		}
+		pending = pendingToolCalls(history)
+		prepared.conversationID = conversation.ID
+	} else {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return emptyPrepared, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save message", "failed to generate conversation id", err)
+		}
+		conversation := datamodel.Conversation{
+			ID:        id.String(),
+			Title:     conversationTitle(requestBody.Messages),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := h.ConversationStore.CreateConversation(tx, conversation); err != nil {
+			return emptyPrepared, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save message", "failed to create conversation", err)
+		}
+		prepared.conversationID = conversation.ID
+	}
+
+	approvals := map[string]bool{}
+	for _, confirmation := range requestBody.ToolConfirmations {
+		approvals[confirmation.ToolCallID] = confirmation.Approved
+	}
+	for _, call := range pending {
+		if approved, ok := approvals[call.ID]; ok && approved {
+			prepared.approved = append(prepared.approved, call)
+			delete(approvals, call.ID)
+			continue
+		}
+		delete(approvals, call.ID)
+		message, err := h.saveLLMToolResult(tx, prepared.conversationID, call, llmToolRejectedResult, now)
+		if err != nil {
+			return emptyPrepared, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save message", "failed to save rejected tool result", err)
+		}
+		prepared.messages = append(prepared.messages, message)
+		prepared.rejected = append(prepared.rejected, call)
+	}
+	// 確認待ちでない呼び出しへの確認は受け付けない
+	if len(approvals) > 0 {
+		return emptyPrepared, &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid parameter",
+				"target":  "tool_confirmations",
+			},
+			LogMessage: "failed to validate request body",
+			Err:        errors.New("tool call is not waiting for confirmation"),
+		}
+	}
+
+	for _, message := range requestBody.Messages {
+		if err := h.createChatMessage(tx, datamodel.ChatMessage{
+			ConversationID: prepared.conversationID,
+			Role:           message.Role,
+			Content:        message.Content,
+			CreatedAt:      now,
+		}); err != nil {
+			return emptyPrepared, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save message", "failed to create chat message", err)
+		}
+		prepared.messages = append(prepared.messages, message)
+	}
+	if err := h.ConversationStore.TouchConversation(tx, prepared.conversationID, now); err != nil {
+		return emptyPrepared, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save message", "failed to update conversation", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return emptyPrepared, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save message", "failed to commit transaction", err)
+	}
+	return prepared, nil
+}
+
+// 会話にアシスタントの応答を追加する。中断やエラーで応答が空の場合は追加しない。
+//
+// promptVersionsは応答を生成したシステムプロンプトのテンプレートのバージョン。
+func (h *LLMChatHandler) appendAssistantMessage(conversationID string, content string, finishReason string, toolCalls []datamodel.ChatToolCall, promptVersions map[string]int) error {
+	if content == "" && len(toolCalls) == 0 && finishReason != datamodel.ChatMessageFinishReasonCompleted {
+		return nil
+	}
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if err := h.createChatMessage(tx, datamodel.ChatMessage{
+		ConversationID: conversationID,
+		Role:           llm.RoleAssistant,
+		Content:        content,
+		FinishReason:   &finishReason,
+		ToolCalls:      toolCalls,
+		PromptVersions: promptVersions,
+		CreatedAt:      now,
+	}); err != nil {
+		return fmt.Errorf("failed to create chat message: %w", err)
+	}
+	if err := h.ConversationStore.TouchConversation(tx, conversationID, now); err != nil {
+		return fmt.Errorf("failed to update conversation: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ツールを実行し、結果を会話に保存する。LLMに送るメッセージと結果を返す。
+//
+// 実行に失敗した場合は途中の変更を破棄し、エラーを結果として保存する。
+func (h *LLMChatHandler) runLLMToolCall(conversationID string, call datamodel.ChatToolCall) (llm.Message, map[string]interface{}, error) {
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return llm.Message{}, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, executed := h.executeLLMTool(tx, call)
+	if !executed {
+		tx.Rollback()
+		if tx, err = h.TransactionStore.Begin(); err != nil {
+			return llm.Message{}, nil, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+	}
+	message, err := h.saveLLMToolResult(tx, conversationID, call, result, time.Now())
+	if err != nil {
+		return llm.Message{}, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return llm.Message{}, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if tool, ok := findLLMTool(call.Name); executed && ok && tool.afterCommit != nil {
+		tool.afterCommit(h)
+	}
+	return message, result, nil
+}
+
+// ツールの結果をツールのメッセージとして会話に追加し、LLMに送るメッセージを返す
+func (h *LLMChatHandler) saveLLMToolResult(tx store.Transaction, conversationID string, call datamodel.ChatToolCall, result map[string]interface{}, createdAt time.Time) (llm.Message, error) {
+	content, err := json.Marshal(result)
+	if err != nil {
+		return llm.Message{}, fmt.Errorf("failed to marshal tool result: %w", err)
+	}
+	if err := h.createChatMessage(tx, datamodel.ChatMessage{
+		ConversationID: conversationID,
+		Role:           llm.RoleTool,
+		Content:        string(content),
+		ToolCallID:     &call.ID,
+		CreatedAt:      createdAt,
+	}); err != nil {
+		return llm.Message{}, fmt.Errorf("failed to create chat message: %w", err)
+	}
+	if err := h.ConversationStore.TouchConversation(tx, conversationID, createdAt); err != nil {
+		return llm.Message{}, fmt.Errorf("failed to update conversation: %w", err)
+	}
+	return llm.Message{Role: llm.RoleTool, Content: string(content), ToolCallID: call.ID, ToolName: call.Name}, nil
+}
+
+// messageにIDを付けて保存する
+func (h *LLMChatHandler) createChatMessage(tx store.Transaction, message datamodel.ChatMessage) error {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return fmt.Errorf("failed to generate chat message id: %w", err)
+	}
+	message.ID = id.String()
+	return h.ChatMessageStore.CreateChatMessage(tx, message)
+}
+
+// 保存したメッセージをLLMに送るメッセージにする
+func chatMessagesToLLMMessages(history []datamodel.ChatMessage) []llm.Message {
+	// ツールの結果には、呼び出したツールの名前を付けて送る
+	toolNames := map[string]string{}
+	messages := make([]llm.Message, 0, len(history))
+	for _, message := range history {
+		converted := llm.Message{Role: message.Role, Content: message.Content}
+		for _, call := range message.ToolCalls {
+			toolNames[call.ID] = call.Name
+			converted.ToolCalls = append(converted.ToolCalls, llm.ToolCall{ID: call.ID, Name: call.Name, Arguments: call.Arguments})
+		}
+		if message.ToolCallID != nil {
+			converted.ToolCallID = *message.ToolCallID
+			converted.ToolName = toolNames[*message.ToolCallID]
+		}
+		messages = append(messages, converted)
+	}
+	return messages
+}
+
+// 結果が保存されていない呼び出しを、呼び出した順に返す
+func pendingToolCalls(history []datamodel.ChatMessage) []datamodel.ChatToolCall {
+	answered := map[string]bool{}
+	for _, message := range history {
+		if message.ToolCallID != nil {
+			answered[*message.ToolCallID] = true
+		}
+	}
+	pending := []datamodel.ChatToolCall{}
+	for _, message := range history {
+		for _, call := range message.ToolCalls {
+			if !answered[call.ID] {
+				pending = append(pending, call)
+			}
+		}
+	}
+	return pending
+}
+
+func toChatToolCalls(toolCalls []llm.ToolCall) []datamodel.ChatToolCall {
+	var calls []datamodel.ChatToolCall
+	for _, call := range toolCalls {
+		calls = append(calls, datamodel.ChatToolCall{ID: call.ID, Name: call.Name, Arguments: call.Arguments})
+	}
+	return calls
+}
+
+func llmToolResultEvent(call datamodel.ChatToolCall, result map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type":         "tool_result",
+		"tool_call_id": call.ID,
+		"name":         call.Name,
+		"result":       result,
+	}
+}
+
+// 最初のユーザーのメッセージの1行目を会話のタイトルにする
+func conversationTitle(messages []llm.Message) string {
+	for _, message := range messages {
+		if message.Role != llm.RoleUser {
+			continue
+		}
+		title, _, _ := strings.Cut(strings.TrimSpace(message.Content), "\n")
+		title = strings.TrimSpace(title)
+		if runes := []rune(title); len(runes) > conversationTitleMaxLength {
+			title = string(runes[:conversationTitleMaxLength])
+		}
+		return title
+	}
+	return ""
+}
+
+// チャットのイベントの送り先
+type llmChatEventWriter interface {
+	WriteEvent(event map[string]interface{}) error
+	// 応答を最後まで送ったことを知らせる
+	WriteDone() error
+	// 応答の生成中に、接続を維持するために定期的に呼ばれる
+	WriteHeartbeat() error
+	Flush()
+}
+
+// イベントをSSEのdata行として送る
+type sseLLMChatEventWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (e *sseLLMChatEventWriter) WriteEvent(event map[string]interface{}) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal chat event: %w", err)
+	}
+	_, err = fmt.Fprintf(e.w, "data: %s\n\n", data)
+	return err
+}
+
+func (e *sseLLMChatEventWriter) WriteDone() error {
+	_, err := fmt.Fprint(e.w, "data: [DONE]\n\n")
+	return err
+}
+
+func (e *sseLLMChatEventWriter) WriteHeartbeat() error {
+	_, err := fmt.Fprint(e.w, ": heartbeat\n\n")
+	return err
+}
+
+func (e *sseLLMChatEventWriter) Flush() {
+	e.flusher.Flush()
+}
+
+func validateLLMChatRequestBody(body io.Reader) (llmChatRequestBody, *errorResponse) {
+	emptyRequestBody := llmChatRequestBody{}
+
+	validator := utils.GetValidator()
+	type MessageValidation struct {
+		Role    any `json:"role" validate:"required,is_string,oneof=system user assistant"`
+		Content any `json:"content" validate:"required,is_string,not_only_whitespaces,max=32768"`
+	}
+	type ToolConfirmationValidation struct {
+		ToolCallID any `json:"tool_call_id" validate:"required,is_string,not_only_whitespaces,max=256"`
+		Approved   any `json:"approved" validate:"is_boolean"`
+	}
+	type RequestBodyValidation struct {
+		ConversationID    any                          `json:"conversation_id" validate:"omitempty,is_string,not_only_whitespaces,max=64"`
+		Messages          []MessageValidation          `json:"messages" validate:"omitempty,max=100,dive"`
+		ToolConfirmations []ToolConfirmationValidation `json:"tool_confirmations" validate:"omitempty,max=100,dive"`
+	}
+	var requestBodyValidation RequestBodyValidation
+	if err := json.NewDecoder(body).Decode(&requestBodyValidation); err != nil {
+		return emptyRequestBody, &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid JSON format",
+			},
+			LogMessage: "failed to decode request body",
+			Err:        err,
+		}
+	}
+	if err := validator.Struct(requestBodyValidation); err != nil {
+		return emptyRequestBody, &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid parameter",
+				"target":  utils.GetFirstValidationErrorTarget(err),
+			},
+			LogMessage: "failed to validate request body",
+			Err:        err,
+		}
+	}
+	// メッセージの追加とツールの呼び出しの確認は、どちらか一方のみ受け付ける。
+	// 確認は既存の会話に対してのみ行える
+	invalidTarget := ""
+	switch {
+	case len(requestBodyValidation.ToolConfirmations) > 0 && len(requestBodyValidation.Messages) > 0:
+		invalidTarget = "tool_confirmations"
+	case len(requestBodyValidation.ToolConfirmations) > 0 && requestBodyValidation.ConversationID == nil:
+		invalidTarget = "conversation_id"
+	case len(requestBodyValidation.ToolConfirmations) == 0 && len(requestBodyValidation.Messages) == 0:
+		invalidTarget = "messages"
+	}
+	if invalidTarget != "" {
+		return emptyRequestBody, &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid parameter",
+				"target":  invalidTarget,
+			},
+			LogMessage: "failed to validate request body",
+			Err:        errors.New("either messages or tool_confirmations must be specified"),
+		}
+	}
+	var requestBody llmChatRequestBody
+	if requestBodyValidation.ConversationID != nil {
+		requestBody.ConversationID = new(string)
+		*requestBody.ConversationID = requestBodyValidation.ConversationID.(string)
+	}
+	for _, message := range requestBodyValidation.Messages {
+		requestBody.Messages = append(requestBody.Messages, llm.Message{
+			Role:    message.Role.(string),
+			Content: message.Content.(string),
+		})
+	}
+	for _, confirmation := range requestBodyValidation.ToolConfirmations {
+		requestBody.ToolConfirmations = append(requestBody.ToolConfirmations, llmToolConfirmation{
+			ToolCallID: confirmation.ToolCallID.(string),
+			Approved:   confirmation.Approved.(bool),
+		})
+	}
+	// 応答を生成するのは最後がユーザーのメッセージの場合のみ
+	if len(requestBody.Messages) > 0 && requestBody.Messages[len(requestBody.Messages)-1].Role != llm.RoleUser {
+		return emptyRequestBody, &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid parameter",
+				"target":  "messages",
+			},
+			LogMessage: "failed to validate request body",
+			Err:        errors.New("last message is not from user"),
+		}
+	}
+	return requestBody, nil
+}
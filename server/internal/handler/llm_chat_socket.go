@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+const (
+	// pingを送ってからpongを待つ時間
+	llmChatSocketPingTimeout = 10 * time.Second
+	// 受け取るメッセージの上限（messagesの上限 100件 x 32768文字が収まる大きさ）
+	llmChatSocketReadLimit = 16 << 20
+)
+
+// /llm/chat と同じイベントをWebSocketで送るチャット。
+//
+// 1つの接続で複数のリクエストを並行して扱い、イベントにはリクエストのrequest_idを付ける。
+// cancelメッセージを受け取ると、そのリクエストのLLMの生成を中断する。
+type LLMChatSocketHandler struct {
+	Chat *LLMChatHandler
+}
+
+// 接続ごとの状態
+type llmChatSocketSession struct {
+	chat *LLMChatHandler
+	conn *websocket.Conn
+	// 接続が閉じるとキャンセルされる。書き込みはリクエストを中断した後も送れるようこれを使う
+	ctx context.Context
+	mu  sync.Mutex
+	// 実行中のリクエストのrequest_idと、その中断
+	requests map[string]context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func (h *LLMChatSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		// Acceptがエラーのレスポンスを返している
+		log.Printf("failed to accept websocket: %v", err)
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(llmChatSocketReadLimit)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	session := &llmChatSocketSession{
+		chat:     h.Chat,
+		conn:     conn,
+		ctx:      ctx,
+		requests: map[string]context.CancelFunc{},
+	}
+	go session.keepAlive(cancel)
+
+	session.serve()
+	// 切断したら実行中のリクエストを中断し、応答の保存を待つ
+	cancel()
+	session.wg.Wait()
+	conn.Close(websocket.StatusNormalClosure, "")
+}
+
+// 接続が閉じるまでメッセージを読み、種類ごとに処理する
+func (s *llmChatSocketSession) serve() {
+	for {
+		_, data, err := s.conn.Read(s.ctx)
+		if err != nil {
+			if status := websocket.CloseStatus(err); status != websocket.StatusNormalClosure && status != websocket.StatusGoingAway && s.ctx.Err() == nil {
+				log.Printf("failed to read websocket message: %v", err)
+			}
+			return
+		}
+		requestID, messageType, errResponse := validateLLMChatSocketMessage(data)
+		if errResponse != nil {
+			s.writeError(requestID, errResponse)
+			continue
+		}
+		switch messageType {
+		case "chat":
+			s.startChat(*requestID, data)
+		case "cancel":
+			s.cancel(*requestID)
+		case "ping":
+			s.write(map[string]interface{}{"type": "pong"})
+		}
+	}
+}
+
+// リクエストを別のgoroutineで処理する。同じrequest_idのリクエストが実行中の場合は受け付けない
+func (s *llmChatSocketSession) startChat(requestID string, data []byte) {
+	requestBody, errResponse := validateLLMChatRequestBody(bytes.NewReader(data))
+	if errResponse != nil {
+		s.writeError(&requestID, errResponse)
+		return
+	}
+	s.mu.Lock()
+	if _, ok := s.requests[requestID]; ok {
+		s.mu.Unlock()
+		s.writeError(&requestID, newCodedErrorResponse(http.StatusConflict, "INVALID_STATE", "Request is already in progress", "duplicate request id", errors.New(requestID)))
+		return
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.requests[requestID] = cancel
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.requests, requestID)
+			s.mu.Unlock()
+			cancel()
+		}()
+		prepared, errResponse := s.chat.prepareConversation(requestBody)
+		if errResponse != nil {
+			s.writeError(&requestID, errResponse)
+			return
+		}
+		s.chat.chat(ctx, cancel, &socketLLMChatEventWriter{session: s, requestID: requestID}, prepared)
+		// cancelメッセージで中断した場合は、中断したことを知らせる
+		if ctx.Err() != nil && s.ctx.Err() == nil {
+			s.write(map[string]interface{}{"type": "canceled", "request_id": requestID})
+		}
+	}()
+}
+
+// request_idのリクエストを中断する。実行中でない場合は何もしない
+func (s *llmChatSocketSession) cancel(requestID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.requests[requestID]; ok {
+		cancel()
+	}
+}
+
+// llmChatHeartbeatIntervalごとにpingを送り、pongが返らない場合は接続を閉じる
+func (s *llmChatSocketSession) keepAlive(cancel context.CancelFunc) {
+	ticker := time.NewTicker(llmChatHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			ctx, cancelPing := context.WithTimeout(s.ctx, llmChatSocketPingTimeout)
+			err := s.conn.Ping(ctx)
+			cancelPing()
+			if err != nil {
+				if s.ctx.Err() == nil {
+					log.Printf("websocket ping failed: %v", err)
+				}
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func (s *llmChatSocketSession) write(event map[string]interface{}) error {
+	return wsjson.Write(s.ctx, s.conn, event)
+}
+
+// リクエストのエラーをerrorイベントとして送る。HTTPの場合のステータスコードとレスポンスボディを含める
+func (s *llmChatSocketSession) writeError(requestID *string, errResponse *errorResponse) {
+	log.Printf("%s: %v", errResponse.LogMessage, errResponse.Err)
+	event := maps.Clone(errResponse.Body)
+	event["type"] = "error"
+	event["request_id"] = requestID
+	event["status"] = errResponse.StatusCode
+	s.write(event)
+}
+
+// イベントにrequest_idを付けてWebSocketで送る
+type socketLLMChatEventWriter struct {
+	session   *llmChatSocketSession
+	requestID string
+}
+
+func (e *socketLLMChatEventWriter) WriteEvent(event map[string]interface{}) error {
+	event = maps.Clone(event)
+	event["request_id"] = e.requestID
+	return e.session.write(event)
+}
+
+func (e *socketLLMChatEventWriter) WriteDone() error {
+	return e.session.write(map[string]interface{}{"type": "done", "request_id": e.requestID})
+}
+
+// 接続の維持はpingで行う
+func (e *socketLLMChatEventWriter) WriteHeartbeat() error {
+	return nil
+}
+
+func (e *socketLLMChatEventWriter) Flush() {}
+
+// メッセージのtypeとrequest_idを検証する。
+//
+// chat・cancelにはrequest_idが必要。request_idを読めた場合は、エラーの場合も返す。
+func validateLLMChatSocketMessage(data []byte) (*string, string, *errorResponse) {
+	validator := utils.GetValidator()
+	type MessageValidation struct {
+		Type      any `json:"type" validate:"required,is_string,oneof=chat cancel ping"`
+		RequestID any `json:"request_id" validate:"omitempty,is_string,not_only_whitespaces,max=64"`
+	}
+	var messageValidation MessageValidation
+	if err := json.Unmarshal(data, &messageValidation); err != nil {
+		return nil, "", &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid JSON format",
+			},
+			LogMessage: "failed to decode websocket message",
+			Err:        err,
+		}
+	}
+	var requestID *string
+	if id, ok := messageValidation.RequestID.(string); ok {
+		requestID = &id
+	}
+	if err := validator.Struct(messageValidation); err != nil {
+		return requestID, "", &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid parameter",
+				"target":  utils.GetFirstValidationErrorTarget(err),
+			},
+			LogMessage: "failed to validate websocket message",
+			Err:        err,
+		}
+	}
+	messageType := messageValidation.Type.(string)
+	if messageType != "ping" && messageValidation.RequestID == nil {
+		return nil, "", &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid parameter",
+				"target":  "request_id",
+			},
+			LogMessage: "failed to validate websocket message",
+			Err:        errors.New("request_id is required"),
+		}
+	}
+	return requestID, messageType, nil
+}
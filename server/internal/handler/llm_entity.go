@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/google/uuid"
+)
+
+// LLMが提案したエンティティを、作成時と同じルールで検証してSSEのentityイベントにする。
+//
+// entityにはLLMが出力したオブジェクトをそのまま入れ、problemsに問題のあるフィールドを入れる（問題がなければ空）。
+// UIは編集モーダルの初期値にentityを使い、problemsのフィールドを強調する。
+// 問題がなければ変更を提案として保存し、proposal_idに入れる（問題がある場合はnull）。
+// エンティティにidがある場合は既存のタスク・目標の更新の提案になる。
+func (h *LLMChatHandler) llmEntityEvent(conversationID string, entity llm.Entity) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(entity.Raw, &fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal entity: %w", err)
+	}
+	var proposalEntity string
+	switch entity.Type {
+	case llm.EntityTypeGoal:
+		proposalEntity = datamodel.ProposalEntityGoal
+	case llm.EntityTypeTask:
+		proposalEntity = datamodel.ProposalEntityTask
+	default:
+		return nil, fmt.Errorf("unknown entity type: %s", entity.Type)
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	change, diff, problems, err := buildProposalChange(h.TaskStore, h.GoalStore, tx, proposalEntity, fields)
+	if err != nil {
+		return nil, err
+	}
+	var proposalID *string
+	if len(problems) == 0 {
+		now := time.Now()
+		proposal := datamodel.Proposal{
+			ID:             uuid.New().String(),
+			ConversationID: &conversationID,
+			Changeset:      []datamodel.ProposalChange{change},
+			Diff:           []datamodel.ProposalChangeDiff{diff},
+			Status:         datamodel.ProposalStatusPending,
+			ExpiresAt:      now.Add(proposalLifetime),
+			CreatedAt:      now,
+		}
+		if err := h.ProposalStore.CreateProposal(tx, proposal); err != nil {
+			return nil, fmt.Errorf("failed to create proposal: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		proposalID = &proposal.ID
+	}
+	if problems == nil {
+		problems = []fieldProblem{}
+	}
+	return map[string]interface{}{
+		"type":        "entity",
+		"entity":      fields,
+		"problems":    problems,
+		"proposal_id": proposalID,
+	}, nil
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/ano333333/llm-time-manager/server/internal/prompt"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+// システムプロンプトに含めるタスクの件数の上限（期日を過ぎたもの・今日が期日のものそれぞれ）
+const llmPromptTasksLimit = 50
+
+// 未完了のタスクの状態
+var llmPromptOpenTaskStatuses = []string{datamodel.TaskStatusTodo, datamodel.TaskStatusDoing, datamodel.TaskStatusPaused}
+
+// LLMに送るシステムプロンプトとメッセージを確認する（デバッグ用）。
+//
+// conversation_idを指定した場合は、その会話を続けるときにLLMに送るメッセージを全て返す。
+type LLMPromptHandler struct {
+	Chat *LLMChatHandler
+}
+
+func (h *LLMPromptHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *LLMPromptHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	tx, err := h.Chat.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to build prompt", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	var history []llm.Message
+	if conversationID := r.URL.Query().Get("conversation_id"); conversationID != "" {
+		conversation, err := h.Chat.ConversationStore.GetConversation(tx, conversationID)
+		if err != nil {
+			return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to build prompt", "failed to get conversation", err)
+		}
+		if conversation == nil {
+			return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Conversation not found", "conversation not found", nil)
+		}
+		messages, err := h.Chat.ChatMessageStore.GetAllChatMessages(tx, conversation.ID)
+		if err != nil {
+			return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to build prompt", "failed to get chat messages", err)
+		}
+		if history, err = h.Chat.conversationLLMMessages(tx, conversation.ID, messages); err != nil {
+			return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to build prompt", "failed to get conversation summary", err)
+		}
+	}
+	built, err := h.Chat.buildPrompt(tx, time.Now())
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to build prompt", "failed to build prompt", err)
+	}
+
+	sections := make([]map[string]interface{}, 0, len(built.Sections))
+	for _, section := range built.Sections {
+		sections = append(sections, map[string]interface{}{
+			"name":     section.Name,
+			"included": section.Included,
+			"omitted":  section.Omitted,
+			"tokens":   section.Tokens,
+		})
+	}
+	messages := []map[string]interface{}{}
+	for _, message := range withSystemPrompt(built, history) {
+		var toolCallID *string
+		if message.ToolCallID != "" {
+			toolCallID = &message.ToolCallID
+		}
+		messages = append(messages, map[string]interface{}{
+			"role":         message.Role,
+			"content":      message.Content,
+			"tool_calls":   toChatToolCalls(message.ToolCalls),
+			"tool_call_id": toolCallID,
+		})
+	}
+	return map[string]interface{}{
+		"prompt": map[string]interface{}{
+			"text":     built.Text,
+			"tokens":   built.Tokens,
+			"budget":   built.Budget,
+			"sections": sections,
+			// 指示の描画に使ったテンプレートのバージョン
+			"template_versions": built.TemplateVersions,
+		},
+		"messages": messages,
+	}, nil
+}
+
+// 現在の目標・タスク・キャプチャスケジュールからシステムプロンプトを組み立てる
+func (h *LLMChatHandler) buildPrompt(tx store.Transaction, now time.Time) (prompt.Prompt, error) {
+	now = now.In(utils.GetJSTTimezone())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	yesterday := today.AddDate(0, 0, -1)
+	c := prompt.Context{}
+
+	doing, err := h.TaskStore.GetDoingTaskAt(tx, now)
+	if err != nil {
+		return prompt.Prompt{}, fmt.Errorf("failed to get doing task: %w", err)
+	}
+	c.DoingTask = doing
+	if c.OverdueTasks, err = h.TaskStore.GetTasks(tx, store.TaskFilter{Statuses: llmPromptOpenTaskStatuses, DueTo: &yesterday}, llmPromptTasksLimit, 0); err != nil {
+		return prompt.Prompt{}, fmt.Errorf("failed to get overdue tasks: %w", err)
+	}
+	if c.TodayTasks, err = h.TaskStore.GetTasks(tx, store.TaskFilter{Statuses: llmPromptOpenTaskStatuses, DueFrom: &today, DueTo: &today}, llmPromptTasksLimit, 0); err != nil {
+		return prompt.Prompt{}, fmt.Errorf("failed to get today's tasks: %w", err)
+	}
+
+	goals, err := h.GoalStore.GetGoal(tx, []string{"active"})
+	if err != nil {
+		return prompt.Prompt{}, fmt.Errorf("failed to get goals: %w", err)
+	}
+	goalIDs := make([]string, 0, len(goals))
+	for _, goal := range goals {
+		goalIDs = append(goalIDs, goal.ID)
+	}
+	totals, err := h.KpiEntryStore.GetKpiTotals(tx, goalIDs)
+	if err != nil {
+		return prompt.Prompt{}, fmt.Errorf("failed to get kpi totals: %w", err)
+	}
+	for _, goal := range goals {
+		promptGoal := prompt.Goal{Goal: goal}
+		if goal.KpiName != nil {
+			total := totals[goal.ID]
+			promptGoal.KpiProgress = &total
+		}
+		c.Goals = append(c.Goals, promptGoal)
+	}
+
+	if c.CaptureSchedule, err = h.CaptureScheduleStore.GetCurrentCaptureSchedule(tx); err != nil {
+		return prompt.Prompt{}, fmt.Errorf("failed to get capture schedule: %w", err)
+	}
+	instructions, versions, err := h.renderInstructions(tx, now)
+	if err != nil {
+		return prompt.Prompt{}, err
+	}
+	built := prompt.Build(c, instructions, h.PromptTokenBudget)
+	built.TemplateVersions = versions
+	return built, nil
+}
+
+// 最新のchat・extractionテンプレートで指示を描画し、使ったテンプレートのバージョンと合わせて返す
+func (h *LLMChatHandler) renderInstructions(tx store.Transaction, now time.Time) (string, map[string]int, error) {
+	templates := map[string]*datamodel.PromptTemplate{}
+	for _, name := range []string{prompt.TemplateChat, prompt.TemplateExtraction} {
+		template, err := h.PromptTemplateStore.GetLatestPromptTemplate(tx, name)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to get prompt template %s: %w", name, err)
+		}
+		if template == nil {
+			return "", nil, fmt.Errorf("prompt template %s not found", name)
+		}
+		templates[name] = template
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	extraction, err := prompt.RenderExtraction(templates[prompt.TemplateExtraction].Body, prompt.ExtractionData{Today: today})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to render extraction template: %w", err)
+	}
+	instructions, err := prompt.RenderChat(templates[prompt.TemplateChat].Body, prompt.NewChatData(now, extraction))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to render chat template: %w", err)
+	}
+	return instructions, map[string]int{
+		prompt.TemplateChat:       templates[prompt.TemplateChat].Version,
+		prompt.TemplateExtraction: templates[prompt.TemplateExtraction].Version,
+	}, nil
+}
+
+// 現在の状況のシステムプロンプトを先頭に付けたメッセージと、組み立てたシステムプロンプトを返す。messagesは変更しない
+func (h *LLMChatHandler) withCurrentSystemPrompt(messages []llm.Message) ([]llm.Message, prompt.Prompt, error) {
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, prompt.Prompt{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	built, err := h.buildPrompt(tx, time.Now())
+	if err != nil {
+		return nil, prompt.Prompt{}, err
+	}
+	return withSystemPrompt(built, messages), built, nil
+}
+
+func withSystemPrompt(built prompt.Prompt, messages []llm.Message) []llm.Message {
+	return append([]llm.Message{{Role: llm.RoleSystem, Content: built.Text}}, messages...)
+}
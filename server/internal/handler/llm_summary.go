@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/ano333333/llm-time-manager/server/internal/prompt"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/google/uuid"
+)
+
+// 会話の履歴をLLMに送るメッセージにする。要約がある場合は、要約と要約していないメッセージを返す
+func (h *LLMChatHandler) conversationLLMMessages(tx store.Transaction, conversationID string, history []datamodel.ChatMessage) ([]llm.Message, error) {
+	summary, err := h.ConversationSummaryStore.GetLatestConversationSummary(tx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation summary: %w", err)
+	}
+	rest, ok := unsummarizedChatMessages(summary, history)
+	if !ok {
+		return chatMessagesToLLMMessages(history), nil
+	}
+	return append([]llm.Message{prompt.SummaryMessage(summary.Content)}, chatMessagesToLLMMessages(rest)...), nil
+}
+
+// 会話の要約していない部分のトークン数がSummaryThresholdを超える場合、古いメッセージを以前の要約と合わせて要約し、保存する。
+//
+// 要約した場合は、LLMに送るメッセージ（要約と残したメッセージ）を返す。要約しない場合はnilを返す。
+func (h *LLMChatHandler) summarizeConversation(ctx context.Context, conversationID string) ([]llm.Message, error) {
+	if h.SummaryThreshold <= 0 {
+		return nil, nil
+	}
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	summary, err := h.ConversationSummaryStore.GetLatestConversationSummary(tx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation summary: %w", err)
+	}
+	history, err := h.ChatMessageStore.GetAllChatMessages(tx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat messages: %w", err)
+	}
+	// LLMの応答を待つ間は読み込みのトランザクションを開いたままにしない
+	tx.Rollback()
+
+	rest, previous := history, ""
+	if unsummarized, ok := unsummarizedChatMessages(summary, history); ok {
+		rest, previous = unsummarized, summary.Content
+	}
+	messages := chatMessagesToLLMMessages(rest)
+	cut := prompt.SummaryCut(messages, h.SummaryThreshold)
+	if cut == 0 {
+		return nil, nil
+	}
+	content, err := h.LLM.Chat(ctx, prompt.SummaryRequest(previous, messages[:cut]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate summary: %w", err)
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, errors.New("summary is empty")
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate conversation summary id: %w", err)
+	}
+	tx, err = h.TransactionStore.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := h.ConversationSummaryStore.CreateConversationSummary(tx, datamodel.ConversationSummary{
+		ID:             id.String(),
+		ConversationID: conversationID,
+		Content:        content,
+		LastMessageID:  rest[cut-1].ID,
+		MessageCount:   len(history) - len(rest) + cut,
+		CreatedAt:      time.Now(),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create conversation summary: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return append([]llm.Message{prompt.SummaryMessage(content)}, messages[cut:]...), nil
+}
+
+// summaryで要約した後のメッセージを返す。要約がない場合や、要約した最後のメッセージが見つからない場合はfalseを返す
+func unsummarizedChatMessages(summary *datamodel.ConversationSummary, history []datamodel.ChatMessage) ([]datamodel.ChatMessage, bool) {
+	if summary == nil {
+		return nil, false
+	}
+	for i, message := range history {
+		if message.ID == summary.LastMessageID {
+			return history[i+1:], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,463 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+	"github.com/google/uuid"
+)
+
+// 1回のリクエストでツールを呼び出せる応答の数。超えた場合はツールを渡さずに応答させる
+const llmToolMaxSteps = 5
+
+// list_tasksで返すタスクの件数
+const (
+	llmToolTasksDefaultLimit = 20
+	llmToolTasksMaxLimit     = 50
+)
+
+// LLMが呼び出せるツール
+type llmTool struct {
+	definition llm.Tool
+	// データを変更するツールは、ユーザーが確認してから実行する
+	requiresConfirmation bool
+	// ツールを実行し、LLMに返す結果を返す
+	execute func(h *LLMChatHandler, tx store.Transaction, arguments json.RawMessage) (map[string]interface{}, error)
+	// 実行したトランザクションのコミット後に呼ぶ。nilの場合は何もしない
+	afterCommit func(h *LLMChatHandler)
+}
+
+// LLMが直せる引数の誤り。メッセージをそのままLLMに返す
+type llmToolInputError struct {
+	message string
+}
+
+func (e *llmToolInputError) Error() string {
+	return e.message
+}
+
+func newLLMToolInputError(format string, args ...any) error {
+	return &llmToolInputError{message: fmt.Sprintf(format, args...)}
+}
+
+// LLMに渡す順のツール
+var llmTools = []llmTool{
+	{
+		definition: llm.Tool{
+			Name:        "list_tasks",
+			Description: "List tasks ordered by due date. Tasks without a due date come last.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"status": {"type": "array", "items": {"type": "string", "enum": ["todo", "doing", "paused", "done", "archived"]}, "description": "Statuses to include. All statuses if omitted."},
+					"goal_id": {"type": "string", "description": "Only tasks of this goal."},
+					"limit": {"type": "integer", "minimum": 1, "maximum": 50, "description": "Maximum number of tasks. Defaults to 20."}
+				}
+			}`),
+		},
+		execute: (*LLMChatHandler).listTasksTool,
+	},
+	{
+		definition: llm.Tool{
+			Name:        "create_task",
+			Description: "Create a task. Requires user confirmation.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"title": {"type": "string", "maxLength": 255},
+					"description": {"type": "string"},
+					"goal_id": {"type": "string", "description": "Goal the task belongs to."},
+					"due": {"type": "string", "description": "Due date in YYYY-MM-DD."},
+					"estimate_min": {"type": "integer", "minimum": 0, "maximum": 10080, "description": "Estimated minutes."},
+					"priority": {"type": "integer", "minimum": 1, "maximum": 5},
+					"tags": {"type": "array", "items": {"type": "string"}}
+				},
+				"required": ["title"]
+			}`),
+		},
+		requiresConfirmation: true,
+		execute:              (*LLMChatHandler).createTaskTool,
+	},
+	{
+		definition: llm.Tool{
+			Name:        "update_task_status",
+			Description: "Change the status of a task. Requires user confirmation.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"task_id": {"type": "string"},
+					"status": {"type": "string", "enum": ["todo", "doing", "paused", "done", "archived"]}
+				},
+				"required": ["task_id", "status"]
+			}`),
+		},
+		requiresConfirmation: true,
+		execute:              (*LLMChatHandler).updateTaskStatusTool,
+	},
+	{
+		definition: llm.Tool{
+			Name:        "list_goals",
+			Description: "List goals with their KPI and the total of recorded KPI entries.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"status": {"type": "array", "items": {"type": "string", "enum": ["active", "paused", "done"]}, "description": "Statuses to include. Defaults to [\"active\"]."}
+				}
+			}`),
+		},
+		execute: (*LLMChatHandler).listGoalsTool,
+	},
+	{
+		definition: llm.Tool{
+			Name:        "add_kpi_entry",
+			Description: "Record progress on the KPI of a goal. Requires user confirmation.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"goal_id": {"type": "string"},
+					"value": {"type": "number", "description": "Amount in the unit of the KPI."},
+					"note": {"type": "string"},
+					"recorded_on": {"type": "string", "description": "Date in YYYY-MM-DD. Defaults to today."}
+				},
+				"required": ["goal_id", "value"]
+			}`),
+		},
+		requiresConfirmation: true,
+		execute:              (*LLMChatHandler).addKpiEntryTool,
+	},
+	{
+		definition: llm.Tool{
+			Name:        "set_capture_schedule",
+			Description: "Turn periodic screen capture on or off and change its interval. Requires user confirmation.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"active": {"type": "boolean"},
+					"interval_min": {"type": "integer", "minimum": 1, "maximum": 1440, "description": "Minutes between captures. Required if no schedule exists yet."}
+				},
+				"required": ["active"]
+			}`),
+		},
+		requiresConfirmation: true,
+		execute:              (*LLMChatHandler).setCaptureScheduleTool,
+		afterCommit: func(h *LLMChatHandler) {
+			h.Scheduler.Reload()
+		},
+	},
+}
+
+func llmToolDefinitions() []llm.Tool {
+	definitions := make([]llm.Tool, 0, len(llmTools))
+	for _, tool := range llmTools {
+		definitions = append(definitions, tool.definition)
+	}
+	return definitions
+}
+
+func findLLMTool(name string) (llmTool, bool) {
+	for _, tool := range llmTools {
+		if tool.definition.Name == name {
+			return tool, true
+		}
+	}
+	return llmTool{}, false
+}
+
+// ツールを実行し、LLMに返す結果を返す。実行できなかった場合は {"error": "..."} とfalseを返す
+func (h *LLMChatHandler) executeLLMTool(tx store.Transaction, call datamodel.ChatToolCall) (map[string]interface{}, bool) {
+	tool, ok := findLLMTool(call.Name)
+	if !ok {
+		return map[string]interface{}{"error": fmt.Sprintf("unknown tool: %s", call.Name)}, false
+	}
+	result, err := tool.execute(h, tx, call.Arguments)
+	if err != nil {
+		var inputErr *llmToolInputError
+		if errors.As(err, &inputErr) {
+			return map[string]interface{}{"error": inputErr.message}, false
+		}
+		log.Printf("failed to execute tool %s: %v", call.Name, err)
+		return map[string]interface{}{"error": "internal error"}, false
+	}
+	return result, true
+}
+
+// 引数をvalidationの構造体に読み込んで検証する
+func decodeLLMToolArguments(arguments json.RawMessage, validation any) error {
+	if err := json.Unmarshal(arguments, validation); err != nil {
+		return newLLMToolInputError("arguments must be a JSON object: %v", err)
+	}
+	if err := utils.GetValidator().Struct(validation); err != nil {
+		return llmToolProblemsError(validationErrorToProblems(err))
+	}
+	return nil
+}
+
+func llmToolProblemsError(problems []fieldProblem) error {
+	return newLLMToolInputError("%v", problemsToError(problems))
+}
+
+func (h *LLMChatHandler) listTasksTool(tx store.Transaction, arguments json.RawMessage) (map[string]interface{}, error) {
+	var args struct {
+		Status []any `json:"status" validate:"omitempty,dive,is_string,oneof=todo doing paused done archived"`
+		GoalID any   `json:"goal_id" validate:"omitempty,is_string"`
+		Limit  any   `json:"limit" validate:"omitnil,is_integer,min=1,max=50"`
+	}
+	if err := decodeLLMToolArguments(arguments, &args); err != nil {
+		return nil, err
+	}
+	filter := store.TaskFilter{}
+	for _, status := range args.Status {
+		filter.Statuses = append(filter.Statuses, status.(string))
+	}
+	if args.GoalID != nil {
+		goalID := args.GoalID.(string)
+		filter.GoalID = &goalID
+	}
+	limit := llmToolTasksDefaultLimit
+	if args.Limit != nil {
+		limit = int(args.Limit.(float64))
+	}
+	tasks, err := h.TaskStore.GetTasks(tx, filter, limit, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+	results := make([]map[string]interface{}, 0, len(tasks))
+	for _, task := range tasks {
+		results = append(results, taskToResponse(task))
+	}
+	return map[string]interface{}{"tasks": results}, nil
+}
+
+func (h *LLMChatHandler) createTaskTool(tx store.Transaction, arguments json.RawMessage) (map[string]interface{}, error) {
+	var args taskInputValidation
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, newLLMToolInputError("arguments must be a JSON object: %v", err)
+	}
+	input, problems := validateTaskInput(args)
+	if len(problems) > 0 {
+		return nil, llmToolProblemsError(problems)
+	}
+	if input.GoalID != nil {
+		goal, err := h.GoalStore.GetGoalByID(tx, *input.GoalID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get goal: %w", err)
+		}
+		if goal == nil {
+			return nil, newLLMToolInputError("goal not found: %s", *input.GoalID)
+		}
+	}
+	now := time.Now()
+	task := datamodel.Task{
+		ID:          uuid.New().String(),
+		GoalID:      input.GoalID,
+		Title:       input.Title,
+		Description: input.Description,
+		Due:         input.Due,
+		EstimateMin: input.EstimateMin,
+		Priority:    input.Priority,
+		Status:      datamodel.TaskStatusTodo,
+		Tags:        input.Tags,
+		Attachments: []any{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := h.TaskStore.CreateTask(tx, task); err != nil {
+		return nil, fmt.Errorf("failed to create task: %w", err)
+	}
+	return map[string]interface{}{"task": taskToResponse(task)}, nil
+}
+
+func (h *LLMChatHandler) updateTaskStatusTool(tx store.Transaction, arguments json.RawMessage) (map[string]interface{}, error) {
+	var args struct {
+		TaskID any `json:"task_id" validate:"required,is_string,not_only_whitespaces"`
+		Status any `json:"status" validate:"required,is_string,oneof=todo doing paused done archived"`
+	}
+	if err := decodeLLMToolArguments(arguments, &args); err != nil {
+		return nil, err
+	}
+	taskID := args.TaskID.(string)
+	updated, err := h.TaskStore.UpdateTaskStatus(tx, taskID, args.Status.(string), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to update task status: %w", err)
+	}
+	if !updated {
+		return nil, newLLMToolInputError("task not found: %s", taskID)
+	}
+	task, err := h.TaskStore.GetTask(tx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+	return map[string]interface{}{"task": taskToResponse(*task)}, nil
+}
+
+func (h *LLMChatHandler) listGoalsTool(tx store.Transaction, arguments json.RawMessage) (map[string]interface{}, error) {
+	var args struct {
+		Status []any `json:"status" validate:"omitempty,dive,is_string,oneof=active paused done"`
+	}
+	if err := decodeLLMToolArguments(arguments, &args); err != nil {
+		return nil, err
+	}
+	statuses := []string{"active"}
+	if len(args.Status) > 0 {
+		statuses = statuses[:0]
+		for _, status := range args.Status {
+			statuses = append(statuses, status.(string))
+		}
+	}
+	goals, err := h.GoalStore.GetGoal(tx, statuses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get goals: %w", err)
+	}
+	goalIDs := make([]string, 0, len(goals))
+	for _, goal := range goals {
+		goalIDs = append(goalIDs, goal.ID)
+	}
+	totals, err := h.KpiEntryStore.GetKpiTotals(tx, goalIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kpi totals: %w", err)
+	}
+	results := make([]map[string]interface{}, 0, len(goals))
+	for _, goal := range goals {
+		var progress *float64
+		if goal.KpiName != nil {
+			total := totals[goal.ID]
+			progress = &total
+		}
+		results = append(results, map[string]interface{}{
+			"id":           goal.ID,
+			"title":        goal.Title,
+			"description":  goal.Description,
+			"start_date":   goal.StartDate.Format(time.DateOnly),
+			"end_date":     goal.EndDate.Format(time.DateOnly),
+			"kpi_name":     goal.KpiName,
+			"kpi_target":   goal.KpiTarget,
+			"kpi_unit":     goal.KpiUnit,
+			"kpi_progress": progress,
+			"status":       goal.Status,
+		})
+	}
+	return map[string]interface{}{"goals": results}, nil
+}
+
+func (h *LLMChatHandler) addKpiEntryTool(tx store.Transaction, arguments json.RawMessage) (map[string]interface{}, error) {
+	var args struct {
+		GoalID     any `json:"goal_id" validate:"required,is_string,not_only_whitespaces"`
+		Value      any `json:"value" validate:"omitnil,is_float64"`
+		Note       any `json:"note" validate:"omitempty,is_string,max=1000"`
+		RecordedOn any `json:"recorded_on" validate:"omitempty,is_string,datetime=2006-01-02"`
+	}
+	if err := decodeLLMToolArguments(arguments, &args); err != nil {
+		return nil, err
+	}
+	// requiredは0を値なしとみなしうるため、nullと省略はここで確かめる
+	if args.Value == nil {
+		return nil, llmToolProblemsError([]fieldProblem{{Field: "value", Reason: "required"}})
+	}
+	goalID := args.GoalID.(string)
+	goal, err := h.GoalStore.GetGoalByID(tx, goalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get goal: %w", err)
+	}
+	if goal == nil {
+		return nil, newLLMToolInputError("goal not found: %s", goalID)
+	}
+	if goal.KpiName == nil {
+		return nil, newLLMToolInputError("goal has no kpi: %s", goalID)
+	}
+	now := time.Now()
+	recordedOn, _ := time.Parse(time.DateOnly, now.In(utils.GetJSTTimezone()).Format(time.DateOnly))
+	if args.RecordedOn != nil {
+		recordedOn, _ = time.Parse(time.DateOnly, args.RecordedOn.(string))
+	}
+	entry := datamodel.KpiEntry{
+		ID:         uuid.New().String(),
+		GoalID:     goalID,
+		Value:      args.Value.(float64),
+		RecordedOn: recordedOn,
+		CreatedAt:  now,
+	}
+	if args.Note != nil {
+		entry.Note = args.Note.(string)
+	}
+	if err := h.KpiEntryStore.CreateKpiEntry(tx, entry); err != nil {
+		return nil, fmt.Errorf("failed to create kpi entry: %w", err)
+	}
+	totals, err := h.KpiEntryStore.GetKpiTotals(tx, []string{goalID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kpi totals: %w", err)
+	}
+	return map[string]interface{}{
+		"kpi_entry": map[string]interface{}{
+			"id":          entry.ID,
+			"goal_id":     entry.GoalID,
+			"value":       entry.Value,
+			"note":        entry.Note,
+			"recorded_on": entry.RecordedOn.Format(time.DateOnly),
+		},
+		"kpi_progress": totals[goalID],
+		"kpi_target":   goal.KpiTarget,
+		"kpi_unit":     goal.KpiUnit,
+	}, nil
+}
+
+// キャプチャスケジュールの有効・無効と間隔を変える。それ以外の設定は変えない
+func (h *LLMChatHandler) setCaptureScheduleTool(tx store.Transaction, arguments json.RawMessage) (map[string]interface{}, error) {
+	var args struct {
+		Active      any `json:"active" validate:"required,is_boolean"`
+		IntervalMin any `json:"interval_min" validate:"omitnil,is_integer,min=1,max=1440"`
+	}
+	if err := decodeLLMToolArguments(arguments, &args); err != nil {
+		return nil, err
+	}
+	state := datamodel.CaptureScheduleStateInactive
+	if args.Active.(bool) {
+		state = datamodel.CaptureScheduleStateActive
+	}
+
+	previous, err := getTargetCaptureSchedule(h.CaptureScheduleStore, tx)
+	if err != nil {
+		return nil, err
+	}
+	var captureSchedule datamodel.CaptureSchedule
+	action := datamodel.CaptureScheduleActionUpdate
+	if previous == nil {
+		if args.IntervalMin == nil {
+			return nil, newLLMToolInputError("interval_min is required because no capture schedule exists")
+		}
+		action = datamodel.CaptureScheduleActionCreate
+		captureSchedule, err = h.CaptureScheduleStore.CreateCaptureSchedule(tx, datamodel.CaptureSchedule{
+			ID:             uuid.New().String(),
+			State:          state,
+			IntervalMin:    int(args.IntervalMin.(float64)),
+			TimeWindows:    []datamodel.CaptureTimeWindow{},
+			ExcludedDates:  []string{},
+			CaptureMode:    datamodel.CaptureScheduleModeFull,
+			ExcludeWindows: []string{},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create capture schedule: %w", err)
+		}
+	} else {
+		updated := *previous
+		updated.State = state
+		if args.IntervalMin != nil {
+			updated.IntervalMin = int(args.IntervalMin.(float64))
+		}
+		captureSchedule, err = h.CaptureScheduleStore.UpdateCaptureSchedule(tx, updated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update capture schedule: %w", err)
+		}
+	}
+	// ユーザーが確認して実行するため、ユーザーによる変更として記録する
+	if err := recordCaptureScheduleHistory(h.CaptureScheduleHistoryStore, tx, action, datamodel.CaptureScheduleChangedByUser, previous, captureSchedule); err != nil {
+		return nil, fmt.Errorf("failed to record capture schedule history: %w", err)
+	}
+	return map[string]interface{}{"schedule": captureScheduleToResponse(captureSchedule)}, nil
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// クエリパラメータのlimit/offsetを読み取る。limitの省略時はdefaultLimit、上限はmaxLimit。
+func parsePagination(r *http.Request, defaultLimit int, maxLimit int) (int, int, *errorResponse) {
+	limit := defaultLimit
+	offset := 0
+	query := r.URL.Query()
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxLimit {
+			return 0, 0, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", fmt.Sprintf("limit must be an integer between 1 and %d", maxLimit), "invalid limit", err)
+		}
+		limit = parsed
+	}
+	if raw := query.Get("offset"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			return 0, 0, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "offset must be a non-negative integer", "invalid offset", err)
+		}
+		offset = parsed
+	}
+	return limit, offset, nil
+}
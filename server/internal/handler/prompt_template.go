@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/prompt"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	promptTemplateVersionsDefaultLimit = 20
+	promptTemplateVersionsMaxLimit     = 100
+)
+
+// プロンプトのテンプレートの最新のバージョンの一覧を返す
+type PromptTemplatesHandler struct {
+	PromptTemplateStore store.PromptTemplateStore
+	TransactionStore    store.TransactionStore
+}
+
+func (h *PromptTemplatesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *PromptTemplatesHandler) get() (map[string]interface{}, *errorResponse) {
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get prompt templates", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	templates, err := h.PromptTemplateStore.GetLatestPromptTemplates(tx)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get prompt templates", "failed to get prompt templates", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get prompt templates", "failed to commit transaction", err)
+	}
+
+	results := make([]map[string]interface{}, 0, len(templates))
+	for _, template := range templates {
+		results = append(results, promptTemplateToResponse(template))
+	}
+	return map[string]interface{}{
+		"templates": results,
+	}, nil
+}
+
+// プロンプトのテンプレートの最新のバージョンを返す
+type PromptTemplateHandler struct {
+	PromptTemplateStore store.PromptTemplateStore
+	TransactionStore    store.TransactionStore
+}
+
+func (h *PromptTemplateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *PromptTemplateHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	name := r.PathValue("name")
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get prompt template", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	template, err := h.PromptTemplateStore.GetLatestPromptTemplate(tx, name)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get prompt template", "failed to get prompt template", err)
+	}
+	if template == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Prompt template not found", "prompt template not found", nil)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get prompt template", "failed to commit transaction", err)
+	}
+
+	return map[string]interface{}{
+		"template": promptTemplateToResponse(*template),
+	}, nil
+}
+
+// プロンプトのテンプレートのバージョンを新しい順に返す。POSTでは新しいバージョンを追加する
+type PromptTemplateVersionsHandler struct {
+	PromptTemplateStore store.PromptTemplateStore
+	TransactionStore    store.TransactionStore
+}
+
+func (h *PromptTemplateVersionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	case "POST":
+		body, errResponse = h.post(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *PromptTemplateVersionsHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	name := r.PathValue("name")
+	if !prompt.IsTemplateName(name) {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Prompt template not found", "prompt template not found", nil)
+	}
+	limit, offset, errResponse := parsePagination(r, promptTemplateVersionsDefaultLimit, promptTemplateVersionsMaxLimit)
+	if errResponse != nil {
+		return nil, errResponse
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get prompt template versions", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	templates, err := h.PromptTemplateStore.GetPromptTemplateVersions(tx, name, limit, offset)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get prompt template versions", "failed to get prompt template versions", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get prompt template versions", "failed to commit transaction", err)
+	}
+
+	results := make([]map[string]interface{}, 0, len(templates))
+	for _, template := range templates {
+		results = append(results, promptTemplateToResponse(template))
+	}
+	return map[string]interface{}{
+		"templates": results,
+		"limit":     limit,
+		"offset":    offset,
+	}, nil
+}
+
+// bodyを検証し、最新のバージョンの次のバージョンとして追加する
+func (h *PromptTemplateVersionsHandler) post(r *http.Request) (map[string]interface{}, *errorResponse) {
+	name := r.PathValue("name")
+	if !prompt.IsTemplateName(name) {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Prompt template not found", "prompt template not found", nil)
+	}
+	validator := utils.GetValidator()
+	type postRequestBodyValidation struct {
+		Body any `json:"body" validate:"required,is_string,not_only_whitespaces,max=20000"`
+	}
+	var requestBodyValidation postRequestBodyValidation
+	if err := json.NewDecoder(r.Body).Decode(&requestBodyValidation); err != nil {
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Invalid JSON format", "failed to decode request body", err)
+	}
+	if err := validator.Struct(requestBodyValidation); err != nil {
+		target := utils.GetFirstValidationErrorTarget(err)
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", fmt.Sprintf("Invalid parameter: %s", target), "failed to validate request body", err)
+	}
+	body := requestBodyValidation.Body.(string)
+	if err := prompt.ValidateTemplate(name, body); err != nil {
+		return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_TEMPLATE", fmt.Sprintf("Invalid template: %v", err), "failed to validate prompt template", err)
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create prompt template", "failed to generate prompt template id", err)
+	}
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create prompt template", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	latest, err := h.PromptTemplateStore.GetLatestPromptTemplate(tx, name)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create prompt template", "failed to get prompt template", err)
+	}
+	template := datamodel.PromptTemplate{
+		ID:        id.String(),
+		Name:      name,
+		Version:   1,
+		Body:      body,
+		CreatedAt: time.Now(),
+	}
+	if latest != nil {
+		template.Version = latest.Version + 1
+	}
+	if err := h.PromptTemplateStore.CreatePromptTemplate(tx, template); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create prompt template", "failed to create prompt template", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create prompt template", "failed to commit transaction", err)
+	}
+
+	return map[string]interface{}{
+		"template": promptTemplateToResponse(template),
+	}, nil
+}
+
+// プロンプトのテンプレートの指定したバージョンを返す
+type PromptTemplateVersionHandler struct {
+	PromptTemplateStore store.PromptTemplateStore
+	TransactionStore    store.TransactionStore
+}
+
+func (h *PromptTemplateVersionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *PromptTemplateVersionHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	name := r.PathValue("name")
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Prompt template not found", "invalid prompt template version", err)
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get prompt template", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	template, err := h.PromptTemplateStore.GetPromptTemplate(tx, name, version)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get prompt template", "failed to get prompt template", err)
+	}
+	if template == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Prompt template not found", "prompt template not found", nil)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get prompt template", "failed to commit transaction", err)
+	}
+
+	return map[string]interface{}{
+		"template": promptTemplateToResponse(*template),
+	}, nil
+}
+
+func promptTemplateToResponse(template datamodel.PromptTemplate) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         template.ID,
+		"name":       template.Name,
+		"version":    template.Version,
+		"body":       template.Body,
+		"created_at": template.CreatedAt.In(utils.GetJSTTimezone()).Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+const (
+	proposalsDefaultLimit = 20
+	proposalsMaxLimit     = 100
+)
+
+// LLMが提案した変更の一覧を新しい順に返す
+type ProposalsHandler struct {
+	ProposalStore    store.ProposalStore
+	TransactionStore store.TransactionStore
+}
+
+func (h *ProposalsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *ProposalsHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	limit, offset, errResponse := parsePagination(r, proposalsDefaultLimit, proposalsMaxLimit)
+	if errResponse != nil {
+		return nil, errResponse
+	}
+	query := r.URL.Query()
+	filter := store.ProposalFilter{}
+	if raw := query.Get("status"); raw != "" {
+		for _, status := range strings.Split(raw, ",") {
+			switch status {
+			case datamodel.ProposalStatusPending, datamodel.ProposalStatusAccepted, datamodel.ProposalStatusRejected, datamodel.ProposalStatusExpired:
+				filter.Statuses = append(filter.Statuses, status)
+			default:
+				return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "status must be pending, accepted, rejected or expired", "invalid status", nil)
+			}
+		}
+	}
+	if raw := query.Get("conversation_id"); raw != "" {
+		filter.ConversationID = &raw
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get proposals", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := h.ProposalStore.ExpireProposals(tx, time.Now()); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get proposals", "failed to expire proposals", err)
+	}
+	proposals, err := h.ProposalStore.GetProposals(tx, filter, limit, offset)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get proposals", "failed to get proposals", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get proposals", "failed to commit transaction", err)
+	}
+
+	results := make([]map[string]interface{}, 0, len(proposals))
+	for _, proposal := range proposals {
+		results = append(results, proposalToResponse(proposal))
+	}
+	return map[string]interface{}{
+		"proposals": results,
+		"limit":     limit,
+		"offset":    offset,
+	}, nil
+}
+
+// LLMが提案した変更を返す
+type ProposalHandler struct {
+	ProposalStore    store.ProposalStore
+	TransactionStore store.TransactionStore
+}
+
+func (h *ProposalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *ProposalHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	id := r.PathValue("id")
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get proposal", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := h.ProposalStore.ExpireProposals(tx, time.Now()); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get proposal", "failed to expire proposals", err)
+	}
+	proposal, err := h.ProposalStore.GetProposal(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get proposal", "failed to get proposal", err)
+	}
+	if proposal == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Proposal not found", "proposal not found", nil)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get proposal", "failed to commit transaction", err)
+	}
+
+	return map[string]interface{}{
+		"proposal": proposalToResponse(*proposal),
+	}, nil
+}
+
+// pendingの提案を承認（変更を適用）または却下する（accept/reject）
+type ProposalResolveHandler struct {
+	ProposalStore    store.ProposalStore
+	TaskStore        store.TaskStore
+	GoalStore        store.GoalStore
+	TransactionStore store.TransactionStore
+	// 提案をこの状態にする。acceptedの場合は変更を適用する
+	Status string
+}
+
+func (h *ProposalResolveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "POST":
+		body, errResponse = h.post(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+// 提案の全ての変更を1つのトランザクションで適用する。1つでも適用できない場合は何も変更しない
+func (h *ProposalResolveHandler) post(r *http.Request) (map[string]interface{}, *errorResponse) {
+	id := r.PathValue("id")
+	now := time.Now()
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update proposal", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	proposal, err := h.ProposalStore.GetProposal(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update proposal", "failed to get proposal", err)
+	}
+	if proposal == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Proposal not found", "proposal not found", nil)
+	}
+	if proposal.Status == datamodel.ProposalStatusPending && !now.Before(proposal.ExpiresAt) {
+		// 期限切れは承認・却下に失敗しても記録する
+		if _, err := h.ProposalStore.ExpireProposals(tx, now); err != nil {
+			return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update proposal", "failed to expire proposals", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update proposal", "failed to commit transaction", err)
+		}
+		proposal.Status = datamodel.ProposalStatusExpired
+	}
+	if proposal.Status != datamodel.ProposalStatusPending {
+		return nil, newCodedErrorResponse(http.StatusConflict, "INVALID_STATE", fmt.Sprintf("Proposal is %s", proposal.Status), "proposal is not pending", fmt.Errorf("%s -> %s", proposal.Status, h.Status))
+	}
+
+	if h.Status == datamodel.ProposalStatusAccepted {
+		for i, change := range proposal.Changeset {
+			reason, err := applyProposalChange(h.TaskStore, h.GoalStore, tx, change, proposal.Diff[i], now)
+			if err != nil {
+				return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update proposal", "failed to apply proposal change", err)
+			}
+			if reason != "" {
+				return nil, newCodedErrorResponse(http.StatusConflict, "CONFLICT", "Proposal can no longer be applied", "failed to apply proposal change", fmt.Errorf("%s", reason))
+			}
+		}
+	}
+	if _, err := h.ProposalStore.ResolveProposal(tx, id, h.Status, now); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update proposal", "failed to resolve proposal", err)
+	}
+	updated, err := h.ProposalStore.GetProposal(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update proposal", "failed to get proposal", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update proposal", "failed to commit transaction", err)
+	}
+
+	return map[string]interface{}{
+		"proposal": proposalToResponse(*updated),
+	}, nil
+}
+
+func proposalToResponse(proposal datamodel.Proposal) map[string]interface{} {
+	timezone := utils.GetJSTTimezone()
+	return map[string]interface{}{
+		"id":              proposal.ID,
+		"conversation_id": proposal.ConversationID,
+		"changeset":       proposal.Changeset,
+		"diff":            proposal.Diff,
+		"status":          proposal.Status,
+		"expires_at":      proposal.ExpiresAt.In(timezone).Format(time.RFC3339),
+		"resolved_at":     formatOptionalTime(proposal.ResolvedAt),
+		"created_at":      proposal.CreatedAt.In(timezone).Format(time.RFC3339),
+	}
+}
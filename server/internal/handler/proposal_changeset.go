@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/google/uuid"
+)
+
+// 提案がpendingでいられる期間
+const proposalLifetime = 7 * 24 * time.Hour
+
+// 変更の対象ごとのフィールド。差分はこの順に並べる
+var proposalFieldNames = map[string][]string{
+	datamodel.ProposalEntityTask: {"goal_id", "title", "description", "due", "estimate_min", "priority", "tags"},
+	datamodel.ProposalEntityGoal: {"title", "description", "start_date", "end_date", "kpi_name", "kpi_target", "kpi_unit", "status"},
+}
+
+// LLMが提案したエンティティを検証し、適用する変更と現在の状態との差分を返す。
+//
+// fieldsにidがある場合は既存のタスク・目標の更新とし、指定されたフィールドを現在の値に上書きしてから検証する。
+// 問題がある場合は問題のあるフィールドを返す。
+func buildProposalChange(taskStore store.TaskStore, goalStore store.GoalStore, tx store.Transaction, entity string, fields map[string]any) (datamodel.ProposalChange, datamodel.ProposalChangeDiff, []fieldProblem, error) {
+	change := datamodel.ProposalChange{Entity: entity, Action: datamodel.ProposalActionCreate}
+	var current map[string]any
+	if id, ok := fields["id"]; ok {
+		idString, ok := id.(string)
+		if !ok || strings.TrimSpace(idString) == "" {
+			return change, datamodel.ProposalChangeDiff{}, []fieldProblem{{Field: "id", Reason: "is_string"}}, nil
+		}
+		var err error
+		current, err = currentProposalFields(taskStore, goalStore, tx, entity, idString)
+		if err != nil {
+			return change, datamodel.ProposalChangeDiff{}, nil, err
+		}
+		if current == nil {
+			return change, datamodel.ProposalChangeDiff{}, []fieldProblem{{Field: "id", Reason: "not_found"}}, nil
+		}
+		change.Action = datamodel.ProposalActionUpdate
+		change.ID = idString
+	} else {
+		change.ID = uuid.New().String()
+	}
+
+	// 更新の場合、指定されていないフィールドは現在の値のままにする
+	input := maps.Clone(current)
+	if input == nil {
+		input = map[string]any{}
+	}
+	for key, value := range fields {
+		if key != "type" && key != "id" {
+			input[key] = value
+		}
+	}
+	after, problems, err := validateProposalFields(goalStore, tx, entity, input)
+	if err != nil || len(problems) > 0 {
+		return change, datamodel.ProposalChangeDiff{}, problems, err
+	}
+	change.Fields = after
+
+	diff := datamodel.ProposalChangeDiff{Entity: change.Entity, Action: change.Action, ID: change.ID, Fields: []datamodel.ProposalFieldDiff{}}
+	for _, name := range proposalFieldNames[entity] {
+		if current != nil && sameProposalValue(current[name], after[name]) {
+			continue
+		}
+		diff.Fields = append(diff.Fields, datamodel.ProposalFieldDiff{Field: name, Before: current[name], After: after[name]})
+	}
+	if len(diff.Fields) == 0 {
+		return change, datamodel.ProposalChangeDiff{}, []fieldProblem{{Field: "id", Reason: "no_changes"}}, nil
+	}
+	return change, diff, nil, nil
+}
+
+// 提案の変更を1つ適用する。
+//
+// 提案した後に対象が変更・削除された場合や、変更が検証を通らなくなった場合は適用せず、理由を返す。
+func applyProposalChange(taskStore store.TaskStore, goalStore store.GoalStore, tx store.Transaction, change datamodel.ProposalChange, diff datamodel.ProposalChangeDiff, now time.Time) (string, error) {
+	if change.Action == datamodel.ProposalActionUpdate {
+		current, err := currentProposalFields(taskStore, goalStore, tx, change.Entity, change.ID)
+		if err != nil {
+			return "", err
+		}
+		if current == nil {
+			return fmt.Sprintf("%s %s not found", change.Entity, change.ID), nil
+		}
+		for _, field := range diff.Fields {
+			if !sameProposalValue(current[field.Field], field.Before) {
+				return fmt.Sprintf("%s %s has been changed since the proposal", change.Entity, change.ID), nil
+			}
+		}
+	}
+	_, problems, err := validateProposalFields(goalStore, tx, change.Entity, change.Fields)
+	if err != nil {
+		return "", err
+	}
+	if len(problems) > 0 {
+		return problemsToError(problems).Error(), nil
+	}
+
+	switch change.Entity {
+	case datamodel.ProposalEntityTask:
+		var task datamodel.Task
+		if change.Action == datamodel.ProposalActionUpdate {
+			current, err := taskStore.GetTask(tx, change.ID)
+			if err != nil {
+				return "", fmt.Errorf("failed to get task: %w", err)
+			}
+			task = *current
+		} else {
+			task = datamodel.Task{ID: change.ID, Status: datamodel.TaskStatusTodo, Attachments: []any{}, CreatedAt: now}
+		}
+		input, _ := decodeProposalTaskInput(change.Fields)
+		applyTaskInput(&task, input)
+		task.UpdatedAt = now
+		if change.Action == datamodel.ProposalActionUpdate {
+			if _, err := taskStore.UpdateTask(tx, task); err != nil {
+				return "", fmt.Errorf("failed to update task: %w", err)
+			}
+			return "", nil
+		}
+		if err := taskStore.CreateTask(tx, task); err != nil {
+			return "", fmt.Errorf("failed to create task: %w", err)
+		}
+	case datamodel.ProposalEntityGoal:
+		input, _ := decodeProposalGoalInput(change.Fields)
+		var goal datamodel.Goal
+		applyGoalInput(&goal, input)
+		if change.Action == datamodel.ProposalActionUpdate {
+			goal.ID = change.ID
+			if _, err := goalStore.UpdateGoal(tx, goal); err != nil {
+				return "", fmt.Errorf("failed to update goal: %w", err)
+			}
+			return "", nil
+		}
+		if _, err := goalStore.CreateGoal(tx, &change.ID, goal.Title, goal.Description, goal.StartDate, goal.EndDate, goal.KpiName, goal.KpiTarget, goal.KpiUnit, goal.Status); err != nil {
+			return "", fmt.Errorf("failed to create goal: %w", err)
+		}
+	default:
+		return fmt.Sprintf("unknown entity: %s", change.Entity), nil
+	}
+	return "", nil
+}
+
+// idのタスク・目標の現在のフィールドを返す。存在しない場合はnilを返す
+func currentProposalFields(taskStore store.TaskStore, goalStore store.GoalStore, tx store.Transaction, entity string, id string) (map[string]any, error) {
+	switch entity {
+	case datamodel.ProposalEntityTask:
+		task, err := taskStore.GetTask(tx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get task: %w", err)
+		}
+		if task == nil {
+			return nil, nil
+		}
+		return taskToProposalFields(*task), nil
+	case datamodel.ProposalEntityGoal:
+		goal, err := goalStore.GetGoalByID(tx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get goal: %w", err)
+		}
+		if goal == nil {
+			return nil, nil
+		}
+		return goalToProposalFields(*goal), nil
+	}
+	return nil, fmt.Errorf("unknown entity: %s", entity)
+}
+
+// fieldsを作成時と同じルールで検証し、正規化したフィールドを返す
+func validateProposalFields(goalStore store.GoalStore, tx store.Transaction, entity string, fields map[string]any) (map[string]any, []fieldProblem, error) {
+	switch entity {
+	case datamodel.ProposalEntityTask:
+		input, problems := decodeProposalTaskInput(fields)
+		if len(problems) > 0 {
+			return nil, problems, nil
+		}
+		if input.GoalID != nil {
+			goal, err := goalStore.GetGoalByID(tx, *input.GoalID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to get goal: %w", err)
+			}
+			if goal == nil {
+				return nil, []fieldProblem{{Field: "goal_id", Reason: "not_found"}}, nil
+			}
+		}
+		var task datamodel.Task
+		applyTaskInput(&task, input)
+		return taskToProposalFields(task), nil, nil
+	case datamodel.ProposalEntityGoal:
+		input, problems := decodeProposalGoalInput(fields)
+		if len(problems) > 0 {
+			return nil, problems, nil
+		}
+		var goal datamodel.Goal
+		applyGoalInput(&goal, input)
+		return goalToProposalFields(goal), nil, nil
+	}
+	return nil, []fieldProblem{{Field: "type", Reason: "oneof"}}, nil
+}
+
+func decodeProposalTaskInput(fields map[string]any) (taskInput, []fieldProblem) {
+	var validation taskInputValidation
+	if err := remarshalProposalFields(fields, &validation); err != nil {
+		return taskInput{}, []fieldProblem{{Field: "", Reason: "invalid"}}
+	}
+	return validateTaskInput(validation)
+}
+
+func decodeProposalGoalInput(fields map[string]any) (postRequestBody, []fieldProblem) {
+	var validation goalInputValidation
+	if err := remarshalProposalFields(fields, &validation); err != nil {
+		return postRequestBody{}, []fieldProblem{{Field: "", Reason: "invalid"}}
+	}
+	return validateGoalInput(validation)
+}
+
+func applyTaskInput(task *datamodel.Task, input taskInput) {
+	task.GoalID = input.GoalID
+	task.Title = input.Title
+	task.Description = input.Description
+	task.Due = input.Due
+	task.EstimateMin = input.EstimateMin
+	task.Priority = input.Priority
+	task.Tags = input.Tags
+}
+
+func applyGoalInput(goal *datamodel.Goal, input postRequestBody) {
+	goal.Title = input.Title
+	goal.Description = input.Description
+	goal.StartDate, _ = time.Parse(time.DateOnly, input.StartDate)
+	goal.EndDate, _ = time.Parse(time.DateOnly, input.EndDate)
+	goal.KpiName = input.KpiName
+	goal.KpiTarget = input.KpiTarget
+	goal.KpiUnit = input.KpiUnit
+	goal.Status = input.Status
+}
+
+func taskToProposalFields(task datamodel.Task) map[string]any {
+	var due *string
+	if task.Due != nil {
+		formatted := task.Due.Format(time.DateOnly)
+		due = &formatted
+	}
+	tags := task.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return normalizeProposalFields(map[string]any{
+		"goal_id":      task.GoalID,
+		"title":        task.Title,
+		"description":  task.Description,
+		"due":          due,
+		"estimate_min": task.EstimateMin,
+		"priority":     task.Priority,
+		"tags":         tags,
+	})
+}
+
+func goalToProposalFields(goal datamodel.Goal) map[string]any {
+	return normalizeProposalFields(map[string]any{
+		"title":       goal.Title,
+		"description": goal.Description,
+		"start_date":  goal.StartDate.Format(time.DateOnly),
+		"end_date":    goal.EndDate.Format(time.DateOnly),
+		"kpi_name":    goal.KpiName,
+		"kpi_target":  goal.KpiTarget,
+		"kpi_unit":    goal.KpiUnit,
+		"status":      goal.Status,
+	})
+}
+
+// JSONに保存して読み込んだ値と比較できるよう、JSONを経由した値にする
+func normalizeProposalFields(fields map[string]any) map[string]any {
+	var normalized map[string]any
+	if err := remarshalProposalFields(fields, &normalized); err != nil {
+		return fields
+	}
+	return normalized
+}
+
+func remarshalProposalFields(fields map[string]any, v any) error {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func sameProposalValue(a any, b any) bool {
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(dataA) == string(dataB)
+}
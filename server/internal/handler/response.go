@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+type errorResponse struct {
+	StatusCode int
+	Body       map[string]interface{}
+	LogMessage string
+	Err        error
+}
+
+// 500 Internal Server Error（{"message": "internal server error"}）のerrorResponseを返す
+func newInternalServerErrorResponse(logMessage string, err error) *errorResponse {
+	return &errorResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body: map[string]interface{}{
+			"message": "internal server error",
+		},
+		LogMessage: logMessage,
+		Err:        err,
+	}
+}
+
+// 共通エラーフォーマット（docs/api.md「共通エラーレスポンス」）のerrorResponseを返す
+func newCodedErrorResponse(statusCode int, code string, message string, logMessage string, err error) *errorResponse {
+	return &errorResponse{
+		StatusCode: statusCode,
+		Body: map[string]interface{}{
+			"code":    code,
+			"message": message,
+		},
+		LogMessage: logMessage,
+		Err:        err,
+	}
+}
+
+// errResponseが非nilならエラーレスポンスを、nilならbodyを200で書き込む
+func writeResponse(w http.ResponseWriter, body map[string]interface{}, errResponse *errorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if errResponse != nil {
+		log.Printf("%s: %v", errResponse.LogMessage, errResponse.Err)
+		w.WriteHeader(errResponse.StatusCode)
+		json.NewEncoder(w).Encode(errResponse.Body)
+		return
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+// tをJSTのRFC3339文字列にする。nilの場合はnilを返す。
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	formatted := t.In(utils.GetJSTTimezone()).Format(time.RFC3339)
+	return &formatted
+}
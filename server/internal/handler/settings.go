@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+type SettingsHandler struct {
+	SettingsStore        store.SettingsStore
+	TransactionStore     store.TransactionStore
+	ThumbnailRegenerator *capture.ThumbnailRegenerator
+	RetentionCleaner     *capture.RetentionCleaner
+	// 設定ファイル（環境変数）由来のため読み取り専用
+	CaptureStoragePath string
+}
+
+func (h *SettingsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get()
+	case "PATCH":
+		body, errResponse = h.patch(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *SettingsHandler) get() (map[string]interface{}, *errorResponse) {
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newInternalServerErrorResponse("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	settings, err := h.SettingsStore.GetSettings(tx)
+	if err != nil {
+		return nil, newInternalServerErrorResponse("failed to get settings", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newInternalServerErrorResponse("failed to commit transaction", err)
+	}
+
+	return map[string]interface{}{
+		"settings": h.settingsToResponse(settings),
+	}, nil
+}
+
+func (h *SettingsHandler) patch(r *http.Request) (map[string]interface{}, *errorResponse) {
+	validator := utils.GetValidator()
+	type MaskRuleValidation struct {
+		DisplayID any `json:"display_id" validate:"omitempty,is_string,not_only_whitespaces,max=64"`
+		X         any `json:"x" validate:"is_integer,min=0"`
+		Y         any `json:"y" validate:"is_integer,min=0"`
+		Width     any `json:"width" validate:"required,is_integer,min=1"`
+		Height    any `json:"height" validate:"required,is_integer,min=1"`
+		Mode      any `json:"mode" validate:"required,is_string,oneof=blur pixelate"`
+	}
+	type patchRequestBodyValidation struct {
+		ThumbnailResolution any                  `json:"thumbnail_resolution" validate:"omitempty,is_integer,min=16,max=3840"`
+		RetentionMaxItems   any                  `json:"retention_max_items" validate:"omitempty,is_integer,min=1"`
+		RetentionMaxDays    any                  `json:"retention_max_days" validate:"omitempty,is_integer,min=1"`
+		RetentionMaxBytes   any                  `json:"retention_max_bytes" validate:"omitempty,is_integer,min=1"`
+		DedupMode           any                  `json:"dedup_mode" validate:"omitempty,is_string,oneof=off mark drop"`
+		DedupThreshold      any                  `json:"dedup_threshold" validate:"omitempty,is_integer,min=0,max=64"`
+		MaskRules           []MaskRuleValidation `json:"mask_rules" validate:"omitempty,max=50,dive"`
+	}
+	invalidJSONResponse := func(err error) *errorResponse {
+		return &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid JSON format",
+			},
+			LogMessage: "failed to decode request body",
+			Err:        err,
+		}
+	}
+	rawBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, invalidJSONResponse(err)
+	}
+	// retention_*はnullで無制限を表すため、キーの有無で「変更しない」と区別する
+	var presentKeys map[string]json.RawMessage
+	if err := json.Unmarshal(rawBody, &presentKeys); err != nil {
+		return nil, invalidJSONResponse(err)
+	}
+	var requestBodyValidation patchRequestBodyValidation
+	if err := json.Unmarshal(rawBody, &requestBodyValidation); err != nil {
+		return nil, invalidJSONResponse(err)
+	}
+	if err := validator.Struct(requestBodyValidation); err != nil {
+		return nil, &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid parameter",
+				"target":  utils.GetFirstValidationErrorTarget(err),
+			},
+			LogMessage: "failed to validate request body",
+			Err:        err,
+		}
+	}
+	// null を許さないキー
+	for key, value := range map[string]any{
+		"thumbnail_resolution": requestBodyValidation.ThumbnailResolution,
+		"dedup_mode":           requestBodyValidation.DedupMode,
+		"dedup_threshold":      requestBodyValidation.DedupThreshold,
+	} {
+		if _, ok := presentKeys[key]; ok && value == nil {
+			return nil, &errorResponse{
+				StatusCode: http.StatusBadRequest,
+				Body: map[string]interface{}{
+					"message": "invalid parameter",
+					"target":  key,
+				},
+				LogMessage: key + " is null",
+				Err:        nil,
+			}
+		}
+	}
+	// 空配列はルールの全削除を表すため、nullとは区別する
+	if _, ok := presentKeys["mask_rules"]; ok && requestBodyValidation.MaskRules == nil {
+		return nil, &errorResponse{
+			StatusCode: http.StatusBadRequest,
+			Body: map[string]interface{}{
+				"message": "invalid parameter",
+				"target":  "mask_rules",
+			},
+			LogMessage: "mask_rules is null",
+			Err:        nil,
+		}
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newInternalServerErrorResponse("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	settings, err := h.SettingsStore.GetSettings(tx)
+	if err != nil {
+		return nil, newInternalServerErrorResponse("failed to get settings", err)
+	}
+	previous := settings
+	if requestBodyValidation.ThumbnailResolution != nil {
+		settings.ThumbnailResolution = int(requestBodyValidation.ThumbnailResolution.(float64))
+	}
+	if _, ok := presentKeys["retention_max_items"]; ok {
+		settings.RetentionMaxItems = nullableInt[int](requestBodyValidation.RetentionMaxItems)
+	}
+	if _, ok := presentKeys["retention_max_days"]; ok {
+		settings.RetentionMaxDays = nullableInt[int](requestBodyValidation.RetentionMaxDays)
+	}
+	if _, ok := presentKeys["retention_max_bytes"]; ok {
+		settings.RetentionMaxBytes = nullableInt[int64](requestBodyValidation.RetentionMaxBytes)
+	}
+	if requestBodyValidation.DedupMode != nil {
+		settings.DedupMode = requestBodyValidation.DedupMode.(string)
+	}
+	if requestBodyValidation.DedupThreshold != nil {
+		settings.DedupThreshold = int(requestBodyValidation.DedupThreshold.(float64))
+	}
+	if requestBodyValidation.MaskRules != nil {
+		settings.MaskRules = []datamodel.MaskRule{}
+		for _, rule := range requestBodyValidation.MaskRules {
+			maskRule := datamodel.MaskRule{
+				X:      int(rule.X.(float64)),
+				Y:      int(rule.Y.(float64)),
+				Width:  int(rule.Width.(float64)),
+				Height: int(rule.Height.(float64)),
+				Mode:   rule.Mode.(string),
+			}
+			if rule.DisplayID != nil {
+				displayID := rule.DisplayID.(string)
+				maskRule.DisplayID = &displayID
+			}
+			settings.MaskRules = append(settings.MaskRules, maskRule)
+		}
+	}
+	settings, err = h.SettingsStore.UpdateSettings(tx, settings)
+	if err != nil {
+		return nil, newInternalServerErrorResponse("failed to update settings", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newInternalServerErrorResponse("failed to commit transaction", err)
+	}
+
+	if settings.ThumbnailResolution != previous.ThumbnailResolution {
+		h.ThumbnailRegenerator.Trigger()
+	}
+	if !reflect.DeepEqual(capture.RetentionPolicyFromSettings(settings), capture.RetentionPolicyFromSettings(previous)) {
+		h.RetentionCleaner.Trigger()
+	}
+
+	return map[string]interface{}{
+		"settings": h.settingsToResponse(settings),
+	}, nil
+}
+
+func (h *SettingsHandler) settingsToResponse(settings datamodel.Settings) map[string]interface{} {
+	return map[string]interface{}{
+		"capture_storage_path": h.CaptureStoragePath,
+		"thumbnail_resolution": settings.ThumbnailResolution,
+		"retention_max_items":  settings.RetentionMaxItems,
+		"retention_max_days":   settings.RetentionMaxDays,
+		"retention_max_bytes":  settings.RetentionMaxBytes,
+		"dedup_mode":           settings.DedupMode,
+		"dedup_threshold":      settings.DedupThreshold,
+		"mask_rules":           maskRulesToResponse(settings.MaskRules),
+	}
+}
+
+func maskRulesToResponse(rules []datamodel.MaskRule) []map[string]interface{} {
+	response := []map[string]interface{}{}
+	for _, rule := range rules {
+		response = append(response, map[string]interface{}{
+			"display_id": rule.DisplayID,
+			"x":          rule.X,
+			"y":          rule.Y,
+			"width":      rule.Width,
+			"height":     rule.Height,
+			"mode":       rule.Mode,
+		})
+	}
+	return response
+}
+
+// バリデーション済みの整数またはnull（JSONデコード結果のfloat64またはnil）をポインタに変換する
+func nullableInt[T int | int64](value any) *T {
+	if value == nil {
+		return nil
+	}
+	converted := T(value.(float64))
+	return &converted
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+const (
+	linkedCapturesDefaultLimit = 20
+	linkedCapturesMaxLimit     = 100
+)
+
+// タスクの詳細と、紐付いたキャプチャを返す
+type TaskHandler struct {
+	TaskStore        store.TaskStore
+	CaptureStore     store.CaptureStore
+	TransactionStore store.TransactionStore
+}
+
+func (h *TaskHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *TaskHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	id := r.PathValue("id")
+	limit, offset, errResponse := parsePagination(r, linkedCapturesDefaultLimit, linkedCapturesMaxLimit)
+	if errResponse != nil {
+		return nil, errResponse
+	}
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get task", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	task, err := h.TaskStore.GetTask(tx, id)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get task", "failed to get task", err)
+	}
+	if task == nil {
+		return nil, newCodedErrorResponse(http.StatusNotFound, "NOT_FOUND", "Task not found", "task not found", nil)
+	}
+	captures, err := h.CaptureStore.GetCaptures(tx, store.CaptureFilter{LinkedTaskID: &id}, limit, offset)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get task", "failed to get linked captures", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get task", "failed to commit transaction", err)
+	}
+
+	return map[string]interface{}{
+		"task":     taskToResponse(*task),
+		"captures": capturesToResponse(captures),
+		"limit":    limit,
+		"offset":   offset,
+	}, nil
+}
+
+func taskToResponse(task datamodel.Task) map[string]interface{} {
+	timezone := utils.GetJSTTimezone()
+	var due *string
+	if task.Due != nil {
+		formatted := task.Due.Format(time.DateOnly)
+		due = &formatted
+	}
+	tags := task.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	attachments := task.Attachments
+	if attachments == nil {
+		attachments = []any{}
+	}
+	return map[string]interface{}{
+		"id":           task.ID,
+		"goal_id":      task.GoalID,
+		"title":        task.Title,
+		"description":  task.Description,
+		"due":          due,
+		"estimate_min": task.EstimateMin,
+		"priority":     task.Priority,
+		"status":       task.Status,
+		"tags":         tags,
+		"attachments":  attachments,
+		"created_at":   task.CreatedAt.In(timezone).Format(time.RFC3339),
+		"updated_at":   task.UpdatedAt.In(timezone).Format(time.RFC3339),
+	}
+}
+
+// タスクの入力値の検証ルール。LLMが提案するタスクで使う
+type taskInputValidation struct {
+	GoalID      any `json:"goal_id" validate:"omitempty,is_string,not_only_whitespaces,max=64"`
+	Title       any `json:"title" validate:"required,min=1,max=255,is_string,not_only_whitespaces"`
+	Description any `json:"description" validate:"omitempty,is_string"`
+	Due         any `json:"due" validate:"omitempty,is_string,datetime=2006-01-02"`
+	EstimateMin any `json:"estimate_min" validate:"omitempty,is_integer,max=10080"`
+	Priority    any `json:"priority" validate:"omitempty,is_integer,min=1,max=5"`
+	Tags        any `json:"tags"`
+}
+
+// 検証済みのタスクの入力値。省略されたフィールドはテーブルのデフォルト値になる
+type taskInput struct {
+	GoalID      *string
+	Title       string
+	Description string
+	Due         *time.Time
+	EstimateMin int
+	Priority    int
+	Tags        []string
+}
+
+const (
+	taskTagsMaxCount    = 20
+	taskTagMaxLength    = 64
+	taskDefaultPriority = 3
+)
+
+// タスクの入力値を検証する。問題がある場合は問題のあるフィールドを全て返す
+func validateTaskInput(input taskInputValidation) (taskInput, []fieldProblem) {
+	var problems []fieldProblem
+	if err := utils.GetValidator().Struct(input); err != nil {
+		problems = validationErrorToProblems(err)
+	}
+	// tagsは文字列の配列かどうかをvalidatorのタグで表せないため自前チェック
+	tags, tagsOK := taskTags(input.Tags)
+	if !tagsOK {
+		problems = append(problems, fieldProblem{Field: "tags", Reason: "invalid_tags"})
+	}
+	if len(problems) > 0 {
+		return taskInput{}, problems
+	}
+
+	result := taskInput{
+		Title:    input.Title.(string),
+		Priority: taskDefaultPriority,
+		Tags:     tags,
+	}
+	if input.GoalID != nil {
+		goalID := input.GoalID.(string)
+		result.GoalID = &goalID
+	}
+	if input.Description != nil {
+		result.Description = input.Description.(string)
+	}
+	if input.Due != nil {
+		due, _ := time.Parse(time.DateOnly, input.Due.(string))
+		result.Due = &due
+	}
+	if input.EstimateMin != nil {
+		result.EstimateMin = int(input.EstimateMin.(float64))
+	}
+	if input.Priority != nil {
+		result.Priority = int(input.Priority.(float64))
+	}
+	return result, nil
+}
+
+// tagsが空白のみでない文字列の配列（taskTagsMaxCount件以下、各taskTagMaxLength文字以下）であれば文字列のスライスを返す
+func taskTags(raw any) ([]string, bool) {
+	if raw == nil {
+		return []string{}, true
+	}
+	values, ok := raw.([]any)
+	if !ok || len(values) > taskTagsMaxCount {
+		return nil, false
+	}
+	tags := make([]string, 0, len(values))
+	for _, value := range values {
+		tag, ok := value.(string)
+		if !ok || strings.TrimSpace(tag) == "" || len([]rune(tag)) > taskTagMaxLength {
+			return nil, false
+		}
+		tags = append(tags, tag)
+	}
+	return tags, true
+}
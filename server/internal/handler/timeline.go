@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/store"
+	"github.com/ano333333/llm-time-manager/server/internal/timeline"
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+// キャプチャの解析結果から作った1日の活動ブロックと、タスクのセッションを返す
+type TimelineHandler struct {
+	CaptureStore            store.CaptureStore
+	CaptureAnalysisJobStore store.CaptureAnalysisJobStore
+	TaskSessionStore        store.TaskSessionStore
+	TransactionStore        store.TransactionStore
+}
+
+func (h *TimelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	var errResponse *errorResponse
+	switch r.Method {
+	case "GET":
+		body, errResponse = h.get(r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeResponse(w, body, errResponse)
+}
+
+func (h *TimelineHandler) get(r *http.Request) (map[string]interface{}, *errorResponse) {
+	timezone := utils.GetJSTTimezone()
+	now := time.Now()
+	from := time.Date(now.In(timezone).Year(), now.In(timezone).Month(), now.In(timezone).Day(), 0, 0, 0, 0, timezone)
+	if raw := r.URL.Query().Get("date"); raw != "" {
+		parsed, err := time.ParseInLocation(time.DateOnly, raw, timezone)
+		if err != nil {
+			return nil, newCodedErrorResponse(http.StatusBadRequest, "INVALID_REQUEST", "date must be YYYY-MM-DD", "invalid date", err)
+		}
+		from = parsed
+	}
+	to := from.AddDate(0, 0, 1)
+
+	tx, err := h.TransactionStore.Begin()
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get timeline", "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	captures, err := h.CaptureStore.GetCapturesInRange(tx, from, to)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get timeline", "failed to get captures", err)
+	}
+	analyses, err := h.CaptureAnalysisJobStore.GetLatestSucceededCaptureAnalysisJobsInRange(tx, from, to)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get timeline", "failed to get capture analyses", err)
+	}
+	sessions, err := h.TaskSessionStore.GetTaskSessionsInRange(tx, from, to)
+	if err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get timeline", "failed to get task sessions", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newCodedErrorResponse(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get timeline", "failed to commit transaction", err)
+	}
+
+	result := timeline.Build(from, to, now, captures, analyses, sessions)
+	blocks := make([]map[string]interface{}, 0, len(result.Blocks))
+	for _, b := range result.Blocks {
+		var task map[string]interface{}
+		if b.Task != nil {
+			task = map[string]interface{}{
+				"id":    b.Task.ID,
+				"title": b.Task.Title,
+			}
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"start":        b.Start.In(timezone).Format(time.RFC3339),
+			"end":          b.End.In(timezone).Format(time.RFC3339),
+			"duration_sec": int(b.Duration().Seconds()),
+			"category":     b.Category,
+			"summary":      b.Summary,
+			"task":         task,
+			"capture_ids":  b.CaptureIDs,
+		})
+	}
+	sessionResults := make([]map[string]interface{}, 0, len(result.Sessions))
+	for _, s := range result.Sessions {
+		sessionResults = append(sessionResults, map[string]interface{}{
+			"id":           s.ID,
+			"task_id":      s.TaskID,
+			"task_title":   s.TaskTitle,
+			"start":        s.Start.In(timezone).Format(time.RFC3339),
+			"end":          s.End.In(timezone).Format(time.RFC3339),
+			"duration_sec": int(s.Duration().Seconds()),
+			"ongoing":      s.Ongoing,
+		})
+	}
+	totals := map[string]interface{}{}
+	for category, duration := range result.CategoryTotals {
+		totals[category] = int(duration.Seconds())
+	}
+	return map[string]interface{}{
+		"date":            from.Format(time.DateOnly),
+		"blocks":          blocks,
+		"sessions":        sessionResults,
+		"category_totals": totals,
+	}, nil
+}
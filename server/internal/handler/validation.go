@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ano333333/llm-time-manager/server/internal/utils"
+)
+
+// 入力値の問題。Fieldはスネークケースのフィールド名、Reasonは満たさなかったルール（validatorのタグ名など）
+type fieldProblem struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func validationErrorToProblems(err error) []fieldProblem {
+	targets := utils.GetValidationErrorTargets(err)
+	if len(targets) == 0 {
+		return []fieldProblem{{Field: "", Reason: "invalid"}}
+	}
+	problems := make([]fieldProblem, 0, len(targets))
+	for _, target := range targets {
+		problems = append(problems, fieldProblem{Field: target.Field, Reason: target.Tag})
+	}
+	return problems
+}
+
+// ログ用に問題の一覧をエラーにする
+func problemsToError(problems []fieldProblem) error {
+	messages := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		messages = append(messages, fmt.Sprintf("%s: %s", problem.Field, problem.Reason))
+	}
+	return fmt.Errorf("invalid fields: %s", strings.Join(messages, ", "))
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// LLMが応答に埋め込む提案の種類
+const (
+	EntityTypeTask = "task"
+	EntityTypeGoal = "goal"
+)
+
+// 1つのエンティティとして読む最大のバイト数。これを超えた候補は捨てる
+const entityMaxBytes = 16 * 1024
+
+// LLMの応答に埋め込まれた {"type":"task"|"goal", ...} のJSONオブジェクト
+type Entity struct {
+	Type string
+	// typeを含むオブジェクト全体
+	Raw json.RawMessage
+}
+
+// ストリームの断片から、応答に埋め込まれたエンティティを取り出す。
+//
+// 文章やコードブロックに混ざったJSONオブジェクトを、文字列リテラルを考慮して波括弧の対応で切り出す。
+// JSONとして読めないものやtypeがtask/goalでないものは、その内側から探し直す。
+type EntityScanner struct {
+	// 読んでいる途中のオブジェクトの候補（'{' から）
+	candidate []byte
+	depth     int
+	inString  bool
+	escaped   bool
+}
+
+// 応答の断片を読み、この断片で閉じたエンティティを返す
+func (s *EntityScanner) Write(text string) []Entity {
+	var entities []Entity
+	s.scan([]byte(text), &entities)
+	return entities
+}
+
+func (s *EntityScanner) scan(data []byte, entities *[]Entity) {
+	for _, c := range data {
+		if s.depth == 0 {
+			if c == '{' {
+				s.candidate = append(s.candidate[:0], c)
+				s.depth = 1
+			}
+			continue
+		}
+		s.candidate = append(s.candidate, c)
+		switch {
+		case s.escaped:
+			s.escaped = false
+		case s.inString:
+			if c == '\\' {
+				s.escaped = true
+			} else if c == '"' {
+				s.inString = false
+			}
+		case c == '"':
+			s.inString = true
+		case c == '{':
+			s.depth++
+		case c == '}':
+			s.depth--
+			if s.depth == 0 {
+				candidate := s.candidate
+				s.candidate = nil
+				if entity, ok := parseEntity(candidate); ok {
+					*entities = append(*entities, entity)
+				} else {
+					// {"items": [{"type": "task", ...}]} のように内側にエンティティがあることもある
+					s.scan(candidate[1:], entities)
+				}
+			}
+		}
+		if s.depth > 0 && len(s.candidate) > entityMaxBytes {
+			s.candidate = nil
+			s.depth = 0
+			s.inString = false
+			s.escaped = false
+		}
+	}
+}
+
+func parseEntity(candidate []byte) (Entity, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(candidate, &fields); err != nil {
+		return Entity{}, false
+	}
+	var entityType string
+	if err := json.Unmarshal(fields["type"], &entityType); err != nil {
+		return Entity{}, false
+	}
+	if entityType != EntityTypeTask && entityType != EntityTypeGoal {
+		return Entity{}, false
+	}
+	return Entity{Type: entityType, Raw: bytes.Clone(candidate)}, true
+}
@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntityScanner(t *testing.T) {
+	scan := func(chunks ...string) []string {
+		var scanner EntityScanner
+		results := []string{}
+		for _, chunk := range chunks {
+			for _, entity := range scanner.Write(chunk) {
+				results = append(results, entity.Type+" "+string(entity.Raw))
+			}
+		}
+		return results
+	}
+
+	t.Run("文章に埋め込まれたエンティティを断片をまたいで取り出す", func(t *testing.T) {
+		// Act
+		results := scan("次のタスクを提案します。\n```json\n{\"type\": \"ta", "sk\", \"title\": \"レポート提出\"}\n```\nまた、目標は", `{"type":"goal","title":"a"}`, "です。")
+
+		// Assert
+		assert.Equal(t, []string{
+			`task {"type": "task", "title": "レポート提出"}`,
+			`goal {"type":"goal","title":"a"}`,
+		}, results)
+	})
+
+	t.Run("文字列リテラル内の波括弧やエスケープを無視する", func(t *testing.T) {
+		// Act
+		results := scan(`{"type":"task","title":"a}b{\"c\"}","tags":[{"x":1}]}`)
+
+		// Assert
+		assert.Equal(t, []string{`task {"type":"task","title":"a}b{\"c\"}","tags":[{"x":1}]}`}, results)
+	})
+
+	t.Run("内側に埋め込まれたエンティティを取り出す", func(t *testing.T) {
+		// Act
+		results := scan(`{"entities": [{"type":"task","title":"a"}, {"type":"goal","title":"b"}]}`)
+
+		// Assert
+		assert.Equal(t, []string{`task {"type":"task","title":"a"}`, `goal {"type":"goal","title":"b"}`}, results)
+	})
+
+	t.Run("JSONとして読めないものや type が task/goal でないものは返さない", func(t *testing.T) {
+		// Act
+		results := scan(
+			`{type: "task", title: "a"}`,
+			`{"type":"note","title":"a"}`,
+			`{"title":"a"}`,
+			`{"type":"task","title":"a",}`,
+			`{"type":"task"`,
+		)
+
+		// Assert
+		assert.Equal(t, []string{}, results)
+	})
+
+	t.Run("閉じない波括弧が長く続いた場合は読み捨て、その後のエンティティを取り出す", func(t *testing.T) {
+		// Act
+		results := scan("{ は波括弧です。", strings.Repeat("あ", entityMaxBytes/3+1), `{"type":"task","title":"a"}`)
+
+		// Assert
+		assert.Equal(t, []string{`task {"type":"task","title":"a"}`}, results)
+	})
+}
@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FakeProviderが1回の呼び出しで返す応答
+type FakeResponse struct {
+	// 順に返す断片
+	Chunks []string `json:"chunks"`
+	// 空でない場合、Chunksを返した後にこのメッセージのエラーを返す
+	Error string `json:"error,omitempty"`
+	// Chunksを返した後に返すツールの呼び出し。ツールを渡さない呼び出しでは返さない
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// スクリプトどおりの応答を返すProvider。ネットワークを使わないテストや開発に使う。
+//
+// 呼び出しごとにResponsesを先頭から1つずつ返し、使い切った後は最後の応答を繰り返す。
+// Responsesが空の場合は、最後のユーザーのメッセージをそのまま返す。
+type FakeProvider struct {
+	Responses []FakeResponse
+
+	mu       sync.Mutex
+	next     int
+	requests [][]Message
+	tools    [][]Tool
+}
+
+// スクリプトのJSONファイル
+type fakeScript struct {
+	Responses []FakeResponse `json:"responses"`
+}
+
+// pathのスクリプト（{"responses": [{"chunks": [...], "error": "...", "tool_calls": [{"id": "...", "name": "...", "arguments": {...}}]}]}）を読み込んだFakeProviderを返す
+func LoadFakeProvider(path string) (*FakeProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fake llm script: %w", err)
+	}
+	var script fakeScript
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse fake llm script: %w", err)
+	}
+	return &FakeProvider{Responses: script.Responses}, nil
+}
+
+func (p *FakeProvider) Chat(ctx context.Context, messages []Message) (string, error) {
+	return collectChat(ctx, p, messages)
+}
+
+func (p *FakeProvider) StreamChat(ctx context.Context, messages []Message, onText func(text string) error) error {
+	_, err := p.StreamChatWithTools(ctx, messages, nil, onText)
+	return err
+}
+
+func (p *FakeProvider) StreamChatWithTools(ctx context.Context, messages []Message, tools []Tool, onText func(text string) error) ([]ToolCall, error) {
+	response := p.respond(messages, tools)
+	for _, chunk := range response.Chunks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := onText(chunk); err != nil {
+			return nil, err
+		}
+	}
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	}
+	if len(tools) == 0 {
+		return nil, nil
+	}
+	return fillToolCallIDs(append([]ToolCall{}, response.ToolCalls...)), nil
+}
+
+// これまでに受け取ったメッセージを呼び出し順に返す
+func (p *FakeProvider) Requests() [][]Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]Message{}, p.requests...)
+}
+
+// これまでに渡されたツールを呼び出し順に返す
+func (p *FakeProvider) Tools() [][]Tool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]Tool{}, p.tools...)
+}
+
+func (p *FakeProvider) respond(messages []Message, tools []Tool) FakeResponse {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, append([]Message{}, messages...))
+	p.tools = append(p.tools, tools)
+	if len(p.Responses) == 0 {
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].Role == RoleUser {
+				return FakeResponse{Chunks: []string{messages[i].Content}}
+			}
+		}
+		return FakeResponse{}
+	}
+	response := p.Responses[min(p.next, len(p.Responses)-1)]
+	p.next++
+	return response
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeProvider(t *testing.T) {
+	messages := []Message{{Role: RoleSystem, Content: "system"}, {Role: RoleUser, Content: "hi"}}
+
+	t.Run("スクリプトの応答を順に返し、使い切った後は最後の応答を繰り返す", func(t *testing.T) {
+		// Arrange
+		provider := &FakeProvider{Responses: []FakeResponse{
+			{Chunks: []string{"a", "b"}},
+			{Chunks: []string{"c"}},
+		}}
+
+		// Act
+		first, firstErr := provider.Chat(t.Context(), messages)
+		second, secondErr := provider.Chat(t.Context(), messages)
+		third, thirdErr := provider.Chat(t.Context(), messages)
+
+		// Assert
+		assert.NoError(t, firstErr)
+		assert.NoError(t, secondErr)
+		assert.NoError(t, thirdErr)
+		assert.Equal(t, []string{"ab", "c", "c"}, []string{first, second, third})
+		assert.Len(t, provider.Requests(), 3)
+		assert.Equal(t, messages, provider.Requests()[0])
+	})
+
+	t.Run("エラーの応答は断片を返した後にエラーを返す", func(t *testing.T) {
+		// Arrange
+		provider := &FakeProvider{Responses: []FakeResponse{{Chunks: []string{"a"}, Error: "boom"}}}
+		var texts []string
+
+		// Act
+		err := provider.StreamChat(t.Context(), messages, func(text string) error {
+			texts = append(texts, text)
+			return nil
+		})
+
+		// Assert
+		assert.EqualError(t, err, "boom")
+		assert.Equal(t, []string{"a"}, texts)
+	})
+
+	t.Run("ツールの呼び出しはツールを渡された場合のみ返す", func(t *testing.T) {
+		// Arrange
+		provider := &FakeProvider{Responses: []FakeResponse{{Chunks: []string{"a"}, ToolCalls: []ToolCall{{Name: "list_tasks"}}}}}
+		tools := []Tool{{Name: "list_tasks"}}
+		onText := func(string) error { return nil }
+
+		// Act
+		withTools, withToolsErr := provider.StreamChatWithTools(t.Context(), messages, tools, onText)
+		withoutTools, withoutToolsErr := provider.StreamChatWithTools(t.Context(), messages, nil, onText)
+
+		// Assert
+		assert.NoError(t, withToolsErr)
+		assert.NoError(t, withoutToolsErr)
+		if assert.Len(t, withTools, 1) {
+			assert.NotEmpty(t, withTools[0].ID)
+			assert.Equal(t, "list_tasks", withTools[0].Name)
+			assert.Equal(t, `{}`, string(withTools[0].Arguments))
+		}
+		assert.Empty(t, withoutTools)
+		assert.Equal(t, [][]Tool{tools, nil}, provider.Tools())
+	})
+
+	t.Run("スクリプトがない場合は最後のユーザーのメッセージを返す", func(t *testing.T) {
+		// Act
+		text, err := (&FakeProvider{}).Chat(t.Context(), messages)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "hi", text)
+	})
+
+	t.Run("NewProvider は fake_script_path のスクリプトを読み込む", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "script.json")
+		if err := os.WriteFile(path, []byte(`{"responses": [{"chunks": ["x", "y"]}]}`), 0o600); err != nil {
+			t.Fatalf("failed to write script: %v", err)
+		}
+
+		// Act
+		provider, err := NewProvider(ProviderConfig{Provider: ProviderFake, FakeScriptPath: path})
+
+		// Assert
+		if assert.NoError(t, err) {
+			text, err := provider.Chat(t.Context(), messages)
+			assert.NoError(t, err)
+			assert.Equal(t, "xy", text)
+		}
+	})
+
+	t.Run("NewProvider は未知のプロバイダにエラーを返す", func(t *testing.T) {
+		// Act
+		_, err := NewProvider(ProviderConfig{Provider: "unknown"})
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
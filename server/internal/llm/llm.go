@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// メッセージの送信者
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	// ツールの呼び出し結果
+	RoleTool = "tool"
+)
+
+// チャットの1メッセージ
+type Message struct {
+	// Role*のいずれか
+	Role    string
+	Content string
+	// メッセージに添付する画像（PNG/JPEG）のデータ
+	Images [][]byte
+	// アシスタントが呼び出したツール（RoleAssistantの場合）
+	ToolCalls []ToolCall
+	// 結果を返す呼び出しのIDとツールの名前（RoleToolの場合）
+	ToolCallID string
+	ToolName   string
+}
+
+// LLMが呼び出せるツール（関数）
+type Tool struct {
+	Name        string
+	Description string
+	// 引数のJSON Schema（type: object）
+	Parameters json.RawMessage
+}
+
+// LLMによるツールの呼び出し
+type ToolCall struct {
+	// 呼び出しのID。APIがIDを返さない場合は生成する
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// 引数のJSONオブジェクト
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// チャットの応答を生成するLLM
+type Provider interface {
+	// messagesに続くアシスタントの応答を生成して返す
+	Chat(ctx context.Context, messages []Message) (string, error)
+	// messagesに続くアシスタントの応答を生成し、断片を受け取るたびにonTextを呼ぶ。
+	//
+	// onTextがエラーを返すと生成を中断し、そのエラーを返す。ctxがキャンセルされた場合も中断する。
+	StreamChat(ctx context.Context, messages []Message, onText func(text string) error) error
+	// toolsを呼び出せる状態でStreamChatと同様に応答を生成し、応答に含まれるツールの呼び出しを返す。
+	//
+	// toolsが空の場合はツールを渡さない。
+	StreamChatWithTools(ctx context.Context, messages []Message, tools []Tool, onText func(text string) error) ([]ToolCall, error)
+}
+
+// StreamChatの断片をつなげて応答全体を返す
+func collectChat(ctx context.Context, provider Provider, messages []Message) (string, error) {
+	var builder strings.Builder
+	if err := provider.StreamChat(ctx, messages, func(text string) error {
+		builder.WriteString(text)
+		return nil
+	}); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// APIがIDを返さなかった呼び出しにIDを付け、引数をJSONとして扱える形にする
+func fillToolCallIDs(calls []ToolCall) []ToolCall {
+	for i := range calls {
+		if calls[i].ID == "" {
+			calls[i].ID = "call_" + uuid.NewString()
+		}
+		if len(calls[i].Arguments) == 0 {
+			calls[i].Arguments = json.RawMessage("{}")
+		} else if !json.Valid(calls[i].Arguments) {
+			// 壊れた引数もそのまま保存・送信できるよう、JSONの文字列にする
+			quoted, _ := json.Marshal(string(calls[i].Arguments))
+			calls[i].Arguments = quoted
+		}
+	}
+	return calls
+}
+
+// Ollama・OpenAI互換APIで共通のツールの定義
+type functionToolDefinition struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+func toFunctionToolDefinitions(tools []Tool) []functionToolDefinition {
+	definitions := make([]functionToolDefinition, 0, len(tools))
+	for _, tool := range tools {
+		definition := functionToolDefinition{Type: "function"}
+		definition.Function.Name = tool.Name
+		definition.Function.Description = tool.Description
+		definition.Function.Parameters = tool.Parameters
+		definitions = append(definitions, definition)
+	}
+	return definitions
+}
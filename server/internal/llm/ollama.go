@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Ollamaの /api/chat を使うProvider
+type OllamaProvider struct {
+	// OllamaのベースURL（例: http://localhost:11434）
+	Endpoint string
+	Model    string
+	// 1回の応答で生成するトークン数の上限。0の場合はOllamaの既定値
+	MaxTokens int
+	// 1回の応答の生成にかける時間の上限。0の場合は無制限
+	Timeout    time.Duration
+	HTTPClient *http.Client
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// base64エンコードした画像
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	// 結果を返したツールの名前（role: tool の場合）
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+// Ollamaのツールの呼び出し。IDはなく、引数はJSONオブジェクト
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaChatRequest struct {
+	Model    string                   `json:"model"`
+	Messages []ollamaMessage          `json:"messages"`
+	Tools    []functionToolDefinition `json:"tools,omitempty"`
+	Stream   bool                     `json:"stream"`
+	Options  map[string]any           `json:"options,omitempty"`
+}
+
+// ストリーム応答の1行
+type ollamaChatChunk struct {
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error"`
+}
+
+func (p *OllamaProvider) Chat(ctx context.Context, messages []Message) (string, error) {
+	return collectChat(ctx, p, messages)
+}
+
+func (p *OllamaProvider) StreamChat(ctx context.Context, messages []Message, onText func(text string) error) error {
+	_, err := p.StreamChatWithTools(ctx, messages, nil, onText)
+	return err
+}
+
+func (p *OllamaProvider) StreamChatWithTools(ctx context.Context, messages []Message, tools []Tool, onText func(text string) error) ([]ToolCall, error) {
+	ctx, cancel := withTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	request := ollamaChatRequest{
+		Model:    p.Model,
+		Messages: make([]ollamaMessage, 0, len(messages)),
+		Stream:   true,
+	}
+	if len(tools) > 0 {
+		request.Tools = toFunctionToolDefinitions(tools)
+	}
+	for _, message := range messages {
+		converted := ollamaMessage{Role: message.Role, Content: message.Content, ToolName: message.ToolName}
+		for _, image := range message.Images {
+			converted.Images = append(converted.Images, base64.StdEncoding.EncodeToString(image))
+		}
+		for _, call := range message.ToolCalls {
+			var toolCall ollamaToolCall
+			toolCall.Function.Name = call.Name
+			toolCall.Function.Arguments = call.Arguments
+			converted.ToolCalls = append(converted.ToolCalls, toolCall)
+		}
+		request.Messages = append(request.Messages, converted)
+	}
+	if p.MaxTokens > 0 {
+		request.Options = map[string]any{"num_predict": p.MaxTokens}
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(p.Endpoint, "/")+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := httpClientOrDefault(p.HTTPClient).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send chat request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama returned %d: %s", res.StatusCode, readErrorBody(res.Body))
+	}
+
+	// 応答は1行1チャンクのJSON。ツールの呼び出しは完成した形でいずれかのチャンクに含まれる
+	var calls []ToolCall
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChatChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode chat response: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("ollama returned error: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			if err := onText(chunk.Message.Content); err != nil {
+				return nil, err
+			}
+		}
+		for _, call := range chunk.Message.ToolCalls {
+			calls = append(calls, ToolCall{Name: call.Function.Name, Arguments: call.Function.Arguments})
+		}
+		if chunk.Done {
+			return fillToolCallIDs(calls), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		// 中断による読み込みエラーはctxのエラーとして返す
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to read chat response: %w", err)
+	}
+	return nil, errors.New("chat response ended before done")
+}
+
+func httpClientOrDefault(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return http.DefaultClient
+}
+
+// エラー応答の本文からメッセージを取り出す。
+//
+// Ollamaの {"error": "..."} とOpenAI互換APIの {"error": {"message": "..."}} に対応する。
+func readErrorBody(body io.Reader) string {
+	data, err := io.ReadAll(io.LimitReader(body, 4096))
+	if err != nil {
+		return err.Error()
+	}
+	var parsed struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(data, &parsed); err == nil && len(parsed.Error) > 0 {
+		var message string
+		if err := json.Unmarshal(parsed.Error, &message); err == nil && message != "" {
+			return message
+		}
+		var object struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(parsed.Error, &object); err == nil && object.Message != "" {
+			return object.Message
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOllamaProvider(t *testing.T) {
+	stream := func(t *testing.T, lines ...string) ([]string, error) {
+		t.Helper()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, line := range lines {
+				fmt.Fprintln(w, line)
+			}
+		}))
+		defer server.Close()
+		client := &OllamaProvider{Endpoint: server.URL + "/", Model: "test-model"}
+		var texts []string
+		err := client.StreamChat(t.Context(), []Message{{Role: RoleUser, Content: "hi"}}, func(text string) error {
+			texts = append(texts, text)
+			return nil
+		})
+		return texts, err
+	}
+
+	t.Run("done までの断片を順に返す", func(t *testing.T) {
+		// Act
+		texts, err := stream(t,
+			`{"message":{"role":"assistant","content":"こん"},"done":false}`,
+			``,
+			`{"message":{"role":"assistant","content":"にちは"},"done":false}`,
+			`{"message":{"role":"assistant","content":""},"done":true}`,
+		)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"こん", "にちは"}, texts)
+	})
+
+	t.Run("ストリーム中のエラーを返す", func(t *testing.T) {
+		// Act
+		texts, err := stream(t,
+			`{"message":{"role":"assistant","content":"こん"},"done":false}`,
+			`{"error":"model unloaded"}`,
+		)
+
+		// Assert
+		assert.ErrorContains(t, err, "model unloaded")
+		assert.Equal(t, []string{"こん"}, texts)
+	})
+
+	t.Run("done の前に応答が終わるとエラーを返す", func(t *testing.T) {
+		// Act
+		_, err := stream(t, `{"message":{"role":"assistant","content":"こん"},"done":false}`)
+
+		// Assert
+		assert.Error(t, err)
+	})
+
+	t.Run("画像は base64 の images として送る", func(t *testing.T) {
+		// Arrange
+		var request map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&request)
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":"画面"},"done":false}`)
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true}`)
+		}))
+		defer server.Close()
+		provider := &OllamaProvider{Endpoint: server.URL, Model: "test-model", MaxTokens: 100}
+
+		// Act
+		text, err := provider.Chat(t.Context(), []Message{{Role: RoleUser, Content: "describe", Images: [][]byte{[]byte("image")}}})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "画面", text)
+		assert.Equal(t, []any{map[string]any{"role": "user", "content": "describe", "images": []any{"aW1hZ2U="}}}, request["messages"])
+		assert.Equal(t, map[string]any{"num_predict": float64(100)}, request["options"])
+	})
+
+	t.Run("ツールを送り、応答に含まれるツールの呼び出しにIDを付けて返す", func(t *testing.T) {
+		// Arrange
+		var request map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&request)
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Tokyo"}}}]},"done":false}`)
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true}`)
+		}))
+		defer server.Close()
+		provider := &OllamaProvider{Endpoint: server.URL, Model: "test-model"}
+		weather := Tool{Name: "get_weather", Description: "Get weather", Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`)}
+		history := []Message{
+			{Role: RoleUser, Content: "weather?"},
+			{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_0", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Osaka"}`)}}},
+			{Role: RoleTool, Content: `{"weather":"sunny"}`, ToolCallID: "call_0", ToolName: "get_weather"},
+		}
+
+		// Act
+		calls, err := provider.StreamChatWithTools(t.Context(), history, []Tool{weather}, func(string) error { return nil })
+
+		// Assert
+		assert.NoError(t, err)
+		if assert.Len(t, calls, 1) {
+			assert.NotEmpty(t, calls[0].ID)
+			assert.Equal(t, "get_weather", calls[0].Name)
+			assert.JSONEq(t, `{"city":"Tokyo"}`, string(calls[0].Arguments))
+		}
+		assert.Equal(t, []any{map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name":        "get_weather",
+				"description": "Get weather",
+				"parameters":  map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+			},
+		}}, request["tools"])
+		assert.Equal(t, []any{
+			map[string]any{"role": "user", "content": "weather?"},
+			map[string]any{"role": "assistant", "content": "", "tool_calls": []any{map[string]any{"function": map[string]any{"name": "get_weather", "arguments": map[string]any{"city": "Osaka"}}}}},
+			map[string]any{"role": "tool", "content": `{"weather":"sunny"}`, "tool_name": "get_weather"},
+		}, request["messages"])
+	})
+}
@@ -0,0 +1,207 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAI互換の /v1/chat/completions を使うProvider
+type OpenAIProvider struct {
+	// APIのベースURL（/v1 を含まない。例: http://localhost:8000）
+	Endpoint string
+	Model    string
+	// 空の場合はAuthorizationヘッダを送らない
+	APIKey string
+	// 1回の応答で生成するトークン数の上限。0の場合はAPIの既定値
+	MaxTokens int
+	// 1回の応答の生成にかける時間の上限。0の場合は無制限
+	Timeout    time.Duration
+	HTTPClient *http.Client
+}
+
+// 画像を含むメッセージのcontentの要素
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAIMessage struct {
+	Role string `json:"role"`
+	// 画像がない場合は文字列、ある場合は[]openAIContentPart
+	Content    any              `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// OpenAI互換APIのツールの呼び出し。引数はJSONの文字列
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIChatRequest struct {
+	Model     string                   `json:"model"`
+	Messages  []openAIMessage          `json:"messages"`
+	Tools     []functionToolDefinition `json:"tools,omitempty"`
+	Stream    bool                     `json:"stream"`
+	MaxTokens int                      `json:"max_tokens,omitempty"`
+}
+
+// ストリーム応答の1イベント
+type openAIChatChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+			// 呼び出しはindexごとに分割して送られ、argumentsは断片をつなげる
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message) (string, error) {
+	return collectChat(ctx, p, messages)
+}
+
+func (p *OpenAIProvider) StreamChat(ctx context.Context, messages []Message, onText func(text string) error) error {
+	_, err := p.StreamChatWithTools(ctx, messages, nil, onText)
+	return err
+}
+
+func (p *OpenAIProvider) StreamChatWithTools(ctx context.Context, messages []Message, tools []Tool, onText func(text string) error) ([]ToolCall, error) {
+	ctx, cancel := withTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	request := openAIChatRequest{
+		Model:     p.Model,
+		Messages:  make([]openAIMessage, 0, len(messages)),
+		Stream:    true,
+		MaxTokens: p.MaxTokens,
+	}
+	if len(tools) > 0 {
+		request.Tools = toFunctionToolDefinitions(tools)
+	}
+	for _, message := range messages {
+		request.Messages = append(request.Messages, toOpenAIMessage(message))
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(p.Endpoint, "/")+"/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	res, err := httpClientOrDefault(p.HTTPClient).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send chat request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("openai api returned %d: %s", res.StatusCode, readErrorBody(res.Body))
+	}
+
+	// 応答はSSEで、data行が1チャンク。最後は data: [DONE]
+	var calls []ToolCall
+	var arguments []strings.Builder
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			for i := range calls {
+				calls[i].Arguments = json.RawMessage(arguments[i].String())
+			}
+			return fillToolCallIDs(calls), nil
+		}
+		var chunk openAIChatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode chat response: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("openai api returned error: %s", chunk.Error.Message)
+		}
+		for _, choice := range chunk.Choices {
+			for _, delta := range choice.Delta.ToolCalls {
+				for len(calls) <= delta.Index {
+					calls = append(calls, ToolCall{})
+					arguments = append(arguments, strings.Builder{})
+				}
+				if delta.ID != "" {
+					calls[delta.Index].ID = delta.ID
+				}
+				calls[delta.Index].Name += delta.Function.Name
+				arguments[delta.Index].WriteString(delta.Function.Arguments)
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+			if err := onText(choice.Delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		// 中断による読み込みエラーはctxのエラーとして返す
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to read chat response: %w", err)
+	}
+	return nil, errors.New("chat response ended before [DONE]")
+}
+
+// 画像はdata URLとしてcontentに含める
+func toOpenAIMessage(message Message) openAIMessage {
+	if len(message.Images) == 0 {
+		converted := openAIMessage{Role: message.Role, Content: message.Content, ToolCallID: message.ToolCallID}
+		for _, call := range message.ToolCalls {
+			toolCall := openAIToolCall{ID: call.ID, Type: "function"}
+			toolCall.Function.Name = call.Name
+			toolCall.Function.Arguments = string(call.Arguments)
+			converted.ToolCalls = append(converted.ToolCalls, toolCall)
+		}
+		return converted
+	}
+	parts := []openAIContentPart{{Type: "text", Text: message.Content}}
+	for _, image := range message.Images {
+		url := "data:" + http.DetectContentType(image) + ";base64," + base64.StdEncoding.EncodeToString(image)
+		parts = append(parts, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: url}})
+	}
+	return openAIMessage{Role: message.Role, Content: parts}
+}
@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAIProvider(t *testing.T) {
+	t.Run("/v1/chat/completions のストリームを [DONE] まで返す", func(t *testing.T) {
+		// Arrange
+		var authorization string
+		var request map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+			authorization = r.Header.Get("Authorization")
+			json.NewDecoder(r.Body).Decode(&request)
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n")
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"こん\"}}]}\n\n")
+			fmt.Fprint(w, ": keep-alive\n\n")
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"にちは\"}}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}))
+		defer server.Close()
+		provider := &OpenAIProvider{Endpoint: server.URL, Model: "test-model", APIKey: "secret", MaxTokens: 100}
+
+		// Act
+		text, err := provider.Chat(t.Context(), []Message{{Role: RoleUser, Content: "hi"}})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "こんにちは", text)
+		assert.Equal(t, "Bearer secret", authorization)
+		assert.Equal(t, "test-model", request["model"])
+		assert.Equal(t, true, request["stream"])
+		assert.Equal(t, float64(100), request["max_tokens"])
+		assert.Equal(t, []any{map[string]any{"role": "user", "content": "hi"}}, request["messages"])
+	})
+
+	t.Run("画像は data URL の content として送る", func(t *testing.T) {
+		// Arrange
+		var request map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&request)
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}))
+		defer server.Close()
+		provider := &OpenAIProvider{Endpoint: server.URL, Model: "test-model"}
+		png := []byte("\x89PNG\r\n\x1a\n")
+
+		// Act
+		err := provider.StreamChat(t.Context(), []Message{{Role: RoleUser, Content: "describe", Images: [][]byte{png}}}, func(string) error { return nil })
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []any{map[string]any{
+			"role": "user",
+			"content": []any{
+				map[string]any{"type": "text", "text": "describe"},
+				map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64,iVBORw0KGgo="}},
+			},
+		}}, request["messages"])
+	})
+
+	t.Run("エラー応答のメッセージを返す", func(t *testing.T) {
+		// Arrange
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"message":"invalid api key","type":"invalid_request_error"}}`)
+		}))
+		defer server.Close()
+		provider := &OpenAIProvider{Endpoint: server.URL, Model: "test-model"}
+
+		// Act
+		_, err := provider.Chat(t.Context(), []Message{{Role: RoleUser, Content: "hi"}})
+
+		// Assert
+		assert.ErrorContains(t, err, "invalid api key")
+	})
+
+	t.Run("断片に分かれたツールの呼び出しをつなげて返す", func(t *testing.T) {
+		// Arrange
+		var request map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&request)
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"function\":{\"name\":\"get_weather\",\"arguments\":\"{\\\"ci\"}}]}}]}\n\n")
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"ty\\\":\\\"Tokyo\\\"}\"}}]}}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}))
+		defer server.Close()
+		provider := &OpenAIProvider{Endpoint: server.URL, Model: "test-model"}
+		weather := Tool{Name: "get_weather", Description: "Get weather", Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`)}
+		history := []Message{
+			{Role: RoleUser, Content: "weather?"},
+			{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_0", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Osaka"}`)}}},
+			{Role: RoleTool, Content: `{"weather":"sunny"}`, ToolCallID: "call_0", ToolName: "get_weather"},
+		}
+
+		// Act
+		calls, err := provider.StreamChatWithTools(t.Context(), history, []Tool{weather}, func(string) error { return nil })
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []ToolCall{{ID: "call_1", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Tokyo"}`)}}, calls)
+		assert.Len(t, request["tools"], 1)
+		assert.Equal(t, []any{
+			map[string]any{"role": "user", "content": "weather?"},
+			map[string]any{"role": "assistant", "content": "", "tool_calls": []any{map[string]any{"id": "call_0", "type": "function", "function": map[string]any{"name": "get_weather", "arguments": `{"city":"Osaka"}`}}}},
+			map[string]any{"role": "tool", "content": `{"weather":"sunny"}`, "tool_call_id": "call_0"},
+		}, request["messages"])
+	})
+}
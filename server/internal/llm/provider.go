@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// プロバイダの種類
+const (
+	ProviderOllama = "ollama"
+	ProviderOpenAI = "openai"
+	ProviderFake   = "fake"
+)
+
+// プロバイダの設定
+type ProviderConfig struct {
+	// Provider*のいずれか
+	Provider string
+	// APIのベースURL
+	Endpoint string
+	Model    string
+	// OpenAI互換APIのAPIキー。空の場合は送らない
+	APIKey string
+	// 1回の応答で生成するトークン数の上限。0の場合はAPIの既定値
+	MaxTokens int
+	// 1回の応答の生成にかける時間の上限。0の場合は無制限
+	Timeout time.Duration
+	// fakeの応答スクリプト（JSON）のパス。空の場合は最後のユーザーのメッセージを返す
+	FakeScriptPath string
+}
+
+// 設定に応じたProviderを返す
+func NewProvider(cfg ProviderConfig) (Provider, error) {
+	switch cfg.Provider {
+	case ProviderOllama:
+		return &OllamaProvider{Endpoint: cfg.Endpoint, Model: cfg.Model, MaxTokens: cfg.MaxTokens, Timeout: cfg.Timeout}, nil
+	case ProviderOpenAI:
+		return &OpenAIProvider{Endpoint: cfg.Endpoint, Model: cfg.Model, APIKey: cfg.APIKey, MaxTokens: cfg.MaxTokens, Timeout: cfg.Timeout}, nil
+	case ProviderFake:
+		if cfg.FakeScriptPath == "" {
+			return &FakeProvider{}, nil
+		}
+		return LoadFakeProvider(cfg.FakeScriptPath)
+	default:
+		return nil, fmt.Errorf("unknown llm provider: %q", cfg.Provider)
+	}
+}
+
+// timeoutが正の場合、その時間でキャンセルされるctxを返す
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
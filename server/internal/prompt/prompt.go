@@ -0,0 +1,222 @@
+package prompt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+// プロンプトのセクション。優先度の高い順
+const (
+	SectionInstructions    = "instructions"
+	SectionDoingTask       = "doing_task"
+	SectionOverdueTasks    = "overdue_tasks"
+	SectionTodayTasks      = "today_tasks"
+	SectionGoals           = "goals"
+	SectionCaptureSchedule = "capture_schedule"
+)
+
+// システムプロンプトに含める現在の状況
+type Context struct {
+	// doingのタスク。なければnil
+	DoingTask *datamodel.Task
+	// 期日を過ぎた未完了のタスク（期日の古い順）
+	OverdueTasks []datamodel.Task
+	// 期日が今日の未完了のタスク
+	TodayTasks []datamodel.Task
+	// 進行中の目標
+	Goals []Goal
+	// 現在のキャプチャスケジュール。なければnil
+	CaptureSchedule *datamodel.CaptureSchedule
+}
+
+type Goal struct {
+	datamodel.Goal
+	// KPIの実績の合計。KPIがない目標はnil
+	KpiProgress *float64
+}
+
+// 組み立てたシステムプロンプト
+type Prompt struct {
+	Text string
+	// Textのトークン数の概算（セクションごとの概算の合計）
+	Tokens int
+	Budget int
+	// セクションごとの内訳（優先度の高い順）
+	Sections []Section
+	// 指示の描画に使ったテンプレートのバージョン（テンプレートの名前→バージョン）
+	TemplateVersions map[string]int
+}
+
+type Section struct {
+	Name string
+	// 含めた項目の数
+	Included int
+	// 予算に収まらず省いた項目の数
+	Omitted int
+	// セクションのトークン数の概算
+	Tokens int
+}
+
+type section struct {
+	name  string
+	title string
+	items []string
+}
+
+// 指示（chatテンプレートを描画したもの）とcの状況からシステムプロンプトを組み立てる。
+//
+// 指示は常に含め、残りのセクションは優先度の高い順に、トークン数の概算がbudgetに収まる分の項目を含める。
+// 収まらない項目は省き、省いた件数を書く。最初の項目も収まらないセクションは見出しごと省く。
+func Build(c Context, instructions string, budget int) Prompt {
+	var text strings.Builder
+	text.WriteString(instructions)
+	used := EstimateTokens(instructions)
+	result := Prompt{Budget: budget, Sections: []Section{{Name: SectionInstructions, Tokens: used}}}
+
+	for _, s := range sections(c) {
+		summary := Section{Name: s.name}
+		header := "\n## " + s.title + "\n"
+		lines := make([]string, 0, len(s.items))
+		for _, item := range s.items {
+			lines = append(lines, "- "+item+"\n")
+		}
+		empty := len(lines) == 0
+		if empty {
+			lines = append(lines, "- なし\n")
+		}
+		var body strings.Builder
+		body.WriteString(header)
+		for i, line := range lines {
+			candidate := body.String() + line
+			// 後ろに省く項目が残る場合は、省いた件数を書く分を空けておく
+			if i < len(lines)-1 {
+				candidate += omittedLine(len(lines) - i - 1)
+			}
+			if used+EstimateTokens(candidate) > budget {
+				break
+			}
+			body.WriteString(line)
+			summary.Included++
+		}
+		// 最初の項目も収まらない場合はセクションごと省く
+		if summary.Included == 0 {
+			summary.Omitted = len(s.items)
+			result.Sections = append(result.Sections, summary)
+			continue
+		}
+		if empty {
+			summary.Included = 0
+		}
+		if summary.Omitted = len(s.items) - summary.Included; summary.Omitted > 0 {
+			body.WriteString(omittedLine(summary.Omitted))
+		}
+		tokens := EstimateTokens(body.String())
+		text.WriteString(body.String())
+		used += tokens
+		summary.Tokens = tokens
+		result.Sections = append(result.Sections, summary)
+	}
+	result.Text = text.String()
+	result.Tokens = used
+	return result
+}
+
+// textのトークン数を概算する。
+//
+// トークナイザはモデルによって異なるため、ASCII文字は4文字で1トークン、それ以外の文字（日本語など）は1文字で1トークンとして数える。
+func EstimateTokens(text string) int {
+	ascii, others := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			others++
+		}
+	}
+	return (ascii+3)/4 + others
+}
+
+var weekdayNames = []string{"日", "月", "火", "水", "木", "金", "土"}
+
+func sections(c Context) []section {
+	doing := section{name: SectionDoingTask, title: "作業中のタスク"}
+	if c.DoingTask != nil {
+		doing.items = append(doing.items, taskLine(*c.DoingTask))
+	}
+	overdue := section{name: SectionOverdueTasks, title: "期日を過ぎたタスク"}
+	for _, task := range c.OverdueTasks {
+		overdue.items = append(overdue.items, taskLine(task))
+	}
+	today := section{name: SectionTodayTasks, title: "今日が期日のタスク"}
+	for _, task := range c.TodayTasks {
+		today.items = append(today.items, taskLine(task))
+	}
+	goals := section{name: SectionGoals, title: "進行中の目標"}
+	for _, goal := range c.Goals {
+		goals.items = append(goals.items, goalLine(goal))
+	}
+	schedule := section{name: SectionCaptureSchedule, title: "画面キャプチャのスケジュール"}
+	if c.CaptureSchedule != nil {
+		schedule.items = append(schedule.items, captureScheduleLine(*c.CaptureSchedule))
+	}
+	return []section{doing, overdue, today, goals, schedule}
+}
+
+func omittedLine(count int) string {
+	return fmt.Sprintf("- ほか%d件\n", count)
+}
+
+func taskLine(task datamodel.Task) string {
+	details := []string{"状態: " + task.Status}
+	if task.Due != nil {
+		details = append(details, "期日: "+task.Due.Format(time.DateOnly))
+	}
+	details = append(details, "優先度: "+strconv.Itoa(task.Priority))
+	if task.EstimateMin > 0 {
+		details = append(details, fmt.Sprintf("見積: %d分", task.EstimateMin))
+	}
+	if task.GoalID != nil {
+		details = append(details, "目標: "+*task.GoalID)
+	}
+	return fmt.Sprintf("[%s] %s（%s）", task.ID, task.Title, strings.Join(details, "、"))
+}
+
+func goalLine(goal Goal) string {
+	details := []string{"期間: " + goal.StartDate.Format(time.DateOnly) + "〜" + goal.EndDate.Format(time.DateOnly)}
+	if goal.KpiName != nil && goal.KpiTarget != nil && goal.KpiUnit != nil {
+		progress := 0.0
+		if goal.KpiProgress != nil {
+			progress = *goal.KpiProgress
+		}
+		details = append(details, fmt.Sprintf("KPI: %s %s/%s%s", *goal.KpiName, formatNumber(progress), formatNumber(*goal.KpiTarget), *goal.KpiUnit))
+	}
+	return fmt.Sprintf("[%s] %s（%s）", goal.ID, goal.Title, strings.Join(details, "、"))
+}
+
+func captureScheduleLine(schedule datamodel.CaptureSchedule) string {
+	windows := []string{}
+	for _, window := range schedule.TimeWindows {
+		days := "毎日"
+		if len(window.Weekdays) > 0 {
+			var names strings.Builder
+			for _, weekday := range window.Weekdays {
+				names.WriteString(weekdayNames[weekday])
+			}
+			days = names.String()
+		}
+		windows = append(windows, fmt.Sprintf("%s %s〜%s", days, window.Start, window.End))
+	}
+	if len(windows) == 0 {
+		windows = append(windows, "終日")
+	}
+	return fmt.Sprintf("状態: %s、間隔: %d分、時間帯: %s", schedule.State, schedule.IntervalMin, strings.Join(windows, "、"))
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,107 @@
+package prompt
+
+import (
+	"testing"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild(t *testing.T) {
+	jst := time.FixedZone("Asia/Tokyo", 9*60*60)
+	instructions := "あなたはアシスタントです。\n"
+	date := func(month time.Month, day int) *time.Time {
+		d := time.Date(2025, month, day, 0, 0, 0, 0, jst)
+		return &d
+	}
+	ptr := func(s string) *string { return &s }
+	ptrFloat := func(f float64) *float64 { return &f }
+	overdue := func(id string, title string) datamodel.Task {
+		return datamodel.Task{ID: id, Title: title, Status: datamodel.TaskStatusTodo, Due: date(11, 15), Priority: 1}
+	}
+
+	t.Run("予算に収まる場合は全てのセクションを優先度の順に含める", func(t *testing.T) {
+		// Arrange
+		c := Context{
+			DoingTask:    &datamodel.Task{ID: "t1", Title: "設計書を書く", Status: datamodel.TaskStatusDoing, Due: date(11, 17), Priority: 2, EstimateMin: 60},
+			OverdueTasks: []datamodel.Task{{ID: "t2", Title: "経費精算", Status: datamodel.TaskStatusTodo, Due: date(11, 15), Priority: 1, GoalID: ptr("g1")}},
+			Goals: []Goal{{
+				Goal:        datamodel.Goal{ID: "g1", Title: "資格を取る", StartDate: *date(11, 1), EndDate: *date(12, 31), KpiName: ptr("勉強時間"), KpiTarget: ptrFloat(40), KpiUnit: ptr("時間")},
+				KpiProgress: ptrFloat(12.5),
+			}},
+			CaptureSchedule: &datamodel.CaptureSchedule{
+				State:       "active",
+				IntervalMin: 15,
+				TimeWindows: []datamodel.CaptureTimeWindow{{Weekdays: []int{1, 2, 3, 4, 5}, Start: "09:00", End: "18:00"}},
+			},
+		}
+
+		// Act
+		built := Build(c, instructions, 10000)
+
+		// Assert
+		assert.Equal(t, instructions+
+			"\n## 作業中のタスク\n- [t1] 設計書を書く（状態: doing、期日: 2025-11-17、優先度: 2、見積: 60分）\n"+
+			"\n## 期日を過ぎたタスク\n- [t2] 経費精算（状態: todo、期日: 2025-11-15、優先度: 1、目標: g1）\n"+
+			"\n## 今日が期日のタスク\n- なし\n"+
+			"\n## 進行中の目標\n- [g1] 資格を取る（期間: 2025-11-01〜2025-12-31、KPI: 勉強時間 12.5/40時間）\n"+
+			"\n## 画面キャプチャのスケジュール\n- 状態: active、間隔: 15分、時間帯: 月火水木金 09:00〜18:00\n", built.Text)
+		assert.Equal(t, 10000, built.Budget)
+		names := []string{}
+		tokens := 0
+		for _, section := range built.Sections {
+			names = append(names, section.Name)
+			tokens += section.Tokens
+			assert.Zero(t, section.Omitted, section.Name)
+		}
+		assert.Equal(t, tokens, built.Tokens)
+		assert.Equal(t, []string{SectionInstructions, SectionDoingTask, SectionOverdueTasks, SectionTodayTasks, SectionGoals, SectionCaptureSchedule}, names)
+		assert.Equal(t, 0, built.Sections[3].Included)
+	})
+
+	t.Run("予算に収まらない項目は省いて件数を書き、最初の項目も収まらないセクションは見出しごと省く", func(t *testing.T) {
+		// Arrange
+		c := Context{
+			OverdueTasks: []datamodel.Task{overdue("t1", "一つ目"), overdue("t2", "二つ目"), overdue("t3", "三つ目")},
+			Goals:        []Goal{{Goal: datamodel.Goal{ID: "g1", Title: "目標", StartDate: *date(11, 1), EndDate: *date(12, 31)}}},
+		}
+		doing := "\n## 作業中のタスク\n- なし\n"
+		overdueSection := "\n## 期日を過ぎたタスク\n- " + taskLine(c.OverdueTasks[0]) + "\n- ほか2件\n"
+		budget := EstimateTokens(instructions) + EstimateTokens(doing) + EstimateTokens(overdueSection)
+
+		// Act
+		built := Build(c, instructions, budget)
+
+		// Assert
+		assert.Equal(t, instructions+doing+overdueSection, built.Text)
+		assert.LessOrEqual(t, built.Tokens, budget)
+		assert.Equal(t, []Section{
+			{Name: SectionInstructions, Tokens: EstimateTokens(instructions)},
+			{Name: SectionDoingTask, Tokens: EstimateTokens(doing)},
+			{Name: SectionOverdueTasks, Included: 1, Omitted: 2, Tokens: EstimateTokens(overdueSection)},
+			{Name: SectionTodayTasks},
+			{Name: SectionGoals, Omitted: 1},
+			{Name: SectionCaptureSchedule},
+		}, built.Sections)
+	})
+
+	t.Run("予算が指示より小さい場合も指示は含める", func(t *testing.T) {
+		// Act
+		built := Build(Context{OverdueTasks: []datamodel.Task{overdue("t1", "一つ目")}}, instructions, 1)
+
+		// Assert
+		assert.Equal(t, instructions, built.Text)
+		assert.Greater(t, built.Tokens, built.Budget)
+		assert.Equal(t, 1, built.Sections[2].Omitted)
+		assert.NotContains(t, built.Text, "## ")
+	})
+}
+
+func TestEstimateTokens(t *testing.T) {
+	assert.Equal(t, 0, EstimateTokens(""))
+	assert.Equal(t, 1, EstimateTokens("abcd"))
+	assert.Equal(t, 2, EstimateTokens("abcde"))
+	assert.Equal(t, 3, EstimateTokens("日本語"))
+	assert.Equal(t, 3, EstimateTokens("ab日本"))
+}
@@ -0,0 +1,94 @@
+package prompt
+
+import (
+	"strings"
+
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+)
+
+// 要約をLLMに送るメッセージの見出し
+const summaryHeader = "これまでの会話の要約:\n"
+
+const summaryInstructions = "あなたは会話を要約するアシスタントです。\n" +
+	"これまでの要約と会話を、後で会話を続けるために必要な情報を残して日本語で簡潔にまとめてください。\n" +
+	"ユーザーの目的・決まったこと・作成や変更したタスクと目標（IDを含む）・未解決のことは必ず残してください。\n" +
+	"要約の本文だけを出力してください。"
+
+// messageのトークン数を概算する。ツールの呼び出しの引数も数える
+func EstimateMessageTokens(message llm.Message) int {
+	tokens := EstimateTokens(message.Content)
+	for _, call := range message.ToolCalls {
+		tokens += EstimateTokens(call.Name) + EstimateTokens(string(call.Arguments))
+	}
+	return tokens
+}
+
+// messagesのトークン数の概算がthresholdを超える場合、先頭から要約するメッセージの数を返す。要約しない場合は0を返す。
+//
+// 新しいメッセージをthresholdの半分に収まるだけ残す。ツールの呼び出しと結果を分けないよう、残す部分はユーザーのメッセージから始め、
+// 最後のユーザーのメッセージ以降は収まらなくても残す。
+func SummaryCut(messages []llm.Message, threshold int) int {
+	if threshold <= 0 {
+		return 0
+	}
+	total := 0
+	for _, message := range messages {
+		total += EstimateMessageTokens(message)
+	}
+	if total <= threshold {
+		return 0
+	}
+	cut, found, kept := 0, false, 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		kept += EstimateMessageTokens(messages[i])
+		if messages[i].Role != llm.RoleUser {
+			continue
+		}
+		if found && kept > threshold/2 {
+			break
+		}
+		cut, found = i, true
+	}
+	return cut
+}
+
+// previous（以前の要約。なければ空）とmessagesを要約させるメッセージを返す
+func SummaryRequest(previous string, messages []llm.Message) []llm.Message {
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString("## これまでの要約\n" + previous + "\n\n")
+	}
+	transcript.WriteString("## 会話\n")
+	for _, message := range messages {
+		transcript.WriteString(transcriptLine(message))
+	}
+	return []llm.Message{
+		{Role: llm.RoleSystem, Content: summaryInstructions},
+		{Role: llm.RoleUser, Content: transcript.String()},
+	}
+}
+
+// 保存した要約を、要約したメッセージの代わりにLLMに送るメッセージにする
+func SummaryMessage(content string) llm.Message {
+	return llm.Message{Role: llm.RoleSystem, Content: summaryHeader + content}
+}
+
+func transcriptLine(message llm.Message) string {
+	var line strings.Builder
+	switch message.Role {
+	case llm.RoleUser:
+		line.WriteString("ユーザー: ")
+	case llm.RoleAssistant:
+		line.WriteString("アシスタント: ")
+	case llm.RoleTool:
+		line.WriteString("ツールの結果（" + message.ToolName + "）: ")
+	default:
+		line.WriteString("システム: ")
+	}
+	line.WriteString(message.Content)
+	for _, call := range message.ToolCalls {
+		line.WriteString("［ツールの呼び出し: " + call.Name + " " + string(call.Arguments) + "］")
+	}
+	line.WriteString("\n")
+	return line.String()
+}
@@ -0,0 +1,93 @@
+package prompt
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ano333333/llm-time-manager/server/internal/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummaryCut(t *testing.T) {
+	// 日本語1文字が1トークン
+	user := func(content string) llm.Message { return llm.Message{Role: llm.RoleUser, Content: content} }
+	assistant := func(content string) llm.Message { return llm.Message{Role: llm.RoleAssistant, Content: content} }
+
+	t.Run("閾値以下の場合は要約しない", func(t *testing.T) {
+		// Arrange
+		messages := []llm.Message{user("一二三四五"), assistant("一二三四五")}
+
+		// Act & Assert
+		assert.Equal(t, 0, SummaryCut(messages, 10))
+	})
+
+	t.Run("閾値の半分に収まる新しいメッセージを、ユーザーのメッセージから残す", func(t *testing.T) {
+		// Arrange
+		messages := []llm.Message{
+			user("一二三四五"), assistant("一二三四五"),
+			user("一二"), assistant("一二"),
+			user("一二"), assistant("一二"),
+		}
+
+		// Act
+		cut := SummaryCut(messages, 16)
+
+		// Assert
+		assert.Equal(t, 2, cut)
+	})
+
+	t.Run("ツールの呼び出しと結果は分けない", func(t *testing.T) {
+		// Arrange
+		messages := []llm.Message{
+			user("一二三四五六七八九十"),
+			user("一二"),
+			{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "c1", Name: "list_tasks", Arguments: json.RawMessage(`{}`)}}},
+			{Role: llm.RoleTool, Content: "一二三四五六", ToolCallID: "c1", ToolName: "list_tasks"},
+			assistant("一二"),
+		}
+
+		// Act
+		cut := SummaryCut(messages, 16)
+
+		// Assert
+		assert.Equal(t, 1, cut)
+	})
+
+	t.Run("最後のユーザーのメッセージ以降は閾値を超えても残す", func(t *testing.T) {
+		// Arrange
+		messages := []llm.Message{user("一二"), assistant("一二"), user("一二三四五六七八九十"), assistant("一二三四五六七八九十")}
+
+		// Act & Assert
+		assert.Equal(t, 2, SummaryCut(messages, 10))
+		assert.Equal(t, 0, SummaryCut(messages[2:], 10))
+	})
+
+	t.Run("閾値が0の場合は要約しない", func(t *testing.T) {
+		assert.Equal(t, 0, SummaryCut([]llm.Message{user("一二"), user("一二")}, 0))
+	})
+}
+
+func TestSummaryRequest(t *testing.T) {
+	// Arrange
+	messages := []llm.Message{
+		{Role: llm.RoleUser, Content: "タスクを見せて"},
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "c1", Name: "list_tasks", Arguments: json.RawMessage(`{"status":"todo"}`)}}},
+		{Role: llm.RoleTool, Content: `{"tasks":[]}`, ToolCallID: "c1", ToolName: "list_tasks"},
+		{Role: llm.RoleAssistant, Content: "タスクはありません"},
+	}
+
+	// Act
+	request := SummaryRequest("以前の要約", messages)
+
+	// Assert
+	if assert.Len(t, request, 2) {
+		assert.Equal(t, llm.RoleSystem, request[0].Role)
+		assert.Equal(t, llm.Message{Role: llm.RoleUser, Content: "## これまでの要約\n以前の要約\n\n## 会話\n" +
+			"ユーザー: タスクを見せて\n" +
+			"アシスタント: ［ツールの呼び出し: list_tasks {\"status\":\"todo\"}］\n" +
+			"ツールの結果（list_tasks）: {\"tasks\":[]}\n" +
+			"アシスタント: タスクはありません\n"}, request[1])
+	}
+	assert.Equal(t, "## 会話\nユーザー: タスクを見せて\n", SummaryRequest("", messages[:1])[1].Content)
+	assert.Equal(t, llm.Message{Role: llm.RoleSystem, Content: "これまでの会話の要約:\n要約"}, SummaryMessage("要約"))
+}
@@ -0,0 +1,139 @@
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+// プロンプトのテンプレートの名前
+const (
+	// チャットのシステムプロンプトの指示
+	TemplateChat = "chat"
+	// 応答にタスク・目標を埋め込ませる指示。chatテンプレートに埋め込む
+	TemplateExtraction = "extraction"
+	// スクリーンショットの解析
+	TemplateScreenshotAnalysis = "screenshot_analysis"
+	// 振り返りのレポート
+	TemplateReport = "report"
+)
+
+var TemplateNames = []string{TemplateChat, TemplateExtraction, TemplateScreenshotAnalysis, TemplateReport}
+
+var ErrUnknownTemplate = errors.New("unknown prompt template")
+
+// chatテンプレートのデータ
+type ChatData struct {
+	// 現在日時（JST）
+	Now time.Time
+	// Nowの曜日（"日"〜"土"）
+	Weekday string
+	// extractionテンプレートを描画したもの
+	Extraction string
+}
+
+// extractionテンプレートのデータ
+type ExtractionData struct {
+	// 今日の日付（JST）
+	Today time.Time
+}
+
+// screenshot_analysisテンプレートのデータ
+type ScreenshotAnalysisData struct {
+	// 撮影日時（JST）
+	CapturedAt time.Time
+	Width      int
+	Height     int
+	// 選べる活動カテゴリ（datamodel.ActivityCategories）
+	Categories []string
+	// 撮影時に作業中だったタスクのタイトル。なければ空
+	DoingTask string
+}
+
+// reportテンプレートのデータ
+type ReportData struct {
+	// 期間の開始日と終了日（JST）
+	From time.Time
+	To   time.Time
+	// 活動カテゴリごとの時間（分）
+	CategoryMinutes map[string]int
+	// 期間中に完了したタスク
+	CompletedTasks []datamodel.Task
+}
+
+func IsTemplateName(name string) bool {
+	return slices.Contains(TemplateNames, name)
+}
+
+// nowのchatテンプレートのデータを返す
+func NewChatData(now time.Time, extraction string) ChatData {
+	return ChatData{Now: now, Weekday: weekdayNames[now.Weekday()], Extraction: extraction}
+}
+
+func RenderChat(body string, data ChatData) (string, error) {
+	return render(TemplateChat, body, data)
+}
+
+func RenderExtraction(body string, data ExtractionData) (string, error) {
+	return render(TemplateExtraction, body, data)
+}
+
+func RenderScreenshotAnalysis(body string, data ScreenshotAnalysisData) (string, error) {
+	return render(TemplateScreenshotAnalysis, body, data)
+}
+
+func RenderReport(body string, data ReportData) (string, error) {
+	return render(TemplateReport, body, data)
+}
+
+// bodyをnameのテンプレートとして検証する。
+//
+// 構文に加えて、nameのデータの型のサンプルで描画できること（存在しないフィールドを参照していないこと）を確かめる。
+func ValidateTemplate(name string, body string) error {
+	sample, ok := templateSamples()[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	_, err := render(name, body, sample)
+	return err
+}
+
+func render(name string, body string, data any) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	if err := tmpl.Execute(&text, data); err != nil {
+		return "", err
+	}
+	return text.String(), nil
+}
+
+// 検証に使う、テンプレートごとのデータのサンプル
+func templateSamples() map[string]any {
+	now := time.Date(2025, 11, 17, 10, 30, 0, 0, time.FixedZone("Asia/Tokyo", 9*60*60))
+	due := now.AddDate(0, 0, -1)
+	return map[string]any{
+		TemplateChat:       NewChatData(now, "extraction"),
+		TemplateExtraction: ExtractionData{Today: now},
+		TemplateScreenshotAnalysis: ScreenshotAnalysisData{
+			CapturedAt: now,
+			Width:      1920,
+			Height:     1080,
+			Categories: datamodel.ActivityCategories,
+			DoingTask:  "設計書を書く",
+		},
+		TemplateReport: ReportData{
+			From:            now.AddDate(0, 0, -7),
+			To:              now,
+			CategoryMinutes: map[string]int{datamodel.ActivityCategoryWork: 120},
+			CompletedTasks:  []datamodel.Task{{ID: "task-1", Title: "設計書を書く", Status: datamodel.TaskStatusDone, Due: &due}},
+		},
+	}
+}
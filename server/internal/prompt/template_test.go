@@ -0,0 +1,47 @@
+package prompt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderChat(t *testing.T) {
+	// Arrange
+	now := time.Date(2025, 11, 17, 10, 30, 0, 0, time.FixedZone("Asia/Tokyo", 9*60*60))
+	extraction, err := RenderExtraction(`今日は {{.Today.Format "2006-01-02"}} です。`, ExtractionData{Today: now})
+	assert.NoError(t, err)
+
+	// Act
+	text, err := RenderChat(`現在日時: {{.Now.Format "2006-01-02 15:04"}}（{{.Weekday}}）{{"\n"}}{{.Extraction}}`, NewChatData(now, extraction))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "現在日時: 2025-11-17 10:30（月）\n今日は 2025-11-17 です。", text)
+}
+
+func TestValidateTemplate(t *testing.T) {
+	t.Run("データの型のフィールドを参照するテンプレートは検証に通る", func(t *testing.T) {
+		assert.NoError(t, ValidateTemplate(TemplateChat, "{{.Now.Format \"15:04\"}}{{.Weekday}}{{.Extraction}}"))
+		assert.NoError(t, ValidateTemplate(TemplateExtraction, "{{.Today.Format \"2006-01-02\"}}"))
+		assert.NoError(t, ValidateTemplate(TemplateScreenshotAnalysis, "{{range .Categories}}- {{.}}\n{{end}}{{if .DoingTask}}{{.DoingTask}}{{end}}{{.Width}}x{{.Height}}"))
+		assert.NoError(t, ValidateTemplate(TemplateReport, "{{range $category, $minutes := .CategoryMinutes}}{{$category}}: {{$minutes}}\n{{end}}{{range .CompletedTasks}}{{.Title}}{{end}}"))
+	})
+
+	t.Run("構文の誤りはエラーになる", func(t *testing.T) {
+		assert.Error(t, ValidateTemplate(TemplateChat, "{{.Now"))
+		assert.Error(t, ValidateTemplate(TemplateChat, "{{if .Weekday}}"))
+	})
+
+	t.Run("データの型にないフィールドを参照するとエラーになる", func(t *testing.T) {
+		assert.Error(t, ValidateTemplate(TemplateChat, "{{.Today}}"))
+		assert.Error(t, ValidateTemplate(TemplateExtraction, "{{.Now}}"))
+	})
+
+	t.Run("存在しないテンプレートの名前はエラーになる", func(t *testing.T) {
+		err := ValidateTemplate("unknown", "本文")
+		assert.True(t, errors.Is(err, ErrUnknownTemplate))
+	})
+}
@@ -0,0 +1,311 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+type CaptureStore interface {
+	// capturesテーブルにinsertし、作成されたCaptureを返す。
+	//
+	// IDは呼び出し側で採番しておくこと（保存先ファイル名に使用するため）。
+	CreateCapture(tx Transaction, capture datamodel.Capture) (datamodel.Capture, error)
+	// idのCaptureを返す。存在しない場合はnilを返す。
+	GetCapture(tx Transaction, id string) (*datamodel.Capture, error)
+	// サムネイルが未生成、またはresolution以外の解像度で生成されたCaptureを撮影日時の新しい順に最大limit件返す
+	GetCapturesWithStaleThumbnail(tx Transaction, resolution int, limit int) ([]datamodel.Capture, error)
+	// サムネイルのパスと生成時の解像度を更新する
+	UpdateCaptureThumbnail(tx Transaction, id string, thumbPath string, resolution int) error
+	// すべてのCaptureを撮影日時の新しい順に返す
+	GetAllCaptures(tx Transaction) ([]datamodel.Capture, error)
+	// idのCaptureとその解析ジョブを削除する。ファイルは削除しない。
+	DeleteCapture(tx Transaction, id string) error
+	// 撮影日時がat以前で最も新しいCaptureを返す。存在しない場合はnilを返す。
+	GetPreviousCapture(tx Transaction, at time.Time) (*datamodel.Capture, error)
+	// 保存せずに捨てた重複キャプチャをidのCaptureに記録する
+	RecordDuplicateCapture(tx Transaction, id string, capturedAt time.Time) (datamodel.Capture, error)
+	// 撮影日時がfrom以降to未満のCaptureを撮影日時の古い順に返す
+	GetCapturesInRange(tx Transaction, from time.Time, to time.Time) ([]datamodel.Capture, error)
+	// filterに一致するCaptureを撮影日時の新しい順に最大limit件返す
+	GetCaptures(tx Transaction, filter CaptureFilter, limit int, offset int) ([]datamodel.Capture, error)
+	// 紐付けるタスクと目標を更新する。nilの場合は紐付けを外す。
+	UpdateCaptureLinks(tx Transaction, id string, linkedTaskID *string, linkedGoalID *string) (datamodel.Capture, error)
+	// 撮影したディスプレイごとのキャプチャの件数と撮影期間を、最後に撮影した日時の新しい順に返す
+	GetCaptureDisplays(tx Transaction) ([]CaptureDisplaySummary, error)
+}
+
+type CaptureFilter struct {
+	// このタスクに紐付いたもの
+	LinkedTaskID *string
+	// この目標に紐付いたもの
+	LinkedGoalID *string
+	// このディスプレイで撮影したもの
+	DisplayID *string
+	// ディスプレイが不明なもの。DisplayIDと同時に指定しないこと
+	UnknownDisplay bool
+}
+
+// 撮影したディスプレイごとのキャプチャの集計
+type CaptureDisplaySummary struct {
+	// ディスプレイが不明なキャプチャの集計の場合nil
+	DisplayID       *string
+	CaptureCount    int
+	FirstCapturedAt time.Time
+	LastCapturedAt  time.Time
+}
+
+type DefaultCaptureStore struct {
+	DB *sql.DB
+}
+
+const captureColumns = "id, path, format, width, height, size_bytes, sha256, mode, captured_at, thumb_path, thumb_resolution, phash, duplicate_of, duplicate_count, last_duplicate_at, linked_task_id, linked_goal_id, display_id, created_at, updated_at"
+
+func (s *DefaultCaptureStore) CreateCapture(tx Transaction, capture datamodel.Capture) (datamodel.Capture, error) {
+	emptyModel := datamodel.Capture{}
+
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return emptyModel, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow(
+		`INSERT INTO captures
+		(id, path, format, width, height, size_bytes, sha256, mode, captured_at, thumb_path, thumb_resolution, phash, duplicate_of, linked_task_id, linked_goal_id, display_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING `+captureColumns+`;`,
+		capture.ID, capture.Path, capture.Format, capture.Width, capture.Height, capture.SizeBytes, capture.SHA256, capture.Mode, capture.CapturedAt.UTC(),
+		valueOrNil(capture.ThumbPath), valueOrNil(capture.ThumbResolution), valueOrNil(capture.PHash), valueOrNil(capture.DuplicateOf),
+		valueOrNil(capture.LinkedTaskID), valueOrNil(capture.LinkedGoalID), valueOrNil(capture.DisplayID),
+	)
+	created, err := scanCapture(row)
+	if err != nil {
+		return emptyModel, err
+	}
+	return created, nil
+}
+
+func (s *DefaultCaptureStore) GetCapture(tx Transaction, id string) (*datamodel.Capture, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow("SELECT "+captureColumns+" FROM captures WHERE id = ?", id)
+	capture, err := scanCapture(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &capture, nil
+}
+
+func (s *DefaultCaptureStore) GetCapturesWithStaleThumbnail(tx Transaction, resolution int, limit int) ([]datamodel.Capture, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	rows, err := defaultTx.Tx.Query(
+		"SELECT "+captureColumns+" FROM captures WHERE thumb_resolution IS NULL OR thumb_resolution != ? ORDER BY captured_at DESC LIMIT ?",
+		resolution,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanCaptures(rows)
+}
+
+func (s *DefaultCaptureStore) UpdateCaptureThumbnail(tx Transaction, id string, thumbPath string, resolution int) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	_, err := defaultTx.Tx.Exec("UPDATE captures SET thumb_path = ?, thumb_resolution = ? WHERE id = ?", thumbPath, resolution, id)
+	return err
+}
+
+func (s *DefaultCaptureStore) GetAllCaptures(tx Transaction) ([]datamodel.Capture, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	rows, err := defaultTx.Tx.Query("SELECT " + captureColumns + " FROM captures ORDER BY captured_at DESC, id ASC")
+	if err != nil {
+		return nil, err
+	}
+	return scanCaptures(rows)
+}
+
+func (s *DefaultCaptureStore) DeleteCapture(tx Transaction, id string) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	// 外部キー制約が無効な接続でも解析ジョブが残らないよう、先に削除する
+	if _, err := defaultTx.Tx.Exec("DELETE FROM capture_analysis_jobs WHERE capture_id = ?", id); err != nil {
+		return err
+	}
+	_, err := defaultTx.Tx.Exec("DELETE FROM captures WHERE id = ?", id)
+	return err
+}
+
+func (s *DefaultCaptureStore) GetPreviousCapture(tx Transaction, at time.Time) (*datamodel.Capture, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow("SELECT "+captureColumns+" FROM captures WHERE captured_at <= ? ORDER BY captured_at DESC, rowid DESC LIMIT 1", at.UTC())
+	capture, err := scanCapture(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &capture, nil
+}
+
+func (s *DefaultCaptureStore) RecordDuplicateCapture(tx Transaction, id string, capturedAt time.Time) (datamodel.Capture, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return datamodel.Capture{}, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow(
+		`UPDATE captures SET
+			duplicate_count = duplicate_count + 1,
+			last_duplicate_at = CASE WHEN last_duplicate_at IS NULL OR last_duplicate_at < ? THEN ? ELSE last_duplicate_at END
+		WHERE id = ?
+		RETURNING `+captureColumns,
+		capturedAt.UTC(),
+		capturedAt.UTC(),
+		id,
+	)
+	return scanCapture(row)
+}
+
+func (s *DefaultCaptureStore) GetCapturesInRange(tx Transaction, from time.Time, to time.Time) ([]datamodel.Capture, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	rows, err := defaultTx.Tx.Query(
+		"SELECT "+captureColumns+" FROM captures WHERE captured_at >= ? AND captured_at < ? ORDER BY captured_at ASC, rowid ASC",
+		from.UTC(),
+		to.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanCaptures(rows)
+}
+
+func (s *DefaultCaptureStore) GetCaptures(tx Transaction, filter CaptureFilter, limit int, offset int) ([]datamodel.Capture, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	conditions := []string{"1 = 1"}
+	args := []any{}
+	if filter.LinkedTaskID != nil {
+		conditions = append(conditions, "linked_task_id = ?")
+		args = append(args, *filter.LinkedTaskID)
+	}
+	if filter.LinkedGoalID != nil {
+		conditions = append(conditions, "linked_goal_id = ?")
+		args = append(args, *filter.LinkedGoalID)
+	}
+	if filter.DisplayID != nil {
+		conditions = append(conditions, "display_id = ?")
+		args = append(args, *filter.DisplayID)
+	}
+	if filter.UnknownDisplay {
+		conditions = append(conditions, "display_id IS NULL")
+	}
+	args = append(args, limit, offset)
+	rows, err := defaultTx.Tx.Query(
+		"SELECT "+captureColumns+" FROM captures WHERE "+strings.Join(conditions, " AND ")+" ORDER BY captured_at DESC, rowid DESC LIMIT ? OFFSET ?",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanCaptures(rows)
+}
+
+func (s *DefaultCaptureStore) UpdateCaptureLinks(tx Transaction, id string, linkedTaskID *string, linkedGoalID *string) (datamodel.Capture, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return datamodel.Capture{}, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow(
+		"UPDATE captures SET linked_task_id = ?, linked_goal_id = ? WHERE id = ? RETURNING "+captureColumns,
+		valueOrNil(linkedTaskID),
+		valueOrNil(linkedGoalID),
+		id,
+	)
+	return scanCapture(row)
+}
+
+func (s *DefaultCaptureStore) GetCaptureDisplays(tx Transaction) ([]CaptureDisplaySummary, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	// MIN/MAXの結果は文字列になるため、撮影日時は該当する行から取り出す
+	rows, err := defaultTx.Tx.Query(
+		`SELECT g.display_id, g.capture_count,
+			(SELECT c.captured_at FROM captures c WHERE c.display_id IS g.display_id ORDER BY c.captured_at ASC LIMIT 1),
+			(SELECT c.captured_at FROM captures c WHERE c.display_id IS g.display_id ORDER BY c.captured_at DESC LIMIT 1)
+		FROM (SELECT display_id, COUNT(*) AS capture_count, MAX(captured_at) AS last_captured_at FROM captures GROUP BY display_id) g
+		ORDER BY g.last_captured_at DESC, g.display_id ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []CaptureDisplaySummary{}
+	for rows.Next() {
+		var summary CaptureDisplaySummary
+		if err := rows.Scan(&summary.DisplayID, &summary.CaptureCount, &summary.FirstCapturedAt, &summary.LastCapturedAt); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// *sql.Rowと*sql.Rowsの共通インターフェース
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCapture(row rowScanner) (datamodel.Capture, error) {
+	var capture datamodel.Capture
+	err := row.Scan(&capture.ID, &capture.Path, &capture.Format, &capture.Width, &capture.Height, &capture.SizeBytes, &capture.SHA256, &capture.Mode, &capture.CapturedAt, &capture.ThumbPath, &capture.ThumbResolution, &capture.PHash, &capture.DuplicateOf, &capture.DuplicateCount, &capture.LastDuplicateAt, &capture.LinkedTaskID, &capture.LinkedGoalID, &capture.DisplayID, &capture.CreatedAt, &capture.UpdatedAt)
+	return capture, err
+}
+
+// rowsをすべて読み込んでCloseする
+func scanCaptures(rows *sql.Rows) ([]datamodel.Capture, error) {
+	defer rows.Close()
+	captures := []datamodel.Capture{}
+	for rows.Next() {
+		capture, err := scanCapture(rows)
+		if err != nil {
+			return nil, err
+		}
+		captures = append(captures, capture)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return captures, nil
+}
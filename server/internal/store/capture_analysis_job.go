@@ -0,0 +1,256 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+// CompleteCaptureAnalysisJobで記録する解析結果
+type CaptureAnalysisResult struct {
+	// 解析に使ったプロンプトのバージョン
+	PromptVersion int
+	Category      string
+	Summary       string
+	Suggestions   []string
+}
+
+type CaptureAnalysisJobStore interface {
+	// capture_analysis_jobsにinsertする。IDは呼び出し側で採番しておくこと。
+	CreateCaptureAnalysisJob(tx Transaction, job datamodel.CaptureAnalysisJob) (datamodel.CaptureAnalysisJob, error)
+	// captureIDの最新のジョブを返す。存在しない場合はnilを返す。
+	GetLatestCaptureAnalysisJob(tx Transaction, captureID string) (*datamodel.CaptureAnalysisJob, error)
+	// captureIDの最新の成功したジョブを返す。存在しない場合はnilを返す。
+	GetLatestSucceededCaptureAnalysisJob(tx Transaction, captureID string) (*datamodel.CaptureAnalysisJob, error)
+	// 撮影日時がfrom以降to未満のキャプチャそれぞれについて、最新の成功したジョブを返す（順不同）
+	GetLatestSucceededCaptureAnalysisJobsInRange(tx Transaction, from time.Time, to time.Time) ([]datamodel.CaptureAnalysisJob, error)
+	// now時点で実行できるqueuedのジョブのうち最も古いものをrunningにして返す。存在しない場合はnilを返す。
+	ClaimNextCaptureAnalysisJob(tx Transaction, now time.Time) (*datamodel.CaptureAnalysisJob, error)
+	// queuedのジョブのうち、最も早く実行できる日時を返す。存在しない場合はnilを返す。
+	GetNextCaptureAnalysisJobAt(tx Transaction) (*time.Time, error)
+	// runningのジョブをsucceededにし、結果を記録する
+	CompleteCaptureAnalysisJob(tx Transaction, id string, result CaptureAnalysisResult, now time.Time) error
+	// runningのジョブの失敗を記録する。retryAtがnilの場合はfailedに、そうでなければretryAtに再実行するqueuedに戻す。
+	FailCaptureAnalysisJob(tx Transaction, id string, errorMessage string, retryAt *time.Time, now time.Time) error
+	// runningのまま残っているジョブをqueuedに戻し、戻した件数を返す（前回終了時に実行中だったジョブの回収用）
+	RequeueRunningCaptureAnalysisJobs(tx Transaction, now time.Time) (int, error)
+}
+
+type DefaultCaptureAnalysisJobStore struct {
+	DB *sql.DB
+}
+
+const captureAnalysisJobColumns = "id, capture_id, status, prompt_version, attempts, max_attempts, next_attempt_at, category, summary, suggestions, error_message, started_at, finished_at, created_at, updated_at"
+
+func (s *DefaultCaptureAnalysisJobStore) CreateCaptureAnalysisJob(tx Transaction, job datamodel.CaptureAnalysisJob) (datamodel.CaptureAnalysisJob, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return datamodel.CaptureAnalysisJob{}, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow(
+		`INSERT INTO capture_analysis_jobs
+		(id, capture_id, status, prompt_version, attempts, max_attempts, next_attempt_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, 0, ?, ?, ?, ?)
+		RETURNING `+captureAnalysisJobColumns,
+		job.ID, job.CaptureID, datamodel.CaptureAnalysisJobStatusQueued, job.PromptVersion, job.MaxAttempts,
+		job.NextAttemptAt.UTC(), job.CreatedAt.UTC(), job.CreatedAt.UTC(),
+	)
+	return scanCaptureAnalysisJob(row)
+}
+
+func (s *DefaultCaptureAnalysisJobStore) GetLatestCaptureAnalysisJob(tx Transaction, captureID string) (*datamodel.CaptureAnalysisJob, error) {
+	return s.getLatest(tx, "capture_id = ?", captureID)
+}
+
+func (s *DefaultCaptureAnalysisJobStore) GetLatestSucceededCaptureAnalysisJob(tx Transaction, captureID string) (*datamodel.CaptureAnalysisJob, error) {
+	return s.getLatest(tx, "capture_id = ? AND status = ?", captureID, datamodel.CaptureAnalysisJobStatusSucceeded)
+}
+
+func (s *DefaultCaptureAnalysisJobStore) getLatest(tx Transaction, condition string, args ...any) (*datamodel.CaptureAnalysisJob, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	// 同一時刻に作成されたジョブはrowidで作成順を保つ
+	row := defaultTx.Tx.QueryRow(
+		"SELECT "+captureAnalysisJobColumns+" FROM capture_analysis_jobs WHERE "+condition+" ORDER BY created_at DESC, rowid DESC LIMIT 1",
+		args...,
+	)
+	job, err := scanCaptureAnalysisJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *DefaultCaptureAnalysisJobStore) GetLatestSucceededCaptureAnalysisJobsInRange(tx Transaction, from time.Time, to time.Time) ([]datamodel.CaptureAnalysisJob, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	rows, err := defaultTx.Tx.Query(
+		`SELECT `+captureAnalysisJobColumns+` FROM capture_analysis_jobs
+		WHERE status = ? AND capture_id IN (SELECT id FROM captures WHERE captured_at >= ? AND captured_at < ?)
+		ORDER BY created_at ASC, rowid ASC`,
+		datamodel.CaptureAnalysisJobStatusSucceeded, from.UTC(), to.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// 作成順に読み、キャプチャごとに後のジョブで上書きする
+	latest := map[string]datamodel.CaptureAnalysisJob{}
+	order := []string{}
+	for rows.Next() {
+		job, err := scanCaptureAnalysisJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := latest[job.CaptureID]; !ok {
+			order = append(order, job.CaptureID)
+		}
+		latest[job.CaptureID] = job
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	jobs := make([]datamodel.CaptureAnalysisJob, 0, len(order))
+	for _, captureID := range order {
+		jobs = append(jobs, latest[captureID])
+	}
+	return jobs, nil
+}
+
+func (s *DefaultCaptureAnalysisJobStore) ClaimNextCaptureAnalysisJob(tx Transaction, now time.Time) (*datamodel.CaptureAnalysisJob, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow(
+		`UPDATE capture_analysis_jobs
+		SET status = ?, attempts = attempts + 1, started_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM capture_analysis_jobs
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at ASC, rowid ASC LIMIT 1
+		)
+		RETURNING `+captureAnalysisJobColumns,
+		datamodel.CaptureAnalysisJobStatusRunning, now.UTC(), now.UTC(),
+		datamodel.CaptureAnalysisJobStatusQueued, now.UTC(),
+	)
+	job, err := scanCaptureAnalysisJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *DefaultCaptureAnalysisJobStore) GetNextCaptureAnalysisJobAt(tx Transaction) (*time.Time, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	var next time.Time
+	err := defaultTx.Tx.QueryRow(
+		"SELECT next_attempt_at FROM capture_analysis_jobs WHERE status = ? ORDER BY next_attempt_at ASC LIMIT 1",
+		datamodel.CaptureAnalysisJobStatusQueued,
+	).Scan(&next)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+func (s *DefaultCaptureAnalysisJobStore) CompleteCaptureAnalysisJob(tx Transaction, id string, result CaptureAnalysisResult, now time.Time) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	suggestions := result.Suggestions
+	if suggestions == nil {
+		suggestions = []string{}
+	}
+	suggestionsJSON, err := json.Marshal(suggestions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal suggestions: %w", err)
+	}
+	_, err = defaultTx.Tx.Exec(
+		"UPDATE capture_analysis_jobs SET status = ?, prompt_version = ?, category = ?, summary = ?, suggestions = ?, error_message = NULL, finished_at = ?, updated_at = ? WHERE id = ? AND status = ?",
+		datamodel.CaptureAnalysisJobStatusSucceeded, result.PromptVersion, result.Category, result.Summary, string(suggestionsJSON), now.UTC(), now.UTC(),
+		id, datamodel.CaptureAnalysisJobStatusRunning,
+	)
+	return err
+}
+
+func (s *DefaultCaptureAnalysisJobStore) FailCaptureAnalysisJob(tx Transaction, id string, errorMessage string, retryAt *time.Time, now time.Time) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	if retryAt != nil {
+		_, err := defaultTx.Tx.Exec(
+			"UPDATE capture_analysis_jobs SET status = ?, error_message = ?, next_attempt_at = ?, updated_at = ? WHERE id = ? AND status = ?",
+			datamodel.CaptureAnalysisJobStatusQueued, errorMessage, retryAt.UTC(), now.UTC(),
+			id, datamodel.CaptureAnalysisJobStatusRunning,
+		)
+		return err
+	}
+	_, err := defaultTx.Tx.Exec(
+		"UPDATE capture_analysis_jobs SET status = ?, error_message = ?, finished_at = ?, updated_at = ? WHERE id = ? AND status = ?",
+		datamodel.CaptureAnalysisJobStatusFailed, errorMessage, now.UTC(), now.UTC(),
+		id, datamodel.CaptureAnalysisJobStatusRunning,
+	)
+	return err
+}
+
+func (s *DefaultCaptureAnalysisJobStore) RequeueRunningCaptureAnalysisJobs(tx Transaction, now time.Time) (int, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return 0, errors.New("transaction is not DefaultTransaction")
+	}
+	result, err := defaultTx.Tx.Exec(
+		"UPDATE capture_analysis_jobs SET status = ?, next_attempt_at = ?, updated_at = ? WHERE status = ?",
+		datamodel.CaptureAnalysisJobStatusQueued, now.UTC(), now.UTC(), datamodel.CaptureAnalysisJobStatusRunning,
+	)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(affected), nil
+}
+
+func scanCaptureAnalysisJob(row rowScanner) (datamodel.CaptureAnalysisJob, error) {
+	var job datamodel.CaptureAnalysisJob
+	var suggestions sql.NullString
+	err := row.Scan(
+		&job.ID, &job.CaptureID, &job.Status, &job.PromptVersion, &job.Attempts, &job.MaxAttempts, &job.NextAttemptAt,
+		&job.Category, &job.Summary, &suggestions, &job.ErrorMessage, &job.StartedAt, &job.FinishedAt, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		return datamodel.CaptureAnalysisJob{}, err
+	}
+	job.Suggestions = []string{}
+	if suggestions.Valid {
+		if err := json.Unmarshal([]byte(suggestions.String), &job.Suggestions); err != nil {
+			return datamodel.CaptureAnalysisJob{}, fmt.Errorf("failed to unmarshal suggestions: %w", err)
+		}
+	}
+	return job, nil
+}
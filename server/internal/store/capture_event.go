@@ -0,0 +1,120 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+// GetCaptureEventsの絞り込み条件。nil・空の条件は絞り込まない。
+type CaptureEventFilter struct {
+	// この日時以降に発生したもの
+	From *time.Time
+	// この日時より前に発生したもの
+	To *time.Time
+	// いずれかの状態のもの
+	States []string
+}
+
+type CaptureEventStore interface {
+	// capture_eventsにinsertする。IDは呼び出し側で採番しておくこと。
+	CreateCaptureEvent(tx Transaction, event datamodel.CaptureEvent) error
+	// requestIDの要求の最新の状態遷移を返す。存在しない場合はnilを返す。
+	GetLatestCaptureEvent(tx Transaction, requestID string) (*datamodel.CaptureEvent, error)
+	// filterに一致する状態遷移を新しい順にoffset件目から最大limit件返す
+	GetCaptureEvents(tx Transaction, filter CaptureEventFilter, limit int, offset int) ([]datamodel.CaptureEvent, error)
+}
+
+type DefaultCaptureEventStore struct {
+	DB *sql.DB
+}
+
+const captureEventColumns = "id, request_id, state, mode, schedule_id, capture_id, error_code, error_message, occurred_at"
+
+func (s *DefaultCaptureEventStore) CreateCaptureEvent(tx Transaction, event datamodel.CaptureEvent) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	_, err := defaultTx.Tx.Exec(
+		"INSERT INTO capture_events ("+captureEventColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		event.ID, event.RequestID, event.State, event.Mode,
+		valueOrNil(event.ScheduleID), valueOrNil(event.CaptureID), valueOrNil(event.ErrorCode), valueOrNil(event.ErrorMessage),
+		event.OccurredAt.UTC(),
+	)
+	return err
+}
+
+func (s *DefaultCaptureEventStore) GetLatestCaptureEvent(tx Transaction, requestID string) (*datamodel.CaptureEvent, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow(
+		"SELECT "+captureEventColumns+" FROM capture_events WHERE request_id = ? ORDER BY occurred_at DESC, rowid DESC LIMIT 1",
+		requestID,
+	)
+	event, err := scanCaptureEvent(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (s *DefaultCaptureEventStore) GetCaptureEvents(tx Transaction, filter CaptureEventFilter, limit int, offset int) ([]datamodel.CaptureEvent, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	conditions := []string{"1 = 1"}
+	args := []any{}
+	if filter.From != nil {
+		conditions = append(conditions, "occurred_at >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "occurred_at < ?")
+		args = append(args, filter.To.UTC())
+	}
+	if len(filter.States) > 0 {
+		conditions = append(conditions, "state IN (?"+strings.Repeat(", ?", len(filter.States)-1)+")")
+		for _, state := range filter.States {
+			args = append(args, state)
+		}
+	}
+	args = append(args, limit, offset)
+	// 同一時刻の遷移はrowidで挿入順を保つ
+	rows, err := defaultTx.Tx.Query(
+		"SELECT "+captureEventColumns+" FROM capture_events WHERE "+strings.Join(conditions, " AND ")+" ORDER BY occurred_at DESC, rowid DESC LIMIT ? OFFSET ?",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []datamodel.CaptureEvent{}
+	for rows.Next() {
+		event, err := scanCaptureEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func scanCaptureEvent(row rowScanner) (datamodel.CaptureEvent, error) {
+	var event datamodel.CaptureEvent
+	err := row.Scan(&event.ID, &event.RequestID, &event.State, &event.Mode, &event.ScheduleID, &event.CaptureID, &event.ErrorCode, &event.ErrorMessage, &event.OccurredAt)
+	return event, err
+}
@@ -2,31 +2,75 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
 
 	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
 )
 
 type CaptureScheduleStore interface {
+	// 状態がactiveのスケジュールを返す。存在しない場合はnilを返す。
 	GetActiveCaptureSchedule(tx Transaction) (*datamodel.CaptureSchedule, error)
-	// アクティブなスケジュールを更新し、更新された行数を返す。
+	// 状態がactiveまたはpausedのスケジュールを返す。存在しない場合はnilを返す。
+	GetCurrentCaptureSchedule(tx Transaction) (*datamodel.CaptureSchedule, error)
+	// 最後に更新されたスケジュールを返す。存在しない場合はnilを返す。
+	GetLatestCaptureSchedule(tx Transaction) (*datamodel.CaptureSchedule, error)
+	// capture_schedulesにinsertし、作成されたスケジュールを返す。IDは呼び出し側で採番しておくこと。
+	CreateCaptureSchedule(tx Transaction, schedule datamodel.CaptureSchedule) (datamodel.CaptureSchedule, error)
+	// schedule.IDのスケジュールの状態・間隔・時間帯・除外日・ジッター・撮影範囲を更新し、更新後のスケジュールを返す。
 	//
-	// 更新前のアクティブなスケジュールの数をチェックしない点に注意。
-	UpdateActiveCaptureSchedule(tx Transaction, active bool, intervalMin int) (int64, error)
+	// 状態が変わる場合は連続失敗回数を0に戻す。
+	UpdateCaptureSchedule(tx Transaction, schedule datamodel.CaptureSchedule) (datamodel.CaptureSchedule, error)
+	// idのスケジュールの状態を更新し、連続失敗回数を0に戻す
+	UpdateCaptureScheduleState(tx Transaction, id string, state string) error
+	// アクティブなスケジュールの連続失敗回数を1増やし、maxConsecutiveFailuresに達した場合はpausedにする。
+	//
+	// 更新後のスケジュールを返す。アクティブなスケジュールが存在しない場合はnilを返す。
+	RecordActiveCaptureScheduleFailure(tx Transaction, reason string, failedAt time.Time, maxConsecutiveFailures int) (*datamodel.CaptureSchedule, error)
+	// アクティブなスケジュールの連続失敗回数を0に戻す
+	ResetActiveCaptureScheduleFailures(tx Transaction) error
 }
 
 type DefaultCaptureScheduleStore struct {
 	DB *sql.DB
 }
 
+const captureScheduleColumns = "id, state, interval_min, consecutive_failures, last_failure_at, last_failure_reason, time_windows, excluded_dates, jitter_pct, capture_mode, region, exclude_windows, created_at, updated_at"
+
 func (s *DefaultCaptureScheduleStore) GetActiveCaptureSchedule(tx Transaction) (*datamodel.CaptureSchedule, error) {
+	return s.getSingleCaptureSchedule(tx, "state = 'active'")
+}
+
+func (s *DefaultCaptureScheduleStore) GetCurrentCaptureSchedule(tx Transaction) (*datamodel.CaptureSchedule, error) {
+	return s.getSingleCaptureSchedule(tx, "state IN ('active', 'paused')")
+}
+
+func (s *DefaultCaptureScheduleStore) GetLatestCaptureSchedule(tx Transaction) (*datamodel.CaptureSchedule, error) {
 	defaultTx, ok := tx.(DefaultTransaction)
 	if !ok {
 		return nil, errors.New("transaction is not DefaultTransaction")
 	}
-	var row datamodel.CaptureSchedule
+	row := defaultTx.Tx.QueryRow("SELECT " + captureScheduleColumns + " FROM capture_schedules ORDER BY updated_at DESC, id ASC LIMIT 1")
+	schedule, err := scanCaptureSchedule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
 
-	rows, err := defaultTx.Tx.Query("SELECT id, active, interval_min, created_at, updated_at FROM capture_schedules WHERE active = 1")
+// conditionに一致するスケジュールを返す。複数一致した場合はエラーを返す。
+func (s *DefaultCaptureScheduleStore) getSingleCaptureSchedule(tx Transaction, condition string) (*datamodel.CaptureSchedule, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+
+	rows, err := defaultTx.Tx.Query("SELECT " + captureScheduleColumns + " FROM capture_schedules WHERE " + condition)
 	if err != nil {
 		return nil, err
 	}
@@ -38,7 +82,7 @@ func (s *DefaultCaptureScheduleStore) GetActiveCaptureSchedule(tx Transaction) (
 		}
 		return nil, nil
 	}
-	err = rows.Scan(&row.ID, &row.Active, &row.IntervalMin, &row.CreatedAt, &row.UpdatedAt)
+	row, err := scanCaptureSchedule(rows)
 	if err != nil {
 		return nil, err
 	}
@@ -48,23 +92,185 @@ func (s *DefaultCaptureScheduleStore) GetActiveCaptureSchedule(tx Transaction) (
 	return &row, nil
 }
 
-func (s *DefaultCaptureScheduleStore) UpdateActiveCaptureSchedule(tx Transaction, active bool, intervalMin int) (int64, error) {
+func (s *DefaultCaptureScheduleStore) CreateCaptureSchedule(tx Transaction, schedule datamodel.CaptureSchedule) (datamodel.CaptureSchedule, error) {
 	defaultTx, ok := tx.(DefaultTransaction)
 	if !ok {
-		return 0, errors.New("transaction is not DefaultTransaction")
+		return datamodel.CaptureSchedule{}, errors.New("transaction is not DefaultTransaction")
+	}
+	timeWindows, excludedDates, err := marshalCaptureScheduleRules(schedule)
+	if err != nil {
+		return datamodel.CaptureSchedule{}, err
 	}
-	result, err := defaultTx.Tx.Exec(
-		"UPDATE capture_schedules SET active = ?, interval_min = ? WHERE active = 1",
-		active,
-		intervalMin,
+	region, excludeWindows, err := marshalCaptureScheduleParams(schedule)
+	if err != nil {
+		return datamodel.CaptureSchedule{}, err
+	}
+	row := defaultTx.Tx.QueryRow(
+		"INSERT INTO capture_schedules (id, state, interval_min, time_windows, excluded_dates, jitter_pct, capture_mode, region, exclude_windows) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING "+captureScheduleColumns,
+		schedule.ID,
+		schedule.State,
+		schedule.IntervalMin,
+		timeWindows,
+		excludedDates,
+		schedule.JitterPct,
+		schedule.CaptureMode,
+		region,
+		excludeWindows,
 	)
+	return scanCaptureSchedule(row)
+}
+
+func (s *DefaultCaptureScheduleStore) UpdateCaptureSchedule(tx Transaction, schedule datamodel.CaptureSchedule) (datamodel.CaptureSchedule, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return datamodel.CaptureSchedule{}, errors.New("transaction is not DefaultTransaction")
+	}
+	timeWindows, excludedDates, err := marshalCaptureScheduleRules(schedule)
+	if err != nil {
+		return datamodel.CaptureSchedule{}, err
+	}
+	region, excludeWindows, err := marshalCaptureScheduleParams(schedule)
 	if err != nil {
-		return 0, err
+		return datamodel.CaptureSchedule{}, err
 	}
+	row := defaultTx.Tx.QueryRow(
+		`UPDATE capture_schedules SET
+			consecutive_failures = CASE WHEN state = ? THEN consecutive_failures ELSE 0 END,
+			state = ?,
+			interval_min = ?,
+			time_windows = ?,
+			excluded_dates = ?,
+			jitter_pct = ?,
+			capture_mode = ?,
+			region = ?,
+			exclude_windows = ?
+		WHERE id = ?
+		RETURNING `+captureScheduleColumns,
+		schedule.State,
+		schedule.State,
+		schedule.IntervalMin,
+		timeWindows,
+		excludedDates,
+		schedule.JitterPct,
+		schedule.CaptureMode,
+		region,
+		excludeWindows,
+		schedule.ID,
+	)
+	return scanCaptureSchedule(row)
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (s *DefaultCaptureScheduleStore) UpdateCaptureScheduleState(tx Transaction, id string, state string) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	_, err := defaultTx.Tx.Exec("UPDATE capture_schedules SET state = ?, consecutive_failures = 0 WHERE id = ?", state, id)
+	return err
+}
+
+func (s *DefaultCaptureScheduleStore) RecordActiveCaptureScheduleFailure(tx Transaction, reason string, failedAt time.Time, maxConsecutiveFailures int) (*datamodel.CaptureSchedule, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow(
+		`UPDATE capture_schedules SET
+			consecutive_failures = consecutive_failures + 1,
+			last_failure_at = ?,
+			last_failure_reason = ?,
+			state = CASE WHEN consecutive_failures + 1 >= ? THEN 'paused' ELSE state END
+		WHERE state = 'active'
+		RETURNING `+captureScheduleColumns,
+		failedAt.UTC(),
+		reason,
+		maxConsecutiveFailures,
+	)
+	schedule, err := scanCaptureSchedule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (s *DefaultCaptureScheduleStore) ResetActiveCaptureScheduleFailures(tx Transaction) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	_, err := defaultTx.Tx.Exec("UPDATE capture_schedules SET consecutive_failures = 0 WHERE state = 'active' AND consecutive_failures > 0")
+	return err
+}
+
+// 時間帯と除外日をJSON文字列にする。nilは空配列として保存する。
+func marshalCaptureScheduleRules(schedule datamodel.CaptureSchedule) (string, string, error) {
+	timeWindows := make([]datamodel.CaptureTimeWindow, len(schedule.TimeWindows))
+	copy(timeWindows, schedule.TimeWindows)
+	for i := range timeWindows {
+		if timeWindows[i].Weekdays == nil {
+			timeWindows[i].Weekdays = []int{}
+		}
+	}
+	excludedDates := schedule.ExcludedDates
+	if excludedDates == nil {
+		excludedDates = []string{}
+	}
+	timeWindowsJSON, err := json.Marshal(timeWindows)
 	if err != nil {
-		return 0, err
+		return "", "", fmt.Errorf("failed to marshal time windows: %w", err)
+	}
+	excludedDatesJSON, err := json.Marshal(excludedDates)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal excluded dates: %w", err)
+	}
+	return string(timeWindowsJSON), string(excludedDatesJSON), nil
+}
+
+// 撮影する領域と除外ウィンドウをJSON文字列にする。領域がnilの場合はNULL、除外ウィンドウがnilの場合は空配列として保存する。
+func marshalCaptureScheduleParams(schedule datamodel.CaptureSchedule) (any, string, error) {
+	var region any
+	if schedule.Region != nil {
+		regionJSON, err := json.Marshal(schedule.Region)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to marshal region: %w", err)
+		}
+		region = string(regionJSON)
+	}
+	excludeWindows := schedule.ExcludeWindows
+	if excludeWindows == nil {
+		excludeWindows = []string{}
+	}
+	excludeWindowsJSON, err := json.Marshal(excludeWindows)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal exclude windows: %w", err)
+	}
+	return region, string(excludeWindowsJSON), nil
+}
+
+func scanCaptureSchedule(row rowScanner) (datamodel.CaptureSchedule, error) {
+	var schedule datamodel.CaptureSchedule
+	var timeWindows, excludedDates, excludeWindows string
+	var region *string
+	err := row.Scan(&schedule.ID, &schedule.State, &schedule.IntervalMin, &schedule.ConsecutiveFailures, &schedule.LastFailureAt, &schedule.LastFailureReason, &timeWindows, &excludedDates, &schedule.JitterPct, &schedule.CaptureMode, &region, &excludeWindows, &schedule.CreatedAt, &schedule.UpdatedAt)
+	if err != nil {
+		return schedule, err
+	}
+	if region != nil {
+		if err := json.Unmarshal([]byte(*region), &schedule.Region); err != nil {
+			return schedule, fmt.Errorf("failed to unmarshal region: %w", err)
+		}
+	}
+	if err := json.Unmarshal([]byte(excludeWindows), &schedule.ExcludeWindows); err != nil {
+		return schedule, fmt.Errorf("failed to unmarshal exclude windows: %w", err)
+	}
+	if err := json.Unmarshal([]byte(timeWindows), &schedule.TimeWindows); err != nil {
+		return schedule, fmt.Errorf("failed to unmarshal time windows: %w", err)
+	}
+	if err := json.Unmarshal([]byte(excludedDates), &schedule.ExcludedDates); err != nil {
+		return schedule, fmt.Errorf("failed to unmarshal excluded dates: %w", err)
 	}
-	return rowsAffected, nil
+	return schedule, nil
 }
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+type CaptureScheduleHistoryStore interface {
+	// 変更履歴をinsertする。IDは呼び出し側で採番しておくこと。changed_atは現在時刻になる。
+	CreateCaptureScheduleHistory(tx Transaction, history datamodel.CaptureScheduleHistory) error
+	// 変更履歴を新しい順にoffset件目から最大limit件返す
+	GetCaptureScheduleHistory(tx Transaction, limit int, offset int) ([]datamodel.CaptureScheduleHistory, error)
+}
+
+type DefaultCaptureScheduleHistoryStore struct {
+	DB *sql.DB
+}
+
+func (s *DefaultCaptureScheduleHistoryStore) CreateCaptureScheduleHistory(tx Transaction, history datamodel.CaptureScheduleHistory) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	oldSnapshot, err := marshalCaptureScheduleSnapshot(history.OldSnapshot)
+	if err != nil {
+		return err
+	}
+	newSnapshot, err := marshalCaptureScheduleSnapshot(history.NewSnapshot)
+	if err != nil {
+		return err
+	}
+	_, err = defaultTx.Tx.Exec(
+		`INSERT INTO capture_schedule_history
+		(id, schedule_id, action, changed_by, old_state, old_interval_min, new_state, new_interval_min, old_snapshot, new_snapshot)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		history.ID, history.ScheduleID, history.Action, history.ChangedBy,
+		valueOrNil(history.OldState), valueOrNil(history.OldIntervalMin),
+		history.NewState, history.NewIntervalMin,
+		oldSnapshot, newSnapshot,
+	)
+	return err
+}
+
+func (s *DefaultCaptureScheduleHistoryStore) GetCaptureScheduleHistory(tx Transaction, limit int, offset int) ([]datamodel.CaptureScheduleHistory, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	// 同一秒内の変更はrowidで挿入順を保つ
+	rows, err := defaultTx.Tx.Query(
+		`SELECT id, schedule_id, action, changed_by, old_state, old_interval_min, new_state, new_interval_min, old_snapshot, new_snapshot, changed_at
+		FROM capture_schedule_history
+		ORDER BY changed_at DESC, rowid DESC
+		LIMIT ? OFFSET ?`,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []datamodel.CaptureScheduleHistory{}
+	for rows.Next() {
+		var h datamodel.CaptureScheduleHistory
+		var oldSnapshot, newSnapshot *string
+		if err := rows.Scan(&h.ID, &h.ScheduleID, &h.Action, &h.ChangedBy, &h.OldState, &h.OldIntervalMin, &h.NewState, &h.NewIntervalMin, &oldSnapshot, &newSnapshot, &h.ChangedAt); err != nil {
+			return nil, err
+		}
+		if h.OldSnapshot, err = unmarshalCaptureScheduleSnapshot(oldSnapshot); err != nil {
+			return nil, err
+		}
+		if h.NewSnapshot, err = unmarshalCaptureScheduleSnapshot(newSnapshot); err != nil {
+			return nil, err
+		}
+		history = append(history, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// スナップショットをJSON文字列にする。nilの場合はNULLとして保存する。
+func marshalCaptureScheduleSnapshot(snapshot *datamodel.CaptureScheduleSnapshot) (any, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal capture schedule snapshot: %w", err)
+	}
+	return string(data), nil
+}
+
+func unmarshalCaptureScheduleSnapshot(data *string) (*datamodel.CaptureScheduleSnapshot, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var snapshot datamodel.CaptureScheduleSnapshot
+	if err := json.Unmarshal([]byte(*data), &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal capture schedule snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+type ChatMessageStore interface {
+	CreateChatMessage(tx Transaction, message datamodel.ChatMessage) error
+	// 会話のメッセージを古い順に返す
+	GetChatMessages(tx Transaction, conversationID string, limit int, offset int) ([]datamodel.ChatMessage, error)
+	// 会話の全てのメッセージを古い順に返す
+	GetAllChatMessages(tx Transaction, conversationID string) ([]datamodel.ChatMessage, error)
+}
+
+type DefaultChatMessageStore struct {
+	DB *sql.DB
+}
+
+const chatMessageColumns = "id, conversation_id, role, content, finish_reason, tool_calls, tool_call_id, prompt_versions, created_at"
+
+func (s *DefaultChatMessageStore) CreateChatMessage(tx Transaction, message datamodel.ChatMessage) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	var toolCalls any
+	if message.ToolCalls != nil {
+		data, err := json.Marshal(message.ToolCalls)
+		if err != nil {
+			return fmt.Errorf("failed to marshal tool calls: %w", err)
+		}
+		toolCalls = string(data)
+	}
+	var promptVersions any
+	if message.PromptVersions != nil {
+		data, err := json.Marshal(message.PromptVersions)
+		if err != nil {
+			return fmt.Errorf("failed to marshal prompt versions: %w", err)
+		}
+		promptVersions = string(data)
+	}
+	_, err := defaultTx.Tx.Exec(
+		"INSERT INTO chat_messages ("+chatMessageColumns+", updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		message.ID, message.ConversationID, message.Role, message.Content, valueOrNil(message.FinishReason),
+		toolCalls, valueOrNil(message.ToolCallID), promptVersions, message.CreatedAt.UTC(), message.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (s *DefaultChatMessageStore) GetChatMessages(tx Transaction, conversationID string, limit int, offset int) ([]datamodel.ChatMessage, error) {
+	return s.getChatMessages(tx, conversationID, limit, offset)
+}
+
+func (s *DefaultChatMessageStore) GetAllChatMessages(tx Transaction, conversationID string) ([]datamodel.ChatMessage, error) {
+	// SQLiteのLIMIT -1は無制限
+	return s.getChatMessages(tx, conversationID, -1, 0)
+}
+
+func (s *DefaultChatMessageStore) getChatMessages(tx Transaction, conversationID string, limit int, offset int) ([]datamodel.ChatMessage, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	// 同一時刻のメッセージはrowidで挿入順を保つ
+	rows, err := defaultTx.Tx.Query(
+		"SELECT "+chatMessageColumns+" FROM chat_messages WHERE conversation_id = ? ORDER BY created_at ASC, rowid ASC LIMIT ? OFFSET ?",
+		conversationID, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []datamodel.ChatMessage{}
+	for rows.Next() {
+		var message datamodel.ChatMessage
+		var toolCalls, promptVersions *string
+		if err := rows.Scan(&message.ID, &message.ConversationID, &message.Role, &message.Content, &message.FinishReason, &toolCalls, &message.ToolCallID, &promptVersions, &message.CreatedAt); err != nil {
+			return nil, err
+		}
+		if toolCalls != nil {
+			if err := json.Unmarshal([]byte(*toolCalls), &message.ToolCalls); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal tool calls: %w", err)
+			}
+		}
+		if promptVersions != nil {
+			if err := json.Unmarshal([]byte(*promptVersions), &message.PromptVersions); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal prompt versions: %w", err)
+			}
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
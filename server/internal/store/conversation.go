@@ -0,0 +1,115 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+type ConversationStore interface {
+	CreateConversation(tx Transaction, conversation datamodel.Conversation) error
+	// 存在しない場合はnilを返す
+	GetConversation(tx Transaction, id string) (*datamodel.Conversation, error)
+	// 最後にメッセージを追加した日時の新しい順に返す
+	GetConversations(tx Transaction, limit int, offset int) ([]datamodel.Conversation, error)
+	// 最後にメッセージを追加した日時を更新する
+	TouchConversation(tx Transaction, id string, updatedAt time.Time) error
+	// 会話とそのメッセージ・要約を削除する。存在しない場合はfalseを返す
+	DeleteConversation(tx Transaction, id string) (bool, error)
+}
+
+type DefaultConversationStore struct {
+	DB *sql.DB
+}
+
+const conversationColumns = "id, title, created_at, updated_at"
+
+func (s *DefaultConversationStore) CreateConversation(tx Transaction, conversation datamodel.Conversation) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	_, err := defaultTx.Tx.Exec(
+		"INSERT INTO conversations ("+conversationColumns+") VALUES (?, ?, ?, ?)",
+		conversation.ID, conversation.Title, conversation.CreatedAt.UTC(), conversation.UpdatedAt.UTC(),
+	)
+	return err
+}
+
+func (s *DefaultConversationStore) GetConversation(tx Transaction, id string) (*datamodel.Conversation, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow("SELECT "+conversationColumns+" FROM conversations WHERE id = ?", id)
+	conversation, err := scanConversation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+func (s *DefaultConversationStore) GetConversations(tx Transaction, limit int, offset int) ([]datamodel.Conversation, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	rows, err := defaultTx.Tx.Query(
+		"SELECT "+conversationColumns+" FROM conversations ORDER BY updated_at DESC, rowid DESC LIMIT ? OFFSET ?",
+		limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []datamodel.Conversation{}
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, conversation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return conversations, nil
+}
+
+func (s *DefaultConversationStore) TouchConversation(tx Transaction, id string, updatedAt time.Time) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	_, err := defaultTx.Tx.Exec("UPDATE conversations SET updated_at = ? WHERE id = ?", updatedAt.UTC(), id)
+	return err
+}
+
+func (s *DefaultConversationStore) DeleteConversation(tx Transaction, id string) (bool, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return false, errors.New("transaction is not DefaultTransaction")
+	}
+	// メッセージ・要約は外部キー制約で削除され、提案のconversation_idはNULLになる
+	result, err := defaultTx.Tx.Exec("DELETE FROM conversations WHERE id = ?", id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func scanConversation(row rowScanner) (datamodel.Conversation, error) {
+	var conversation datamodel.Conversation
+	err := row.Scan(&conversation.ID, &conversation.Title, &conversation.CreatedAt, &conversation.UpdatedAt)
+	return conversation, err
+}
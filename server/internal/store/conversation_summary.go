@@ -0,0 +1,52 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+type ConversationSummaryStore interface {
+	CreateConversationSummary(tx Transaction, summary datamodel.ConversationSummary) error
+	// 会話の最新の要約を返す。要約がない場合はnilを返す
+	GetLatestConversationSummary(tx Transaction, conversationID string) (*datamodel.ConversationSummary, error)
+}
+
+type DefaultConversationSummaryStore struct {
+	DB *sql.DB
+}
+
+const conversationSummaryColumns = "id, conversation_id, content, last_message_id, message_count, created_at"
+
+func (s *DefaultConversationSummaryStore) CreateConversationSummary(tx Transaction, summary datamodel.ConversationSummary) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	_, err := defaultTx.Tx.Exec(
+		"INSERT INTO conversation_summaries ("+conversationSummaryColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+		summary.ID, summary.ConversationID, summary.Content, summary.LastMessageID, summary.MessageCount, summary.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (s *DefaultConversationSummaryStore) GetLatestConversationSummary(tx Transaction, conversationID string) (*datamodel.ConversationSummary, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow(
+		"SELECT "+conversationSummaryColumns+" FROM conversation_summaries WHERE conversation_id = ? ORDER BY created_at DESC, rowid DESC LIMIT 1",
+		conversationID,
+	)
+	var summary datamodel.ConversationSummary
+	err := row.Scan(&summary.ID, &summary.ConversationID, &summary.Content, &summary.LastMessageID, &summary.MessageCount, &summary.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
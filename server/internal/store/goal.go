@@ -18,6 +18,12 @@ type GoalStore interface {
 	// idが指定されていない場合はUUIDを生成してinsertする。
 	// kpi_*のnull/非nullが揃っているかチェックしない。
 	CreateGoal(tx Transaction, id *string, title string, description string, startDate time.Time, endDate time.Time, kpiName *string, kpiTarget *float64, kpiUnit *string, status string) (datamodel.Goal, error)
+	// idのGoalを返す。存在しない場合はnilを返す。
+	GetGoalByID(tx Transaction, id string) (*datamodel.Goal, error)
+	// goal.IDのGoalのcreated_at・updated_at以外を更新する。存在しない場合はfalseを返す。
+	//
+	// updated_atはトリガーで更新する。
+	UpdateGoal(tx Transaction, goal datamodel.Goal) (bool, error)
 }
 
 type DefaultGoalStore struct {
@@ -92,6 +98,42 @@ func (s *DefaultGoalStore) CreateGoal(tx Transaction, id *string, title string,
 	return goal, nil
 }
 
+func (s *DefaultGoalStore) GetGoalByID(tx Transaction, id string) (*datamodel.Goal, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow("SELECT id, title, description, start_date, end_date, kpi_name, kpi_target, kpi_unit, status, created_at, updated_at FROM goals WHERE id = ?", id)
+	var goal datamodel.Goal
+	err := row.Scan(&goal.ID, &goal.Title, &goal.Description, &goal.StartDate, &goal.EndDate, &goal.KpiName, &goal.KpiTarget, &goal.KpiUnit, &goal.Status, &goal.CreatedAt, &goal.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &goal, nil
+}
+
+func (s *DefaultGoalStore) UpdateGoal(tx Transaction, goal datamodel.Goal) (bool, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return false, errors.New("transaction is not DefaultTransaction")
+	}
+	result, err := defaultTx.Tx.Exec(
+		"UPDATE goals SET title = ?, description = ?, start_date = ?, end_date = ?, kpi_name = ?, kpi_target = ?, kpi_unit = ?, status = ? WHERE id = ?",
+		goal.Title, goal.Description, goal.StartDate, goal.EndDate, valueOrNil(goal.KpiName), valueOrNil(goal.KpiTarget), valueOrNil(goal.KpiUnit), goal.Status, goal.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
 // 非nilの場合は*valueを、nilの場合はnilを返す
 func valueOrNil[T any](value *T) any {
 	if value == nil {
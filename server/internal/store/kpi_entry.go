@@ -0,0 +1,68 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+type KpiEntryStore interface {
+	CreateKpiEntry(tx Transaction, entry datamodel.KpiEntry) error
+	// 目標ごとのKPIの実績の合計を返す。実績がない目標は含まない
+	GetKpiTotals(tx Transaction, goalIDs []string) (map[string]float64, error)
+}
+
+type DefaultKpiEntryStore struct {
+	DB *sql.DB
+}
+
+func (s *DefaultKpiEntryStore) CreateKpiEntry(tx Transaction, entry datamodel.KpiEntry) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	// recorded_onはDATE型のため日付の文字列で保存する
+	_, err := defaultTx.Tx.Exec(
+		"INSERT INTO kpi_entries (id, goal_id, value, note, recorded_on, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		entry.ID, entry.GoalID, entry.Value, entry.Note, entry.RecordedOn.Format(time.DateOnly), entry.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (s *DefaultKpiEntryStore) GetKpiTotals(tx Transaction, goalIDs []string) (map[string]float64, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	totals := map[string]float64{}
+	if len(goalIDs) == 0 {
+		return totals, nil
+	}
+	args := make([]any, 0, len(goalIDs))
+	for _, id := range goalIDs {
+		args = append(args, id)
+	}
+	rows, err := defaultTx.Tx.Query(
+		"SELECT goal_id, SUM(value) FROM kpi_entries WHERE goal_id IN (?"+strings.Repeat(", ?", len(goalIDs)-1)+") GROUP BY goal_id",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var goalID string
+		var total float64
+		if err := rows.Scan(&goalID, &total); err != nil {
+			return nil, err
+		}
+		totals[goalID] = total
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
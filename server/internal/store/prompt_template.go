@@ -0,0 +1,120 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+type PromptTemplateStore interface {
+	CreatePromptTemplate(tx Transaction, template datamodel.PromptTemplate) error
+	// 存在しない場合はnilを返す
+	GetPromptTemplate(tx Transaction, name string, version int) (*datamodel.PromptTemplate, error)
+	// nameの最新のバージョンを返す。存在しない場合はnilを返す
+	GetLatestPromptTemplate(tx Transaction, name string) (*datamodel.PromptTemplate, error)
+	// 全てのテンプレートの最新のバージョンを名前の順に返す
+	GetLatestPromptTemplates(tx Transaction) ([]datamodel.PromptTemplate, error)
+	// nameのバージョンを新しい順に返す
+	GetPromptTemplateVersions(tx Transaction, name string, limit int, offset int) ([]datamodel.PromptTemplate, error)
+}
+
+type DefaultPromptTemplateStore struct {
+	DB *sql.DB
+}
+
+const promptTemplateColumns = "id, name, version, body, created_at"
+
+func (s *DefaultPromptTemplateStore) CreatePromptTemplate(tx Transaction, template datamodel.PromptTemplate) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	_, err := defaultTx.Tx.Exec(
+		"INSERT INTO prompt_templates ("+promptTemplateColumns+") VALUES (?, ?, ?, ?, ?)",
+		template.ID, template.Name, template.Version, template.Body, template.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (s *DefaultPromptTemplateStore) GetPromptTemplate(tx Transaction, name string, version int) (*datamodel.PromptTemplate, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow("SELECT "+promptTemplateColumns+" FROM prompt_templates WHERE name = ? AND version = ?", name, version)
+	return scanOptionalPromptTemplate(row)
+}
+
+func (s *DefaultPromptTemplateStore) GetLatestPromptTemplate(tx Transaction, name string) (*datamodel.PromptTemplate, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow("SELECT "+promptTemplateColumns+" FROM prompt_templates WHERE name = ? ORDER BY version DESC LIMIT 1", name)
+	return scanOptionalPromptTemplate(row)
+}
+
+func (s *DefaultPromptTemplateStore) GetLatestPromptTemplates(tx Transaction) ([]datamodel.PromptTemplate, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	rows, err := defaultTx.Tx.Query(
+		"SELECT " + promptTemplateColumns + " FROM prompt_templates AS t " +
+			"WHERE version = (SELECT MAX(version) FROM prompt_templates WHERE name = t.name) ORDER BY name ASC",
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanPromptTemplates(rows)
+}
+
+func (s *DefaultPromptTemplateStore) GetPromptTemplateVersions(tx Transaction, name string, limit int, offset int) ([]datamodel.PromptTemplate, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	rows, err := defaultTx.Tx.Query(
+		"SELECT "+promptTemplateColumns+" FROM prompt_templates WHERE name = ? ORDER BY version DESC LIMIT ? OFFSET ?",
+		name, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanPromptTemplates(rows)
+}
+
+func scanPromptTemplate(row rowScanner) (datamodel.PromptTemplate, error) {
+	var template datamodel.PromptTemplate
+	err := row.Scan(&template.ID, &template.Name, &template.Version, &template.Body, &template.CreatedAt)
+	return template, err
+}
+
+func scanOptionalPromptTemplate(row rowScanner) (*datamodel.PromptTemplate, error) {
+	template, err := scanPromptTemplate(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// rowsをすべて読み込んでCloseする
+func scanPromptTemplates(rows *sql.Rows) ([]datamodel.PromptTemplate, error) {
+	defer rows.Close()
+	templates := []datamodel.PromptTemplate{}
+	for rows.Next() {
+		template, err := scanPromptTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+type ProposalStore interface {
+	CreateProposal(tx Transaction, proposal datamodel.Proposal) error
+	// 存在しない場合はnilを返す
+	GetProposal(tx Transaction, id string) (*datamodel.Proposal, error)
+	// filterに一致する提案を新しい順に返す
+	GetProposals(tx Transaction, filter ProposalFilter, limit int, offset int) ([]datamodel.Proposal, error)
+	// pendingの提案をstatusにする。pendingの提案が存在しない場合はfalseを返す
+	ResolveProposal(tx Transaction, id string, status string, resolvedAt time.Time) (bool, error)
+	// 期限がnow以前のpendingの提案をexpiredにし、その件数を返す
+	ExpireProposals(tx Transaction, now time.Time) (int64, error)
+}
+
+// GetProposalsの絞り込み条件。nil・空のフィールドは絞り込まない
+type ProposalFilter struct {
+	Statuses       []string
+	ConversationID *string
+}
+
+type DefaultProposalStore struct {
+	DB *sql.DB
+}
+
+const proposalColumns = "id, conversation_id, changeset, diff, status, expires_at, resolved_at, created_at"
+
+func (s *DefaultProposalStore) CreateProposal(tx Transaction, proposal datamodel.Proposal) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	changeset, err := json.Marshal(proposal.Changeset)
+	if err != nil {
+		return fmt.Errorf("failed to marshal changeset: %w", err)
+	}
+	diff, err := json.Marshal(proposal.Diff)
+	if err != nil {
+		return fmt.Errorf("failed to marshal diff: %w", err)
+	}
+	var resolvedAt any
+	if proposal.ResolvedAt != nil {
+		resolvedAt = proposal.ResolvedAt.UTC()
+	}
+	_, err = defaultTx.Tx.Exec(
+		"INSERT INTO proposals ("+proposalColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		proposal.ID, valueOrNil(proposal.ConversationID), string(changeset), string(diff), proposal.Status,
+		proposal.ExpiresAt.UTC(), resolvedAt, proposal.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (s *DefaultProposalStore) GetProposal(tx Transaction, id string) (*datamodel.Proposal, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow("SELECT "+proposalColumns+" FROM proposals WHERE id = ?", id)
+	proposal, err := scanProposal(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+func (s *DefaultProposalStore) GetProposals(tx Transaction, filter ProposalFilter, limit int, offset int) ([]datamodel.Proposal, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	conditions := []string{"1 = 1"}
+	args := []any{}
+	if len(filter.Statuses) > 0 {
+		conditions = append(conditions, "status IN (?"+strings.Repeat(", ?", len(filter.Statuses)-1)+")")
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+	if filter.ConversationID != nil {
+		conditions = append(conditions, "conversation_id = ?")
+		args = append(args, *filter.ConversationID)
+	}
+	args = append(args, limit, offset)
+	rows, err := defaultTx.Tx.Query(
+		"SELECT "+proposalColumns+" FROM proposals WHERE "+strings.Join(conditions, " AND ")+" ORDER BY created_at DESC, rowid DESC LIMIT ? OFFSET ?",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	proposals := []datamodel.Proposal{}
+	for rows.Next() {
+		proposal, err := scanProposal(rows)
+		if err != nil {
+			return nil, err
+		}
+		proposals = append(proposals, proposal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return proposals, nil
+}
+
+func (s *DefaultProposalStore) ResolveProposal(tx Transaction, id string, status string, resolvedAt time.Time) (bool, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return false, errors.New("transaction is not DefaultTransaction")
+	}
+	result, err := defaultTx.Tx.Exec(
+		"UPDATE proposals SET status = ?, resolved_at = ? WHERE id = ? AND status = ?",
+		status, resolvedAt.UTC(), id, datamodel.ProposalStatusPending,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (s *DefaultProposalStore) ExpireProposals(tx Transaction, now time.Time) (int64, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return 0, errors.New("transaction is not DefaultTransaction")
+	}
+	// 期限切れになった日時は期限の日時とする
+	result, err := defaultTx.Tx.Exec(
+		"UPDATE proposals SET status = ?, resolved_at = expires_at WHERE status = ? AND expires_at <= ?",
+		datamodel.ProposalStatusExpired, datamodel.ProposalStatusPending, now.UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanProposal(row rowScanner) (datamodel.Proposal, error) {
+	var proposal datamodel.Proposal
+	var changeset, diff string
+	if err := row.Scan(&proposal.ID, &proposal.ConversationID, &changeset, &diff, &proposal.Status, &proposal.ExpiresAt, &proposal.ResolvedAt, &proposal.CreatedAt); err != nil {
+		return datamodel.Proposal{}, err
+	}
+	if err := json.Unmarshal([]byte(changeset), &proposal.Changeset); err != nil {
+		return datamodel.Proposal{}, fmt.Errorf("failed to unmarshal changeset: %w", err)
+	}
+	if err := json.Unmarshal([]byte(diff), &proposal.Diff); err != nil {
+		return datamodel.Proposal{}, fmt.Errorf("failed to unmarshal diff: %w", err)
+	}
+	return proposal, nil
+}
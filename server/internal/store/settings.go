@@ -0,0 +1,73 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+type SettingsStore interface {
+	GetSettings(tx Transaction) (datamodel.Settings, error)
+	// 設定をsettingsの値で上書きし、更新後の設定を返す
+	UpdateSettings(tx Transaction, settings datamodel.Settings) (datamodel.Settings, error)
+}
+
+type DefaultSettingsStore struct {
+	DB *sql.DB
+}
+
+const settingsColumns = "thumbnail_resolution, retention_max_items, retention_max_days, retention_max_bytes, dedup_mode, dedup_threshold, mask_rules, created_at, updated_at"
+
+func (s *DefaultSettingsStore) GetSettings(tx Transaction) (datamodel.Settings, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return datamodel.Settings{}, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow("SELECT " + settingsColumns + " FROM settings WHERE id = 1")
+	return scanSettings(row)
+}
+
+func (s *DefaultSettingsStore) UpdateSettings(tx Transaction, settings datamodel.Settings) (datamodel.Settings, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return datamodel.Settings{}, errors.New("transaction is not DefaultTransaction")
+	}
+	maskRules := settings.MaskRules
+	if maskRules == nil {
+		maskRules = []datamodel.MaskRule{}
+	}
+	maskRulesJSON, err := json.Marshal(maskRules)
+	if err != nil {
+		return datamodel.Settings{}, fmt.Errorf("failed to marshal mask rules: %w", err)
+	}
+	row := defaultTx.Tx.QueryRow(
+		`UPDATE settings
+		SET thumbnail_resolution = ?, retention_max_items = ?, retention_max_days = ?, retention_max_bytes = ?, dedup_mode = ?, dedup_threshold = ?, mask_rules = ?
+		WHERE id = 1
+		RETURNING `+settingsColumns,
+		settings.ThumbnailResolution,
+		valueOrNil(settings.RetentionMaxItems),
+		valueOrNil(settings.RetentionMaxDays),
+		valueOrNil(settings.RetentionMaxBytes),
+		settings.DedupMode,
+		settings.DedupThreshold,
+		string(maskRulesJSON),
+	)
+	return scanSettings(row)
+}
+
+func scanSettings(row rowScanner) (datamodel.Settings, error) {
+	var settings datamodel.Settings
+	var maskRules string
+	err := row.Scan(&settings.ThumbnailResolution, &settings.RetentionMaxItems, &settings.RetentionMaxDays, &settings.RetentionMaxBytes, &settings.DedupMode, &settings.DedupThreshold, &maskRules, &settings.CreatedAt, &settings.UpdatedAt)
+	if err != nil {
+		return settings, err
+	}
+	if err := json.Unmarshal([]byte(maskRules), &settings.MaskRules); err != nil {
+		return settings, fmt.Errorf("failed to unmarshal mask rules: %w", err)
+	}
+	return settings, nil
+}
@@ -0,0 +1,229 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+type TaskStore interface {
+	// idのTaskを返す。存在しない場合はnilを返す。
+	GetTask(tx Transaction, id string) (*datamodel.Task, error)
+	// atの時点でdoingだったTaskを返す。複数ある場合は最も新しくdoingになったもの、存在しない場合はnilを返す。
+	GetDoingTaskAt(tx Transaction, at time.Time) (*datamodel.Task, error)
+	// filterに一致するTaskを期日の近い順（期日なしは最後）に返す
+	GetTasks(tx Transaction, filter TaskFilter, limit int, offset int) ([]datamodel.Task, error)
+	// tasksテーブルにinsertする。created_at・updated_atはtaskの値を使う
+	CreateTask(tx Transaction, task datamodel.Task) error
+	// idのTaskのstatusを更新する。存在しない場合はfalseを返す
+	UpdateTaskStatus(tx Transaction, id string, status string, updatedAt time.Time) (bool, error)
+	// task.IDのTaskのstatus・attachments・created_at以外を更新する。存在しない場合はfalseを返す
+	UpdateTask(tx Transaction, task datamodel.Task) (bool, error)
+}
+
+// GetTasksの絞り込み条件。nil・空のフィールドは絞り込まない
+type TaskFilter struct {
+	Statuses []string
+	GoalID   *string
+	// 期日がDueFrom以降・DueTo以前（日付で比較）。指定した場合、期日のないTaskは含まない
+	DueFrom *time.Time
+	DueTo   *time.Time
+}
+
+type DefaultTaskStore struct {
+	DB *sql.DB
+}
+
+const taskColumns = "t.id, t.goal_id, t.title, t.description, t.due, t.estimate_min, t.priority, t.status, t.tags, t.attachments, t.created_at, t.updated_at"
+
+func (s *DefaultTaskStore) GetTask(tx Transaction, id string) (*datamodel.Task, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow("SELECT "+taskColumns+" FROM tasks t WHERE t.id = ?", id)
+	task, err := scanTask(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (s *DefaultTaskStore) GetDoingTaskAt(tx Transaction, at time.Time) (*datamodel.Task, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	row := defaultTx.Tx.QueryRow(
+		`SELECT `+taskColumns+`
+		FROM task_sessions s JOIN tasks t ON t.id = s.task_id
+		WHERE s.started_at <= ? AND (s.ended_at IS NULL OR s.ended_at > ?)
+		ORDER BY s.started_at DESC, s.rowid DESC LIMIT 1`,
+		at.UTC(),
+		at.UTC(),
+	)
+	task, err := scanTask(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func scanTask(row rowScanner) (datamodel.Task, error) {
+	var task datamodel.Task
+	var tags, attachments string
+	err := row.Scan(&task.ID, &task.GoalID, &task.Title, &task.Description, &task.Due, &task.EstimateMin, &task.Priority, &task.Status, &tags, &attachments, &task.CreatedAt, &task.UpdatedAt)
+	if err != nil {
+		return task, err
+	}
+	if err := json.Unmarshal([]byte(tags), &task.Tags); err != nil {
+		return task, fmt.Errorf("failed to unmarshal tags: %w", err)
+	}
+	if err := json.Unmarshal([]byte(attachments), &task.Attachments); err != nil {
+		return task, fmt.Errorf("failed to unmarshal attachments: %w", err)
+	}
+	return task, nil
+}
+
+func (s *DefaultTaskStore) GetTasks(tx Transaction, filter TaskFilter, limit int, offset int) ([]datamodel.Task, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	conditions := []string{"1 = 1"}
+	args := []any{}
+	if len(filter.Statuses) > 0 {
+		conditions = append(conditions, "t.status IN (?"+strings.Repeat(", ?", len(filter.Statuses)-1)+")")
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+	if filter.GoalID != nil {
+		conditions = append(conditions, "t.goal_id = ?")
+		args = append(args, *filter.GoalID)
+	}
+	if filter.DueFrom != nil {
+		conditions = append(conditions, "t.due >= ?")
+		args = append(args, filter.DueFrom.Format(time.DateOnly))
+	}
+	if filter.DueTo != nil {
+		conditions = append(conditions, "t.due <= ?")
+		args = append(args, filter.DueTo.Format(time.DateOnly))
+	}
+	args = append(args, limit, offset)
+	rows, err := defaultTx.Tx.Query(
+		"SELECT "+taskColumns+" FROM tasks t WHERE "+strings.Join(conditions, " AND ")+
+			" ORDER BY t.due IS NULL, t.due ASC, t.created_at ASC, t.rowid ASC LIMIT ? OFFSET ?",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []datamodel.Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (s *DefaultTaskStore) CreateTask(tx Transaction, task datamodel.Task) error {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return errors.New("transaction is not DefaultTransaction")
+	}
+	tags := task.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %w", err)
+	}
+	attachments := task.Attachments
+	if attachments == nil {
+		attachments = []any{}
+	}
+	attachmentsJSON, err := json.Marshal(attachments)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attachments: %w", err)
+	}
+	// dueはDATE型のため日付の文字列で保存する
+	var due any
+	if task.Due != nil {
+		due = task.Due.Format(time.DateOnly)
+	}
+	_, err = defaultTx.Tx.Exec(
+		`INSERT INTO tasks (id, goal_id, title, description, due, estimate_min, priority, status, tags, attachments, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		task.ID, valueOrNil(task.GoalID), task.Title, task.Description, due, task.EstimateMin, task.Priority, task.Status,
+		string(tagsJSON), string(attachmentsJSON), task.CreatedAt.UTC(), task.UpdatedAt.UTC(),
+	)
+	return err
+}
+
+func (s *DefaultTaskStore) UpdateTaskStatus(tx Transaction, id string, status string, updatedAt time.Time) (bool, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return false, errors.New("transaction is not DefaultTransaction")
+	}
+	result, err := defaultTx.Tx.Exec("UPDATE tasks SET status = ?, updated_at = ? WHERE id = ?", status, updatedAt.UTC(), id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (s *DefaultTaskStore) UpdateTask(tx Transaction, task datamodel.Task) (bool, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return false, errors.New("transaction is not DefaultTransaction")
+	}
+	tags := task.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal tags: %w", err)
+	}
+	var due any
+	if task.Due != nil {
+		due = task.Due.Format(time.DateOnly)
+	}
+	result, err := defaultTx.Tx.Exec(
+		"UPDATE tasks SET goal_id = ?, title = ?, description = ?, due = ?, estimate_min = ?, priority = ?, tags = ?, updated_at = ? WHERE id = ?",
+		valueOrNil(task.GoalID), task.Title, task.Description, due, task.EstimateMin, task.Priority, string(tagsJSON), task.UpdatedAt.UTC(), task.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
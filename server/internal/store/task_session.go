@@ -0,0 +1,49 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+type TaskSessionStore interface {
+	// from以降to未満の期間と重なるセッションを開始日時の古い順に返す。doingのままのセッションも含む。
+	GetTaskSessionsInRange(tx Transaction, from time.Time, to time.Time) ([]datamodel.TaskSession, error)
+}
+
+type DefaultTaskSessionStore struct {
+	DB *sql.DB
+}
+
+func (s *DefaultTaskSessionStore) GetTaskSessionsInRange(tx Transaction, from time.Time, to time.Time) ([]datamodel.TaskSession, error) {
+	defaultTx, ok := tx.(DefaultTransaction)
+	if !ok {
+		return nil, errors.New("transaction is not DefaultTransaction")
+	}
+	rows, err := defaultTx.Tx.Query(
+		`SELECT s.id, s.task_id, t.title, s.started_at, s.ended_at
+		FROM task_sessions s JOIN tasks t ON t.id = s.task_id
+		WHERE s.started_at < ? AND (s.ended_at IS NULL OR s.ended_at > ?)
+		ORDER BY s.started_at ASC, s.rowid ASC`,
+		to.UTC(), from.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []datamodel.TaskSession{}
+	for rows.Next() {
+		var session datamodel.TaskSession
+		if err := rows.Scan(&session.ID, &session.TaskID, &session.TaskTitle, &session.StartedAt, &session.EndedAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
@@ -0,0 +1,216 @@
+package timeline
+
+import (
+	"time"
+
+	"github.com/ano333333/llm-time-manager/server/internal/capture"
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+)
+
+// 解析結果以外のカテゴリ
+const (
+	// 画面が変化しなかった期間（capture.DetectIdlePeriods）
+	CategoryIdle = "idle"
+	// 解析が終わっていない、または失敗したキャプチャ
+	CategoryUnknown = "unknown"
+)
+
+const (
+	// 1件のキャプチャが表す期間の上限。次のキャプチャまでこれより空いている場合、その間は記録なしとする。
+	MaxCaptureGap = 15 * time.Minute
+	// idleとみなす、画面が変化しなかった期間の長さの下限
+	IdleMinDuration = 10 * time.Minute
+)
+
+type Task struct {
+	ID    string
+	Title string
+}
+
+// 同じカテゴリ・同じタスクのキャプチャが続いた期間
+type Block struct {
+	Start    time.Time
+	End      time.Time
+	Category string
+	// ブロックの最初のキャプチャの解析結果の要約
+	Summary string
+	// ブロックの開始時点でdoingだったタスク。なければnil
+	Task *Task
+	// ブロックに含まれるキャプチャ（撮影日時の古い順）
+	CaptureIDs []string
+}
+
+func (b Block) Duration() time.Duration {
+	return b.End.Sub(b.Start)
+}
+
+// 対象期間に切り詰めたタスクのセッション
+type Session struct {
+	ID        string
+	TaskID    string
+	TaskTitle string
+	Start     time.Time
+	End       time.Time
+	// doingのまま終わっていない
+	Ongoing bool
+}
+
+func (s Session) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+type Timeline struct {
+	Blocks   []Block
+	Sessions []Session
+	// カテゴリごとのブロックの合計時間
+	CategoryTotals map[string]time.Duration
+}
+
+// from以降to未満の期間のタイムラインを作る。
+//
+// capturesは撮影日時の古い順に並んでいること。analysesはキャプチャごとの最新の成功した解析ジョブ。
+// 各キャプチャは次のキャプチャまで（最大MaxCaptureGap、nowまで）の期間を表し、
+// 隣接する期間のうちカテゴリとdoingだったタスクが同じものを1つのブロックにまとめる。
+func Build(from time.Time, to time.Time, now time.Time, captures []datamodel.Capture, analyses []datamodel.CaptureAnalysisJob, taskSessions []datamodel.TaskSession) Timeline {
+	end := to
+	if now.Before(end) {
+		end = now
+	}
+	sessions := clipSessions(from, end, taskSessions)
+
+	analysisByCapture := map[string]datamodel.CaptureAnalysisJob{}
+	for _, a := range analyses {
+		analysisByCapture[a.CaptureID] = a
+	}
+	idle := idleCaptures(captures)
+
+	blocks := []Block{}
+	var previous *segment
+	for i, c := range captures {
+		s := segment{start: c.CapturedAt, category: CategoryUnknown}
+		if a, ok := analysisByCapture[c.ID]; ok {
+			s.category = valueOr(a.Category, datamodel.ActivityCategoryOther)
+			s.summary = valueOr(a.Summary, "")
+		} else if previous != nil && c.DuplicateOf != nil && *c.DuplicateOf == previous.captureID {
+			// 重複は解析しないため、重複元の結果を引き継ぐ
+			s.category, s.summary = previous.category, previous.summary
+		}
+		s.captureID = c.ID
+		previous = &s
+		if idle[c.ID] {
+			s.category = CategoryIdle
+		}
+
+		covered := c.CapturedAt
+		if c.LastDuplicateAt != nil && c.LastDuplicateAt.After(covered) {
+			covered = *c.LastDuplicateAt
+		}
+		s.end = covered.Add(MaxCaptureGap)
+		if i+1 < len(captures) && captures[i+1].CapturedAt.Before(s.end) {
+			s.end = captures[i+1].CapturedAt
+		}
+		if end.Before(s.end) {
+			s.end = end
+		}
+		if !s.start.Before(s.end) {
+			continue
+		}
+		s.task = taskAt(sessions, s.start)
+
+		last := len(blocks) - 1
+		if last >= 0 && blocks[last].End.Equal(s.start) && blocks[last].Category == s.category && sameTask(blocks[last].Task, s.task) {
+			blocks[last].End = s.end
+			blocks[last].CaptureIDs = append(blocks[last].CaptureIDs, c.ID)
+			continue
+		}
+		blocks = append(blocks, Block{
+			Start:      s.start,
+			End:        s.end,
+			Category:   s.category,
+			Summary:    s.summary,
+			Task:       s.task,
+			CaptureIDs: []string{c.ID},
+		})
+	}
+
+	totals := map[string]time.Duration{}
+	for _, b := range blocks {
+		totals[b.Category] += b.Duration()
+	}
+	return Timeline{Blocks: blocks, Sessions: sessions, CategoryTotals: totals}
+}
+
+// 1件のキャプチャが表す期間
+type segment struct {
+	captureID string
+	start     time.Time
+	end       time.Time
+	category  string
+	summary   string
+	task      *Task
+}
+
+// セッションをfrom以降end未満に切り詰める。終わっていないセッションはendまでとする。
+func clipSessions(from time.Time, end time.Time, taskSessions []datamodel.TaskSession) []Session {
+	sessions := []Session{}
+	for _, ts := range taskSessions {
+		s := Session{ID: ts.ID, TaskID: ts.TaskID, TaskTitle: ts.TaskTitle, Start: ts.StartedAt, End: end, Ongoing: ts.EndedAt == nil}
+		if ts.EndedAt != nil && ts.EndedAt.Before(end) {
+			s.End = *ts.EndedAt
+		}
+		if s.Start.Before(from) {
+			s.Start = from
+		}
+		if s.Start.Before(s.End) {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions
+}
+
+// 画面が変化しなかった期間に含まれるキャプチャのID
+func idleCaptures(captures []datamodel.Capture) map[string]bool {
+	index := map[string]int{}
+	for i, c := range captures {
+		index[c.ID] = i
+	}
+	idle := map[string]bool{}
+	for _, period := range capture.DetectIdlePeriods(captures, IdleMinDuration) {
+		i := index[period.CaptureID]
+		idle[captures[i].ID] = true
+		for j := i + 1; j < len(captures) && captures[j].DuplicateOf != nil && *captures[j].DuplicateOf == captures[j-1].ID; j++ {
+			idle[captures[j].ID] = true
+		}
+	}
+	return idle
+}
+
+// tの時点でdoingだったタスク。複数ある場合は最も新しく開始したもの
+func taskAt(sessions []Session, t time.Time) *Task {
+	var task *Task
+	var startedAt time.Time
+	for _, s := range sessions {
+		if s.Start.After(t) || !s.End.After(t) {
+			continue
+		}
+		if task == nil || !s.Start.Before(startedAt) {
+			task = &Task{ID: s.TaskID, Title: s.TaskTitle}
+			startedAt = s.Start
+		}
+	}
+	return task
+}
+
+func sameTask(a *Task, b *Task) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.ID == b.ID
+}
+
+func valueOr(value *string, defaultValue string) string {
+	if value == nil {
+		return defaultValue
+	}
+	return *value
+}
@@ -0,0 +1,158 @@
+package timeline
+
+import (
+	"testing"
+	"time"
+
+	datamodel "github.com/ano333333/llm-time-manager/server/internal/data-model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild(t *testing.T) {
+	base := time.Date(2025, 11, 17, 0, 0, 0, 0, time.UTC)
+	ptr := func(s string) *string { return &s }
+	ptrTime := func(t time.Time) *time.Time { return &t }
+	at := func(min int) time.Time { return base.Add(time.Duration(min) * time.Minute) }
+	from, to := base, base.Add(24*time.Hour)
+	now := to.Add(time.Hour)
+	analysis := func(captureID string, category string, summary string) datamodel.CaptureAnalysisJob {
+		return datamodel.CaptureAnalysisJob{CaptureID: captureID, Category: ptr(category), Summary: ptr(summary)}
+	}
+
+	t.Run("カテゴリとタスクが同じ連続したキャプチャを1つのブロックにまとめる", func(t *testing.T) {
+		// Arrange
+		captures := []datamodel.Capture{
+			{ID: "c0", CapturedAt: at(0)},
+			{ID: "c1", CapturedAt: at(5)},
+			{ID: "c2", CapturedAt: at(10)},
+			{ID: "c3", CapturedAt: at(15)},
+		}
+		analyses := []datamodel.CaptureAnalysisJob{
+			analysis("c0", "work", "コードを書いている"),
+			analysis("c1", "work", "テストを書いている"),
+			analysis("c2", "communication", "チャットをしている"),
+		}
+		sessions := []datamodel.TaskSession{
+			{ID: "s0", TaskID: "t0", TaskTitle: "実装", StartedAt: at(0), EndedAt: ptrTime(at(12))},
+		}
+
+		// Act
+		timeline := Build(from, to, now, captures, analyses, sessions)
+
+		// Assert
+		task := &Task{ID: "t0", Title: "実装"}
+		assert.Equal(t, []Block{
+			{Start: at(0), End: at(10), Category: "work", Summary: "コードを書いている", Task: task, CaptureIDs: []string{"c0", "c1"}},
+			{Start: at(10), End: at(15), Category: "communication", Summary: "チャットをしている", Task: task, CaptureIDs: []string{"c2"}},
+			{Start: at(15), End: at(30), Category: CategoryUnknown, Summary: "", Task: nil, CaptureIDs: []string{"c3"}},
+		}, timeline.Blocks)
+		assert.Equal(t, []Session{
+			{ID: "s0", TaskID: "t0", TaskTitle: "実装", Start: at(0), End: at(12)},
+		}, timeline.Sessions)
+		assert.Equal(t, map[string]time.Duration{
+			"work":          10 * time.Minute,
+			"communication": 5 * time.Minute,
+			CategoryUnknown: 15 * time.Minute,
+		}, timeline.CategoryTotals)
+	})
+
+	t.Run("キャプチャの間隔がMaxCaptureGapより空いた場合はブロックを分ける", func(t *testing.T) {
+		// Arrange
+		captures := []datamodel.Capture{
+			{ID: "c0", CapturedAt: at(0)},
+			{ID: "c1", CapturedAt: at(60)},
+		}
+		analyses := []datamodel.CaptureAnalysisJob{
+			analysis("c0", "work", "a"),
+			analysis("c1", "work", "b"),
+		}
+
+		// Act
+		timeline := Build(from, to, now, captures, analyses, nil)
+
+		// Assert
+		assert.Equal(t, []Block{
+			{Start: at(0), End: at(15), Category: "work", Summary: "a", CaptureIDs: []string{"c0"}},
+			{Start: at(60), End: at(75), Category: "work", Summary: "b", CaptureIDs: []string{"c1"}},
+		}, timeline.Blocks)
+	})
+
+	t.Run("重複は重複元の解析結果を引き継ぎ、画面が変化しなかった期間はidleにする", func(t *testing.T) {
+		// Arrange
+		captures := []datamodel.Capture{
+			{ID: "c0", CapturedAt: at(0)},
+			{ID: "c1", CapturedAt: at(5)},
+			{ID: "c2", CapturedAt: at(10), DuplicateOf: ptr("c1")},
+			{ID: "c3", CapturedAt: at(15)},
+			{ID: "c4", CapturedAt: at(20), DuplicateOf: ptr("c3")},
+			{ID: "c5", CapturedAt: at(25), DuplicateOf: ptr("c4")},
+		}
+		analyses := []datamodel.CaptureAnalysisJob{
+			analysis("c0", "learning", "ドキュメントを読んでいる"),
+			analysis("c1", "learning", "動画を見ている"),
+			analysis("c3", "work", "エディタを開いている"),
+		}
+
+		// Act
+		timeline := Build(from, to, now, captures, analyses, nil)
+
+		// Assert
+		assert.Equal(t, []Block{
+			{Start: at(0), End: at(15), Category: "learning", Summary: "ドキュメントを読んでいる", CaptureIDs: []string{"c0", "c1", "c2"}},
+			{Start: at(15), End: at(40), Category: CategoryIdle, Summary: "エディタを開いている", CaptureIDs: []string{"c3", "c4", "c5"}},
+		}, timeline.Blocks)
+	})
+
+	t.Run("ブロックとセッションを対象期間と現在時刻で切り詰める", func(t *testing.T) {
+		// Arrange
+		current := at(20)
+		captures := []datamodel.Capture{
+			{ID: "c0", CapturedAt: at(10)},
+		}
+		sessions := []datamodel.TaskSession{
+			{ID: "s0", TaskID: "t0", TaskTitle: "前日から", StartedAt: at(-30), EndedAt: ptrTime(at(5))},
+			{ID: "s1", TaskID: "t1", TaskTitle: "作業中", StartedAt: at(8)},
+			{ID: "s2", TaskID: "t0", TaskTitle: "前日から", StartedAt: at(-60), EndedAt: ptrTime(at(-10))},
+		}
+
+		// Act
+		timeline := Build(from, to, current, captures, nil, sessions)
+
+		// Assert
+		assert.Equal(t, []Block{
+			{Start: at(10), End: at(20), Category: CategoryUnknown, Task: &Task{ID: "t1", Title: "作業中"}, CaptureIDs: []string{"c0"}},
+		}, timeline.Blocks)
+		assert.Equal(t, []Session{
+			{ID: "s0", TaskID: "t0", TaskTitle: "前日から", Start: at(0), End: at(5)},
+			{ID: "s1", TaskID: "t1", TaskTitle: "作業中", Start: at(8), End: at(20), Ongoing: true},
+		}, timeline.Sessions)
+	})
+
+	t.Run("重なるセッションがある場合は最も新しく開始したタスクに紐付ける", func(t *testing.T) {
+		// Arrange
+		captures := []datamodel.Capture{
+			{ID: "c0", CapturedAt: at(10)},
+			{ID: "c1", CapturedAt: at(15)},
+		}
+		sessions := []datamodel.TaskSession{
+			{ID: "s0", TaskID: "t0", TaskTitle: "A", StartedAt: at(0)},
+			{ID: "s1", TaskID: "t1", TaskTitle: "B", StartedAt: at(12)},
+		}
+
+		// Act
+		timeline := Build(from, to, now, captures, nil, sessions)
+
+		// Assert
+		assert.Equal(t, []Block{
+			{Start: at(10), End: at(15), Category: CategoryUnknown, Task: &Task{ID: "t0", Title: "A"}, CaptureIDs: []string{"c0"}},
+			{Start: at(15), End: at(30), Category: CategoryUnknown, Task: &Task{ID: "t1", Title: "B"}, CaptureIDs: []string{"c1"}},
+		}, timeline.Blocks)
+	})
+
+	t.Run("キャプチャがない場合は空を返す", func(t *testing.T) {
+		timeline := Build(from, to, now, nil, nil, nil)
+		assert.Empty(t, timeline.Blocks)
+		assert.Empty(t, timeline.Sessions)
+		assert.Empty(t, timeline.CategoryTotals)
+	})
+}
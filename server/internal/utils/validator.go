@@ -16,8 +16,8 @@ func isInteger(fl validator.FieldLevel) bool {
 		return false
 	}
 	fieldFloat := fl.Field().Float()
-	// 10進で文字列変換し、%d+にマッチするかどうかをチェック
-	fieldString := strconv.FormatFloat(fieldFloat, 'g', -1, 64)
+	// 10進で文字列変換し、%d+にマッチするかどうかをチェック（'g'では1e6以上が指数表記になるため'f'を使う）
+	fieldString := strconv.FormatFloat(fieldFloat, 'f', -1, 64)
 	matched, err := regexp.MatchString("^\\d+$", fieldString)
 	if err != nil {
 		return false
@@ -91,19 +91,47 @@ func GetValidator() *validator.Validate {
 }
 
 func GetFirstValidationErrorTarget(err error) string {
+	targets := GetValidationErrorTargets(err)
+	if len(targets) == 0 {
+		return ""
+	}
+	return targets[0].Field
+}
+
+// バリデーションエラーの対象フィールドと、失敗したルールのタグ
+type ValidationErrorTarget struct {
+	Field string
+	Tag   string
+}
+
+// バリデーションエラーの対象フィールドをフィールドの順に返す。同じフィールドのエラーは最初のもののみ返す
+func GetValidationErrorTargets(err error) []ValidationErrorTarget {
 	validationErrors, convErr := err.(validator.ValidationErrors)
 	if !convErr {
 		log.Printf("failed to convert error to validator.ValidationErrors: %v", err)
-		return ""
+		return nil
 	}
 
+	var targets []ValidationErrorTarget
+	seen := map[string]bool{}
 	for _, e := range validationErrors {
+		// ネストしたフィールドや配列の要素はトップレベルのフィールド名を返す
+		// 例: "RequestBody.TimeWindows[0].Start" -> "TimeWindows"
 		fieldName := e.Field()
+		if _, rest, found := strings.Cut(e.StructNamespace(), "."); found {
+			fieldName, _, _ = strings.Cut(rest, ".")
+			fieldName, _, _ = strings.Cut(fieldName, "[")
+		}
 		// フィールド名を小文字のスネークケースに変換
 		// 例: "Active" -> "active", "IntervalMin" -> "interval_min"
-		return toSnakeCase(fieldName)
+		field := toSnakeCase(fieldName)
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+		targets = append(targets, ValidationErrorTarget{Field: field, Tag: e.Tag()})
 	}
-	return ""
+	return targets
 }
 
 func toSnakeCase(s string) string {
@@ -0,0 +1,41 @@
+-- +goose Up
+-- capturesテーブルの作成
+CREATE TABLE IF NOT EXISTS captures (
+    id TEXT PRIMARY KEY,
+    path TEXT NOT NULL,
+    format TEXT NOT NULL CHECK (format IN ('png', 'jpeg')),
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size_bytes INTEGER NOT NULL,
+    sha256 TEXT NOT NULL,
+    mode TEXT NOT NULL DEFAULT 'manual' CHECK (mode IN ('manual', 'scheduled')),
+    captured_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- インデックスの作成
+CREATE INDEX IF NOT EXISTS idx_captures_captured_at ON captures(captured_at);
+CREATE INDEX IF NOT EXISTS idx_captures_mode ON captures(mode);
+
+-- updated_atの自動更新トリガー
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS update_captures_updated_at
+    AFTER UPDATE ON captures
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE captures SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- トリガーの削除
+DROP TRIGGER IF EXISTS update_captures_updated_at;
+
+-- インデックスの削除
+DROP INDEX IF EXISTS idx_captures_mode;
+DROP INDEX IF EXISTS idx_captures_captured_at;
+
+-- テーブルの削除
+DROP TABLE IF EXISTS captures;
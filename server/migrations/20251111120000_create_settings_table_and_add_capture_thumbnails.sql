@@ -0,0 +1,36 @@
+-- +goose Up
+-- settingsテーブルの作成（常に id = 1 の1行のみ）
+CREATE TABLE IF NOT EXISTS settings (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    thumbnail_resolution INTEGER NOT NULL DEFAULT 320 CHECK (thumbnail_resolution > 0),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO settings (id) VALUES (1) ON CONFLICT (id) DO NOTHING;
+
+-- updated_atの自動更新トリガー
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS update_settings_updated_at
+    AFTER UPDATE ON settings
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE settings SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+-- +goose StatementEnd
+
+-- capturesにサムネイルのカラムを追加
+-- thumb_resolution はサムネイル生成時の settings.thumbnail_resolution
+ALTER TABLE captures ADD COLUMN thumb_path TEXT;
+ALTER TABLE captures ADD COLUMN thumb_resolution INTEGER;
+
+-- +goose Down
+ALTER TABLE captures DROP COLUMN thumb_resolution;
+ALTER TABLE captures DROP COLUMN thumb_path;
+
+-- トリガーの削除
+DROP TRIGGER IF EXISTS update_settings_updated_at;
+
+-- テーブルの削除
+DROP TABLE IF EXISTS settings;
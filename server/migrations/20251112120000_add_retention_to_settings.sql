@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- キャプチャの保持ポリシー（NULLは無制限）
+-- デフォルト値は capture_schedules から削除した retention_max_items/retention_max_days と同じ
+ALTER TABLE settings ADD COLUMN retention_max_items INTEGER DEFAULT 100 CHECK (retention_max_items IS NULL OR retention_max_items > 0);
+ALTER TABLE settings ADD COLUMN retention_max_days INTEGER DEFAULT 30 CHECK (retention_max_days IS NULL OR retention_max_days > 0);
+ALTER TABLE settings ADD COLUMN retention_max_bytes INTEGER CHECK (retention_max_bytes IS NULL OR retention_max_bytes > 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE settings DROP COLUMN retention_max_bytes;
+ALTER TABLE settings DROP COLUMN retention_max_days;
+ALTER TABLE settings DROP COLUMN retention_max_items;
+-- +goose StatementEnd
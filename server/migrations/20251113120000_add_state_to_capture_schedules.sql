@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- active(0/1) を状態(inactive/active/paused)に置き換え、連続失敗回数を記録する
+ALTER TABLE capture_schedules ADD COLUMN state TEXT NOT NULL DEFAULT 'inactive' CHECK (state IN ('inactive', 'active', 'paused'));
+ALTER TABLE capture_schedules ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0 CHECK (consecutive_failures >= 0);
+ALTER TABLE capture_schedules ADD COLUMN last_failure_at DATETIME;
+ALTER TABLE capture_schedules ADD COLUMN last_failure_reason TEXT;
+UPDATE capture_schedules SET state = CASE WHEN active = 1 THEN 'active' ELSE 'inactive' END;
+ALTER TABLE capture_schedules DROP COLUMN active;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE capture_schedules ADD COLUMN active INTEGER NOT NULL DEFAULT 0;
+UPDATE capture_schedules SET active = CASE WHEN state = 'active' THEN 1 ELSE 0 END;
+ALTER TABLE capture_schedules DROP COLUMN last_failure_reason;
+ALTER TABLE capture_schedules DROP COLUMN last_failure_at;
+ALTER TABLE capture_schedules DROP COLUMN consecutive_failures;
+ALTER TABLE capture_schedules DROP COLUMN state;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- キャプチャスケジュールの変更履歴
+CREATE TABLE IF NOT EXISTS capture_schedule_history (
+    id TEXT PRIMARY KEY,
+    schedule_id TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('create', 'update', 'start', 'stop', 'resume', 'pause')),
+    changed_by TEXT NOT NULL CHECK (changed_by IN ('user', 'system')),
+    old_state TEXT,
+    old_interval_min INTEGER,
+    new_state TEXT NOT NULL,
+    new_interval_min INTEGER NOT NULL,
+    changed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_capture_schedule_history_changed_at ON capture_schedule_history(changed_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_capture_schedule_history_changed_at;
+DROP TABLE IF EXISTS capture_schedule_history;
+-- +goose StatementEnd
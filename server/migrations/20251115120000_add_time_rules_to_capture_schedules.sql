@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- キャプチャを許可する時間帯・除外日(JSON配列)と、間隔のジッター(%)を追加する
+ALTER TABLE capture_schedules ADD COLUMN time_windows TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE capture_schedules ADD COLUMN excluded_dates TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE capture_schedules ADD COLUMN jitter_pct INTEGER NOT NULL DEFAULT 0 CHECK (jitter_pct >= 0 AND jitter_pct <= 50);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE capture_schedules DROP COLUMN jitter_pct;
+ALTER TABLE capture_schedules DROP COLUMN excluded_dates;
+ALTER TABLE capture_schedules DROP COLUMN time_windows;
+-- +goose StatementEnd
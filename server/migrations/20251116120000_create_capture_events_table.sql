@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- キャプチャ要求の状態遷移の記録
+CREATE TABLE IF NOT EXISTS capture_events (
+    id TEXT PRIMARY KEY,
+    request_id TEXT NOT NULL,
+    state TEXT NOT NULL CHECK (state IN ('requested', 'granted', 'denied', 'completed', 'failed')),
+    mode TEXT NOT NULL CHECK (mode IN ('manual', 'scheduled')),
+    schedule_id TEXT,
+    capture_id TEXT,
+    error_code TEXT,
+    error_message TEXT,
+    occurred_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_capture_events_request_id ON capture_events(request_id);
+CREATE INDEX IF NOT EXISTS idx_capture_events_occurred_at ON capture_events(occurred_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_capture_events_occurred_at;
+DROP INDEX IF EXISTS idx_capture_events_request_id;
+DROP TABLE IF EXISTS capture_events;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 知覚ハッシュ(dHash)と、直前のキャプチャとの重複の記録
+ALTER TABLE captures ADD COLUMN phash TEXT;
+ALTER TABLE captures ADD COLUMN duplicate_of TEXT;
+ALTER TABLE captures ADD COLUMN duplicate_count INTEGER NOT NULL DEFAULT 0 CHECK (duplicate_count >= 0);
+ALTER TABLE captures ADD COLUMN last_duplicate_at DATETIME;
+-- 重複したキャプチャの扱い（off: 判定しない / mark: 保存して重複として記録 / drop: 保存しない）と判定のしきい値（ハミング距離）
+ALTER TABLE settings ADD COLUMN dedup_mode TEXT NOT NULL DEFAULT 'mark' CHECK (dedup_mode IN ('off', 'mark', 'drop'));
+ALTER TABLE settings ADD COLUMN dedup_threshold INTEGER NOT NULL DEFAULT 4 CHECK (dedup_threshold >= 0 AND dedup_threshold <= 64);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE settings DROP COLUMN dedup_threshold;
+ALTER TABLE settings DROP COLUMN dedup_mode;
+ALTER TABLE captures DROP COLUMN last_duplicate_at;
+ALTER TABLE captures DROP COLUMN duplicate_count;
+ALTER TABLE captures DROP COLUMN duplicate_of;
+ALTER TABLE captures DROP COLUMN phash;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- キャプチャの解析ジョブと、その結果（要約・提案）
+CREATE TABLE IF NOT EXISTS capture_analysis_jobs (
+    id TEXT PRIMARY KEY,
+    capture_id TEXT NOT NULL REFERENCES captures(id) ON DELETE CASCADE,
+    status TEXT NOT NULL CHECK (status IN ('queued', 'running', 'succeeded', 'failed')),
+    prompt_version INTEGER NOT NULL CHECK (prompt_version >= 1),
+    attempts INTEGER NOT NULL DEFAULT 0 CHECK (attempts >= 0),
+    max_attempts INTEGER NOT NULL CHECK (max_attempts >= 1),
+    next_attempt_at DATETIME NOT NULL,
+    summary TEXT,
+    suggestions TEXT,
+    error_message TEXT,
+    started_at DATETIME,
+    finished_at DATETIME,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_capture_analysis_jobs_capture_id ON capture_analysis_jobs(capture_id);
+CREATE INDEX IF NOT EXISTS idx_capture_analysis_jobs_status_next_attempt_at ON capture_analysis_jobs(status, next_attempt_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_capture_analysis_jobs_status_next_attempt_at;
+DROP INDEX IF EXISTS idx_capture_analysis_jobs_capture_id;
+DROP TABLE IF EXISTS capture_analysis_jobs;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 解析結果の活動カテゴリ（succeeded のみ）
+ALTER TABLE capture_analysis_jobs ADD COLUMN category TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE capture_analysis_jobs DROP COLUMN category;
+-- +goose StatementEnd
@@ -0,0 +1,67 @@
+-- +goose Up
+-- タスクが doing だった期間。tasks.status の変更からトリガーで記録する。
+-- 日時は Go から保存する DATETIME と同じ形式（UTC）にする。Go は秒未満が 0 の場合に小数部を書かないため、文字列比較の順序を揃えるよう秒単位で記録する。
+CREATE TABLE IF NOT EXISTS task_sessions (
+    id TEXT PRIMARY KEY,
+    task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    started_at DATETIME NOT NULL,
+    ended_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_task_sessions_task_id ON task_sessions(task_id);
+CREATE INDEX IF NOT EXISTS idx_task_sessions_started_at ON task_sessions(started_at);
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS start_task_session_on_insert
+    AFTER INSERT ON tasks
+    FOR EACH ROW
+    WHEN NEW.status = 'doing'
+BEGIN
+    INSERT INTO task_sessions (id, task_id, started_at)
+    VALUES (lower(hex(randomblob(16))), NEW.id, strftime('%Y-%m-%d %H:%M:%S+00:00', 'now'));
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS start_task_session_on_update
+    AFTER UPDATE OF status ON tasks
+    FOR EACH ROW
+    WHEN NEW.status = 'doing' AND OLD.status <> 'doing'
+BEGIN
+    INSERT INTO task_sessions (id, task_id, started_at)
+    VALUES (lower(hex(randomblob(16))), NEW.id, strftime('%Y-%m-%d %H:%M:%S+00:00', 'now'));
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS end_task_session_on_update
+    AFTER UPDATE OF status ON tasks
+    FOR EACH ROW
+    WHEN OLD.status = 'doing' AND NEW.status <> 'doing'
+BEGIN
+    UPDATE task_sessions SET ended_at = strftime('%Y-%m-%d %H:%M:%S+00:00', 'now')
+    WHERE task_id = OLD.id AND ended_at IS NULL;
+END;
+-- +goose StatementEnd
+
+-- 外部キー制約が無効な接続で削除された場合もセッションを残さない
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS delete_task_sessions_on_delete
+    AFTER DELETE ON tasks
+    FOR EACH ROW
+BEGIN
+    DELETE FROM task_sessions WHERE task_id = OLD.id;
+END;
+-- +goose StatementEnd
+
+-- 既に doing のタスクは、マイグレーション時点から記録を始める
+INSERT INTO task_sessions (id, task_id, started_at)
+SELECT lower(hex(randomblob(16))), id, strftime('%Y-%m-%d %H:%M:%S+00:00', 'now') FROM tasks WHERE status = 'doing';
+
+-- +goose Down
+DROP TRIGGER IF EXISTS delete_task_sessions_on_delete;
+DROP TRIGGER IF EXISTS end_task_session_on_update;
+DROP TRIGGER IF EXISTS start_task_session_on_update;
+DROP TRIGGER IF EXISTS start_task_session_on_insert;
+DROP INDEX IF EXISTS idx_task_sessions_started_at;
+DROP INDEX IF EXISTS idx_task_sessions_task_id;
+DROP TABLE IF EXISTS task_sessions;
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+-- キャプチャに紐付けたタスクと目標（撮影時に doing だったタスクを自動で紐付ける）
+ALTER TABLE captures ADD COLUMN linked_task_id TEXT;
+ALTER TABLE captures ADD COLUMN linked_goal_id TEXT;
+-- +goose StatementEnd
+CREATE INDEX IF NOT EXISTS idx_captures_linked_task_id ON captures(linked_task_id);
+CREATE INDEX IF NOT EXISTS idx_captures_linked_goal_id ON captures(linked_goal_id);
+
+-- タスク・目標の削除時に紐付けを外す
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS unlink_captures_on_task_delete
+    AFTER DELETE ON tasks
+    FOR EACH ROW
+BEGIN
+    UPDATE captures SET linked_task_id = NULL WHERE linked_task_id = OLD.id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS unlink_captures_on_goal_delete
+    AFTER DELETE ON goals
+    FOR EACH ROW
+BEGIN
+    UPDATE captures SET linked_goal_id = NULL WHERE linked_goal_id = OLD.id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER IF EXISTS unlink_captures_on_goal_delete;
+DROP TRIGGER IF EXISTS unlink_captures_on_task_delete;
+DROP INDEX IF EXISTS idx_captures_linked_goal_id;
+DROP INDEX IF EXISTS idx_captures_linked_task_id;
+-- +goose StatementBegin
+ALTER TABLE captures DROP COLUMN linked_goal_id;
+ALTER TABLE captures DROP COLUMN linked_task_id;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 保存・解析の前にぼかす矩形（JSON 配列）
+ALTER TABLE settings ADD COLUMN mask_rules TEXT NOT NULL DEFAULT '[]';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE settings DROP COLUMN mask_rules;
+-- +goose StatementEnd
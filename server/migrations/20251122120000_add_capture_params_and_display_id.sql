@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 定期キャプチャで撮影する範囲（全画面・ウィンドウ・領域）と、領域(JSON)、除外するウィンドウID(JSON配列)
+ALTER TABLE capture_schedules ADD COLUMN capture_mode TEXT NOT NULL DEFAULT 'full' CHECK (capture_mode IN ('full', 'window', 'region'));
+ALTER TABLE capture_schedules ADD COLUMN region TEXT;
+ALTER TABLE capture_schedules ADD COLUMN exclude_windows TEXT NOT NULL DEFAULT '[]';
+-- キャプチャを撮影したディスプレイ（クライアントが報告しなかった場合 NULL）
+ALTER TABLE captures ADD COLUMN display_id TEXT;
+-- +goose StatementEnd
+CREATE INDEX IF NOT EXISTS idx_captures_display_id ON captures(display_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_captures_display_id;
+-- +goose StatementBegin
+ALTER TABLE captures DROP COLUMN display_id;
+ALTER TABLE capture_schedules DROP COLUMN exclude_windows;
+ALTER TABLE capture_schedules DROP COLUMN region;
+ALTER TABLE capture_schedules DROP COLUMN capture_mode;
+-- +goose StatementEnd
@@ -0,0 +1,63 @@
+-- +goose Up
+-- +goose StatementBegin
+-- チャットの会話。chat_messagesを会話ごとにまとめる
+CREATE TABLE IF NOT EXISTS conversations (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_conversations_updated_at ON conversations(updated_at);
+
+-- 既存のメッセージは1つの会話にまとめる
+INSERT INTO conversations (id, title, created_at, updated_at)
+SELECT lower(hex(randomblob(16))), '以前の会話', MIN(created_at), MAX(created_at) FROM chat_messages HAVING COUNT(*) > 0;
+
+-- conversation_idを追加するため作り直す。更新日時はGoから保存するためトリガーは作らない
+CREATE TABLE chat_messages_new (
+    id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('user', 'assistant', 'system')),
+    content TEXT NOT NULL,
+    -- アシスタントの応答の終わり方。それ以外のメッセージはNULL
+    finish_reason TEXT CHECK (finish_reason IN ('completed', 'aborted', 'error')),
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+INSERT INTO chat_messages_new (id, conversation_id, role, content, finish_reason, created_at, updated_at)
+SELECT id, (SELECT id FROM conversations LIMIT 1), role, content, CASE WHEN role = 'assistant' THEN 'completed' END, created_at, updated_at
+FROM chat_messages;
+DROP TRIGGER IF EXISTS update_chat_messages_updated_at;
+DROP INDEX IF EXISTS idx_chat_messages_role;
+DROP INDEX IF EXISTS idx_chat_messages_created_at;
+DROP TABLE chat_messages;
+ALTER TABLE chat_messages_new RENAME TO chat_messages;
+CREATE INDEX IF NOT EXISTS idx_chat_messages_conversation_id_created_at ON chat_messages(conversation_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE chat_messages_old (
+    id TEXT PRIMARY KEY,
+    role TEXT NOT NULL CHECK (role IN ('user', 'assistant', 'system')),
+    content TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO chat_messages_old (id, role, content, created_at, updated_at)
+SELECT id, role, content, created_at, updated_at FROM chat_messages;
+DROP INDEX IF EXISTS idx_chat_messages_conversation_id_created_at;
+DROP TABLE chat_messages;
+ALTER TABLE chat_messages_old RENAME TO chat_messages;
+CREATE INDEX IF NOT EXISTS idx_chat_messages_created_at ON chat_messages(created_at);
+CREATE INDEX IF NOT EXISTS idx_chat_messages_role ON chat_messages(role);
+CREATE TRIGGER IF NOT EXISTS update_chat_messages_updated_at
+    AFTER UPDATE ON chat_messages
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE chat_messages SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+DROP INDEX IF EXISTS idx_conversations_updated_at;
+DROP TABLE IF EXISTS conversations;
+-- +goose StatementEnd
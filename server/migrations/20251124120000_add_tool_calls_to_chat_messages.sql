@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+-- ツールの呼び出しと結果を記録するため、role・finish_reasonのCHECK制約を変えて作り直す
+CREATE TABLE chat_messages_new (
+    id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('user', 'assistant', 'system', 'tool')),
+    content TEXT NOT NULL,
+    -- アシスタントの応答の終わり方。それ以外のメッセージはNULL
+    finish_reason TEXT CHECK (finish_reason IN ('completed', 'aborted', 'error', 'tool_calls')),
+    -- アシスタントが呼び出したツール（JSON配列）。呼び出しがない場合はNULL
+    tool_calls TEXT,
+    -- 結果を返した呼び出しのID（role = 'tool' の場合）
+    tool_call_id TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+INSERT INTO chat_messages_new (id, conversation_id, role, content, finish_reason, created_at, updated_at)
+SELECT id, conversation_id, role, content, finish_reason, created_at, updated_at FROM chat_messages;
+DROP INDEX IF EXISTS idx_chat_messages_conversation_id_created_at;
+DROP TABLE chat_messages;
+ALTER TABLE chat_messages_new RENAME TO chat_messages;
+CREATE INDEX IF NOT EXISTS idx_chat_messages_conversation_id_created_at ON chat_messages(conversation_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE chat_messages_old (
+    id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('user', 'assistant', 'system')),
+    content TEXT NOT NULL,
+    finish_reason TEXT CHECK (finish_reason IN ('completed', 'aborted', 'error')),
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+-- ツールの結果は戻せないため捨てる
+INSERT INTO chat_messages_old (id, conversation_id, role, content, finish_reason, created_at, updated_at)
+SELECT id, conversation_id, role, content, CASE WHEN finish_reason = 'tool_calls' THEN 'completed' ELSE finish_reason END, created_at, updated_at
+FROM chat_messages WHERE role <> 'tool';
+DROP INDEX IF EXISTS idx_chat_messages_conversation_id_created_at;
+DROP TABLE chat_messages;
+ALTER TABLE chat_messages_old RENAME TO chat_messages;
+CREATE INDEX IF NOT EXISTS idx_chat_messages_conversation_id_created_at ON chat_messages(conversation_id, created_at);
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 目標のKPIの実績。目標ごとの合計を進捗とする
+CREATE TABLE IF NOT EXISTS kpi_entries (
+    id TEXT PRIMARY KEY,
+    goal_id TEXT NOT NULL REFERENCES goals(id) ON DELETE CASCADE,
+    value REAL NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    -- 実績の日付（JST, "YYYY-MM-DD"）
+    recorded_on DATE NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_kpi_entries_goal_id_recorded_on ON kpi_entries(goal_id, recorded_on);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_kpi_entries_goal_id_recorded_on;
+DROP TABLE IF EXISTS kpi_entries;
+-- +goose StatementEnd
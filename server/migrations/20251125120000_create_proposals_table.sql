@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- LLMが提案したタスク・目標の変更。承認されるまで適用しない
+CREATE TABLE IF NOT EXISTS proposals (
+    id TEXT PRIMARY KEY,
+    -- 提案した会話。会話の削除後はNULL
+    conversation_id TEXT REFERENCES conversations(id) ON DELETE SET NULL,
+    -- 適用する変更（JSON配列）
+    changeset TEXT NOT NULL,
+    -- 提案した時点の状態との差分（JSON配列）
+    diff TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'rejected', 'expired')),
+    expires_at DATETIME NOT NULL,
+    -- 承認・却下・期限切れになった日時。pendingの間はNULL
+    resolved_at DATETIME,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_proposals_status_created_at ON proposals(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_proposals_conversation_id ON proposals(conversation_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_proposals_conversation_id;
+DROP INDEX IF EXISTS idx_proposals_status_created_at;
+DROP TABLE IF EXISTS proposals;
+-- +goose StatementEnd
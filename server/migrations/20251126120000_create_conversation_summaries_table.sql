@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 会話の古いメッセージをLLMで要約したもの。メッセージ自体はchat_messagesに残す
+CREATE TABLE IF NOT EXISTS conversation_summaries (
+    id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    -- 会話の最初からlast_message_idまでのメッセージの要約（以前の要約を含む）
+    content TEXT NOT NULL,
+    -- 要約した最後のメッセージ
+    last_message_id TEXT NOT NULL,
+    -- 要約したメッセージの数
+    message_count INTEGER NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_conversation_summaries_conversation_id_created_at ON conversation_summaries(conversation_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_conversation_summaries_conversation_id_created_at;
+DROP TABLE IF EXISTS conversation_summaries;
+-- +goose StatementEnd
@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+-- LLMに送るプロンプトのテンプレート（Goのtext/template）。編集するたびに新しいバージョンを追加し、古いバージョンも残す
+CREATE TABLE IF NOT EXISTS prompt_templates (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL CHECK (name IN ('chat', 'extraction', 'screenshot_analysis', 'report')),
+    -- 名前ごとに1から連番
+    version INTEGER NOT NULL CHECK (version > 0),
+    body TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (name, version)
+);
+
+-- 初期のテンプレート（バージョン1）
+INSERT INTO prompt_templates (id, name, version, body) VALUES
+('prompt-template-chat-1', 'chat', 1, 'あなたはユーザーの目標とタスクの管理を手伝うアシスタントです。
+現在日時: {{.Now.Format "2006-01-02 15:04"}}（{{.Weekday}}）
+{{.Extraction}}以下はユーザーの現在の状況です。[ ] 内はタスク・目標のIDです。
+'),
+('prompt-template-extraction-1', 'extraction', 1, 'タスクや目標の作成・変更を提案する場合は、{"type": "task", ...} または {"type": "goal", ...} のJSONオブジェクトを応答に含めてください。既存のタスク・目標を変更する場合は "id" を含めてください。
+'),
+('prompt-template-screenshot_analysis-1', 'screenshot_analysis', 1, 'あなたはユーザーのPCの画面キャプチャから活動を分類するアシスタントです。
+撮影日時: {{.CapturedAt.Format "2006-01-02 15:04"}}（{{.Width}}x{{.Height}}）
+{{if .DoingTask}}撮影時に作業中だったタスク: {{.DoingTask}}
+{{end}}画面の活動を次のカテゴリのいずれかに分類してください。
+{{range .Categories}}- {{.}}
+{{end}}'),
+('prompt-template-report-1', 'report', 1, 'あなたはユーザーの活動を振り返るアシスタントです。
+{{.From.Format "2006-01-02"}}〜{{.To.Format "2006-01-02"}} の活動を、良かった点と改善点を含めて簡潔にまとめてください。
+## カテゴリごとの時間
+{{range $category, $minutes := .CategoryMinutes}}- {{$category}}: {{$minutes}}分
+{{else}}- なし
+{{end}}## 完了したタスク
+{{range .CompletedTasks}}- {{.Title}}
+{{else}}- なし
+{{end}}')
+ON CONFLICT (name, version) DO NOTHING;
+
+-- アシスタントの応答を生成したテンプレートのバージョン（テンプレートの名前→バージョンのJSONオブジェクト）
+ALTER TABLE chat_messages ADD COLUMN prompt_versions TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chat_messages DROP COLUMN prompt_versions;
+DROP TABLE IF EXISTS prompt_templates;
+-- +goose StatementEnd
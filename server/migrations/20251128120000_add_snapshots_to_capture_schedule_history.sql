@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 変更前後のスケジュールの設定（JSON）。old_snapshotは作成時にNULL。この列を追加する前の履歴はどちらもNULL
+ALTER TABLE capture_schedule_history ADD COLUMN old_snapshot TEXT;
+ALTER TABLE capture_schedule_history ADD COLUMN new_snapshot TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE capture_schedule_history DROP COLUMN new_snapshot;
+ALTER TABLE capture_schedule_history DROP COLUMN old_snapshot;
+-- +goose StatementEnd
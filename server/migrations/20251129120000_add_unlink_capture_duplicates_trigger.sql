@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 削除されたキャプチャを指すduplicate_ofを残さない
+UPDATE captures SET duplicate_of = NULL WHERE duplicate_of IS NOT NULL AND duplicate_of NOT IN (SELECT id FROM captures);
+CREATE INDEX IF NOT EXISTS idx_captures_duplicate_of ON captures(duplicate_of);
+-- +goose StatementEnd
+
+-- 重複元のキャプチャが削除されたら、重複の記録を外す
+-- +goose StatementBegin
+CREATE TRIGGER IF NOT EXISTS unlink_capture_duplicates
+    AFTER DELETE ON captures
+    FOR EACH ROW
+BEGIN
+    UPDATE captures SET duplicate_of = NULL WHERE duplicate_of = OLD.id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS unlink_capture_duplicates;
+DROP INDEX IF EXISTS idx_captures_duplicate_of;
+-- +goose StatementEnd